@@ -48,6 +48,39 @@ type ExporterConfigTemplateSpec struct {
 	// +kubebuilder:validation:Optional
 	// +kubebuilder:validation:MinLength=1
 	SystemdServiceTemplate string `json:"systemdServiceTemplate"`
+
+	// Files declares the mode and ownership of the files rendered from the templates on the exporter hosts.
+	// +kubebuilder:validation:Optional
+	Files ExporterFiles `json:"files,omitempty"`
+}
+
+// ExporterFiles declares the mode and ownership of the files of an exporter.
+type ExporterFiles struct {
+	// Config declares the attributes of the exporter config file, mode 0600 by default as it holds the exporter token.
+	// +kubebuilder:validation:Optional
+	Config *FileAttributes `json:"config,omitempty"`
+
+	// Systemd declares the attributes of the systemd container or service unit, mode 0644 by default.
+	// +kubebuilder:validation:Optional
+	Systemd *FileAttributes `json:"systemd,omitempty"`
+}
+
+// FileAttributes declares the mode and ownership of a file written on the exporter hosts.
+type FileAttributes struct {
+	// Mode is the octal permission mode of the file, like "0640", the default mode of the file when empty.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Pattern=`^0?[0-7]{3}$`
+	Mode string `json:"mode,omitempty"`
+
+	// Owner is the numeric user ID owning the file, the existing owner is kept when unset.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	Owner *int `json:"owner,omitempty"`
+
+	// Group is the numeric group ID owning the file, the existing group is kept when unset.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	Group *int `json:"group,omitempty"`
 }
 
 // ExporterMeta defines metadata for the exporter.
@@ -25,7 +25,8 @@ type ExporterHostSpec struct {
 	// LocationRef references the physical location of the exporter host.
 	LocationRef LocationRef `json:"locationRef,omitempty"`
 
-	// ContainerImage is the container image to be used for the exporter.
+	// ContainerImage is the bootc image the exporter host runs, the host is switched to it
+	// when it runs another image.
 	ContainerImage string `json:"containerImage,omitempty"`
 
 	// Addresses is a list of network addresses for the exporter host.
@@ -36,10 +37,51 @@ type ExporterHostSpec struct {
 
 	// Management options for the exporter host, could be SSH access, flightctl device ids, etc..
 	Management Management `json:"management,omitempty"`
+
+	// MaintenanceWindows are the time ranges during which the bootc image of the exporter host can be
+	// updated, they replace the windows of its location. The updates are not restricted without windows.
+	MaintenanceWindows []MaintenanceWindow `json:"maintenanceWindows,omitempty"`
+}
+
+// MaintenanceWindow is a weekly time range during which the exporter hosts can be disrupted.
+type MaintenanceWindow struct {
+	// Days are the days of the week the window starts, as Mon, Tue, Wed, Thu, Fri, Sat or Sun,
+	// every day when empty.
+	Days []string `json:"days,omitempty"`
+	// Start is the time the window starts, as HH:MM.
+	// +kubebuilder:validation:Required
+	Start string `json:"start"`
+	// End is the time the window ends, as HH:MM, on the next day when it isn't after Start.
+	// +kubebuilder:validation:Required
+	End string `json:"end"`
+	// TimeZone is the IANA time zone of Start and End (e.g. Europe/Madrid), UTC by default.
+	TimeZone string `json:"timeZone,omitempty"`
 }
 
+// Management selects the backend managing the exporter host, only one of its fields can be set.
 type Management struct {
 	SSH SSHCredentials `json:"ssh,omitempty"`
+	// Flightctl renders the exporters in the spec of a flightctl device instead of deploying them over SSH.
+	Flightctl *FlightctlManagement `json:"flightctl,omitempty"`
+	// Local reconciles the exporter files into a local directory, for offline testing.
+	Local *LocalManagement `json:"local,omitempty"`
+}
+
+// FlightctlManagement defines the flightctl device of an exporter host.
+type FlightctlManagement struct {
+	// DeviceID is the name of the flightctl device.
+	// +kubebuilder:validation:Required
+	DeviceID string `json:"deviceId"`
+	// SpecFile is the path of the rendered device spec, relative to the directory of the
+	// configuration file, flightctl/<deviceId>.yaml by default.
+	SpecFile string `json:"specFile,omitempty"`
+}
+
+// LocalManagement defines the local directory standing for an exporter host.
+type LocalManagement struct {
+	// Root is the directory standing for the root of the exporter host file system.
+	// +kubebuilder:validation:Required
+	Root string `json:"root"`
 }
 
 type SSHCredentials struct {
@@ -57,6 +99,33 @@ type SSHCredentials struct {
 	Password string `json:"password,omitempty"`
 	// Port is the SSH port (default is 22).
 	Port int `json:"port,omitempty"`
+	// HostKey pins the SSH host key, either as a public key in authorized_keys format
+	// (e.g. "ssh-ed25519 AAAA...") or as a SHA256 fingerprint (e.g. "SHA256:...").
+	HostKey string `json:"hostKey,omitempty"`
+	// JumpHosts are the jump hosts (bastions) to go through to reach the host, in order, like ssh ProxyJump.
+	// The connection to a jump host is shared by all the hosts behind it during an apply.
+	JumpHosts []SSHJumpHost `json:"jumpHosts,omitempty"`
+}
+
+// SSHJumpHost defines the SSH access to a jump host.
+type SSHJumpHost struct {
+	// Host is the hostname or IP address of the jump host.
+	Host string `json:"host"`
+	// User is the SSH username.
+	User string `json:"user,omitempty"`
+	// KeyFile is the path to the SSH private key file.
+	KeyFile string `json:"keyFile,omitempty"`
+	// SSHKeyData is the SSH private key data as a string.
+	SSHKeyData string `json:"sshKeyData,omitempty"`
+	// SSHKeyPassword is the password for encrypted SSH private keys.
+	SSHKeyPassword string `json:"sshKeyPassword,omitempty"`
+	// Password is the SSH password (if not using key-based auth).
+	Password string `json:"password,omitempty"`
+	// Port is the SSH port (default is 22).
+	Port int `json:"port,omitempty"`
+	// HostKey pins the SSH host key, either as a public key in authorized_keys format
+	// or as a SHA256 fingerprint, the known_hosts file is used when it is not set.
+	HostKey string `json:"hostKey,omitempty"`
 }
 
 // LocationRef defines the physical location details.
@@ -81,8 +150,16 @@ type SNMPPower struct {
 	Host string `json:"host,omitempty"`
 	// User is the SNMP username.
 	User string `json:"user,omitempty"`
-	// Password is the SNMP password.
+	// Password is the SNMP password, the requests are not authenticated without it.
 	Password string `json:"password,omitempty"`
+	// AuthProtocol is the SNMP authentication protocol, SHA by default.
+	// +kubebuilder:validation:Enum=MD5;SHA;SHA224;SHA256;SHA384;SHA512
+	AuthProtocol string `json:"authProtocol,omitempty"`
+	// PrivProtocol is the SNMP privacy protocol encrypting the requests, they are not encrypted when empty.
+	// +kubebuilder:validation:Enum=DES;AES
+	PrivProtocol string `json:"privProtocol,omitempty"`
+	// PrivPassword is the SNMP privacy password, Password by default.
+	PrivPassword string `json:"privPassword,omitempty"`
 	// OID is the SNMP OID for controlling the power outlet.
 	OID string `json:"oid,omitempty"`
 	// Plug is the outlet/plug number on the PDU.
@@ -4,6 +4,14 @@ const (
 	DeadAnnotation       = "jumpstarter.dev/dead"
 	LegacyDeadAnnotation = "dead"
 	UnmanagedAnnotation  = "jumpstarter.dev/unmanaged"
+
+	// JumpstarterInstancesAnnotation restricts a Client or an ExporterAccessPolicy to a
+	// comma-separated list of JumpstarterInstance names, lint rejects an empty or unknown list.
+	JumpstarterInstancesAnnotation = "jumpstarter.dev/jumpstarter-instances"
+
+	// SSHHostKeyAnnotation holds the SHA256 fingerprint of an ExporterHost SSH host key
+	// recorded on first use, it is used when spec.management.ssh.hostKey is not set.
+	SSHHostKeyAnnotation = "jumpstarter.dev/ssh-host-key"
 )
 
 func (e *ExporterInstance) HasConfigTemplate() bool {
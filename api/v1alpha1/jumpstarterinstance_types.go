@@ -46,12 +46,50 @@ type JumpstarterInstanceSpec struct {
 	// +kubebuilder:validation:Optional
 	Namespace string `json:"namespace,omitempty"`
 
+	// TLS configures how the CA bundle of the controller gRPC endpoints is discovered
+	// and distributed to exporters and clients.
+	// +kubebuilder:validation:Optional
+	TLS JumpstarterInstanceTLS `json:"tls,omitempty"`
+
 	// Notes provides additional information or comments about the Jumpstarter instance.
 	// This field can be used to document the purpose, configuration, or any other relevant details.
 	// +kubebuilder:validation:Optional
 	Notes string `json:"notes,omitempty"`
 }
 
+// JumpstarterInstanceTLS defines where the CA bundle of the Jumpstarter controller is found.
+// When no reference is set, the "ca.crt" key of the "jumpstarter-service-ca-cert" ConfigMap
+// in the instance namespace is used.
+type JumpstarterInstanceTLS struct {
+	// CAConfigMapRef references a ConfigMap key containing the PEM encoded CA bundle.
+	// +kubebuilder:validation:Optional
+	CAConfigMapRef *CAReference `json:"caConfigMapRef,omitempty"`
+
+	// CASecretRef references a Secret key containing the PEM encoded CA bundle.
+	// It takes precedence over CAConfigMapRef.
+	// +kubebuilder:validation:Optional
+	CASecretRef *CAReference `json:"caSecretRef,omitempty"`
+
+	// Insecure disables TLS verification for the exporters and clients of this instance.
+	// It must be explicitly enabled, a missing CA bundle is an error otherwise.
+	// +kubebuilder:validation:Optional
+	Insecure bool `json:"insecure,omitempty"`
+}
+
+// CAReference points to a key of a ConfigMap or Secret containing a CA bundle.
+type CAReference struct {
+	// Name of the ConfigMap or Secret.
+	Name string `json:"name"`
+
+	// Namespace of the ConfigMap or Secret, defaults to the JumpstarterInstance namespace.
+	// +kubebuilder:validation:Optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Key containing the CA bundle, defaults to "ca.crt".
+	// +kubebuilder:validation:Optional
+	Key string `json:"key,omitempty"`
+}
+
 // JumpstarterInstanceStatus defines the observed state of JumpstarterInstance.
 type JumpstarterInstanceStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
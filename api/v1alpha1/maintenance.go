@@ -0,0 +1,120 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// maintenanceDays are the days of the week of MaintenanceWindow.Days
+var maintenanceDays = map[string]time.Weekday{
+	"Sun": time.Sunday,
+	"Mon": time.Monday,
+	"Tue": time.Tuesday,
+	"Wed": time.Wednesday,
+	"Thu": time.Thursday,
+	"Fri": time.Friday,
+	"Sat": time.Saturday,
+}
+
+// parseClock parses a HH:MM time of the day
+func parseClock(value string) (hour, minute int, err error) {
+	clock, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	return clock.Hour(), clock.Minute(), nil
+}
+
+// location returns the time zone of the window
+func (w *MaintenanceWindow) location() (*time.Location, error) {
+	if w.TimeZone == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(w.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone %q: %w", w.TimeZone, err)
+	}
+	return loc, nil
+}
+
+// Validate checks the days, times and time zone of the window
+func (w *MaintenanceWindow) Validate() error {
+	for _, day := range w.Days {
+		if _, ok := maintenanceDays[day]; !ok {
+			return fmt.Errorf("invalid day %q, expected Mon, Tue, Wed, Thu, Fri, Sat or Sun", day)
+		}
+	}
+	if _, _, err := parseClock(w.Start); err != nil {
+		return err
+	}
+	if _, _, err := parseClock(w.End); err != nil {
+		return err
+	}
+	_, err := w.location()
+	return err
+}
+
+// startsOn checks whether the window starts on a day of the week
+func (w *MaintenanceWindow) startsOn(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, name := range w.Days {
+		if maintenanceDays[name] == day {
+			return true
+		}
+	}
+	return false
+}
+
+// Contains checks whether t is within the window
+func (w *MaintenanceWindow) Contains(t time.Time) (bool, error) {
+	if err := w.Validate(); err != nil {
+		return false, err
+	}
+	loc, _ := w.location()
+	startHour, startMinute, _ := parseClock(w.Start)
+	endHour, endMinute, _ := parseClock(w.End)
+	t = t.In(loc)
+	// a window spanning midnight may have started the day before
+	for _, dayOffset := range []int{0, -1} {
+		year, month, day := t.AddDate(0, 0, dayOffset).Date()
+		start := time.Date(year, month, day, startHour, startMinute, 0, 0, loc)
+		end := time.Date(year, month, day, endHour, endMinute, 0, 0, loc)
+		if !end.After(start) {
+			end = end.AddDate(0, 0, 1)
+		}
+		if w.startsOn(start.Weekday()) && !t.Before(start) && t.Before(end) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// String describes the window, i.e. "Sat,Sun 02:00-06:00 Europe/Madrid"
+func (w *MaintenanceWindow) String() string {
+	days := "daily"
+	if len(w.Days) > 0 {
+		days = strings.Join(w.Days, ",")
+	}
+	timeZone := w.TimeZone
+	if timeZone == "" {
+		timeZone = "UTC"
+	}
+	return fmt.Sprintf("%s %s-%s %s", days, w.Start, w.End, timeZone)
+}
+
+// InMaintenanceWindows checks whether t is within one of windows, it always is without windows
+func InMaintenanceWindows(windows []MaintenanceWindow, t time.Time) (bool, error) {
+	if len(windows) == 0 {
+		return true, nil
+	}
+	for i := range windows {
+		in, err := windows[i].Contains(t)
+		if err != nil || in {
+			return in, err
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,74 @@
+package v1alpha1
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaintenanceWindow_Contains(t *testing.T) {
+	at := func(value string) time.Time {
+		parsed, err := time.Parse(time.RFC3339, value)
+		require.NoError(t, err)
+		return parsed
+	}
+	tests := []struct {
+		name   string
+		window MaintenanceWindow
+		time   string
+		want   bool
+	}{
+		{"daily, inside", MaintenanceWindow{Start: "02:00", End: "05:00"}, "2025-06-04T03:00:00Z", true},
+		{"daily, at the end", MaintenanceWindow{Start: "02:00", End: "05:00"}, "2025-06-04T05:00:00Z", false},
+		{"daily, before", MaintenanceWindow{Start: "02:00", End: "05:00"}, "2025-06-04T01:59:00Z", false},
+		{"over midnight, after midnight", MaintenanceWindow{Start: "22:00", End: "04:00"}, "2025-06-04T01:00:00Z", true},
+		{"over midnight, before midnight", MaintenanceWindow{Start: "22:00", End: "04:00"}, "2025-06-04T23:00:00Z", true},
+		// 2025-06-07 is a Saturday
+		{"weekend, on Saturday", MaintenanceWindow{Days: []string{"Sat", "Sun"}, Start: "08:00", End: "20:00"},
+			"2025-06-07T10:00:00Z", true},
+		{"weekend, on Friday", MaintenanceWindow{Days: []string{"Sat", "Sun"}, Start: "08:00", End: "20:00"},
+			"2025-06-06T10:00:00Z", false},
+		{"started the day before", MaintenanceWindow{Days: []string{"Sun"}, Start: "22:00", End: "06:00"},
+			"2025-06-09T05:00:00Z", true},
+		{"time zone", MaintenanceWindow{Start: "02:00", End: "05:00", TimeZone: "Europe/Madrid"},
+			"2025-06-04T01:00:00Z", true},
+		{"time zone, in UTC", MaintenanceWindow{Start: "02:00", End: "05:00", TimeZone: "Europe/Madrid"},
+			"2025-06-04T03:30:00Z", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.window.Contains(at(tt.time))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMaintenanceWindow_Validate(t *testing.T) {
+	assert.NoError(t, (&MaintenanceWindow{Days: []string{"Mon"}, Start: "00:00", End: "23:59"}).Validate())
+	assert.Error(t, (&MaintenanceWindow{Days: []string{"Monday"}, Start: "00:00", End: "01:00"}).Validate())
+	assert.Error(t, (&MaintenanceWindow{Start: "24:00", End: "01:00"}).Validate())
+	assert.Error(t, (&MaintenanceWindow{Start: "00:00"}).Validate())
+	assert.Error(t, (&MaintenanceWindow{Start: "00:00", End: "01:00", TimeZone: "Mars/Olympus"}).Validate())
+}
+
+func TestInMaintenanceWindows(t *testing.T) {
+	now := time.Date(2025, 6, 4, 12, 0, 0, 0, time.UTC)
+	in, err := InMaintenanceWindows(nil, now)
+	require.NoError(t, err)
+	assert.True(t, in, "the updates are not restricted without windows")
+
+	in, err = InMaintenanceWindows([]MaintenanceWindow{{Start: "02:00", End: "05:00"}, {Start: "11:00", End: "13:00"}}, now)
+	require.NoError(t, err)
+	assert.True(t, in)
+
+	in, err = InMaintenanceWindows([]MaintenanceWindow{{Start: "02:00", End: "05:00"}}, now)
+	require.NoError(t, err)
+	assert.False(t, in)
+
+	assert.Equal(t, "Sat,Sun 02:00-06:00 Europe/Madrid",
+		(&MaintenanceWindow{Days: []string{"Sat", "Sun"}, Start: "02:00", End: "06:00", TimeZone: "Europe/Madrid"}).String())
+}
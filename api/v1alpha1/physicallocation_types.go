@@ -34,6 +34,9 @@ type PhysicalLocationSpec struct {
 	Description string    `json:"description,omitempty"`
 	Address     string    `json:"address,omitempty"`
 	Contacts    []Contact `json:"contacts,omitempty"`
+	// MaintenanceWindows are the time ranges during which the bootc image of the exporter hosts of
+	// the location can be updated, unless the hosts define their own.
+	MaintenanceWindows []MaintenanceWindow `json:"maintenanceWindows,omitempty"`
 }
 
 // PhysicalLocationStatus defines the observed state of PhysicalLocation.
@@ -24,6 +24,21 @@ import (
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CAReference) DeepCopyInto(out *CAReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CAReference.
+func (in *CAReference) DeepCopy() *CAReference {
+	if in == nil {
+		return nil
+	}
+	out := new(CAReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ConfigTemplateRef) DeepCopyInto(out *ConfigTemplateRef) {
 	*out = *in
@@ -139,6 +154,7 @@ func (in *ExporterConfigTemplateList) DeepCopyObject() runtime.Object {
 func (in *ExporterConfigTemplateSpec) DeepCopyInto(out *ExporterConfigTemplateSpec) {
 	*out = *in
 	in.ExporterMetadata.DeepCopyInto(&out.ExporterMetadata)
+	in.Files.DeepCopyInto(&out.Files)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExporterConfigTemplateSpec.
@@ -166,6 +182,31 @@ func (in *ExporterConfigTemplateStatus) DeepCopy() *ExporterConfigTemplateStatus
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExporterFiles) DeepCopyInto(out *ExporterFiles) {
+	*out = *in
+	if in.Config != nil {
+		in, out := &in.Config, &out.Config
+		*out = new(FileAttributes)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Systemd != nil {
+		in, out := &in.Systemd, &out.Systemd
+		*out = new(FileAttributes)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExporterFiles.
+func (in *ExporterFiles) DeepCopy() *ExporterFiles {
+	if in == nil {
+		return nil
+	}
+	out := new(ExporterFiles)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ExporterHost) DeepCopyInto(out *ExporterHost) {
 	*out = *in
@@ -250,7 +291,14 @@ func (in *ExporterHostSpec) DeepCopyInto(out *ExporterHostSpec) {
 		copy(*out, *in)
 	}
 	out.Power = in.Power
-	out.Management = in.Management
+	in.Management.DeepCopyInto(&out.Management)
+	if in.MaintenanceWindows != nil {
+		in, out := &in.MaintenanceWindows, &out.MaintenanceWindows
+		*out = make([]MaintenanceWindow, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExporterHostSpec.
@@ -400,6 +448,46 @@ func (in *ExporterMeta) DeepCopy() *ExporterMeta {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FileAttributes) DeepCopyInto(out *FileAttributes) {
+	*out = *in
+	if in.Owner != nil {
+		in, out := &in.Owner, &out.Owner
+		*out = new(int)
+		**out = **in
+	}
+	if in.Group != nil {
+		in, out := &in.Group, &out.Group
+		*out = new(int)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FileAttributes.
+func (in *FileAttributes) DeepCopy() *FileAttributes {
+	if in == nil {
+		return nil
+	}
+	out := new(FileAttributes)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FlightctlManagement) DeepCopyInto(out *FlightctlManagement) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FlightctlManagement.
+func (in *FlightctlManagement) DeepCopy() *FlightctlManagement {
+	if in == nil {
+		return nil
+	}
+	out := new(FlightctlManagement)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *JumpstarterInstance) DeepCopyInto(out *JumpstarterInstance) {
 	*out = *in
@@ -467,6 +555,7 @@ func (in *JumpstarterInstanceSpec) DeepCopyInto(out *JumpstarterInstanceSpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	in.TLS.DeepCopyInto(&out.TLS)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JumpstarterInstanceSpec.
@@ -494,6 +583,31 @@ func (in *JumpstarterInstanceStatus) DeepCopy() *JumpstarterInstanceStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JumpstarterInstanceTLS) DeepCopyInto(out *JumpstarterInstanceTLS) {
+	*out = *in
+	if in.CAConfigMapRef != nil {
+		in, out := &in.CAConfigMapRef, &out.CAConfigMapRef
+		*out = new(CAReference)
+		**out = **in
+	}
+	if in.CASecretRef != nil {
+		in, out := &in.CASecretRef, &out.CASecretRef
+		*out = new(CAReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JumpstarterInstanceTLS.
+func (in *JumpstarterInstanceTLS) DeepCopy() *JumpstarterInstanceTLS {
+	if in == nil {
+		return nil
+	}
+	out := new(JumpstarterInstanceTLS)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *JumsptarterInstanceRef) DeepCopyInto(out *JumsptarterInstanceRef) {
 	*out = *in
@@ -509,6 +623,21 @@ func (in *JumsptarterInstanceRef) DeepCopy() *JumsptarterInstanceRef {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LocalManagement) DeepCopyInto(out *LocalManagement) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LocalManagement.
+func (in *LocalManagement) DeepCopy() *LocalManagement {
+	if in == nil {
+		return nil
+	}
+	out := new(LocalManagement)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LocationRef) DeepCopyInto(out *LocationRef) {
 	*out = *in
@@ -524,10 +653,40 @@ func (in *LocationRef) DeepCopy() *LocationRef {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MaintenanceWindow) DeepCopyInto(out *MaintenanceWindow) {
+	*out = *in
+	if in.Days != nil {
+		in, out := &in.Days, &out.Days
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MaintenanceWindow.
+func (in *MaintenanceWindow) DeepCopy() *MaintenanceWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(MaintenanceWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Management) DeepCopyInto(out *Management) {
 	*out = *in
-	out.SSH = in.SSH
+	in.SSH.DeepCopyInto(&out.SSH)
+	if in.Flightctl != nil {
+		in, out := &in.Flightctl, &out.Flightctl
+		*out = new(FlightctlManagement)
+		**out = **in
+	}
+	if in.Local != nil {
+		in, out := &in.Local, &out.Local
+		*out = new(LocalManagement)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Management.
@@ -607,6 +766,13 @@ func (in *PhysicalLocationSpec) DeepCopyInto(out *PhysicalLocationSpec) {
 		*out = make([]Contact, len(*in))
 		copy(*out, *in)
 	}
+	if in.MaintenanceWindows != nil {
+		in, out := &in.MaintenanceWindows, &out.MaintenanceWindows
+		*out = make([]MaintenanceWindow, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PhysicalLocationSpec.
@@ -668,6 +834,11 @@ func (in *SNMPPower) DeepCopy() *SNMPPower {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SSHCredentials) DeepCopyInto(out *SSHCredentials) {
 	*out = *in
+	if in.JumpHosts != nil {
+		in, out := &in.JumpHosts, &out.JumpHosts
+		*out = make([]SSHJumpHost, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SSHCredentials.
@@ -679,3 +850,18 @@ func (in *SSHCredentials) DeepCopy() *SSHCredentials {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SSHJumpHost) DeepCopyInto(out *SSHJumpHost) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SSHJumpHost.
+func (in *SSHJumpHost) DeepCopy() *SSHJumpHost {
+	if in == nil {
+		return nil
+	}
+	out := new(SSHJumpHost)
+	in.DeepCopyInto(out)
+	return out
+}
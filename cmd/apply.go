@@ -19,17 +19,27 @@ package main
 import (
 	"context"
 	"fmt"
+	"maps"
 	"os"
+	"path/filepath"
 	"regexp"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+	"k8s.io/apimachinery/pkg/labels"
 
+	api "github.com/jumpstarter-dev/jumpstarter-lab-config/api/v1alpha1"
 	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/config"
 	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/config_lint"
 	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/exporter/host"
 	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/exporter/template"
 	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/instance"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/output"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/plan"
 	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/templating"
 	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/unmanaged"
 )
@@ -42,14 +52,17 @@ var applyCmd = &cobra.Command{
 	Args:         cobra.MaximumNArgs(1),
 	SilenceUsage: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		dryRun, _ := cmd.Flags().GetBool("dry-run")
-		prune, _ := cmd.Flags().GetBool("prune")
-		vaultPassFile, _ := cmd.Flags().GetString("vault-password-file")
-		debugConfigs, _ := cmd.Flags().GetBool("debug-configs")
-		filterClients, _ := cmd.Flags().GetString("filter-clients")
-		filterExporters, _ := cmd.Flags().GetString("filter-exporters")
-		printCredentials, _ := cmd.Flags().GetBool("print-exporter-credentials")
-		parallel, _ := cmd.Flags().GetInt("parallel")
+		opts, err := syncOptionsFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		opts.DryRun, _ = cmd.Flags().GetBool("dry-run")
+		opts.PrintCredentials, _ = cmd.Flags().GetBool("print-exporter-credentials")
+		opts.ClientConfigsDir, _ = cmd.Flags().GetString("client-configs-dir")
+		planFile, _ := cmd.Flags().GetString("plan")
+
+		ctx, cancel := opts.context(cmd.Context())
+		defer cancel()
 
 		// Determine config file path
 		configFilePath := defaultConfigFile
@@ -57,120 +70,465 @@ var applyCmd = &cobra.Command{
 			configFilePath = args[0]
 		}
 
-		// Load the configuration file
-		cfg, err := config.LoadConfig(configFilePath, vaultPassFile)
-		if err != nil {
-			return fmt.Errorf("error loading config file %s: %w", configFilePath, err)
+		if planFile != "" {
+			return applyPlan(ctx, cmd, configFilePath, planFile, opts)
 		}
+		return runSync(ctx, configFilePath, opts, nil)
+	},
+}
+
+// syncOptions are the options of a sync of the configuration, shared by the apply and plan commands
+type syncOptions struct {
+	plan.Options
+	DryRun            bool
+	VaultPasswordFile string
+	DebugConfigs      bool
+	PrintCredentials  bool
+	ClientConfigsDir  string
+	Parallel          int
+	ParallelInstances int
+	Timeout           time.Duration
+	TrustOnFirstUse   bool
+	PowerCycleState   string
+}
+
+// planOptionFlags are the flags stored in a plan, they can't be changed when the plan is applied
+var planOptionFlags = []string{
+	"prune", "managed-by", "force-conflicts", "filter-clients", "filter-exporters", "filter-policies",
+	"allow-mass-delete", "max-deletions", "max-deletion-percent", "max-concurrent-upgrades",
+	"health-timeout", "force-restarts", "canary-hosts", "canary-selector", "max-unavailable",
+	"max-failure-percent", "power-cycle-after", "max-power-cycles", "power-cycle-cooldown", "boot-timeout",
+}
+
+// addSyncFlags registers the flags shared by the apply and plan commands
+func addSyncFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("prune", false, "Delete resources that are no longer defined in configuration, and decommission "+
+		"the exporters removed from it on the exporter hosts (only the ones listed in /etc/jumpstarter/managed-exporters.yaml)")
+	cmd.Flags().String("managed-by", instance.DefaultManagedBy,
+		"Value of the "+instance.ManagedByLabel+" label identifying the resources owned (and pruned) by this configuration")
+	cmd.Flags().Bool("allow-mass-delete", false, "Allow --prune to delete any number of resources")
+	cmd.Flags().Int("max-deletions", 10, "Abort when --prune would delete more than this number of resources in an instance")
+	cmd.Flags().Int("max-deletion-percent", 30,
+		"Abort when --prune would delete more than this percentage of the managed resources in an instance "+
+			"(from 5 deletions)")
+	cmd.Flags().String("vault-password-file", "", "Path to the vault password file for decrypting variables")
+	cmd.Flags().Bool("debug-configs", false, "Show debug configs")
+	cmd.Flags().String("filter-clients", "", "Regexp pattern to filter clients by name")
+	cmd.Flags().String("filter-exporters", "", "Regexp pattern to filter exporters by name")
+	cmd.Flags().String("filter-policies", "", "Regexp pattern to filter exporter access policies by name")
+	cmd.Flags().Bool("force-conflicts", false, "Take ownership of fields managed by other field managers during server-side apply")
+	cmd.Flags().Int("parallel", 10, "Number of hosts to process in parallel during ssh operation (0 for sequential)")
+	cmd.Flags().Duration("timeout", 0, "Stop after this duration, finishing the operations in progress (i.e. 30m, 0 for no timeout)")
+	cmd.Flags().Int("parallel-instances", 4, "Number of jumpstarter instances to sync in parallel (0 for unlimited)")
+	cmd.Flags().Int("max-concurrent-upgrades", 0,
+		"Maximum number of exporter hosts updating their bootc image at once, counting the updates in progress (0 for unlimited)")
+	cmd.Flags().Duration("health-timeout", 2*time.Minute,
+		"Roll back the exporters which aren't running and online this long after their update (0 to skip the check)")
+	cmd.Flags().Bool("force-restarts", false,
+		"Restart the leased exporters and update the bootc image of their hosts instead of deferring it to a later sync")
+	cmd.Flags().StringSlice("canary-hosts", nil, "Exporter hosts synced first, before the other hosts")
+	cmd.Flags().String("canary-selector", "", "Label selector of the exporter hosts synced first (i.e. rollout=canary)")
+	cmd.Flags().Int("max-unavailable", 0,
+		"Sync the exporter hosts after the canaries in batches of this many hosts (0 for a single batch)")
+	cmd.Flags().Int("max-failure-percent", 0,
+		"Stop the rollout when more than this percentage of the exporter hosts of the canaries or a batch failed, "+
+			"including the hosts queued for retry")
+	cmd.Flags().Int("power-cycle-after", 0,
+		"Power cycle the exporter hosts through their PDU outlet after this many failed SSH attempts (0 to never power cycle)")
+	cmd.Flags().Int("max-power-cycles", 3, "Maximum number of exporter hosts power cycled in a run (0 for unlimited)")
+	cmd.Flags().Duration("power-cycle-cooldown", 6*time.Hour,
+		"Don't power cycle an exporter host again within this duration of its last power cycle")
+	cmd.Flags().Duration("boot-timeout", 5*time.Minute, "Time a power cycled exporter host has to accept SSH connections again")
+	cmd.Flags().String("power-cycle-state", "",
+		"File recording the power cycles of the exporter hosts on this runner for the cooldown "+
+			"(default power-cycles.json in the jumpstarter-lab-config directory of the user cache directory)")
+	cmd.Flags().String("ssh-host-keys", hostKeysStrict, "Exporter host key verification: "+hostKeysStrict+
+		" only accepts pinned or known_hosts keys, "+hostKeysTOFU+" also trusts unknown hosts on first use and pins their key")
+}
+
+const (
+	hostKeysStrict = "strict"
+	hostKeysTOFU   = "tofu"
+)
+
+// syncOptionsFromFlags reads the flags registered by addSyncFlags
+func syncOptionsFromFlags(cmd *cobra.Command) (syncOptions, error) {
+	flags := cmd.Flags()
+	var opts syncOptions
+	opts.Prune, _ = flags.GetBool("prune")
+	opts.ManagedBy, _ = flags.GetString("managed-by")
+	opts.ForceConflicts, _ = flags.GetBool("force-conflicts")
+	opts.FilterClients, _ = flags.GetString("filter-clients")
+	opts.FilterExporters, _ = flags.GetString("filter-exporters")
+	opts.FilterPolicies, _ = flags.GetString("filter-policies")
+	opts.AllowMassDelete, _ = flags.GetBool("allow-mass-delete")
+	opts.MaxDeletions, _ = flags.GetInt("max-deletions")
+	opts.MaxDeletionPercent, _ = flags.GetInt("max-deletion-percent")
+	if flags.Changed("max-deletions") && !flags.Changed("max-deletion-percent") {
+		// an explicit count replaces the percentage check
+		opts.MaxDeletionPercent = 0
+	}
+	opts.VaultPasswordFile, _ = flags.GetString("vault-password-file")
+	opts.DebugConfigs, _ = flags.GetBool("debug-configs")
+	opts.Parallel, _ = flags.GetInt("parallel")
+	opts.ParallelInstances, _ = flags.GetInt("parallel-instances")
+	opts.Timeout, _ = flags.GetDuration("timeout")
+	opts.MaxConcurrentUpgrades, _ = flags.GetInt("max-concurrent-upgrades")
+	opts.HealthTimeout = durationFlag(cmd, "health-timeout")
+	opts.ForceRestarts, _ = flags.GetBool("force-restarts")
+	opts.CanaryHosts, _ = flags.GetStringSlice("canary-hosts")
+	opts.CanarySelector, _ = flags.GetString("canary-selector")
+	opts.MaxUnavailable, _ = flags.GetInt("max-unavailable")
+	opts.MaxFailurePercent, _ = flags.GetInt("max-failure-percent")
+	opts.PowerCycleAfter, _ = flags.GetInt("power-cycle-after")
+	opts.MaxPowerCycles, _ = flags.GetInt("max-power-cycles")
+	opts.PowerCycleCooldown = durationFlag(cmd, "power-cycle-cooldown")
+	opts.BootTimeout = durationFlag(cmd, "boot-timeout")
+	opts.PowerCycleState, _ = flags.GetString("power-cycle-state")
+	if _, err := opts.rollout(); err != nil {
+		return opts, err
+	}
+	if _, err := opts.remediation(); err != nil {
+		return opts, err
+	}
+
+	hostKeys, _ := flags.GetString("ssh-host-keys")
+	switch hostKeys {
+	case hostKeysStrict:
+	case hostKeysTOFU:
+		opts.TrustOnFirstUse = true
+	default:
+		return opts, fmt.Errorf("invalid --ssh-host-keys %q, expected %s or %s", hostKeys, hostKeysStrict, hostKeysTOFU)
+	}
+	return opts, nil
+}
 
-		config_lint.Validate(cfg)
-		unmanagedSummary := unmanaged.ProcessUnmanagedExporters(cfg, dryRun, time.Now)
+// durationFlag returns the value of a duration flag as stored in a plan
+func durationFlag(cmd *cobra.Command, name string) plan.Duration {
+	d, _ := cmd.Flags().GetDuration(name)
+	return plan.Duration(d)
+}
 
-		tapplier, err := templating.NewTemplateApplier(cfg, nil)
+// rollout returns the rollout strategy of the exporter hosts
+func (o syncOptions) rollout() (host.RolloutStrategy, error) {
+	rollout := host.RolloutStrategy{
+		CanaryHosts:       o.CanaryHosts,
+		MaxUnavailable:    o.MaxUnavailable,
+		MaxFailurePercent: o.MaxFailurePercent,
+	}
+	if selector := o.CanarySelector; selector != "" {
+		parsed, err := labels.Parse(selector)
 		if err != nil {
-			return fmt.Errorf("error creating template applier %w", err)
+			return rollout, fmt.Errorf("invalid --canary-selector %q: %w", selector, err)
 		}
+		rollout.CanarySelector = parsed
+	}
+	if rollout.MaxUnavailable < 0 {
+		return rollout, fmt.Errorf("invalid --max-unavailable %d, expected 0 or more", rollout.MaxUnavailable)
+	}
+	if rollout.MaxFailurePercent < 0 || rollout.MaxFailurePercent > 100 {
+		return rollout, fmt.Errorf("invalid --max-failure-percent %d, expected 0 to 100", rollout.MaxFailurePercent)
+	}
+	return rollout, nil
+}
 
-		// Compile client filter regexp if provided
-		var clientFilter *regexp.Regexp
-		if filterClients != "" {
-			clientFilter, err = regexp.Compile(filterClients)
-			if err != nil {
-				return fmt.Errorf("invalid client filter regexp '%s': %w", filterClients, err)
-			}
+// remediation returns the power cycle policy of the unreachable exporter hosts
+func (o syncOptions) remediation() (host.RemediationPolicy, error) {
+	policy := host.RemediationPolicy{
+		After:          o.PowerCycleAfter,
+		MaxPowerCycles: o.MaxPowerCycles,
+		Cooldown:       time.Duration(o.PowerCycleCooldown),
+		BootTimeout:    time.Duration(o.BootTimeout),
+		CycleDelay:     10 * time.Second,
+	}
+	if policy.After < 0 {
+		return policy, fmt.Errorf("invalid --power-cycle-after %d, expected 0 or more", policy.After)
+	}
+	if policy.MaxPowerCycles < 0 {
+		return policy, fmt.Errorf("invalid --max-power-cycles %d, expected 0 or more", policy.MaxPowerCycles)
+	}
+	policy.StateFile = o.PowerCycleState
+	if policy.StateFile == "" && policy.After > 0 {
+		cacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return policy, fmt.Errorf("no --power-cycle-state and no user cache directory: %w", err)
 		}
+		policy.StateFile = filepath.Join(cacheDir, "jumpstarter-lab-config", "power-cycles.json")
+	}
+	return policy, nil
+}
 
-		// Compile exporter filter regexp if provided
-		var exporterFilter *regexp.Regexp
-		if filterExporters != "" {
-			exporterFilter, err = regexp.Compile(filterExporters)
-			if err != nil {
-				return fmt.Errorf("invalid exporter filter regexp '%s': %w", filterExporters, err)
-			}
-		}
+// context returns the context of the sync, bounded by the --timeout
+func (o syncOptions) context(parent context.Context) (context.Context, context.CancelFunc) {
+	if o.Timeout > 0 {
+		return context.WithTimeout(parent, o.Timeout)
+	}
+	return context.WithCancel(parent)
+}
 
-		if dryRun {
-			fmt.Println("Dry run: Would apply changes to:")
-			fmt.Println()
-		} else {
-			fmt.Println("Applying changes:")
-			fmt.Println()
+// compileFilter compiles a name filter, an empty pattern matches everything
+func compileFilter(kind, pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	filter, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s filter regexp '%s': %w", kind, pattern, err)
+	}
+	return filter, nil
+}
+
+// runSync syncs the jumpstarter instances and the exporter hosts with the configuration,
+// the changes are recorded in recorder when it is not nil
+func runSync(ctx context.Context, configFilePath string, opts syncOptions, recorder *plan.Recorder) error {
+	// Load the configuration file
+	cfg, err := config.LoadConfig(configFilePath, opts.VaultPasswordFile)
+	if err != nil {
+		return fmt.Errorf("error loading config file %s: %w", configFilePath, err)
+	}
+
+	config_lint.Validate(cfg)
+	unmanagedSummary := unmanaged.ProcessUnmanagedExporters(cfg, opts.DryRun, time.Now)
+
+	tapplier, err := templating.NewTemplateApplier(cfg, nil)
+	if err != nil {
+		return fmt.Errorf("error creating template applier %w", err)
+	}
+
+	clientFilter, err := compileFilter("client", opts.FilterClients)
+	if err != nil {
+		return err
+	}
+	exporterFilter, err := compileFilter("exporter", opts.FilterExporters)
+	if err != nil {
+		return err
+	}
+	policyFilter, err := compileFilter("policy", opts.FilterPolicies)
+	if err != nil {
+		return err
+	}
+
+	rollout, err := opts.rollout()
+	if err != nil {
+		return err
+	}
+	remediation, err := opts.remediation()
+	if err != nil {
+		return err
+	}
+
+	deletionLimits := instance.DeletionLimits{MaxCount: opts.MaxDeletions, MaxPercent: opts.MaxDeletionPercent}
+
+	if opts.DryRun {
+		fmt.Println("Dry run: Would apply changes to:")
+		fmt.Println()
+	} else {
+		fmt.Println("Applying changes:")
+		fmt.Println()
+	}
+	// create a serviceParametersMap to store the service parameters for the exporters in the jumpstarter instances
+	serviceParametersMap := make(map[string]template.ServiceParameters)
+
+	// create the instance clients and plan the deletions before changing anything,
+	// so a configuration mistake cannot wipe an instance
+	instanceClients := make(map[string]*instance.Instance)
+	instanceOutputs := make(map[string]*output.Buffer)
+	for _, inst := range cfg.Loaded.JumpstarterInstances {
+		instanceCopy := inst.DeepCopy()
+		err = tapplier.Apply(instanceCopy)
+		if err != nil {
+			return fmt.Errorf("error applying template for %s: %w", inst.Name, err)
 		}
-		// create a serviceParametersMap to store the service parameters for the exporters in the jumpstarter instances
-		serviceParametersMap := make(map[string]template.ServiceParameters)
+		instanceClient, err := instance.NewInstance(instanceCopy, instanceCopy.Spec.Kubeconfig, opts.DryRun, opts.Prune,
+			opts.PrintCredentials)
+		if err != nil {
+			return fmt.Errorf("error creating instance for %s: %w", inst.Name, err)
+		}
+		instanceClient.SetForceConflicts(opts.ForceConflicts)
+		instanceClient.SetManagedBy(opts.ManagedBy)
+		instanceClient.SetRecorder(recorder)
+		// the output of each instance is buffered, so it stays readable while they are synced in parallel
+		out := output.NewBuffer()
+		instanceClient.SetOutput(out)
+		instanceClients[inst.Name] = instanceClient
+		instanceOutputs[inst.Name] = out
 
-		for _, inst := range cfg.Loaded.JumpstarterInstances {
-			instanceCopy := inst.DeepCopy()
-			err = tapplier.Apply(instanceCopy)
+		if opts.Prune && !opts.AllowMassDelete {
+			deletions, err := instanceClient.PlanDeletions(ctx, cfg, clientFilter, policyFilter,
+				exporterFilter, unmanagedSummary.Exporters)
 			if err != nil {
-				return fmt.Errorf("error applying template for %s: %w", inst.Name, err)
+				return fmt.Errorf("error planning deletions for %s: %w", inst.Name, err)
 			}
-			instanceClient, err := instance.NewInstance(instanceCopy, instanceCopy.Spec.Kubeconfig, dryRun, prune,
-				printCredentials)
-			if err != nil {
-				return fmt.Errorf("error creating instance for %s: %w", inst.Name, err)
+			if err := deletions.Check(deletionLimits, out); err != nil {
+				_ = out.Flush(os.Stdout)
+				return err
 			}
+		}
+	}
 
-			err = instanceClient.SyncClients(context.Background(), cfg, clientFilter)
-			if err != nil {
-				return fmt.Errorf("error syncing clients for %s: %w", inst.Name, err)
+	// sync the instances in parallel, buffering the output of each instance so it stays readable
+	var mu sync.Mutex
+	instanceStatus := make(map[string]string)
+	g, gctx := errgroup.WithContext(ctx)
+	if opts.ParallelInstances > 0 {
+		g.SetLimit(opts.ParallelInstances)
+	}
+	for name, instanceClient := range instanceClients {
+		g.Go(func() error {
+			if gctx.Err() != nil {
+				mu.Lock()
+				defer mu.Unlock()
+				instanceStatus[name] = statusNotStarted
+				return nil
 			}
 
-			instanceServiceParametersMap, err := instanceClient.SyncExporters(
-				context.Background(),
-				cfg,
-				exporterFilter,
-				unmanagedSummary.Exporters,
-			)
+			out := instanceOutputs[name]
+			instanceServiceParametersMap, err := syncInstance(gctx, instanceClient, cfg, clientFilter, policyFilter,
+				exporterFilter, unmanagedSummary.Exporters, opts.ClientConfigsDir)
+
+			mu.Lock()
+			defer mu.Unlock()
+			_ = out.Flush(os.Stdout)
+			switch {
+			case err == nil:
+				instanceStatus[name] = statusSynced
+			case gctx.Err() != nil:
+				instanceStatus[name] = statusInterrupted
+			default:
+				instanceStatus[name] = statusFailed
+			}
 			if err != nil {
-				return fmt.Errorf("error syncing exporters for %s: %w", inst.Name, err)
-			} else {
-				// merge instanceServiceParametersMap into serviceParametersMap
-				for k, v := range instanceServiceParametersMap {
-					serviceParametersMap[k] = v
-				}
+				return fmt.Errorf("error syncing %s: %w", name, err)
 			}
-		}
+			maps.Copy(serviceParametersMap, instanceServiceParametersMap)
+			return nil
+		})
+	}
+	err = g.Wait()
+	printInstanceSummary(instanceStatus)
+	if ctx.Err() != nil {
+		fmt.Println("⏹️  Exporter hosts were not synced")
+		return fmt.Errorf("apply interrupted: %w", context.Cause(ctx))
+	}
+	if err != nil {
+		return err
+	}
 
-		exporterHostSyncer := host.NewExporterHostSyncer(cfg, tapplier, serviceParametersMap, dryRun, debugConfigs,
-			exporterFilter, parallel)
-
-		err = exporterHostSyncer.SyncExporterHosts()
-		if err != nil {
-			return fmt.Errorf("error syncing exporter hosts: %w", err)
+	exporterHostSyncer := host.NewExporterHostSyncer(cfg, tapplier, serviceParametersMap, opts.DryRun, opts.DebugConfigs,
+		exporterFilter, opts.Parallel)
+	exporterHostSyncer.SetRecorder(recorder)
+	exporterHostSyncer.SetTrustOnFirstUse(opts.TrustOnFirstUse)
+	exporterHostSyncer.SetPrune(opts.Prune)
+	exporterHostSyncer.SetRollout(rollout)
+	if err := exporterHostSyncer.SetRemediation(remediation); err != nil {
+		return fmt.Errorf("failed to set up the power cycles: %w", err)
+	}
+	exporterHostSyncer.SetMaxConcurrentUpgrades(opts.MaxConcurrentUpgrades)
+	exporterHostSyncer.SetHealthCheck(time.Duration(opts.HealthTimeout), func(ctx context.Context, exporterInstance *api.ExporterInstance) (bool, error) {
+		instanceClient, ok := instanceClients[exporterInstance.Spec.JumpstarterInstanceRef.Name]
+		if !ok {
+			return false, fmt.Errorf("unknown jumpstarter instance %s", exporterInstance.Spec.JumpstarterInstanceRef.Name)
 		}
+		return instanceClient.ExporterOnline(ctx, exporterInstance.Name)
+	})
+	exporterHostSyncer.SetLeaseCheck(opts.ForceRestarts, func(ctx context.Context, exporterInstance *api.ExporterInstance) (string, error) {
+		instanceClient, ok := instanceClients[exporterInstance.Spec.JumpstarterInstanceRef.Name]
+		if !ok {
+			return "", fmt.Errorf("unknown jumpstarter instance %s", exporterInstance.Spec.JumpstarterInstanceRef.Name)
+		}
+		return instanceClient.ExporterLease(ctx, exporterInstance.Name)
+	})
 
-		if unmanagedSummary.Count() > 0 {
-			if unmanagedSummary.OldestDays > 0 {
-				_, _ = fmt.Fprintf(
-					os.Stderr,
-					"\n⚠️  Warning: %d exporter(s) are unmanaged (oldest: %d day(s)). "+
-						"They were skipped for sync.\n",
-					unmanagedSummary.Count(), unmanagedSummary.OldestDays,
-				)
-			} else {
-				_, _ = fmt.Fprintf(
-					os.Stderr,
-					"\n⚠️  Warning: %d exporter(s) are unmanaged. They were skipped for sync.\n",
-					unmanagedSummary.Count(),
-				)
-			}
+	err = exporterHostSyncer.SyncExporterHosts(ctx)
+	if err != nil {
+		return fmt.Errorf("error syncing exporter hosts: %w", err)
+	}
+
+	if unmanagedSummary.Count() > 0 {
+		if unmanagedSummary.OldestDays > 0 {
+			_, _ = fmt.Fprintf(
+				os.Stderr,
+				"\n⚠️  Warning: %d exporter(s) are unmanaged (oldest: %d day(s)). "+
+					"They were skipped for sync.\n",
+				unmanagedSummary.Count(), unmanagedSummary.OldestDays,
+			)
+		} else {
+			_, _ = fmt.Fprintf(
+				os.Stderr,
+				"\n⚠️  Warning: %d exporter(s) are unmanaged. They were skipped for sync.\n",
+				unmanagedSummary.Count(),
+			)
 		}
+	}
 
-		return nil
-	},
+	return nil
 }
 
 func init() {
 	// Add flags to apply command
 	applyCmd.Flags().Bool("dry-run", false, "Show what would be applied without making changes")
-	applyCmd.Flags().Bool("prune", false, "Delete resources that are no longer defined in configuration")
-	applyCmd.Flags().String("vault-password-file", "", "Path to the vault password file for decrypting variables")
-	applyCmd.Flags().Bool("debug-configs", false, "Show debug configs")
-	applyCmd.Flags().String("filter-clients", "", "Regexp pattern to filter clients by name")
-	applyCmd.Flags().String("filter-exporters", "", "Regexp pattern to filter exporters by name")
+	addSyncFlags(applyCmd)
 	applyCmd.Flags().Bool("print-exporter-credentials", false, "Print connection details for exporters")
-	applyCmd.Flags().Int("parallel", 10, "Number of hosts to process in parallel during ssh operation (0 for sequential)")
+	applyCmd.Flags().String("client-configs-dir", "", "Write jumpstarter client config files for the synced clients to this directory")
+	applyCmd.Flags().String("plan", "", "Apply the plan file made by the plan command, refusing if the live state drifted since")
 
 	rootCmd.AddCommand(applyCmd)
 }
+
+const (
+	statusSynced      = "synced"
+	statusFailed      = "failed"
+	statusInterrupted = "interrupted"
+	statusNotStarted  = "not started"
+)
+
+// printInstanceSummary prints how the sync of each jumpstarter instance ended
+func printInstanceSummary(instanceStatus map[string]string) {
+	byStatus := make(map[string][]string)
+	for name, status := range instanceStatus {
+		byStatus[status] = append(byStatus[status], name)
+	}
+
+	fmt.Printf("\n📊 Jumpstarter instances: %d synced, %d failed, %d interrupted, %d not started\n",
+		len(byStatus[statusSynced]), len(byStatus[statusFailed]),
+		len(byStatus[statusInterrupted]), len(byStatus[statusNotStarted]))
+	for _, status := range []string{statusFailed, statusInterrupted, statusNotStarted} {
+		names := byStatus[status]
+		if len(names) == 0 {
+			continue
+		}
+		slices.Sort(names)
+		fmt.Printf("  %s: %s\n", status, strings.Join(names, ", "))
+	}
+}
+
+// syncInstance syncs the clients, policies and exporters of a jumpstarter instance, returning
+// the service parameters of its exporters
+func syncInstance(
+	ctx context.Context,
+	instanceClient *instance.Instance,
+	cfg *config.Config,
+	clientFilter, policyFilter, exporterFilter *regexp.Regexp,
+	unmanagedExporters map[string]bool,
+	clientConfigsDir string,
+) (map[string]template.ServiceParameters, error) {
+	if err := instanceClient.SyncClients(ctx, cfg, clientFilter); err != nil {
+		return nil, fmt.Errorf("error syncing clients: %w", err)
+	}
+
+	if clientConfigsDir != "" {
+		if err := instanceClient.WriteClientConfigs(ctx, cfg, clientFilter, clientConfigsDir); err != nil {
+			return nil, fmt.Errorf("error writing client configs: %w", err)
+		}
+	}
+
+	if err := instanceClient.SyncPolicies(ctx, cfg, policyFilter); err != nil {
+		return nil, fmt.Errorf("error syncing policies: %w", err)
+	}
+
+	serviceParametersMap, err := instanceClient.SyncExporters(ctx, cfg, exporterFilter, unmanagedExporters)
+	if err != nil {
+		return nil, fmt.Errorf("error syncing exporters: %w", err)
+	}
+	return serviceParametersMap, nil
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2025. The Jumpstarter Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/config"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/instance"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/templating"
+)
+
+var credentialsCmd = &cobra.Command{
+	Use:   "credentials",
+	Short: "Retrieve credentials issued by the jumpstarter controllers",
+}
+
+var credentialsClientCmd = &cobra.Command{
+	Use:   "client <name> [config-file]",
+	Short: "Generate jumpstarter client config files for a client",
+	Long: `Read the credential issued to a client by the jumpstarter controllers and generate ` +
+		`ready to use jumpstarter client config files, one per jumpstarter instance. ` +
+		`The files are printed to stdout unless --output-dir is used.`,
+	Args:         cobra.RangeArgs(1, 2),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		vaultPassFile, _ := cmd.Flags().GetString("vault-password-file")
+		outputDir, _ := cmd.Flags().GetString("output-dir")
+		instanceName, _ := cmd.Flags().GetString("instance")
+
+		clientName := args[0]
+		configFilePath := defaultConfigFile
+		if len(args) > 1 {
+			configFilePath = args[1]
+		}
+
+		cfg, err := config.LoadConfig(configFilePath, vaultPassFile)
+		if err != nil {
+			return fmt.Errorf("error loading config file %s: %w", configFilePath, err)
+		}
+
+		cfgClient, ok := cfg.Loaded.Clients[clientName]
+		if !ok {
+			return fmt.Errorf("client %s is not defined in the configuration", clientName)
+		}
+		if instanceName != "" {
+			inst, ok := cfg.Loaded.JumpstarterInstances[instanceName]
+			if !ok {
+				return fmt.Errorf("jumpstarter instance %s is not defined in the configuration", instanceName)
+			}
+			if !config.TargetsJumpstarterInstance(cfgClient.ObjectMeta, inst) {
+				return fmt.Errorf("client %s is not synced to jumpstarter instance %s", clientName, instanceName)
+			}
+		}
+
+		tapplier, err := templating.NewTemplateApplier(cfg, nil)
+		if err != nil {
+			return fmt.Errorf("error creating template applier %w", err)
+		}
+
+		documents := 0
+		for name, inst := range cfg.Loaded.JumpstarterInstances {
+			if instanceName != "" && name != instanceName {
+				continue
+			}
+			if !config.TargetsJumpstarterInstance(cfgClient.ObjectMeta, inst) {
+				continue
+			}
+			instanceCopy := inst.DeepCopy()
+			if err := tapplier.Apply(instanceCopy); err != nil {
+				return fmt.Errorf("error applying template for %s: %w", name, err)
+			}
+			instanceClient, err := instance.NewInstance(instanceCopy, instanceCopy.Spec.Kubeconfig, false, false, false)
+			if err != nil {
+				return fmt.Errorf("error creating instance for %s: %w", name, err)
+			}
+
+			clientConfig, err := instanceClient.GetClientConfig(context.Background(), clientName)
+			if err != nil {
+				return fmt.Errorf("error getting client config for %s from %s: %w", clientName, name, err)
+			}
+
+			if outputDir != "" {
+				path := filepath.Join(outputDir, instanceClient.ClientConfigFileName(clientName))
+				if err := instance.WriteClientConfigFile(path, clientConfig); err != nil {
+					return err
+				}
+				fmt.Printf("🔑 [%s] Wrote client config for %s to %s\n", name, clientName, path)
+				continue
+			}
+
+			if documents > 0 {
+				fmt.Println("---")
+			}
+			fmt.Print(string(clientConfig))
+			documents++
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	credentialsClientCmd.Flags().String("vault-password-file", "", "Path to the vault password file for decrypting variables")
+	credentialsClientCmd.Flags().String("output-dir", "", "Directory to write the client config files to, instead of stdout")
+	credentialsClientCmd.Flags().String("instance", "", "Only generate the client config for this jumpstarter instance")
+
+	credentialsCmd.AddCommand(credentialsClientCmd)
+	rootCmd.AddCommand(credentialsCmd)
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2025. The Jumpstarter Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"regexp"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/config"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/config_lint"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/exporter/host"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/exporter/template"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/instance"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/templating"
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff [config-file]",
+	Short: "Show the drift of the exporter hosts from the configuration",
+	Long: `Compare the container units, service units and exporter configs on the exporter hosts ` +
+		`with the rendered configuration, and the running exporter images with the latest ones. ` +
+		`Prints a unified diff per host and file, without changing anything, and exits with a ` +
+		`non-zero status when any host drifted.`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		vaultPasswordFile, _ := cmd.Flags().GetString("vault-password-file")
+		filterExporters, _ := cmd.Flags().GetString("filter-exporters")
+		parallel, _ := cmd.Flags().GetInt("parallel")
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+
+		ctx, cancel := syncOptions{Timeout: timeout}.context(cmd.Context())
+		defer cancel()
+
+		// Determine config file path
+		configFilePath := defaultConfigFile
+		if len(args) > 0 {
+			configFilePath = args[0]
+		}
+
+		exporterFilter, err := compileFilter("exporter", filterExporters)
+		if err != nil {
+			return err
+		}
+
+		drifted, err := diffExporterHosts(ctx, configFilePath, vaultPasswordFile, exporterFilter, parallel, os.Stdout)
+		if err != nil {
+			return err
+		}
+		if drifted > 0 {
+			return fmt.Errorf("%d exporter hosts drifted from the configuration", drifted)
+		}
+		fmt.Println("✅ The exporter hosts match the configuration")
+		return nil
+	},
+}
+
+func init() {
+	diffCmd.Flags().String("vault-password-file", "", "Path to the vault password file for decrypting variables")
+	diffCmd.Flags().String("filter-exporters", "", "Regexp pattern to filter exporters by name")
+	diffCmd.Flags().Int("parallel", 10, "Number of hosts to compare in parallel (0 for sequential)")
+	diffCmd.Flags().Duration("timeout", 0, "Stop after this duration (i.e. 5m, 0 for no timeout)")
+
+	rootCmd.AddCommand(diffCmd)
+}
+
+// diffExporterHosts writes the diff of the exporter hosts to w and returns the number of drifted hosts,
+// the exporter credentials are read from the jumpstarter instances without changing them
+func diffExporterHosts(ctx context.Context, configFilePath, vaultPasswordFile string, exporterFilter *regexp.Regexp,
+	parallel int, w io.Writer) (int, error) {
+	cfg, err := config.LoadConfig(configFilePath, vaultPasswordFile)
+	if err != nil {
+		return 0, fmt.Errorf("error loading config file %s: %w", configFilePath, err)
+	}
+	if errorsByFile := config_lint.Lint(cfg); len(errorsByFile) > 0 {
+		return 0, fmt.Errorf("the configuration has errors in %d files, run lint for details", len(errorsByFile))
+	}
+
+	tapplier, err := templating.NewTemplateApplier(cfg, nil)
+	if err != nil {
+		return 0, fmt.Errorf("error creating template applier %w", err)
+	}
+
+	unmanagedExporters := make(map[string]bool)
+	for _, exporterInstance := range cfg.Loaded.ExporterInstances {
+		if exporterInstance == nil {
+			continue
+		}
+		if isUnmanaged, _ := exporterInstance.IsUnmanaged(); isUnmanaged {
+			unmanagedExporters[exporterInstance.Name] = true
+		}
+	}
+
+	serviceParametersMap := make(map[string]template.ServiceParameters)
+	for _, inst := range cfg.Loaded.JumpstarterInstances {
+		instanceCopy := inst.DeepCopy()
+		if err := tapplier.Apply(instanceCopy); err != nil {
+			return 0, fmt.Errorf("error applying template for %s: %w", inst.Name, err)
+		}
+		instanceClient, err := instance.NewInstance(instanceCopy, instanceCopy.Spec.Kubeconfig, true, false, false)
+		if err != nil {
+			return 0, fmt.Errorf("error creating instance for %s: %w", inst.Name, err)
+		}
+		instanceServiceParametersMap, err := instanceClient.ExporterServiceParameters(ctx, cfg, exporterFilter,
+			unmanagedExporters)
+		if err != nil {
+			return 0, err
+		}
+		maps.Copy(serviceParametersMap, instanceServiceParametersMap)
+	}
+
+	exporterHostSyncer := host.NewExporterHostSyncer(cfg, tapplier, serviceParametersMap, true, false,
+		exporterFilter, parallel)
+	drifted, err := exporterHostSyncer.DiffExporterHosts(ctx, w)
+	if err != nil {
+		return drifted, fmt.Errorf("error comparing exporter hosts: %w", err)
+	}
+	return drifted, nil
+}
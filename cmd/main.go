@@ -17,7 +17,11 @@ limitations under the License.
 package main
 
 import (
+	"context"
+	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/spf13/cobra"
 
@@ -53,9 +57,25 @@ var rootCmd = &cobra.Command{
 	in enterprise environments.`,
 }
 
+// signalContext returns a context cancelled on SIGINT/SIGTERM, so commands can stop cleanly
+// after the operations in flight. A second signal terminates the process right away.
+func signalContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		// restore the default behavior for the next signal
+		signal.Stop(signals)
+		_, _ = fmt.Fprintf(os.Stderr, "\n🛑 Received %s, finishing the operations in progress (repeat to abort)\n", sig)
+		cancel()
+	}()
+	return ctx
+}
+
 // nolint:gocyclo
 func main() {
-	if err := rootCmd.Execute(); err != nil {
+	if err := rootCmd.ExecuteContext(signalContext()); err != nil {
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,119 @@
+/*
+Copyright 2025. The Jumpstarter Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/plan"
+)
+
+var planCmd = &cobra.Command{
+	Use:   "plan [config-file]",
+	Short: "Save the changes apply would make to a plan file",
+	Long: `Run apply in dry-run mode and save every change it would make to the jumpstarter ` +
+		`instances and exporter hosts to a machine-readable plan file, with hashes of the ` +
+		`state before and after each change. Use apply --plan to apply it.`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts, err := syncOptionsFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		opts.DryRun = true
+		planFile, _ := cmd.Flags().GetString("output")
+
+		ctx, cancel := opts.context(cmd.Context())
+		defer cancel()
+
+		// Determine config file path
+		configFilePath := defaultConfigFile
+		if len(args) > 0 {
+			configFilePath = args[0]
+		}
+
+		recorder := plan.NewRecorder()
+		if err := runSync(ctx, configFilePath, opts, recorder); err != nil {
+			return err
+		}
+
+		p := recorder.Plan(opts.Options)
+		if err := p.Save(planFile); err != nil {
+			return err
+		}
+		fmt.Printf("\n📝 Saved plan with %d changes to %s, apply it with: apply --plan %s\n",
+			len(p.Changes), planFile, planFile)
+		return nil
+	},
+}
+
+func init() {
+	planCmd.Flags().StringP("output", "o", "plan.json", "Path of the plan file to write")
+	addSyncFlags(planCmd)
+
+	rootCmd.AddCommand(planCmd)
+}
+
+// applyPlan applies a plan file. The changes needed now are recorded with a dry-run first and the
+// apply is refused when they differ from the planned ones, i.e. when an object or host file changed
+// since the plan was made. The apply itself refuses any change which isn't in the plan, so a change
+// of the live state between the check and the apply is not applied either.
+func applyPlan(ctx context.Context, cmd *cobra.Command, configFilePath, planFile string, opts syncOptions) error {
+	for _, name := range planOptionFlags {
+		if cmd.Flags().Changed(name) {
+			return fmt.Errorf("--%s cannot be used with --plan, the options stored in the plan are used", name)
+		}
+	}
+
+	planned, err := plan.Load(planFile)
+	if err != nil {
+		return err
+	}
+	opts.Options = planned.Options
+
+	fmt.Printf("🔍 Checking the live state against plan %s\n\n", planFile)
+	check := opts
+	check.DryRun = true
+	check.PrintCredentials = false
+	check.ClientConfigsDir = ""
+	recorder := plan.NewRecorder()
+	if err := runSync(ctx, configFilePath, check, recorder); err != nil {
+		return fmt.Errorf("error checking plan %s: %w", planFile, err)
+	}
+
+	if drift := planned.Drift(recorder.Plan(opts.Options)); len(drift) > 0 {
+		fmt.Printf("\n❌ The live state drifted since plan %s was made:\n", planFile)
+		for _, line := range drift {
+			fmt.Printf("  %s\n", line)
+		}
+		return fmt.Errorf("refusing to apply plan %s, %d changes differ from the plan: make a new plan", planFile, len(drift))
+	}
+
+	if opts.DryRun {
+		fmt.Printf("\n✅ The live state matches plan %s\n", planFile)
+		return nil
+	}
+	fmt.Printf("\n✅ The live state matches plan %s, applying %d changes\n\n", planFile, len(planned.Changes))
+	if err := runSync(ctx, configFilePath, opts, planned.Enforce()); err != nil {
+		return fmt.Errorf("error applying plan %s: %w", planFile, err)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2025. The Jumpstarter Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/config"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/power"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/templating"
+)
+
+var powerCmd = &cobra.Command{
+	Use:   "power [config-file] <exporter-host> on|off|cycle|status",
+	Short: "Control the power outlet of an exporter host",
+	Long: `Switch the PDU outlet of an exporter host on or off, power cycle it, or read its state, ` +
+		`over SNMPv3 with the power configuration of the ExporterHost.`,
+	Args:         cobra.RangeArgs(2, 3),
+	ValidArgs:    []string{"on", "off", "cycle", "status"},
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		vaultPassFile, _ := cmd.Flags().GetString("vault-password-file")
+		cycleDelay, _ := cmd.Flags().GetDuration("cycle-delay")
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+
+		// the config file comes first like in the other commands, it's optional
+		configFilePath := defaultConfigFile
+		if len(args) > 2 {
+			configFilePath, args = args[0], args[1:]
+		}
+		hostName, action := args[0], args[1]
+		switch action {
+		case "on", "off", "cycle", "status":
+		default:
+			return fmt.Errorf("invalid power action %q, expected on, off, cycle or status", action)
+		}
+
+		cfg, err := config.LoadConfig(configFilePath, vaultPassFile)
+		if err != nil {
+			return fmt.Errorf("error loading config file %s: %w", configFilePath, err)
+		}
+		exporterHost, ok := cfg.Loaded.ExporterHosts[hostName]
+		if !ok {
+			return fmt.Errorf("exporter host %s is not defined in the configuration", hostName)
+		}
+
+		tapplier, err := templating.NewTemplateApplier(cfg, nil)
+		if err != nil {
+			return fmt.Errorf("error creating template applier %w", err)
+		}
+		hostCopy := exporterHost.DeepCopy()
+		if err := tapplier.Apply(hostCopy); err != nil {
+			return fmt.Errorf("error applying template for %s: %w", hostName, err)
+		}
+		outlet, err := power.NewSNMPOutlet(hostCopy.Spec.Power.SNMP)
+		if err != nil {
+			return fmt.Errorf("exporter host %s: %w", hostName, err)
+		}
+
+		ctx, cancel := syncOptions{Timeout: timeout}.context(cmd.Context())
+		defer cancel()
+
+		plug := hostCopy.Spec.Power.SNMP.Plug
+		switch action {
+		case "on":
+			err = outlet.Set(ctx, power.On)
+		case "off":
+			err = outlet.Set(ctx, power.Off)
+		case "cycle":
+			fmt.Printf("🔌 Power cycling %s (plug %d on %s)...\n", hostName, plug, outlet.Addr)
+			err = outlet.Cycle(ctx, cycleDelay)
+		}
+		if err != nil {
+			return fmt.Errorf("error switching %s %s: %w", hostName, action, err)
+		}
+
+		state, err := outlet.Status(ctx)
+		if err != nil {
+			return fmt.Errorf("error reading the power state of %s: %w", hostName, err)
+		}
+		fmt.Printf("🔌 %s is %s (plug %d on %s)\n", hostName, state, plug, outlet.Addr)
+		return nil
+	},
+}
+
+func init() {
+	powerCmd.Flags().String("vault-password-file", "", "Path to the vault password file for decrypting variables")
+	powerCmd.Flags().Duration("cycle-delay", 5*time.Second, "Time the outlet is kept off when power cycling")
+	powerCmd.Flags().Duration("timeout", time.Minute, "Stop after this duration (i.e. 30s, 0 for no timeout)")
+
+	rootCmd.AddCommand(powerCmd)
+}
@@ -15,6 +15,8 @@ type Config struct {
 	BaseDir           string                            `yaml:"-"` // Not serialized, set programmatically
 	Loaded            *LoadedLabConfig                  `yaml:"-"` // Not serialized, used internally
 	ContainerVersions map[string]*container.ImageLabels `yaml:"-"` // Not serialized, container versions by image URL
+	// KnownHosts is the known_hosts file used to verify the exporter host keys, relative to BaseDir
+	KnownHosts string `yaml:"known_hosts"`
 }
 
 // Sources defines the paths for various configuration files.
@@ -28,6 +30,15 @@ type Sources struct {
 	JumpstarterInstances []string `yaml:"jumpstarter_instances"`
 }
 
+// KnownHostsFile returns the path of the known_hosts file used to verify the exporter host keys,
+// or an empty string if the configuration doesn't set one
+func (cfg *Config) KnownHostsFile() string {
+	if cfg.KnownHosts == "" || filepath.IsAbs(cfg.KnownHosts) {
+		return cfg.KnownHosts
+	}
+	return filepath.Join(cfg.BaseDir, cfg.KnownHosts)
+}
+
 // LoadConfig reads a YAML file from the given filePath and unmarshals it into a Config struct.
 func LoadConfig(filePath string, vaultPassFile string) (*Config, error) {
 	data, err := os.ReadFile(filePath)
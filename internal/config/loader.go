@@ -1,10 +1,12 @@
 package config
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
 	"reflect"
+	"slices"
 	"strings"
 
 	jsApi "github.com/jumpstarter-dev/jumpstarter-controller/api/v1alpha1"
@@ -45,6 +47,61 @@ func (cfg *LoadedLabConfig) GetPolicies() map[string]*jsApi.ExporterAccessPolicy
 	return cfg.Policies
 }
 
+// GetPoliciesForJumpstarterInstance returns the policies that must be synced to the given instance.
+// A policy listing instances in its jumpstarter.dev/jumpstarter-instances annotation only targets
+// those instances, otherwise a policy with a namespace only targets instances in that namespace,
+// and a policy without either targets every instance.
+func (cfg *LoadedLabConfig) GetPoliciesForJumpstarterInstance(instance *api.JumpstarterInstance) map[string]*jsApi.ExporterAccessPolicy {
+	policies := make(map[string]*jsApi.ExporterAccessPolicy)
+	for name, policy := range cfg.Policies {
+		if TargetsJumpstarterInstance(policy.ObjectMeta, instance) {
+			policies[name] = policy
+		}
+	}
+	return policies
+}
+
+// GetClientsForJumpstarterInstance returns the clients that must be synced to the given instance,
+// following the same targeting rules as GetPoliciesForJumpstarterInstance.
+func (cfg *LoadedLabConfig) GetClientsForJumpstarterInstance(instance *api.JumpstarterInstance) map[string]*jsApi.Client {
+	clients := make(map[string]*jsApi.Client)
+	for name, client := range cfg.Clients {
+		if TargetsJumpstarterInstance(client.ObjectMeta, instance) {
+			clients[name] = client
+		}
+	}
+	return clients
+}
+
+// JumpstarterInstanceTargets returns the instance names listed in the
+// jumpstarter.dev/jumpstarter-instances annotation, or nil if it is not set. An annotation
+// without any name returns an empty list, which targets no instance.
+func JumpstarterInstanceTargets(meta metav1.ObjectMeta) []string {
+	value, ok := meta.Annotations[api.JumpstarterInstancesAnnotation]
+	if !ok {
+		return nil
+	}
+	targets := []string{}
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			targets = append(targets, name)
+		}
+	}
+	return targets
+}
+
+// TargetsJumpstarterInstance reports whether an object from the configuration
+// should be synced to the given jumpstarter instance.
+func TargetsJumpstarterInstance(meta metav1.ObjectMeta, instance *api.JumpstarterInstance) bool {
+	if targets := JumpstarterInstanceTargets(meta); targets != nil {
+		return slices.Contains(targets, instance.Name)
+	}
+	if meta.Namespace != "" && instance.Spec.Namespace != "" {
+		return meta.Namespace == instance.Spec.Namespace
+	}
+	return true
+}
+
 func (cfg *LoadedLabConfig) GetPhysicalLocations() map[string]*api.PhysicalLocation {
 	return cfg.PhysicalLocations
 }
@@ -331,7 +388,7 @@ func retrieveContainerVersionsFromExporters(loaded *LoadedLabConfig) map[string]
 	for imageURL := range uniqueImages {
 		fmt.Printf("🔍 Checking container version for %s...\n", imageURL)
 
-		imageLabels, err := container.GetImageLabelsFromRegistry(imageURL)
+		imageLabels, err := container.GetImageLabelsFromRegistry(context.Background(), imageURL, container.DefaultArchitecture)
 		if err != nil {
 			fmt.Printf("Latest container version of %s: unavailable (%v)\n", imageURL, err)
 			containerVersions[imageURL] = &container.ImageLabels{} // Store empty labels
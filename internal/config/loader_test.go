@@ -21,6 +21,10 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	jsApi "github.com/jumpstarter-dev/jumpstarter-controller/api/v1alpha1"
+	api "github.com/jumpstarter-dev/jumpstarter-lab-config/api/v1alpha1"
 )
 
 const (
@@ -546,3 +550,69 @@ metadata:
 	assert.Equal(t, expected2, documents[1])
 	assert.Equal(t, expected3, documents[2])
 }
+
+func TestTargetsJumpstarterInstance(t *testing.T) {
+	instance := &api.JumpstarterInstance{
+		ObjectMeta: metav1.ObjectMeta{Name: "jump1"},
+		Spec:       api.JumpstarterInstanceSpec{Namespace: "lab"},
+	}
+
+	tests := []struct {
+		name     string
+		meta     metav1.ObjectMeta
+		expected bool
+	}{
+		{"no annotation and no namespace", metav1.ObjectMeta{}, true},
+		{"matching namespace", metav1.ObjectMeta{Namespace: "lab"}, true},
+		{"other namespace", metav1.ObjectMeta{Namespace: "other"}, false},
+		{"listed in annotation", metav1.ObjectMeta{
+			Annotations: map[string]string{api.JumpstarterInstancesAnnotation: "jump0, jump1"},
+		}, true},
+		{"not listed in annotation", metav1.ObjectMeta{
+			Annotations: map[string]string{api.JumpstarterInstancesAnnotation: "jump0"},
+		}, false},
+		{"annotation takes precedence over namespace", metav1.ObjectMeta{
+			Namespace:   "other",
+			Annotations: map[string]string{api.JumpstarterInstancesAnnotation: "jump1"},
+		}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, TargetsJumpstarterInstance(tt.meta, instance))
+		})
+	}
+}
+
+func TestJumpstarterInstanceTargets(t *testing.T) {
+	assert.Nil(t, JumpstarterInstanceTargets(metav1.ObjectMeta{}))
+	assert.Equal(t, []string{"a", "b"}, JumpstarterInstanceTargets(metav1.ObjectMeta{
+		Annotations: map[string]string{api.JumpstarterInstancesAnnotation: " a,,b "},
+	}))
+}
+
+func TestGetClientsForJumpstarterInstance(t *testing.T) {
+	cfg := &LoadedLabConfig{
+		Clients: map[string]*jsApi.Client{
+			"everywhere": {ObjectMeta: metav1.ObjectMeta{Name: "everywhere"}},
+			"partner": {ObjectMeta: metav1.ObjectMeta{
+				Name:        "partner",
+				Annotations: map[string]string{api.JumpstarterInstancesAnnotation: "partner-jump"},
+			}},
+		},
+	}
+
+	internal := &api.JumpstarterInstance{ObjectMeta: metav1.ObjectMeta{Name: "internal-jump"}}
+	partner := &api.JumpstarterInstance{ObjectMeta: metav1.ObjectMeta{Name: "partner-jump"}}
+
+	assert.ElementsMatch(t, []string{"everywhere"}, keys(cfg.GetClientsForJumpstarterInstance(internal)))
+	assert.ElementsMatch(t, []string{"everywhere", "partner"}, keys(cfg.GetClientsForJumpstarterInstance(partner)))
+}
+
+func keys[V any](m map[string]V) []string {
+	result := make([]string, 0, len(m))
+	for k := range m {
+		result = append(result, k)
+	}
+	return result
+}
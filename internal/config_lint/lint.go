@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"os"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	api "github.com/jumpstarter-dev/jumpstarter-lab-config/api/v1alpha1"
 	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/config"
 	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/exporter/template"
 )
@@ -141,6 +144,46 @@ func validateReferences(cfg *config.Config) map[string][]error {
 		}
 	}
 
+	// Validate the maintenance windows
+	for name, host := range cfg.Loaded.GetExporterHosts() {
+		for i := range host.Spec.MaintenanceWindows {
+			if err := host.Spec.MaintenanceWindows[i].Validate(); err != nil {
+				addError(getSourceFile("ExporterHost", name), fmt.Sprintf("ExporterHost %s has an invalid maintenance window: %v",
+					name, err))
+			}
+		}
+	}
+	for name, location := range cfg.Loaded.GetPhysicalLocations() {
+		for i := range location.Spec.MaintenanceWindows {
+			if err := location.Spec.MaintenanceWindows[i].Validate(); err != nil {
+				addError(getSourceFile("PhysicalLocation", name), fmt.Sprintf("PhysicalLocation %s has an invalid maintenance window: %v",
+					name, err))
+			}
+		}
+	}
+
+	// Validate the jumpstarter instance targets of clients and policies, an empty list would
+	// silently sync the object to no instance
+	validateTargets := func(kind, name string, meta metav1.ObjectMeta) {
+		targets := config.JumpstarterInstanceTargets(meta)
+		if targets != nil && len(targets) == 0 {
+			addError(getSourceFile(kind, name), fmt.Sprintf("%s %s has an empty %s annotation, it targets no jumpstarter instance",
+				kind, name, api.JumpstarterInstancesAnnotation))
+		}
+		for _, target := range targets {
+			if _, exists := cfg.Loaded.GetJumpstarterInstances()[target]; !exists {
+				addError(getSourceFile(kind, name), fmt.Sprintf("%s %s references non-existent jumpstarter instance %s",
+					kind, name, target))
+			}
+		}
+	}
+	for name, client := range cfg.Loaded.GetClients() {
+		validateTargets("Client", name, client.ObjectMeta)
+	}
+	for name, policy := range cfg.Loaded.GetPolicies() {
+		validateTargets("ExporterAccessPolicy", name, policy.ObjectMeta)
+	}
+
 	// Validate ExporterInstance references
 	for name, instance := range cfg.Loaded.GetExporterInstances() {
 		if instance == nil {
@@ -3,7 +3,9 @@ package config_lint
 import (
 	"testing"
 
+	jsApi "github.com/jumpstarter-dev/jumpstarter-controller/api/v1alpha1"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	v1alphaConfig "github.com/jumpstarter-dev/jumpstarter-lab-config/api/v1alpha1"
@@ -283,3 +285,150 @@ func TestValidateReferences_SkipsUnmanagedExporters(t *testing.T) {
 	errorsByFile := validateReferences(cfg)
 	assert.Empty(t, errorsByFile)
 }
+
+func TestValidateReferences_PolicyJumpstarterInstances(t *testing.T) {
+	cfg := &config.Config{
+		Loaded: &config.LoadedLabConfig{
+			Policies: map[string]*jsApi.ExporterAccessPolicy{
+				"valid-policy": {
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "valid-policy",
+						Annotations: map[string]string{
+							v1alphaConfig.JumpstarterInstancesAnnotation: "test-instance",
+						},
+					},
+				},
+				"invalid-policy": {
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "invalid-policy",
+						Annotations: map[string]string{
+							v1alphaConfig.JumpstarterInstancesAnnotation: "test-instance, missing-instance",
+						},
+					},
+				},
+			},
+			JumpstarterInstances: map[string]*v1alphaConfig.JumpstarterInstance{
+				"test-instance": {ObjectMeta: metav1.ObjectMeta{Name: "test-instance"}},
+			},
+			SourceFiles: map[string]map[string]string{
+				"ExporterAccessPolicy": {
+					"valid-policy":   "valid-policy.yaml",
+					"invalid-policy": "invalid-policy.yaml",
+				},
+			},
+		},
+	}
+
+	errorsByFile := validateReferences(cfg)
+	assert.Len(t, errorsByFile, 1)
+	assert.Len(t, errorsByFile["invalid-policy.yaml"], 1)
+	assert.Contains(t, errorsByFile["invalid-policy.yaml"][0].Error(), "missing-instance")
+}
+
+func TestValidateReferences_ClientJumpstarterInstances(t *testing.T) {
+	cfg := &config.Config{
+		Loaded: &config.LoadedLabConfig{
+			Clients: map[string]*jsApi.Client{
+				"partner-client": {
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "partner-client",
+						Annotations: map[string]string{
+							v1alphaConfig.JumpstarterInstancesAnnotation: "partner-instance",
+						},
+					},
+				},
+			},
+			JumpstarterInstances: map[string]*v1alphaConfig.JumpstarterInstance{
+				"test-instance": {ObjectMeta: metav1.ObjectMeta{Name: "test-instance"}},
+			},
+			SourceFiles: map[string]map[string]string{
+				"Client": {
+					"partner-client": "partner-client.yaml",
+				},
+			},
+		},
+	}
+
+	errorsByFile := validateReferences(cfg)
+	assert.Len(t, errorsByFile, 1)
+	assert.Len(t, errorsByFile["partner-client.yaml"], 1)
+	assert.Contains(t, errorsByFile["partner-client.yaml"][0].Error(),
+		"Client partner-client references non-existent jumpstarter instance partner-instance")
+}
+
+func TestValidateReferences_EmptyJumpstarterInstances(t *testing.T) {
+	cfg := &config.Config{
+		Loaded: &config.LoadedLabConfig{
+			Clients: map[string]*jsApi.Client{
+				"typo-client": {
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "typo-client",
+						Annotations: map[string]string{
+							v1alphaConfig.JumpstarterInstancesAnnotation: " , ",
+						},
+					},
+				},
+			},
+			Policies: map[string]*jsApi.ExporterAccessPolicy{
+				"empty-policy": {
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "empty-policy",
+						Annotations: map[string]string{
+							v1alphaConfig.JumpstarterInstancesAnnotation: "",
+						},
+					},
+				},
+			},
+			JumpstarterInstances: map[string]*v1alphaConfig.JumpstarterInstance{
+				"test-instance": {ObjectMeta: metav1.ObjectMeta{Name: "test-instance"}},
+			},
+			SourceFiles: map[string]map[string]string{
+				"Client":               {"typo-client": "typo-client.yaml"},
+				"ExporterAccessPolicy": {"empty-policy": "empty-policy.yaml"},
+			},
+		},
+	}
+
+	errorsByFile := validateReferences(cfg)
+	assert.Len(t, errorsByFile, 2)
+	require.Len(t, errorsByFile["typo-client.yaml"], 1)
+	assert.Contains(t, errorsByFile["typo-client.yaml"][0].Error(),
+		"Client typo-client has an empty jumpstarter.dev/jumpstarter-instances annotation")
+	require.Len(t, errorsByFile["empty-policy.yaml"], 1)
+	assert.Contains(t, errorsByFile["empty-policy.yaml"][0].Error(),
+		"ExporterAccessPolicy empty-policy has an empty jumpstarter.dev/jumpstarter-instances annotation")
+}
+
+func TestValidateReferences_MaintenanceWindows(t *testing.T) {
+	cfg := &config.Config{
+		Loaded: &config.LoadedLabConfig{
+			ExporterHosts: map[string]*v1alphaConfig.ExporterHost{
+				"sidekick-1": {
+					ObjectMeta: metav1.ObjectMeta{Name: "sidekick-1"},
+					Spec: v1alphaConfig.ExporterHostSpec{MaintenanceWindows: []v1alphaConfig.MaintenanceWindow{
+						{Days: []string{"Saturday"}, Start: "02:00", End: "06:00"},
+					}},
+				},
+			},
+			PhysicalLocations: map[string]*v1alphaConfig.PhysicalLocation{
+				"lab": {
+					ObjectMeta: metav1.ObjectMeta{Name: "lab"},
+					Spec: v1alphaConfig.PhysicalLocationSpec{MaintenanceWindows: []v1alphaConfig.MaintenanceWindow{
+						{Start: "22:00", End: "04:00", TimeZone: "Europe/Madrid"},
+					}},
+				},
+			},
+			SourceFiles: map[string]map[string]string{
+				"ExporterHost": {
+					"sidekick-1": "sidekick-1.yaml",
+				},
+			},
+		},
+	}
+
+	errorsByFile := validateReferences(cfg)
+	assert.Len(t, errorsByFile, 1)
+	assert.Len(t, errorsByFile["sidekick-1.yaml"], 1)
+	assert.Contains(t, errorsByFile["sidekick-1.yaml"][0].Error(),
+		`ExporterHost sidekick-1 has an invalid maintenance window: invalid day "Saturday"`)
+}
@@ -0,0 +1,483 @@
+package container
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultArchitecture is the architecture of the images inspected without one
+const DefaultArchitecture = "amd64"
+
+// Manifest media types accepted from the registries
+const (
+	mediaTypeOCIIndex        = "application/vnd.oci.image.index.v1+json"
+	mediaTypeOCIManifest     = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeDockerList      = "application/vnd.docker.distribution.manifest.list.v2+json"
+	mediaTypeDockerManifest  = "application/vnd.docker.distribution.manifest.v2+json"
+	maxManifestSize          = 4 << 20
+	dockerHubRegistry        = "docker.io"
+	dockerHubRegistryAPIHost = "registry-1.docker.io"
+)
+
+// ImageInfo describes an image of a registry for an architecture
+type ImageInfo struct {
+	// Digest is the digest of the manifest the image reference points to, an index for multi-arch images
+	Digest string
+	// ManifestDigest is the digest of the image manifest of the architecture
+	ManifestDigest string
+	Architecture   string
+	Labels         map[string]string
+}
+
+// Registry reads images from OCI registries, with the credentials of the standard auth files. The
+// images are cached, so every image is read once per run and architecture.
+type Registry struct {
+	// Client sends the registry requests
+	Client *http.Client
+	// AuthFiles are the containers auth files read for credentials, the first one with credentials
+	// for a registry wins
+	AuthFiles []string
+
+	mu     sync.Mutex
+	images map[string]*cachedImage
+	tokens map[string]string
+}
+
+// cachedImage is an image read once, concurrent readers wait for the first one
+type cachedImage struct {
+	done chan struct{}
+	info *ImageInfo
+	err  error
+}
+
+// NewRegistry returns a registry client reading the credentials from the standard auth files
+func NewRegistry() *Registry {
+	return &Registry{Client: &http.Client{Timeout: time.Minute}, AuthFiles: defaultAuthFiles()}
+}
+
+// DefaultRegistry is the registry client shared by the whole run
+var DefaultRegistry = NewRegistry()
+
+// defaultAuthFiles returns the auth files of podman, skopeo and docker, in their priority order
+func defaultAuthFiles() []string {
+	var files []string
+	if file := os.Getenv("REGISTRY_AUTH_FILE"); file != "" {
+		files = append(files, file)
+	}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		files = append(files, filepath.Join(dir, "containers", "auth.json"))
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		files = append(files, filepath.Join(home, ".config", "containers", "auth.json"),
+			filepath.Join(home, ".docker", "config.json"))
+	}
+	return files
+}
+
+// imageReference is a parsed image reference
+type imageReference struct {
+	// registry is the registry of the image as written in the auth files, host is its API host
+	registry   string
+	host       string
+	repository string
+	reference  string
+}
+
+// parseImageReference parses [registry/]repository[:tag][@digest], the images without registry
+// are on Docker Hub
+func parseImageReference(image string) (*imageReference, error) {
+	image = strings.TrimPrefix(strings.TrimSpace(image), "docker://")
+	if image == "" {
+		return nil, errors.New("empty image reference")
+	}
+	ref := &imageReference{reference: "latest"}
+	name := image
+	if at := strings.Index(image, "@"); at >= 0 {
+		name, ref.reference = image[:at], image[at+1:]
+	}
+	if colon := strings.LastIndex(name, ":"); colon > strings.LastIndex(name, "/") {
+		if !strings.Contains(image, "@") {
+			ref.reference = name[colon+1:]
+		}
+		name = name[:colon]
+	}
+
+	first, rest, found := strings.Cut(name, "/")
+	if found && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		ref.registry, ref.repository = first, rest
+	} else {
+		ref.registry, ref.repository = dockerHubRegistry, name
+	}
+	ref.host = ref.registry
+	if ref.registry == dockerHubRegistry {
+		ref.host = dockerHubRegistryAPIHost
+		if !strings.Contains(ref.repository, "/") {
+			ref.repository = "library/" + ref.repository
+		}
+	}
+	if ref.repository == "" || ref.reference == "" {
+		return nil, fmt.Errorf("invalid image reference %q", image)
+	}
+	return ref, nil
+}
+
+// Inspect returns the digests and labels of an image for an architecture, DefaultArchitecture when empty
+func (r *Registry) Inspect(ctx context.Context, image, architecture string) (*ImageInfo, error) {
+	if architecture == "" {
+		architecture = DefaultArchitecture
+	}
+	key := image + "|" + architecture
+
+	r.mu.Lock()
+	if r.images == nil {
+		r.images = make(map[string]*cachedImage)
+	}
+	cached, ok := r.images[key]
+	if !ok {
+		cached = &cachedImage{done: make(chan struct{})}
+		r.images[key] = cached
+	}
+	r.mu.Unlock()
+
+	if ok {
+		select {
+		case <-cached.done:
+			return cached.info, cached.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	cached.info, cached.err = r.inspect(ctx, image, architecture)
+	if cached.err != nil && ctx.Err() != nil {
+		// an interrupted read is not cached
+		r.mu.Lock()
+		delete(r.images, key)
+		r.mu.Unlock()
+	}
+	close(cached.done)
+	return cached.info, cached.err
+}
+
+// inspect reads an image from its registry
+func (r *Registry) inspect(ctx context.Context, image, architecture string) (*ImageInfo, error) {
+	ref, err := parseImageReference(image)
+	if err != nil {
+		return nil, err
+	}
+
+	body, mediaType, digest, err := r.manifest(ctx, ref, ref.reference)
+	if err != nil {
+		return nil, err
+	}
+	info := &ImageInfo{Digest: digest, ManifestDigest: digest, Architecture: architecture}
+
+	var manifest struct {
+		MediaType string `json:"mediaType"`
+		Config    struct {
+			Digest string `json:"digest"`
+		} `json:"config"`
+		Manifests []struct {
+			Digest   string `json:"digest"`
+			Platform struct {
+				OS           string `json:"os"`
+				Architecture string `json:"architecture"`
+			} `json:"platform"`
+		} `json:"manifests"`
+	}
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse the manifest of %s: %w", image, err)
+	}
+	if mediaType == mediaTypeOCIIndex || mediaType == mediaTypeDockerList || len(manifest.Manifests) > 0 {
+		platformDigest := ""
+		for _, m := range manifest.Manifests {
+			if m.Platform.OS == "linux" && m.Platform.Architecture == architecture {
+				platformDigest = m.Digest
+				break
+			}
+		}
+		if platformDigest == "" {
+			return nil, fmt.Errorf("image %s has no linux/%s manifest", image, architecture)
+		}
+		if body, _, info.ManifestDigest, err = r.manifest(ctx, ref, platformDigest); err != nil {
+			return nil, err
+		}
+		manifest.Config.Digest = ""
+		if err := json.Unmarshal(body, &manifest); err != nil {
+			return nil, fmt.Errorf("failed to parse the %s manifest of %s: %w", architecture, image, err)
+		}
+	}
+	if manifest.Config.Digest == "" {
+		return nil, fmt.Errorf("the manifest of %s has no config", image)
+	}
+
+	blob, err := r.get(ctx, ref, "/blobs/"+manifest.Config.Digest, "")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = blob.Body.Close()
+	}()
+	config, err := io.ReadAll(io.LimitReader(blob.Body, maxManifestSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the config of %s: %w", image, err)
+	}
+	if err := verifyDigest(manifest.Config.Digest, config); err != nil {
+		return nil, fmt.Errorf("config of %s: %w", image, err)
+	}
+	var imageConfig struct {
+		Architecture string `json:"architecture"`
+		Config       struct {
+			Labels map[string]string `json:"Labels"`
+		} `json:"config"`
+	}
+	if err := json.Unmarshal(config, &imageConfig); err != nil {
+		return nil, fmt.Errorf("failed to parse the config of %s: %w", image, err)
+	}
+	if imageConfig.Architecture != "" {
+		info.Architecture = imageConfig.Architecture
+	}
+	info.Labels = imageConfig.Config.Labels
+	return info, nil
+}
+
+// manifest returns a manifest with its media type and digest
+func (r *Registry) manifest(ctx context.Context, ref *imageReference, reference string) ([]byte, string, string, error) {
+	accept := strings.Join([]string{mediaTypeOCIIndex, mediaTypeDockerList, mediaTypeOCIManifest, mediaTypeDockerManifest}, ", ")
+	resp, err := r.get(ctx, ref, "/manifests/"+reference, accept)
+	if err != nil {
+		return nil, "", "", err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxManifestSize))
+	if err != nil {
+		return nil, "", "", fmt.Errorf("failed to read the manifest %s of %s: %w", reference, ref.repository, err)
+	}
+	digest := "sha256:" + sha256Hex(body)
+	if strings.HasPrefix(reference, "sha256:") {
+		if err := verifyDigest(reference, body); err != nil {
+			return nil, "", "", fmt.Errorf("manifest of %s: %w", ref.repository, err)
+		}
+	}
+	mediaType, _, _ := strings.Cut(resp.Header.Get("Content-Type"), ";")
+	return body, strings.TrimSpace(mediaType), digest, nil
+}
+
+// get sends a GET request to the repository API of an image, authenticating when the registry asks for it
+func (r *Registry) get(ctx context.Context, ref *imageReference, path, accept string) (*http.Response, error) {
+	endpoint := "https://" + ref.host + "/v2/" + ref.repository + path
+	send := func(authorization string) (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		resp, err := r.Client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to reach the registry %s: %w", ref.registry, err)
+		}
+		return resp, nil
+	}
+
+	tokenKey := ref.host + "/" + ref.repository
+	r.mu.Lock()
+	authorization := r.tokens[tokenKey]
+	r.mu.Unlock()
+	resp, err := send(authorization)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		_ = resp.Body.Close()
+		if authorization, err = r.authorize(ctx, ref, challenge); err != nil {
+			return nil, err
+		}
+		r.mu.Lock()
+		if r.tokens == nil {
+			r.tokens = make(map[string]string)
+		}
+		r.tokens[tokenKey] = authorization
+		r.mu.Unlock()
+		if resp, err = send(authorization); err != nil {
+			return nil, err
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("registry %s answered %s for %s%s", ref.registry, resp.Status, ref.repository, path)
+	}
+	return resp, nil
+}
+
+// authorize answers the authentication challenge of a registry, with a basic authorization or a
+// bearer token for pulling the repository
+func (r *Registry) authorize(ctx context.Context, ref *imageReference, challenge string) (string, error) {
+	user, password, err := r.credentials(ref)
+	if err != nil {
+		return "", err
+	}
+	scheme, params := parseChallenge(challenge)
+	switch scheme {
+	case "basic":
+		if user == "" {
+			return "", fmt.Errorf("registry %s requires credentials, none found in %s", ref.registry,
+				strings.Join(r.AuthFiles, ", "))
+		}
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password)), nil
+	case "bearer":
+	default:
+		return "", fmt.Errorf("unsupported authentication challenge %q from registry %s", challenge, ref.registry)
+	}
+
+	realm, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return "", fmt.Errorf("invalid token realm %q from registry %s", params["realm"], ref.registry)
+	}
+	query := realm.Query()
+	if service := params["service"]; service != "" {
+		query.Set("service", service)
+	}
+	query.Set("scope", "repository:"+ref.repository+":pull")
+	realm.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if user != "" {
+		req.SetBasicAuth(user, password)
+	}
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to get a token from %s: %w", realm.Host, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token server %s answered %s for %s", realm.Host, resp.Status, ref.repository)
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxManifestSize)).Decode(&token); err != nil {
+		return "", fmt.Errorf("failed to parse the token from %s: %w", realm.Host, err)
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	if token.Token == "" {
+		return "", fmt.Errorf("no token from %s", realm.Host)
+	}
+	return "Bearer " + token.Token, nil
+}
+
+// parseChallenge parses a WWW-Authenticate header into its lowercase scheme and its parameters
+func parseChallenge(challenge string) (string, map[string]string) {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(challenge), " ")
+	params := make(map[string]string)
+	for rest = strings.TrimSpace(rest); rest != ""; {
+		key, value, found := strings.Cut(rest, "=")
+		if !found {
+			break
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		if strings.HasPrefix(value, `"`) {
+			end := strings.Index(value[1:], `"`)
+			if end < 0 {
+				break
+			}
+			params[key], rest = value[1:end+1], value[end+2:]
+		} else {
+			params[key], rest, _ = strings.Cut(value, ",")
+		}
+		rest = strings.TrimLeft(rest, ", ")
+	}
+	return strings.ToLower(scheme), params
+}
+
+// credentials returns the credentials of the repository of an image from the first auth file with
+// credentials for it, the most specific entry of a file wins (quay.io/org/repo, quay.io/org, quay.io)
+func (r *Registry) credentials(ref *imageReference) (string, string, error) {
+	keys := []string{ref.registry + "/" + ref.repository}
+	for path := ref.repository; strings.Contains(path, "/"); {
+		path = path[:strings.LastIndex(path, "/")]
+		keys = append(keys, ref.registry+"/"+path)
+	}
+	keys = append(keys, ref.registry, "https://"+ref.registry, "http://"+ref.registry)
+	if ref.registry == dockerHubRegistry {
+		keys = append(keys, "https://index.docker.io/v1/", "index.docker.io")
+	}
+
+	for _, file := range r.AuthFiles {
+		data, err := os.ReadFile(file)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return "", "", fmt.Errorf("failed to read the auth file %s: %w", file, err)
+		}
+		var authFile struct {
+			Auths map[string]struct {
+				Auth string `json:"auth"`
+			} `json:"auths"`
+		}
+		if err := json.Unmarshal(data, &authFile); err != nil {
+			return "", "", fmt.Errorf("failed to parse the auth file %s: %w", file, err)
+		}
+		for _, key := range keys {
+			entry, ok := authFile.Auths[key]
+			if !ok || entry.Auth == "" {
+				continue
+			}
+			decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+			if err != nil {
+				return "", "", fmt.Errorf("invalid auth of %s in %s: %w", key, file, err)
+			}
+			user, password, found := strings.Cut(string(decoded), ":")
+			if !found {
+				return "", "", fmt.Errorf("invalid auth of %s in %s", key, file)
+			}
+			return user, password, nil
+		}
+	}
+	return "", "", nil
+}
+
+// verifyDigest checks the sha256 digest of a content
+func verifyDigest(digest string, content []byte) error {
+	algorithm, expected, _ := strings.Cut(digest, ":")
+	if algorithm != "sha256" {
+		return fmt.Errorf("unsupported digest %s", digest)
+	}
+	if sha256Hex(content) != expected {
+		return fmt.Errorf("content doesn't match its digest %s", digest)
+	}
+	return nil
+}
+
+func sha256Hex(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,202 @@
+package container
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testRegistry is an in-process registry serving a multi-arch image, its API requires a bearer
+// token issued for the credentials admin:secret
+type testRegistry struct {
+	server    *httptest.Server
+	host      string
+	manifests atomic.Int32
+	tokens    atomic.Int32
+	// content maps the manifest references and the blob digests to their content
+	content map[string][]byte
+	// indexDigest is the digest of the multi-arch index
+	indexDigest string
+}
+
+func (r *testRegistry) add(content []byte) string {
+	digest := "sha256:" + sha256Hex(content)
+	r.content[digest] = content
+	return digest
+}
+
+func startTestRegistry(t *testing.T) *testRegistry {
+	t.Helper()
+	r := &testRegistry{content: make(map[string][]byte)}
+
+	platforms := map[string]string{}
+	for _, arch := range []string{"amd64", "arm64"} {
+		config, err := json.Marshal(map[string]any{"architecture": arch, "os": "linux", "config": map[string]any{
+			"Labels": map[string]string{"jumpstarter.version": "0.7.1-" + arch, "jumpstarter.revision": "abc123"}}})
+		require.NoError(t, err)
+		manifest, err := json.Marshal(map[string]any{"schemaVersion": 2, "mediaType": mediaTypeOCIManifest,
+			"config": map[string]any{"mediaType": "application/vnd.oci.image.config.v1+json", "digest": r.add(config)}})
+		require.NoError(t, err)
+		platforms[arch] = r.add(manifest)
+	}
+	index, err := json.Marshal(map[string]any{"schemaVersion": 2, "mediaType": mediaTypeOCIIndex, "manifests": []any{
+		map[string]any{"digest": platforms["amd64"], "platform": map[string]string{"os": "linux", "architecture": "amd64"}},
+		map[string]any{"digest": platforms["arm64"], "platform": map[string]string{"os": "linux", "architecture": "arm64"}},
+	}})
+	require.NoError(t, err)
+	r.indexDigest = r.add(index)
+	r.content["0.7.1"] = index
+
+	r.server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/token" {
+			user, password, ok := req.BasicAuth()
+			if !ok || user != "admin" || password != "secret" ||
+				req.URL.Query().Get("scope") != "repository:lab/exporter:pull" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			r.tokens.Add(1)
+			_, _ = w.Write([]byte(`{"token":"test-token"}`))
+			return
+		}
+		if req.Header.Get("Authorization") != "Bearer test-token" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+r.server.URL+`/token",service="test-registry"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		path, ok := strings.CutPrefix(req.URL.Path, "/v2/lab/exporter/")
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		kind, reference, _ := strings.Cut(path, "/")
+		content, ok := r.content[reference]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if kind == "manifests" {
+			r.manifests.Add(1)
+			var manifest struct {
+				MediaType string `json:"mediaType"`
+			}
+			_ = json.Unmarshal(content, &manifest)
+			w.Header().Set("Content-Type", manifest.MediaType)
+		}
+		_, _ = w.Write(content)
+	}))
+	t.Cleanup(r.server.Close)
+	r.host = strings.TrimPrefix(r.server.URL, "https://")
+	return r
+}
+
+// writeAuthFile writes a containers auth file with the credentials of the registries
+func writeAuthFile(t *testing.T, credentials map[string]string) string {
+	t.Helper()
+	auths := make(map[string]any)
+	for registry, userPassword := range credentials {
+		auths[registry] = map[string]string{"auth": base64.StdEncoding.EncodeToString([]byte(userPassword))}
+	}
+	data, err := json.Marshal(map[string]any{"auths": auths})
+	require.NoError(t, err)
+	file := filepath.Join(t.TempDir(), "auth.json")
+	require.NoError(t, os.WriteFile(file, data, 0600))
+	return file
+}
+
+func TestRegistryInspect(t *testing.T) {
+	ctx := context.Background()
+	r := startTestRegistry(t)
+	registry := &Registry{Client: r.server.Client(), AuthFiles: []string{
+		filepath.Join(t.TempDir(), "missing.json"),
+		writeAuthFile(t, map[string]string{r.host: "admin:secret"}),
+	}}
+	image := r.host + "/lab/exporter:0.7.1"
+
+	info, err := registry.Inspect(ctx, image, "")
+	require.NoError(t, err)
+	assert.Equal(t, r.indexDigest, info.Digest)
+	assert.NotEqual(t, info.Digest, info.ManifestDigest)
+	assert.Equal(t, "amd64", info.Architecture)
+	assert.Equal(t, &ImageLabels{Version: "0.7.1-amd64", Revision: "abc123"}, labelsFromImage(info.Labels))
+
+	info, err = registry.Inspect(ctx, image, "arm64")
+	require.NoError(t, err)
+	assert.Equal(t, "0.7.1-arm64", info.Labels["jumpstarter.version"])
+	assert.Equal(t, int32(4), r.manifests.Load())
+	assert.Equal(t, int32(1), r.tokens.Load(), "the token of the repository is reused")
+
+	_, err = registry.Inspect(ctx, image, "arm64")
+	require.NoError(t, err)
+	assert.Equal(t, int32(4), r.manifests.Load(), "the images are cached")
+
+	info, err = registry.Inspect(ctx, r.host+"/lab/exporter@"+r.indexDigest, "amd64")
+	require.NoError(t, err)
+	assert.Equal(t, r.indexDigest, info.Digest)
+
+	_, err = registry.Inspect(ctx, image, "s390x")
+	require.ErrorContains(t, err, "has no linux/s390x manifest")
+	_, err = registry.Inspect(ctx, r.host+"/lab/exporter:missing", "amd64")
+	require.ErrorContains(t, err, "404 Not Found")
+}
+
+func TestRegistryInspect_Credentials(t *testing.T) {
+	r := startTestRegistry(t)
+	image := r.host + "/lab/exporter:0.7.1"
+
+	registry := &Registry{Client: r.server.Client()}
+	_, err := registry.Inspect(context.Background(), image, "")
+	require.ErrorContains(t, err, "token server "+r.host+" answered 401 Unauthorized")
+
+	registry = &Registry{Client: r.server.Client(), AuthFiles: []string{writeAuthFile(t, map[string]string{
+		r.host:              "admin:wrong",
+		r.host + "/lab":     "admin:secret",
+		r.host + "/lab/foo": "admin:other",
+	})}}
+	_, err = registry.Inspect(context.Background(), image, "")
+	require.NoError(t, err, "the most specific credentials are used")
+}
+
+func TestParseImageReference(t *testing.T) {
+	tests := []struct {
+		image string
+		want  imageReference
+	}{
+		{"quay.io/jumpstarter-dev/jumpstarter:0.7.1",
+			imageReference{"quay.io", "quay.io", "jumpstarter-dev/jumpstarter", "0.7.1"}},
+		{"docker://quay.io/jumpstarter-dev/jumpstarter",
+			imageReference{"quay.io", "quay.io", "jumpstarter-dev/jumpstarter", "latest"}},
+		{"localhost:5000/exporter:dev", imageReference{"localhost:5000", "localhost:5000", "exporter", "dev"}},
+		{"fedora:42", imageReference{"docker.io", "registry-1.docker.io", "library/fedora", "42"}},
+		{"quay.io/lab/exporter:1.0@sha256:0123",
+			imageReference{"quay.io", "quay.io", "lab/exporter", "sha256:0123"}},
+	}
+	for _, tt := range tests {
+		got, err := parseImageReference(tt.image)
+		require.NoError(t, err, tt.image)
+		assert.Equal(t, tt.want, *got, tt.image)
+	}
+	_, err := parseImageReference("")
+	assert.Error(t, err)
+}
+
+func TestParseChallenge(t *testing.T) {
+	scheme, params := parseChallenge(`Bearer realm="https://quay.io/v2/auth",service="quay.io",scope="repository:a/b:pull"`)
+	assert.Equal(t, "bearer", scheme)
+	assert.Equal(t, map[string]string{"realm": "https://quay.io/v2/auth", "service": "quay.io",
+		"scope": "repository:a/b:pull"}, params)
+
+	scheme, params = parseChallenge(`Basic realm="Registry"`)
+	assert.Equal(t, "basic", scheme)
+	assert.Equal(t, "Registry", params["realm"])
+}
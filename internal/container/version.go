@@ -1,7 +1,7 @@
 package container
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"os/exec"
 	"strings"
@@ -13,30 +13,23 @@ type ImageLabels struct {
 	Revision string
 }
 
-// GetImageLabelsFromRegistry retrieves image labels from a registry using skopeo
-func GetImageLabelsFromRegistry(imageURL string) (*ImageLabels, error) {
-	// Always add the docker:// prefix for skopeo
-	imageURL = "docker://" + imageURL
-
-	cmd := exec.Command("skopeo", "inspect", "--override-os", "linux", "--override-arch", "amd64", imageURL)
-	output, err := cmd.Output()
+// GetImageLabelsFromRegistry retrieves the labels of an image for an architecture from its registry,
+// DefaultArchitecture when empty
+func GetImageLabelsFromRegistry(ctx context.Context, imageURL, architecture string) (*ImageLabels, error) {
+	info, err := DefaultRegistry.Inspect(ctx, imageURL, architecture)
 	if err != nil {
-		return nil, fmt.Errorf("failed to inspect image %s with skopeo: %w", imageURL, err)
-	}
-
-	var imageInfo struct {
-		Labels map[string]string `json:"Labels"`
-	}
-
-	if err := json.Unmarshal(output, &imageInfo); err != nil {
-		return nil, fmt.Errorf("failed to parse skopeo output: %w", err)
+		return nil, fmt.Errorf("failed to inspect image %s: %w", imageURL, err)
 	}
+	return labelsFromImage(info.Labels), nil
+}
 
+// labelsFromImage returns the version labels of an image
+func labelsFromImage(labels map[string]string) *ImageLabels {
 	// Get both label sets
-	jumpstarterVersion := imageInfo.Labels["jumpstarter.version"]
-	jumpstarterRevision := imageInfo.Labels["jumpstarter.revision"]
-	ociVersion := imageInfo.Labels["org.opencontainers.image.version"]
-	ociRevision := imageInfo.Labels["org.opencontainers.image.revision"]
+	jumpstarterVersion := labels["jumpstarter.version"]
+	jumpstarterRevision := labels["jumpstarter.revision"]
+	ociVersion := labels["org.opencontainers.image.version"]
+	ociRevision := labels["org.opencontainers.image.revision"]
 
 	// Use jumpstarter labels if both exist, otherwise fall back to OCI labels
 	var version, revision string
@@ -53,7 +46,7 @@ func GetImageLabelsFromRegistry(imageURL string) (*ImageLabels, error) {
 	return &ImageLabels{
 		Version:  version,
 		Revision: revision,
-	}, nil
+	}
 }
 
 // GetRunningContainerLabels retrieves labels from a running container using podman inspect
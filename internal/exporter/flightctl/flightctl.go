@@ -0,0 +1,556 @@
+package flightctl
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/api/v1alpha1"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/exporter/manager"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/plan"
+)
+
+const (
+	flightctlAPIVersion = "flightctl.io/v1alpha1"
+	// flightctlManagedPrefix prefixes the device annotations listing the managed exporters,
+	// the annotation of an exporter service holds the name of its ExporterInstance
+	flightctlManagedPrefix = "exporters.jumpstarter.dev/"
+)
+
+// flightctlDevice is a flightctl Device spec, kept as a generic document so the fields which
+// aren't rendered for the exporters are written back unchanged. Only the config providers, the
+// systemd match patterns and the managed annotations of the exporters, and the bootc image, are changed.
+type flightctlDevice map[string]any
+
+// flightctlConfig is an inline config provider, holding the files of an exporter
+type flightctlConfig struct {
+	Name   string          `yaml:"name"`
+	Inline []flightctlFile `yaml:"inline"`
+}
+
+type flightctlFile struct {
+	Path    string `yaml:"path"`
+	Content string `yaml:"content"`
+	Mode    int    `yaml:"mode,omitempty"`
+	User    string `yaml:"user,omitempty"`
+	Group   string `yaml:"group,omitempty"`
+}
+
+// Manager renders the exporters of a host in the spec of its flightctl device instead
+// of deploying them, the spec is applied with `flightctl apply -f`. The flightctl agent then writes
+// the files and reports the exporter services, it owns the services and the bootc image.
+type Manager struct {
+	ExporterHost *v1alpha1.ExporterHost
+	deviceID     string
+	specFile     string
+	writer       io.Writer
+	// recorder receives the exporter changes, nil unless a plan is being made or checked
+	recorder *plan.Recorder
+	// secrets are masked in the hashes of the recorded exporter changes
+	secrets []string
+}
+
+// New creates the manager of an exporter host rendered in a flightctl device spec, a relative
+// spec file is resolved against configDir, the directory of the configuration file
+func New(exporterHost *v1alpha1.ExporterHost, configDir string) (*Manager, error) {
+	management := exporterHost.Spec.Management.Flightctl
+	if management.DeviceID == "" {
+		return nil, fmt.Errorf("flightctl device id of %q is not set", exporterHost.Name)
+	}
+	specFile := management.SpecFile
+	if specFile == "" {
+		specFile = filepath.Join("flightctl", management.DeviceID+".yaml")
+	}
+	if !filepath.IsAbs(specFile) {
+		specFile = filepath.Join(configDir, specFile)
+	}
+	return &Manager{ExporterHost: exporterHost, deviceID: management.DeviceID,
+		specFile: specFile, writer: os.Stdout}, nil
+}
+
+func (m *Manager) Status() (string, error) {
+	if _, err := m.readDevice(); err != nil {
+		return "", err
+	}
+	return "ok", nil
+}
+
+// readDevice reads the device spec file, a missing file is an empty device
+func (m *Manager) readDevice() (flightctlDevice, error) {
+	content, err := os.ReadFile(m.specFile)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read %s: %w", m.specFile, err)
+	}
+	// decoded in a plain map, yaml.v3 would decode the nested objects in flightctlDevice
+	var doc map[string]any
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", m.specFile, err)
+	}
+	if doc == nil {
+		doc = make(map[string]any)
+	}
+	device := flightctlDevice(doc)
+	if _, ok := device["apiVersion"]; !ok {
+		device["apiVersion"] = flightctlAPIVersion
+	}
+	if _, ok := device["kind"]; !ok {
+		device["kind"] = "Device"
+	}
+	if metadata := object(device, "metadata", true); metadata["name"] == nil {
+		metadata["name"] = m.deviceID
+	}
+	return device, nil
+}
+
+// writeDevice replaces the device spec file, readable only by the current user since it holds the
+// exporter tokens
+func (m *Manager) writeDevice(device flightctlDevice) error {
+	content, err := yaml.Marshal(device)
+	if err != nil {
+		return fmt.Errorf("failed to marshal the device spec: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(m.specFile), 0o755); err != nil {
+		return fmt.Errorf("failed to create parent directories for %s: %w", m.specFile, err)
+	}
+	file, err := os.CreateTemp(filepath.Dir(m.specFile), "."+filepath.Base(m.specFile)+".tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file for %s: %w", m.specFile, err)
+	}
+	_, err = file.Write(content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), m.specFile)
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+		return fmt.Errorf("failed to write %s: %w", m.specFile, err)
+	}
+	return nil
+}
+
+// flightctlExporterConfig returns the inline config provider of an exporter, its files are owned
+// by root unless the template declares another owner
+func flightctlExporterConfig(exporterConfig *v1alpha1.ExporterConfigTemplate) (flightctlConfig, error) {
+	svcName := exporterConfig.Spec.ExporterMetadata.Name
+	containerSystemdFile, serviceSystemdFile, exporterConfigFile := manager.ExporterFiles(svcName)
+	fileAttrs, err := manager.TemplateFileAttrs(exporterConfig)
+	if err != nil {
+		return flightctlConfig{}, err
+	}
+	config := flightctlConfig{Name: svcName}
+	for _, file := range []struct{ path, content string }{
+		{containerSystemdFile, exporterConfig.Spec.SystemdContainerTemplate},
+		{serviceSystemdFile, exporterConfig.Spec.SystemdServiceTemplate},
+		{exporterConfigFile, exporterConfig.Spec.ConfigTemplate},
+	} {
+		if file.content == "" {
+			continue
+		}
+		attrs := fileAttrs[file.path]
+		config.Inline = append(config.Inline, flightctlFile{Path: file.path, Content: file.content,
+			Mode: int(attrs.Mode), User: flightctlOwner(attrs.UID), Group: flightctlOwner(attrs.GID)})
+	}
+	return config, nil
+}
+
+// flightctlOwner returns the owner or group of a flightctl file, root when it isn't declared
+func flightctlOwner(id int) string {
+	if id == manager.KeepOwner {
+		return "root"
+	}
+	return strconv.Itoa(id)
+}
+
+// object returns the object under key of parent, it is created when missing and create is set,
+// nil otherwise
+func object(parent map[string]any, key string, create bool) map[string]any {
+	if obj, ok := parent[key].(map[string]any); ok {
+		return obj
+	}
+	if !create {
+		return nil
+	}
+	obj := make(map[string]any)
+	parent[key] = obj
+	return obj
+}
+
+// toObject converts v to the generic representation of the YAML documents
+func toObject(v any) (map[string]any, error) {
+	content, err := yaml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var obj map[string]any
+	if err := yaml.Unmarshal(content, &obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// configs returns the config providers of the device
+func (d flightctlDevice) configs() []any {
+	configs, _ := object(d, "spec", true)["config"].([]any)
+	return configs
+}
+
+// configIndex returns the index of the config provider of an exporter in the device, or -1
+func (d flightctlDevice) configIndex(svcName string) int {
+	return slices.IndexFunc(d.configs(), func(c any) bool {
+		config, ok := c.(map[string]any)
+		return ok && config["name"] == svcName
+	})
+}
+
+// matchPatterns returns the systemd units reported by the flightctl agent
+func (d flightctlDevice) matchPatterns() []string {
+	var patterns []string
+	if systemd := object(object(d, "spec", true), "systemd", false); systemd != nil {
+		values, _ := systemd["matchPatterns"].([]any)
+		for _, pattern := range values {
+			if pattern, ok := pattern.(string); ok {
+				patterns = append(patterns, pattern)
+			}
+		}
+	}
+	return patterns
+}
+
+// setMatchPatterns replaces the systemd units reported by the flightctl agent
+func (d flightctlDevice) setMatchPatterns(patterns []string) {
+	spec := object(d, "spec", true)
+	if len(patterns) == 0 {
+		if systemd := object(spec, "systemd", false); systemd != nil {
+			delete(systemd, "matchPatterns")
+		}
+		return
+	}
+	values := make([]any, 0, len(patterns))
+	for _, pattern := range patterns {
+		values = append(values, pattern)
+	}
+	object(spec, "systemd", true)["matchPatterns"] = values
+}
+
+// setExporter adds or replaces the config provider of an exporter and watches its service
+func (d flightctlDevice) setExporter(config flightctlConfig) error {
+	obj, err := toObject(config)
+	if err != nil {
+		return fmt.Errorf("failed to render the config of %s: %w", config.Name, err)
+	}
+	configs := d.configs()
+	if idx := d.configIndex(config.Name); idx >= 0 {
+		configs[idx] = obj
+	} else {
+		configs = append(configs, obj)
+	}
+	object(d, "spec", true)["config"] = configs
+	if patterns, pattern := d.matchPatterns(), config.Name+".service"; !slices.Contains(patterns, pattern) {
+		patterns = append(patterns, pattern)
+		slices.Sort(patterns)
+		d.setMatchPatterns(patterns)
+	}
+	return nil
+}
+
+// removeExporter removes the config provider, service and managed annotation of an exporter
+func (d flightctlDevice) removeExporter(svcName string) {
+	spec := object(d, "spec", true)
+	if idx := d.configIndex(svcName); idx >= 0 {
+		spec["config"] = slices.Delete(d.configs(), idx, idx+1)
+	}
+	d.setMatchPatterns(slices.DeleteFunc(d.matchPatterns(),
+		func(pattern string) bool { return pattern == svcName+".service" }))
+	delete(d.annotations(false), flightctlManagedPrefix+svcName)
+}
+
+// marshalConfig returns the YAML of the config provider of an exporter, empty when it is missing
+func (d flightctlDevice) marshalConfig(svcName string) (string, error) {
+	idx := d.configIndex(svcName)
+	if idx < 0 {
+		return "", nil
+	}
+	content, err := yaml.Marshal(d.configs()[idx])
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal the config of %s: %w", svcName, err)
+	}
+	return string(content), nil
+}
+
+// annotations returns the annotations of the device, nil when it has none and create isn't set
+func (d flightctlDevice) annotations(create bool) map[string]any {
+	return object(object(d, "metadata", true), "annotations", create)
+}
+
+// image returns the bootc image of the device spec, empty when it isn't set
+func (d flightctlDevice) image() string {
+	image, _ := object(object(d, "spec", true), "os", false)["image"].(string)
+	return image
+}
+
+// setImage sets the bootc image of the device spec
+func (d flightctlDevice) setImage(image string) {
+	object(object(d, "spec", true), "os", true)["image"] = image
+}
+
+// NeedsUpdate checks whether Apply would change the device spec for exporterConfig
+func (m *Manager) NeedsUpdate(exporterConfig *v1alpha1.ExporterConfigTemplate) (bool, error) {
+	diff, err := m.Diff(exporterConfig)
+	if err != nil {
+		return false, err
+	}
+	return diff != "", nil
+}
+
+// Diff returns the unified diff of the config of the exporter in the device spec, empty when it is
+// up to date, the secrets are masked
+func (m *Manager) Diff(exporterConfig *v1alpha1.ExporterConfigTemplate) (string, error) {
+	existing, desired, _, err := m.renderExporter(exporterConfig)
+	if err != nil {
+		return "", err
+	}
+	diff, err := manager.UnifiedDiff(m.specFile+"#"+exporterConfig.Spec.ExporterMetadata.Name, existing, desired)
+	if err != nil {
+		return "", err
+	}
+	return manager.SanitizeDiff(diff), nil
+}
+
+// renderExporter returns the existing and desired config of an exporter, and the device with the desired one
+func (m *Manager) renderExporter(exporterConfig *v1alpha1.ExporterConfigTemplate) (string, string, flightctlDevice, error) {
+	svcName := exporterConfig.Spec.ExporterMetadata.Name
+	device, err := m.readDevice()
+	if err != nil {
+		return "", "", nil, err
+	}
+	existing, err := device.marshalConfig(svcName)
+	if err != nil {
+		return "", "", nil, err
+	}
+	config, err := flightctlExporterConfig(exporterConfig)
+	if err != nil {
+		return "", "", nil, err
+	}
+	if err := device.setExporter(config); err != nil {
+		return "", "", nil, err
+	}
+	desired, err := device.marshalConfig(svcName)
+	if err != nil {
+		return "", "", nil, err
+	}
+	return existing, desired, device, nil
+}
+
+// Apply renders the exporter in the device spec, the flightctl agent restarts it once the spec is applied
+func (m *Manager) Apply(exporterConfig *v1alpha1.ExporterConfigTemplate, dryRun bool) error {
+	if exporterConfig.Spec.SystemdContainerTemplate != "" && exporterConfig.Spec.SystemdServiceTemplate != "" {
+		return fmt.Errorf("both SystemdContainerTemplate and SystemdServiceTemplate specified - only one should be used")
+	}
+	svcName := exporterConfig.Spec.ExporterMetadata.Name
+	existing, desired, device, err := m.renderExporter(exporterConfig)
+	if err != nil {
+		return err
+	}
+	if existing == desired {
+		return nil
+	}
+
+	change := plan.Change{Kind: "flightctl-config", Name: svcName, Action: plan.ActionUpdate,
+		After: plan.HashMasked(desired, m.secrets)}
+	if existing == "" {
+		change.Action = plan.ActionCreate
+	} else {
+		change.Before = plan.HashMasked(existing, m.secrets)
+	}
+	if err := m.record(change); err != nil {
+		return err
+	}
+	if dryRun {
+		_, _ = fmt.Fprintf(m.writer, "        📄 Would render %s in the flightctl device spec %s\n", svcName, m.specFile)
+		return nil
+	}
+	if err := m.writeDevice(device); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(m.writer, "        ✏️ Rendered %s in the flightctl device spec %s\n", svcName, m.specFile)
+	return nil
+}
+
+// VerifyExporter doesn't check anything, the exporter is deployed by the flightctl agent
+func (m *Manager) VerifyExporter(*v1alpha1.ExporterConfigTemplate, time.Duration, manager.OnlineCheck) error {
+	return nil
+}
+
+func (m *Manager) RunHostCommand(command string) (*manager.CommandResult, error) {
+	return nil, fmt.Errorf("the flightctl backend of %q doesn't run commands", m.ExporterHost.Name)
+}
+
+// GetBootcStatus reports the host as not managed, the bootc image is managed by flightctl
+func (m *Manager) GetBootcStatus() manager.BootcStatus {
+	return manager.BOOTC_NOT_MANAGED
+}
+
+// BootcUpdating reports no update in progress, the bootc image is updated by the flightctl agent
+func (m *Manager) BootcUpdating() bool {
+	return false
+}
+
+// BootcImages returns the image of the device spec as the staged image, the images deployed on the
+// device are only known to flightctl
+func (m *Manager) BootcImages() (*manager.BootcImages, error) {
+	device, err := m.readDevice()
+	if err != nil || device.image() == "" {
+		return nil, err
+	}
+	return &manager.BootcImages{Staged: device.image()}, nil
+}
+
+// HandleBootcUpgrade renders ContainerImage in the device spec, the flightctl agent switches the
+// device to it and handles the upgrades
+func (m *Manager) HandleBootcUpgrade(dryRun bool) error {
+	desired := m.ExporterHost.Spec.ContainerImage
+	if desired == "" {
+		_, _ = fmt.Fprintf(m.writer, "    ℹ️ The bootc image is managed by flightctl\n")
+		return nil
+	}
+	device, err := m.readDevice()
+	if err != nil {
+		return err
+	}
+	current := device.image()
+	if current == desired {
+		_, _ = fmt.Fprintf(m.writer, "    🖼️  Bootc image: %s\n", desired)
+		return nil
+	}
+
+	change := plan.Change{Kind: "bootc", Name: "image", Action: plan.ActionSwitch, After: plan.HashContent(desired)}
+	if current != "" {
+		change.Before = plan.HashContent(current)
+	}
+	if err := m.record(change); err != nil {
+		return err
+	}
+	if dryRun {
+		_, _ = fmt.Fprintf(m.writer, "    📄 Would render bootc image %s in the flightctl device spec %s\n", desired, m.specFile)
+		return nil
+	}
+	device.setImage(desired)
+	if err := m.writeDevice(device); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(m.writer, "    ✏️ Rendered bootc image %s in the flightctl device spec %s\n", desired, m.specFile)
+	return nil
+}
+
+// StopExporter can't stop the exporter, the flightctl agent owns the services
+func (m *Manager) StopExporter(svcName string, dryRun bool) (bool, error) {
+	_, _ = fmt.Fprintf(m.writer, "        ⚠️ %s not stopped, the flightctl backend can't stop services\n", svcName)
+	return false, nil
+}
+
+// ManagedExporters returns the exporters rendered in the device spec
+func (m *Manager) ManagedExporters() (map[string]string, error) {
+	device, err := m.readDevice()
+	if err != nil {
+		return nil, err
+	}
+	exporters := make(map[string]string)
+	for key, instanceName := range device.annotations(false) {
+		if svcName, ok := strings.CutPrefix(key, flightctlManagedPrefix); ok {
+			exporters[svcName], _ = instanceName.(string)
+		}
+	}
+	return exporters, nil
+}
+
+// MarkManaged records the exporter service svcName of the ExporterInstance instanceName in the
+// annotations of the device, on dry run the change is recorded without writing it
+func (m *Manager) MarkManaged(svcName, instanceName string, dryRun bool) error {
+	device, err := m.readDevice()
+	if err != nil {
+		return err
+	}
+	annotations := device.annotations(true)
+	previous, _ := annotations[flightctlManagedPrefix+svcName].(string)
+	if previous == instanceName {
+		return nil
+	}
+	if err := m.record(manager.ManagedExporterChange(svcName, previous, instanceName)); err != nil {
+		return err
+	}
+	if dryRun {
+		return nil
+	}
+	annotations[flightctlManagedPrefix+svcName] = instanceName
+	return m.writeDevice(device)
+}
+
+// Decommission removes the exporter service svcName from the device spec, the flightctl agent then
+// removes its files
+func (m *Manager) Decommission(svcName string, dryRun bool) error {
+	device, err := m.readDevice()
+	if err != nil {
+		return err
+	}
+	existing, err := device.marshalConfig(svcName)
+	if err != nil {
+		return err
+	}
+	err = m.record(plan.Change{Kind: "flightctl-config", Name: svcName, Action: plan.ActionDelete,
+		Before: plan.HashContent(existing)})
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		_, _ = fmt.Fprintf(m.writer, "        📄 Would remove %s from the flightctl device spec %s\n", svcName, m.specFile)
+		return nil
+	}
+	device.removeExporter(svcName)
+	if err := m.writeDevice(device); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(m.writer, "        ✅ %s removed from the flightctl device spec %s\n", svcName, m.specFile)
+	return nil
+}
+
+// SetBootcScheduler is ignored, the flightctl agent schedules the updates of the bootc image
+func (m *Manager) SetBootcScheduler(manager.BootcScheduler) {}
+
+// SetLeaseCheck is ignored, the flightctl agent restarts the exporters
+func (m *Manager) SetLeaseCheck(manager.LeaseCheck) {}
+
+// SetWriter sets the output writer, os.Stdout by default
+func (m *Manager) SetWriter(w io.Writer) {
+	m.writer = w
+}
+
+// SetRecorder records the exporter changes of the device spec in r
+func (m *Manager) SetRecorder(r *plan.Recorder) {
+	m.recorder = r
+}
+
+// SetSecrets masks secrets in the hashes of the recorded exporter changes, they are the credentials
+// of the next exporter applied
+func (m *Manager) SetSecrets(secrets ...string) {
+	m.secrets = secrets
+}
+
+// record records a change made on this host, the change must not be made on error
+func (m *Manager) record(change plan.Change) error {
+	return manager.RecordHostChange(m.recorder, m.ExporterHost.Name, change)
+}
+
+func (m *Manager) Close() error {
+	return nil
+}
@@ -0,0 +1,159 @@
+package flightctl
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/api/v1alpha1"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/plan"
+)
+
+func createTestExporterConfig() *v1alpha1.ExporterConfigTemplate {
+	exporterConfig := &v1alpha1.ExporterConfigTemplate{}
+	exporterConfig.Spec.ExporterMetadata.Name = "exporter"
+	exporterConfig.Spec.ConfigTemplate = "endpoint: grpc.example.com\ntoken: secret-token\n"
+	exporterConfig.Spec.SystemdServiceTemplate = "[Service]\nExecStart=/usr/bin/jmp run\n"
+	return exporterConfig
+}
+
+func TestManager(t *testing.T) {
+	specFile := filepath.Join(t.TempDir(), "device.yaml")
+	host := &v1alpha1.ExporterHost{ObjectMeta: metav1.ObjectMeta{Name: "flightctl"},
+		Spec: v1alpha1.ExporterHostSpec{Management: v1alpha1.Management{
+			Flightctl: &v1alpha1.FlightctlManagement{DeviceID: "device", SpecFile: specFile}}}}
+	manager, err := New(host, "")
+	require.NoError(t, err)
+	var out bytes.Buffer
+	recorder := plan.NewRecorder()
+	manager.SetWriter(&out)
+	manager.SetRecorder(recorder)
+	exporterConfig := createTestExporterConfig()
+
+	require.NoError(t, manager.Apply(exporterConfig, true))
+	_, err = os.Stat(specFile)
+	assert.ErrorIs(t, err, os.ErrNotExist, "nothing is written on dry run")
+	assert.Len(t, recorder.Plan(plan.Options{}).Changes, 1)
+
+	require.NoError(t, manager.Apply(exporterConfig, false))
+	require.NoError(t, manager.MarkManaged("exporter", "instance", false))
+	info, err := os.Stat(specFile)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm(), "the device spec holds the tokens")
+
+	content, err := os.ReadFile(specFile)
+	require.NoError(t, err)
+	var device struct {
+		Kind     string
+		Metadata struct {
+			Name        string
+			Annotations map[string]string
+		}
+		Spec struct {
+			Config  []flightctlConfig
+			Systemd struct {
+				MatchPatterns []string `yaml:"matchPatterns"`
+			}
+		}
+	}
+	require.NoError(t, yaml.Unmarshal(content, &device))
+	assert.Equal(t, "Device", device.Kind)
+	assert.Equal(t, "device", device.Metadata.Name)
+	assert.Equal(t, map[string]string{"exporters.jumpstarter.dev/exporter": "instance"}, device.Metadata.Annotations)
+	require.Len(t, device.Spec.Config, 1)
+	assert.Equal(t, []flightctlFile{
+		{Path: "/etc/systemd/system/exporter.service", Content: exporterConfig.Spec.SystemdServiceTemplate,
+			Mode: 0o644, User: "root", Group: "root"},
+		{Path: "/etc/jumpstarter/exporters/exporter.yaml", Content: exporterConfig.Spec.ConfigTemplate,
+			Mode: 0o600, User: "root", Group: "root"},
+	}, device.Spec.Config[0].Inline)
+	assert.Equal(t, []string{"exporter.service"}, device.Spec.Systemd.MatchPatterns)
+
+	needsUpdate, err := manager.NeedsUpdate(exporterConfig)
+	require.NoError(t, err)
+	assert.False(t, needsUpdate)
+
+	exporters, err := manager.ManagedExporters()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"exporter": "instance"}, exporters)
+
+	require.NoError(t, manager.Decommission("exporter", false))
+	exporters, err = manager.ManagedExporters()
+	require.NoError(t, err)
+	assert.Empty(t, exporters)
+	content, err = os.ReadFile(specFile)
+	require.NoError(t, err)
+	assert.NotContains(t, string(content), "exporter.service")
+}
+
+func TestManager_KeepsDeviceFields(t *testing.T) {
+	configDir := t.TempDir()
+	host := &v1alpha1.ExporterHost{ObjectMeta: metav1.ObjectMeta{Name: "flightctl"},
+		Spec: v1alpha1.ExporterHostSpec{ContainerImage: "quay.io/example/exporter-os:2",
+			Management: v1alpha1.Management{Flightctl: &v1alpha1.FlightctlManagement{DeviceID: "device"}}}}
+	manager, err := New(host, configDir)
+	require.NoError(t, err)
+	manager.SetWriter(&bytes.Buffer{})
+	specFile := filepath.Join(configDir, "flightctl", "device.yaml")
+	assert.Equal(t, specFile, manager.specFile, "the spec file is relative to the configuration file")
+
+	require.NoError(t, os.MkdirAll(filepath.Dir(specFile), 0o755))
+	require.NoError(t, os.WriteFile(specFile, []byte(`apiVersion: flightctl.io/v1alpha1
+kind: Device
+metadata:
+  name: device
+  labels:
+    site: lab-1
+spec:
+  os:
+    image: quay.io/example/exporter-os:1
+  config:
+    - name: site-config
+      gitRef:
+        repository: site
+        targetRevision: main
+        path: /etc/site
+  applications:
+    - name: monitoring
+      image: quay.io/example/monitoring:latest
+  systemd:
+    matchPatterns:
+      - chronyd.service
+`), 0o600))
+
+	require.NoError(t, manager.Apply(createTestExporterConfig(), false))
+	require.NoError(t, manager.HandleBootcUpgrade(false))
+	require.NoError(t, manager.MarkManaged("exporter", "instance", false))
+
+	content, err := os.ReadFile(specFile)
+	require.NoError(t, err)
+	var device map[string]any
+	require.NoError(t, yaml.Unmarshal(content, &device))
+	metadata := device["metadata"].(map[string]any)
+	assert.Equal(t, map[string]any{"site": "lab-1"}, metadata["labels"])
+	spec := device["spec"].(map[string]any)
+	assert.Equal(t, map[string]any{"image": "quay.io/example/exporter-os:2"}, spec["os"])
+	assert.Equal(t, []any{map[string]any{"name": "monitoring", "image": "quay.io/example/monitoring:latest"}},
+		spec["applications"])
+	configs := spec["config"].([]any)
+	require.Len(t, configs, 2)
+	assert.Equal(t, "site-config", configs[0].(map[string]any)["name"])
+	assert.Contains(t, configs[0], "gitRef")
+	assert.Equal(t, "exporter", configs[1].(map[string]any)["name"])
+	assert.Equal(t, map[string]any{"matchPatterns": []any{"chronyd.service", "exporter.service"}}, spec["systemd"])
+
+	require.NoError(t, manager.Decommission("exporter", false))
+	device = nil
+	content, err = os.ReadFile(specFile)
+	require.NoError(t, err)
+	require.NoError(t, yaml.Unmarshal(content, &device))
+	spec = device["spec"].(map[string]any)
+	assert.Len(t, spec["config"], 1)
+	assert.Equal(t, map[string]any{"matchPatterns": []any{"chronyd.service"}}, spec["systemd"])
+}
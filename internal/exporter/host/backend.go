@@ -0,0 +1,114 @@
+/*
+Copyright 2025. The Jumpstarter Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package host
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/api/v1alpha1"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/exporter/flightctl"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/exporter/manager"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/exporter/ssh"
+)
+
+// BackendOptions are the options of the host managers, each backend uses the ones relevant to it
+type BackendOptions struct {
+	// HostKeys verifies the SSH host keys, only pinned keys are accepted when nil
+	HostKeys *ssh.HostKeyVerifier
+	// JumpHosts shares the connections to the SSH jump hosts, private to each host when nil
+	JumpHosts *ssh.JumpHostPool
+	// ConfigDir is the directory of the configuration file, the relative paths of the hosts are resolved against it
+	ConfigDir string
+}
+
+// Backend manages the exporter hosts selected by their management options
+type Backend struct {
+	// Name identifies the backend in the messages
+	Name string
+	// Manages checks whether the management options of a host select this backend
+	Manages func(management *v1alpha1.Management) bool
+	// New creates the HostManager of an exporter host
+	New func(ctx context.Context, exporterHost *v1alpha1.ExporterHost, opts BackendOptions) (manager.HostManager, error)
+}
+
+var backends []Backend
+
+// RegisterBackend adds a host management backend, it isn't safe to call once hosts are managed
+func RegisterBackend(backend Backend) {
+	backends = append(backends, backend)
+}
+
+func init() {
+	RegisterBackend(Backend{
+		Name:    "ssh",
+		Manages: func(management *v1alpha1.Management) bool { return management.SSH.Host != "" },
+		New: func(ctx context.Context, exporterHost *v1alpha1.ExporterHost, opts BackendOptions) (manager.HostManager, error) {
+			transport, err := ssh.Connect(ctx, exporterHost, opts.HostKeys, opts.JumpHosts)
+			if err != nil {
+				return nil, err
+			}
+			return manager.New(ctx, exporterHost, transport), nil
+		},
+	})
+	RegisterBackend(Backend{
+		Name:    "flightctl",
+		Manages: func(management *v1alpha1.Management) bool { return management.Flightctl != nil },
+		New: func(_ context.Context, exporterHost *v1alpha1.ExporterHost, opts BackendOptions) (manager.HostManager, error) {
+			flightctlManager, err := flightctl.New(exporterHost, opts.ConfigDir)
+			if err != nil {
+				return nil, err
+			}
+			return flightctlManager, nil
+		},
+	})
+	RegisterBackend(Backend{
+		Name:    "local",
+		Manages: func(management *v1alpha1.Management) bool { return management.Local != nil },
+		New: func(ctx context.Context, exporterHost *v1alpha1.ExporterHost, _ BackendOptions) (manager.HostManager, error) {
+			transport, err := manager.NewLocalTransport(exporterHost)
+			if err != nil {
+				return nil, err
+			}
+			return manager.New(ctx, exporterHost, transport), nil
+		},
+	})
+}
+
+// NewHostManager creates the HostManager of an exporter host with the backend selected by its
+// management options, only one backend can be selected
+func NewHostManager(ctx context.Context, exporterHost *v1alpha1.ExporterHost, opts BackendOptions) (manager.HostManager, error) {
+	var selected []Backend
+	for _, backend := range backends {
+		if backend.Manages(&exporterHost.Spec.Management) {
+			selected = append(selected, backend)
+		}
+	}
+	switch len(selected) {
+	case 0:
+		return nil, fmt.Errorf("no management configured for exporter host %q", exporterHost.Name)
+	case 1:
+		return selected[0].New(ctx, exporterHost, opts)
+	}
+	names := make([]string, 0, len(selected))
+	for _, backend := range selected {
+		names = append(names, backend.Name)
+	}
+	return nil, fmt.Errorf("exporter host %q is managed by %s, only one management can be configured",
+		exporterHost.Name, strings.Join(names, " and "))
+}
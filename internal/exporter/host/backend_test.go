@@ -0,0 +1,214 @@
+/*
+Copyright 2025. The Jumpstarter Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package host
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/api/v1alpha1"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/config"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/exporter/flightctl"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/exporter/manager"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/exporter/template"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/plan"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/templating"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/vars"
+)
+
+func createTestBackendExporterConfig() *v1alpha1.ExporterConfigTemplate {
+	exporterConfig := &v1alpha1.ExporterConfigTemplate{}
+	exporterConfig.Spec.ExporterMetadata.Name = "exporter"
+	exporterConfig.Spec.ConfigTemplate = "endpoint: grpc.example.com\ntoken: secret-token\n"
+	exporterConfig.Spec.SystemdServiceTemplate = "[Service]\nExecStart=/usr/bin/jmp run\n"
+	return exporterConfig
+}
+
+func TestNewHostManager(t *testing.T) {
+	root := t.TempDir()
+	tests := []struct {
+		name       string
+		management v1alpha1.Management
+		wantType   manager.HostManager
+		errorMsg   string
+	}{
+		{
+			name:     "no management",
+			errorMsg: `no management configured for exporter host "backend"`,
+		},
+		{
+			name: "several managements",
+			management: v1alpha1.Management{
+				SSH:   v1alpha1.SSHCredentials{Host: "test-host.example.com"},
+				Local: &v1alpha1.LocalManagement{Root: root},
+			},
+			errorMsg: `exporter host "backend" is managed by ssh and local, only one management can be configured`,
+		},
+		{
+			name:       "local",
+			management: v1alpha1.Management{Local: &v1alpha1.LocalManagement{Root: root}},
+			wantType:   &manager.Manager{},
+		},
+		{
+			name:       "local without root",
+			management: v1alpha1.Management{Local: &v1alpha1.LocalManagement{}},
+			errorMsg:   `local root directory of "backend" is not set`,
+		},
+		{
+			name:       "flightctl",
+			management: v1alpha1.Management{Flightctl: &v1alpha1.FlightctlManagement{DeviceID: "device"}},
+			wantType:   &flightctl.Manager{},
+		},
+		{
+			name:       "flightctl without device id",
+			management: v1alpha1.Management{Flightctl: &v1alpha1.FlightctlManagement{}},
+			errorMsg:   `flightctl device id of "backend" is not set`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host := &v1alpha1.ExporterHost{ObjectMeta: metav1.ObjectMeta{Name: "backend"},
+				Spec: v1alpha1.ExporterHostSpec{Management: tt.management}}
+			hostManager, err := NewHostManager(context.Background(), host, BackendOptions{})
+			if tt.errorMsg != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.errorMsg, err.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.IsType(t, tt.wantType, hostManager)
+		})
+	}
+}
+
+func TestLocalBackend(t *testing.T) {
+	root := t.TempDir()
+	host := &v1alpha1.ExporterHost{ObjectMeta: metav1.ObjectMeta{Name: "local"},
+		Spec: v1alpha1.ExporterHostSpec{Management: v1alpha1.Management{
+			Local: &v1alpha1.LocalManagement{Root: root}}}}
+	hostManager, err := NewHostManager(context.Background(), host, BackendOptions{})
+	require.NoError(t, err)
+	var out bytes.Buffer
+	recorder := plan.NewRecorder()
+	hostManager.SetWriter(&out)
+	hostManager.SetRecorder(recorder)
+	exporterConfig := createTestBackendExporterConfig()
+
+	diff, err := hostManager.Diff(exporterConfig)
+	require.NoError(t, err)
+	assert.Contains(t, diff, "+++ b/etc/jumpstarter/exporters/exporter.yaml")
+	assert.NotContains(t, diff, "secret-token", "the secrets are masked")
+
+	require.NoError(t, hostManager.Apply(exporterConfig, true))
+	_, err = os.Stat(filepath.Join(root, "etc/jumpstarter/exporters/exporter.yaml"))
+	assert.ErrorIs(t, err, os.ErrNotExist, "nothing is written on dry run")
+
+	require.NoError(t, hostManager.Apply(exporterConfig, false))
+	info, err := os.Stat(filepath.Join(root, "etc/jumpstarter/exporters/exporter.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	content, err := os.ReadFile(filepath.Join(root, "etc/systemd/system/exporter.service"))
+	require.NoError(t, err)
+	assert.Equal(t, exporterConfig.Spec.SystemdServiceTemplate, string(content))
+	assert.Contains(t, out.String(), "exporter not restarted")
+
+	needsUpdate, err := hostManager.NeedsUpdate(exporterConfig)
+	require.NoError(t, err)
+	assert.False(t, needsUpdate)
+
+	require.NoError(t, hostManager.MarkManaged("exporter", "instance", false))
+	exporters, err := hostManager.ManagedExporters()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"exporter": "instance"}, exporters)
+
+	require.NoError(t, hostManager.Decommission("exporter", false))
+	_, err = os.Stat(filepath.Join(root, "etc/systemd/system/exporter.service"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	exporters, err = hostManager.ManagedExporters()
+	require.NoError(t, err)
+	assert.Empty(t, exporters)
+}
+
+func TestPlanNewExporter(t *testing.T) {
+	root := t.TempDir()
+	variables, err := vars.NewVariables("")
+	require.NoError(t, err)
+	cfg := &config.Config{Loaded: &config.LoadedLabConfig{
+		Variables: variables,
+		JumpstarterInstances: map[string]*v1alpha1.JumpstarterInstance{
+			"prod": {ObjectMeta: metav1.ObjectMeta{Name: "prod"},
+				Spec: v1alpha1.JumpstarterInstanceSpec{Namespace: "lab", Endpoints: []string{"grpc.example.com"}}},
+		},
+		ExporterHosts: map[string]*v1alpha1.ExporterHost{
+			"local-host": {ObjectMeta: metav1.ObjectMeta{Name: "local-host"},
+				Spec: v1alpha1.ExporterHostSpec{Management: v1alpha1.Management{
+					Local: &v1alpha1.LocalManagement{Root: root}}}},
+		},
+		ExporterConfigTemplates: map[string]*v1alpha1.ExporterConfigTemplate{
+			"template": {ObjectMeta: metav1.ObjectMeta{Name: "template"},
+				Spec: v1alpha1.ExporterConfigTemplateSpec{
+					ExporterMetadata:       v1alpha1.ExporterMeta{Name: "new-exporter"},
+					ConfigTemplate:         "endpoint: $( params.endpoint )\ntoken: \"$( params.token )\"\n",
+					SystemdServiceTemplate: "[Service]\nExecStart=/usr/bin/jmp run\n",
+				}},
+		},
+		ExporterInstances: map[string]*v1alpha1.ExporterInstance{
+			"new-exporter": {ObjectMeta: metav1.ObjectMeta{Name: "new-exporter"},
+				Spec: v1alpha1.ExporterInstanceSpec{
+					ExporterHostRef:        v1alpha1.ExporterHostRef{Name: "local-host"},
+					JumpstarterInstanceRef: v1alpha1.JumsptarterInstanceRef{Name: "prod"},
+					ConfigTemplateRef:      v1alpha1.ConfigTemplateRef{Name: "template", Parameters: map[string]string{}}}},
+		},
+	}}
+	tapplier, err := templating.NewTemplateApplier(cfg, nil)
+	require.NoError(t, err)
+
+	// the plan is made before the exporter exists, with the dry run token
+	recorder := plan.NewRecorder()
+	planner := NewExporterHostSyncer(cfg, tapplier,
+		map[string]template.ServiceParameters{"prod:new-exporter": {Token: template.DryRunToken}}, true, false, nil, 1)
+	planner.SetRecorder(recorder)
+	require.NoError(t, planner.SyncExporterHosts(context.Background()))
+	planned := recorder.Plan(plan.Options{})
+	kinds := []string{}
+	for _, change := range planned.Changes {
+		kinds = append(kinds, change.Kind+" "+change.Name)
+	}
+	assert.Contains(t, kinds, "managed-exporter new-exporter")
+	assert.Contains(t, kinds, "file /etc/jumpstarter/exporters/new-exporter.yaml")
+
+	// the apply renders the token issued once the exporter was created
+	applier := NewExporterHostSyncer(cfg, tapplier,
+		map[string]template.ServiceParameters{"prod:new-exporter": {Token: "issued-token"}}, false, false, nil, 1)
+	applier.SetRecorder(planned.Enforce())
+	require.NoError(t, applier.SyncExporterHosts(context.Background()))
+
+	content, err := os.ReadFile(filepath.Join(root, "etc/jumpstarter/exporters/new-exporter.yaml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), `token: "issued-token"`)
+	manifest, err := os.ReadFile(filepath.Join(root, "etc/jumpstarter/managed-exporters.yaml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(manifest), "new-exporter: new-exporter")
+}
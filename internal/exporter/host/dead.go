@@ -0,0 +1,91 @@
+/*
+Copyright 2025. The Jumpstarter Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package host
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	api "github.com/jumpstarter-dev/jumpstarter-lab-config/api/v1alpha1"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/exporter/manager"
+)
+
+// deadExporterInstances returns the dead exporter instances of a host matching the exporter filter,
+// the unmanaged ones are left alone
+func (e *ExporterHostSyncer) deadExporterInstances(hostName string) []*api.ExporterInstance {
+	var dead []*api.ExporterInstance
+	for _, exporterInstance := range e.cfg.Loaded.GetExporterInstancesByExporterHost(hostName) {
+		if exporterInstance == nil {
+			continue
+		}
+		if e.exporterFilter != nil && !e.exporterFilter.MatchString(exporterInstance.Name) {
+			continue
+		}
+		if isDead, _ := isExporterInstanceDead(exporterInstance); !isDead {
+			continue
+		}
+		if isUnmanaged, _ := isExporterInstanceUnmanaged(exporterInstance); isUnmanaged {
+			continue
+		}
+		dead = append(dead, exporterInstance)
+	}
+	slices.SortFunc(dead, func(a, b *api.ExporterInstance) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return dead
+}
+
+// exporterServiceName returns the name of the exporter service of an instance on the host, from its
+// rendered config or else from the managed exporters of the host
+func (e *ExporterHostSyncer) exporterServiceName(exporterInstance *api.ExporterInstance, hostSsh manager.HostManager) (string, error) {
+	tcfg, renderErr := e.renderExporterConfig(exporterInstance)
+	if renderErr == nil {
+		return tcfg.Spec.ExporterMetadata.Name, nil
+	}
+	managed, err := hostSsh.ManagedExporters()
+	if err != nil {
+		return "", err
+	}
+	for svcName, instanceName := range managed {
+		if instanceName == exporterInstance.Name {
+			return svcName, nil
+		}
+	}
+	return "", fmt.Errorf("unknown exporter service: %w", renderErr)
+}
+
+// stopDeadExporters stops and disables the services of the dead exporters of a host, their files are
+// kept for forensics. The failures are reported on out and left for the next run.
+func (e *ExporterHostSyncer) stopDeadExporters(hostName string, hostSsh manager.HostManager, out *OutputBuffer) {
+	for _, exporterInstance := range e.deadExporterInstances(hostName) {
+		_, deadAnnotation := isExporterInstanceDead(exporterInstance)
+		out.Printf("    💀 Exporter instance: %s is dead: %s\n", exporterInstance.Name, deadAnnotation)
+		svcName, err := e.exporterServiceName(exporterInstance, hostSsh)
+		if err == nil {
+			var stopped bool
+			stopped, err = hostSsh.StopExporter(svcName, e.dryRun)
+			if stopped {
+				out.MarkChanged()
+			}
+		}
+		if err != nil {
+			out.Printf("    ❌ Failed to stop dead exporter %s: %v\n", exporterInstance.Name, err)
+			out.MarkError()
+		}
+	}
+}
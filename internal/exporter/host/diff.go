@@ -0,0 +1,188 @@
+/*
+Copyright 2025. The Jumpstarter Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package host
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+
+	api "github.com/jumpstarter-dev/jumpstarter-lab-config/api/v1alpha1"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/exporter/manager"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/exporter/ssh"
+)
+
+// hostDiff is the result of the comparison of an exporter host with its configuration
+type hostDiff struct {
+	// instances holds the diff of each drifted exporter instance, in the order of the host work
+	instances []instanceDiff
+	// image reports the bootc image of the host when it isn't the one of its ContainerImage
+	image string
+	// notCompared lists the exporter instances without credentials yet, they are not deployed until
+	// apply gets them
+	notCompared []string
+	// out holds the messages of the connection to the host, i.e. the host keys trusted on first use
+	out *OutputBuffer
+	err error
+}
+
+type instanceDiff struct {
+	name string
+	diff string
+}
+
+// DiffExporterHosts compares the exporter hosts with their rendered configuration without changing
+// them, and writes a unified diff of every drifted file to w, grouped by host and exporter instance.
+// It returns the number of drifted hosts, and an error listing the hosts that couldn't be compared.
+func (e *ExporterHostSyncer) DiffExporterHosts(ctx context.Context, w io.Writer) (int, error) {
+	if err := e.checkKnownHosts(); err != nil {
+		return 0, err
+	}
+
+	e.jumpHosts = ssh.NewJumpHostPool()
+	defer func() {
+		_ = e.jumpHosts.Close()
+	}()
+
+	work, err := e.collectHostWork(nil)
+	if err != nil {
+		return 0, err
+	}
+
+	results := make([]hostDiff, len(work))
+	g, _ := errgroup.WithContext(ctx)
+	if e.parallelism > 0 {
+		g.SetLimit(e.parallelism)
+	}
+	for idx, hw := range work {
+		g.Go(func() error {
+			results[idx] = e.diffHost(ctx, hw)
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	drifted := 0
+	var failures []string
+	for idx, hw := range work {
+		result := results[idx]
+		if result.err == nil && len(result.instances) == 0 && result.image == "" &&
+			len(result.notCompared) == 0 && (result.out == nil || result.out.buf.Len() == 0) {
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "\n💻  Exporter host: %s\n", hw.hostName)
+		if result.out != nil {
+			_ = result.out.buf.Flush(w)
+		}
+		for _, name := range result.notCompared {
+			_, _ = fmt.Fprintf(w, "⏳ Exporter instance: %s not compared, its credentials are not issued yet\n", name)
+		}
+		if result.image != "" {
+			_, _ = fmt.Fprint(w, result.image)
+		}
+		for _, inst := range result.instances {
+			_, _ = fmt.Fprintf(w, "📟 Exporter instance: %s\n%s", inst.name, inst.diff)
+		}
+		if len(result.instances) > 0 || result.image != "" {
+			drifted++
+		}
+		if result.err != nil {
+			_, _ = fmt.Fprintf(w, "❌ %v\n", result.err)
+			failures = append(failures, hw.hostName)
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return drifted, fmt.Errorf("exporter host diff interrupted: %w", err)
+	}
+	if len(failures) > 0 {
+		return drifted, fmt.Errorf("failed to diff %d exporter hosts: %s", len(failures), strings.Join(failures, ", "))
+	}
+	return drifted, nil
+}
+
+// diffHost compares the exporter instances of a host with their rendered configuration
+func (e *ExporterHostSyncer) diffHost(ctx context.Context, hw hostWork) hostDiff {
+	if err := ctx.Err(); err != nil {
+		return hostDiff{err: err}
+	}
+	result := hostDiff{out: NewOutputBuffer(hw.hostName, 0)}
+	hostSsh, err := e.newHostManager(ctx, hw.host, result.out)
+	if err != nil {
+		result.err = err
+		return result
+	}
+	defer func() {
+		_ = hostSsh.Close()
+	}()
+
+	if result.image, err = bootcImageDiff(hw.host, hostSsh); err != nil {
+		result.err = err
+		return result
+	}
+	for _, exporterInstance := range hw.instances {
+		if !e.hasServiceParameters(exporterInstance) {
+			result.notCompared = append(result.notCompared, exporterInstance.Name)
+			continue
+		}
+		diff, err := e.diffExporterInstance(exporterInstance, hostSsh)
+		if err != nil {
+			result.err = fmt.Errorf("failed to diff %s: %w", exporterInstance.Name, err)
+			return result
+		}
+		if diff != "" {
+			result.instances = append(result.instances, instanceDiff{name: exporterInstance.Name, diff: diff})
+		}
+	}
+	return result
+}
+
+// hasServiceParameters reports whether the credentials of an exporter instance are known
+func (e *ExporterHostSyncer) hasServiceParameters(exporterInstance *api.ExporterInstance) bool {
+	_, ok := e.serviceParametersMap[exporterInstance.Spec.JumpstarterInstanceRef.Name+":"+exporterInstance.Name]
+	return ok
+}
+
+// diffExporterInstance returns the diff of an exporter instance on the host
+func (e *ExporterHostSyncer) diffExporterInstance(exporterInstance *api.ExporterInstance, hostSsh manager.HostManager) (string, error) {
+	tcfg, err := e.renderExporterConfig(exporterInstance)
+	if err != nil {
+		return "", err
+	}
+	return hostSsh.Diff(tcfg)
+}
+
+// bootcImageDiff reports the bootc image of the host when it doesn't boot the one of its
+// ContainerImage, staged only until a reboot, it is empty when the host has no ContainerImage or
+// isn't a bootc host
+func bootcImageDiff(host *api.ExporterHost, hostSsh manager.HostManager) (string, error) {
+	desired := host.Spec.ContainerImage
+	if desired == "" {
+		return "", nil
+	}
+	images, err := hostSsh.BootcImages()
+	if err != nil {
+		return "", err
+	}
+	if images == nil || images.Booted == desired {
+		return "", nil
+	}
+	return fmt.Sprintf("🖼️  Bootc image: %s, want %s\n", images, desired), nil
+}
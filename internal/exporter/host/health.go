@@ -0,0 +1,59 @@
+/*
+Copyright 2025. The Jumpstarter Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package host
+
+import (
+	"context"
+	"time"
+
+	api "github.com/jumpstarter-dev/jumpstarter-lab-config/api/v1alpha1"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/exporter/manager"
+)
+
+// ExporterOnlineFunc checks whether the controller reports the exporter of an instance as online
+type ExporterOnlineFunc func(ctx context.Context, exporterInstance *api.ExporterInstance) (bool, error)
+
+// SetHealthCheck verifies the exporters restarted by an update: they must be running, without
+// errors in their journal and reported online by online (when not nil) within timeout, or their
+// previous configuration is restored. A zero timeout disables the verification.
+func (e *ExporterHostSyncer) SetHealthCheck(timeout time.Duration, online ExporterOnlineFunc) {
+	e.healthTimeout = timeout
+	e.exporterOnline = online
+}
+
+// verifyExporter verifies the health of the exporter of an instance after Apply
+func (e *ExporterHostSyncer) verifyExporter(exporterInstance *api.ExporterInstance, tcfg *api.ExporterConfigTemplate, hostSsh manager.HostManager) error {
+	if e.dryRun || e.healthTimeout <= 0 {
+		return nil
+	}
+	var online manager.OnlineCheck
+	if e.exporterOnline != nil {
+		online = func(ctx context.Context) (bool, error) {
+			return e.exporterOnline(ctx, exporterInstance)
+		}
+	}
+	return hostSsh.VerifyExporter(tcfg, e.healthTimeout, online)
+}
+
+// healthCheckFailed reports an exporter which isn't healthy after its update, it is not retried
+func (e *ExporterHostSyncer) healthCheckFailed(instanceName string, err error, out *OutputBuffer) {
+	out.Printf("    ❌ %v\n", err)
+	out.MarkError()
+	e.unhealthyMu.Lock()
+	defer e.unhealthyMu.Unlock()
+	e.unhealthyExporters = append(e.unhealthyExporters, instanceName)
+}
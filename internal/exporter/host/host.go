@@ -18,9 +18,11 @@ package host
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
 	"regexp"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -30,8 +32,10 @@ import (
 
 	api "github.com/jumpstarter-dev/jumpstarter-lab-config/api/v1alpha1"
 	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/config"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/exporter/manager"
 	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/exporter/ssh"
 	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/exporter/template"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/plan"
 	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/templating"
 )
 
@@ -62,6 +66,35 @@ type ExporterHostSyncer struct {
 	exporterFilter       *regexp.Regexp
 	retryConfig          RetryConfig
 	parallelism          int
+	recorder             *plan.Recorder
+	trustOnFirstUse      bool
+	// prune decommissions the exporters removed from the configuration
+	prune bool
+	// jumpHosts shares the jump host connections between the hosts during SyncExporterHosts
+	jumpHosts *ssh.JumpHostPool
+
+	// hostKeyMu serializes the annotation updates of the source files and guards hostKeyFailures
+	hostKeyMu       sync.Mutex
+	hostKeyFailures []string
+
+	// healthTimeout bounds the health verification of the updated exporters, disabled when zero
+	healthTimeout  time.Duration
+	exporterOnline ExporterOnlineFunc
+	// unhealthyMu guards unhealthyExporters, the instances which weren't healthy after their update
+	unhealthyMu        sync.Mutex
+	unhealthyExporters []string
+
+	// rollout stages the sync of the hosts
+	rollout RolloutStrategy
+	// bootcUpdates schedules the bootc updates of the hosts
+	bootcUpdates bootcUpdates
+	// remediation power cycles the hosts which can't be reached over SSH
+	remediation remediation
+
+	// exporterLeased reports the leased exporters, whose disruptive restarts are deferred unless
+	// forceRestarts is set
+	exporterLeased ExporterLeaseFunc
+	forceRestarts  bool
 }
 
 func NewExporterHostSyncer(cfg *config.Config,
@@ -88,6 +121,84 @@ func NewExporterHostSyncer(cfg *config.Config,
 	}
 }
 
+// SetRecorder records the changes made on the exporter hosts in r
+func (e *ExporterHostSyncer) SetRecorder(r *plan.Recorder) {
+	e.recorder = r
+}
+
+// SetTrustOnFirstUse accepts the host key of exporter hosts without a pinned or known key, and pins
+// it in the jumpstarter.dev/ssh-host-key annotation of the ExporterHost source file
+func (e *ExporterHostSyncer) SetTrustOnFirstUse(tofu bool) {
+	e.trustOnFirstUse = tofu
+}
+
+// hostKeyVerifier returns the host key verifier for a connection, the keys trusted on first use
+// are reported in out
+func (e *ExporterHostSyncer) hostKeyVerifier(out *OutputBuffer) *ssh.HostKeyVerifier {
+	return &ssh.HostKeyVerifier{
+		KnownHostsFile:  e.cfg.KnownHostsFile(),
+		TrustOnFirstUse: e.trustOnFirstUse,
+		OnFirstUse: func(host *api.ExporterHost, fingerprint string) error {
+			if e.dryRun {
+				out.Printf("    🔐 dry run: Would trust host key %s on first use\n", fingerprint)
+				return nil
+			}
+			if err := e.pinHostKey(host, fingerprint); err != nil {
+				return err
+			}
+			out.Printf("    🔐 Trusted host key %s on first use, pinned in the %s annotation\n",
+				fingerprint, api.SSHHostKeyAnnotation)
+			return nil
+		},
+	}
+}
+
+// checkKnownHosts fails when the known_hosts file of the configuration can't be loaded
+func (e *ExporterHostSyncer) checkKnownHosts() error {
+	if e.cfg == nil {
+		return nil
+	}
+	return (&ssh.HostKeyVerifier{KnownHostsFile: e.cfg.KnownHostsFile()}).CheckKnownHosts()
+}
+
+// newHostManager creates the HostManager of a host with the backend selected by its management
+func (e *ExporterHostSyncer) newHostManager(ctx context.Context, host *api.ExporterHost, out *OutputBuffer) (manager.HostManager, error) {
+	opts := BackendOptions{HostKeys: e.hostKeyVerifier(out), JumpHosts: e.jumpHosts}
+	if e.cfg != nil {
+		opts.ConfigDir = e.cfg.BaseDir
+	}
+	return NewHostManager(ctx, host, opts)
+}
+
+// pinHostKey records the fingerprint of a host key in the source file of the ExporterHost,
+// and in host so the retries of this run use it
+func (e *ExporterHostSyncer) pinHostKey(host *api.ExporterHost, fingerprint string) error {
+	sourceFile := e.cfg.Loaded.SourceFiles["ExporterHost"][host.Name]
+	if sourceFile == "" {
+		return fmt.Errorf("source file of exporter host %s is unknown", host.Name)
+	}
+
+	e.hostKeyMu.Lock()
+	defer e.hostKeyMu.Unlock()
+	if err := config.UpdateAnnotationInSourceFile(sourceFile, host.Name, api.SSHHostKeyAnnotation, fingerprint); err != nil {
+		return err
+	}
+	if host.Annotations == nil {
+		host.Annotations = map[string]string{}
+	}
+	host.Annotations[api.SSHHostKeyAnnotation] = fingerprint
+	return nil
+}
+
+// hostKeyFailed reports a host key verification failure, the host is not retried
+func (e *ExporterHostSyncer) hostKeyFailed(hostName string, err error, out *OutputBuffer) {
+	out.Printf("    🔐 %v\n", err)
+	out.MarkError()
+	e.hostKeyMu.Lock()
+	defer e.hostKeyMu.Unlock()
+	e.hostKeyFailures = append(e.hostKeyFailures, hostName)
+}
+
 // isExporterInstanceDead checks if an exporter instance is marked as dead via annotation
 func isExporterInstanceDead(instance *api.ExporterInstance) (bool, string) {
 	return instance.IsDead()
@@ -156,32 +267,14 @@ func (e *ExporterHostSyncer) filterExporterInstances(hostName string, exporterIn
 }
 
 // processExporterInstance processes a single exporter instance
-func (e *ExporterHostSyncer) processExporterInstance(exporterInstance *api.ExporterInstance, hostSsh ssh.HostManager, out *OutputBuffer) error {
+func (e *ExporterHostSyncer) processExporterInstance(exporterInstance *api.ExporterInstance, hostSsh manager.HostManager, out *OutputBuffer) error {
 	// filterExporterInstances only passes active (non-dead, managed) instances here.
 
 	out.Printf("    📟 Exporter instance: %s\n", exporterInstance.Name)
-	errName := "ExporterInstance:" + exporterInstance.Name
-
-	et, err := template.NewExporterInstanceTemplater(e.cfg, exporterInstance)
-	if err != nil {
-		return fmt.Errorf("error creating ExporterInstanceTemplater for %s : %w", errName, err)
-	}
-
-	spRef := exporterInstance.Spec.JumpstarterInstanceRef.Name + ":" + exporterInstance.Name
-	serviceParameters, ok := e.serviceParametersMap[spRef]
-	if !ok {
-		return fmt.Errorf("service parameters not found for %s", spRef)
-	}
-	et.SetServiceParameters(serviceParameters)
-
-	_, err = et.RenderTemplateLabels()
-	if err != nil {
-		return fmt.Errorf("error creating ExporterInstanceTemplater for %s : %w", errName, err)
-	}
 
-	tcfg, err := et.RenderTemplateConfig()
+	tcfg, err := e.renderExporterConfig(exporterInstance)
 	if err != nil {
-		return fmt.Errorf("error rendering template config for %s : %w", errName, err)
+		return err
 	}
 
 	if e.debugConfigs {
@@ -198,7 +291,52 @@ func (e *ExporterHostSyncer) processExporterInstance(exporterInstance *api.Expor
 		out.Printf("%s\n", strings.Repeat("─", 60))
 	}
 
-	return hostSsh.Apply(tcfg, e.dryRun)
+	hostSsh.SetLeaseCheck(e.leaseCheck(exporterInstance, out))
+	// the plan of a new exporter is made before its token is issued, the token is kept out of the hashes
+	hostSsh.SetSecrets(e.serviceParametersMap[serviceParametersRef(exporterInstance)].Token)
+	if err := hostSsh.Apply(tcfg, e.dryRun); err != nil {
+		return err
+	}
+	if err := hostSsh.MarkManaged(tcfg.Spec.ExporterMetadata.Name, exporterInstance.Name, e.dryRun); err != nil {
+		return err
+	}
+	if e.dryRun {
+		return nil
+	}
+	return e.verifyExporter(exporterInstance, tcfg, hostSsh)
+}
+
+// serviceParametersRef returns the key of the service parameters of an exporter instance
+func serviceParametersRef(exporterInstance *api.ExporterInstance) string {
+	return exporterInstance.Spec.JumpstarterInstanceRef.Name + ":" + exporterInstance.Name
+}
+
+// renderExporterConfig renders the exporter config template of an exporter instance with its service parameters
+func (e *ExporterHostSyncer) renderExporterConfig(exporterInstance *api.ExporterInstance) (*api.ExporterConfigTemplate, error) {
+	errName := "ExporterInstance:" + exporterInstance.Name
+
+	et, err := template.NewExporterInstanceTemplater(e.cfg, exporterInstance)
+	if err != nil {
+		return nil, fmt.Errorf("error creating ExporterInstanceTemplater for %s : %w", errName, err)
+	}
+
+	spRef := serviceParametersRef(exporterInstance)
+	serviceParameters, ok := e.serviceParametersMap[spRef]
+	if !ok {
+		return nil, fmt.Errorf("service parameters not found for %s", spRef)
+	}
+	et.SetServiceParameters(serviceParameters)
+
+	_, err = et.RenderTemplateLabels()
+	if err != nil {
+		return nil, fmt.Errorf("error creating ExporterInstanceTemplater for %s : %w", errName, err)
+	}
+
+	tcfg, err := et.RenderTemplateConfig()
+	if err != nil {
+		return nil, fmt.Errorf("error rendering template config for %s : %w", errName, err)
+	}
+	return tcfg, nil
 }
 
 // calculateBackoffDelay calculates the delay for exponential backoff
@@ -227,15 +365,33 @@ func getRetryItemDescription(retryItem RetryItem) string {
 	}
 }
 
-// processExporterInstancesAndBootc processes exporter instances and collects failures on the OutputBuffer
-func (e *ExporterHostSyncer) processExporterInstancesAndBootc(exporterInstances []*api.ExporterInstance, hostName string, renderedHost *api.ExporterHost, out *OutputBuffer) {
+// processExporterInstancesAndBootc processes exporter instances and collects failures on the OutputBuffer,
+// the instances not processed when ctx is done are reported as interrupted instead of being retried
+func (e *ExporterHostSyncer) processExporterInstancesAndBootc(ctx context.Context, exporterInstances []*api.ExporterInstance, hostName string, renderedHost *api.ExporterHost, out *OutputBuffer) {
 	// Create SSH connection
-	hostSsh, err := ssh.NewSSHHostManager(renderedHost)
+	hostSsh, err := e.newHostManager(ctx, renderedHost, out)
 	if err == nil {
 		// Wire the SSH host manager to write to our buffer
 		hostSsh.SetWriter(out.Writer())
+		hostSsh.SetRecorder(e.recorder)
+		hostSsh.SetBootcScheduler(e.bootcScheduler(ctx, renderedHost, hostName, out))
 		_, err = hostSsh.Status()
 	}
+	if err == nil {
+		e.sshConnected(hostName)
+	}
+	if err != nil && ctx.Err() != nil {
+		out.Printf("    ⏹️  Interrupted before connecting, %d instances not processed\n", len(exporterInstances))
+		out.MarkInterrupted(len(exporterInstances))
+		if hostSsh != nil {
+			_ = hostSsh.Close()
+		}
+		return
+	}
+	if ssh.IsHostKeyError(err) {
+		e.hostKeyFailed(hostName, err, out)
+		return
+	}
 	if err != nil {
 		out.Printf("    ❌ Failed to create/test SSH connection: %v\n", err)
 		out.MarkError()
@@ -259,6 +415,7 @@ func (e *ExporterHostSyncer) processExporterInstancesAndBootc(exporterInstances
 			LastError:        err,
 			LastAttemptTime:  time.Now(),
 		})
+		e.sshFailed(ctx, renderedHost, hostName, err, out.retryItems, out)
 		return
 	}
 
@@ -266,9 +423,25 @@ func (e *ExporterHostSyncer) processExporterInstancesAndBootc(exporterInstances
 		_ = hostSsh.Close()
 	}()
 
+	e.stopDeadExporters(hostName, hostSsh, out)
+
 	// Process exporter instances
-	for _, exporterInstance := range exporterInstances {
+	for idx, exporterInstance := range exporterInstances {
+		if ctx.Err() != nil {
+			out.Printf("    ⏹️  Interrupted, %d instances not processed\n", len(exporterInstances)-idx)
+			out.MarkInterrupted(len(exporterInstances) - idx)
+			return
+		}
 		if err := e.processExporterInstance(exporterInstance, hostSsh, out); err != nil {
+			if ctx.Err() != nil {
+				out.Printf("    ⏹️  Interrupted while processing %s: %v\n", exporterInstance.Name, err)
+				out.MarkInterrupted(len(exporterInstances) - idx)
+				return
+			}
+			if manager.IsHealthError(err) {
+				e.healthCheckFailed(exporterInstance.Name, err, out)
+				continue
+			}
 			out.Printf("    ❌ Failed to process %s: %v\n", exporterInstance.Name, err)
 			out.MarkError()
 			out.AddRetryItem(RetryItem{
@@ -284,7 +457,16 @@ func (e *ExporterHostSyncer) processExporterInstancesAndBootc(exporterInstances
 		}
 	}
 
+	if e.prunesHost(hostName) && ctx.Err() == nil {
+		e.pruneOrphanedExporters(hostName, hostSsh, out)
+	}
+
 	// Handle bootc upgrade
+	if ctx.Err() != nil {
+		out.Printf("    ⏹️  Interrupted, bootc upgrade not checked\n")
+		out.MarkInterrupted(0)
+		return
+	}
 	if err := hostSsh.HandleBootcUpgrade(e.dryRun); err != nil {
 		out.Printf("    ⚠️  Bootc upgrade error: %v\n", err)
 		out.MarkError()
@@ -299,6 +481,36 @@ func (e *ExporterHostSyncer) processExporterInstancesAndBootc(exporterInstances
 	}
 }
 
+// maintainHost stops the dead exporters and prunes the orphaned exporters of a host without
+// exporter instances to sync
+func (e *ExporterHostSyncer) maintainHost(ctx context.Context, w hostWork, out *OutputBuffer) {
+	hostSsh, err := e.newHostManager(ctx, w.host, out)
+	if err != nil && ctx.Err() != nil {
+		out.Printf("    ⏹️  Interrupted before connecting\n")
+		out.MarkInterrupted(0)
+		return
+	}
+	if ssh.IsHostKeyError(err) {
+		e.hostKeyFailed(w.hostName, err, out)
+		return
+	}
+	if err != nil {
+		out.Printf("    ❌ Failed to create SSH connection: %v\n", err)
+		out.MarkError()
+		return
+	}
+	defer func() {
+		_ = hostSsh.Close()
+	}()
+
+	hostSsh.SetWriter(out.Writer())
+	hostSsh.SetRecorder(e.recorder)
+	e.stopDeadExporters(w.hostName, hostSsh, out)
+	if e.prunesHost(w.hostName) && ctx.Err() == nil {
+		e.pruneOrphanedExporters(w.hostName, hostSsh, out)
+	}
+}
+
 // hostWork represents a unit of work for processing a single host
 type hostWork struct {
 	host      *api.ExporterHost
@@ -308,12 +520,28 @@ type hostWork struct {
 
 // processGlobalRetryQueue processes the global retry queue with exponential backoff
 // Retries are parallelized by host, using the same concurrency limit as the initial pass.
-// Returns the number of items that succeeded on retry.
-func (e *ExporterHostSyncer) processGlobalRetryQueue(retryQueue []RetryItem, printer *SyncPrinter) (int32, error) {
+// Returns the number of items that succeeded on retry, and the hosts with items given up.
+func (e *ExporterHostSyncer) processGlobalRetryQueue(ctx context.Context, retryQueue []RetryItem, printer *SyncPrinter) (int32, []string, error) {
 	var finalErrors []string
 	var totalSucceeded int32
+	var failedHosts []string
+	giveUp := func(retryItem RetryItem, reason string) {
+		finalErrors = append(finalErrors, fmt.Sprintf("%s on %s: %s",
+			getRetryItemDescription(retryItem), retryItem.HostName, reason))
+		if !slices.Contains(failedHosts, retryItem.HostName) {
+			failedHosts = append(failedHosts, retryItem.HostName)
+		}
+	}
 
 	for len(retryQueue) > 0 {
+		if ctx.Err() != nil {
+			fmt.Printf("⏹️  Interrupted, giving up on %d items in the retry queue\n", len(retryQueue))
+			for _, retryItem := range retryQueue {
+				giveUp(retryItem, "interrupted")
+			}
+			break
+		}
+
 		var nextRetryQueue []RetryItem
 		var itemsToRetry []RetryItem
 
@@ -324,12 +552,11 @@ func (e *ExporterHostSyncer) processGlobalRetryQueue(retryQueue []RetryItem, pri
 				if retryItem.ExporterInstance == nil {
 					fmt.Printf("💀 Max retry attempts exceeded for bootc upgrade on %s, giving up: %v\n",
 						retryItem.HostName, retryItem.LastError)
-					finalErrors = append(finalErrors, fmt.Sprintf("bootc upgrade on %s: %v", retryItem.HostName, retryItem.LastError))
 				} else {
 					fmt.Printf("💀 Max retry attempts exceeded for %s on %s, giving up: %v\n",
 						retryItem.ExporterInstance.Name, retryItem.HostName, retryItem.LastError)
-					finalErrors = append(finalErrors, fmt.Sprintf("%s on %s: %v", retryItem.ExporterInstance.Name, retryItem.HostName, retryItem.LastError))
 				}
+				giveUp(retryItem, fmt.Sprint(retryItem.LastError))
 				continue
 			}
 
@@ -354,7 +581,7 @@ func (e *ExporterHostSyncer) processGlobalRetryQueue(retryQueue []RetryItem, pri
 
 		// Process host groups in parallel
 		var retryMu sync.Mutex
-		g, _ := errgroup.WithContext(context.Background())
+		g, _ := errgroup.WithContext(ctx)
 		if e.parallelism > 0 {
 			g.SetLimit(e.parallelism)
 		}
@@ -365,7 +592,7 @@ func (e *ExporterHostSyncer) processGlobalRetryQueue(retryQueue []RetryItem, pri
 				out := NewOutputBuffer(hostName, len(items))
 				out.Printf("\n🔄 Retrying %d items on %s...\n", len(items), hostName)
 
-				localRetries, succeeded := e.processRetryGroup(items, out)
+				localRetries, succeeded := e.processRetryGroup(ctx, items, out)
 				out.Done()
 				roundSucceeded.Add(int32(succeeded))
 
@@ -402,43 +629,69 @@ func (e *ExporterHostSyncer) processGlobalRetryQueue(retryQueue []RetryItem, pri
 					roundedDelay = 1 * time.Second
 				}
 				fmt.Printf("⏳ Waiting %v before next retry cycle...\n", roundedDelay)
-				time.Sleep(roundedDelay + 1*time.Second)
+				select {
+				case <-ctx.Done():
+				case <-time.After(roundedDelay + 1*time.Second):
+				}
 			}
 		}
 	}
 
 	// Return error if any instances failed after all retries
 	if len(finalErrors) > 0 {
-		return totalSucceeded, fmt.Errorf("failed to process exporter instances after retries: %s", strings.Join(finalErrors, "; "))
+		return totalSucceeded, failedHosts, fmt.Errorf("failed to process exporter instances after retries: %s", strings.Join(finalErrors, "; "))
 	}
 
-	return totalSucceeded, nil
+	return totalSucceeded, nil, nil
+}
+
+// retryStage drains the retry queue of the hosts of a rollout stage, so the hosts which recover
+// don't count as failed when the stage is judged. It returns the number of hosts still failing.
+func (e *ExporterHostSyncer) retryStage(ctx context.Context, retryQueue []RetryItem, printer *SyncPrinter) (int, error) {
+	if len(retryQueue) == 0 {
+		return 0, nil
+	}
+	retryTotal := int32(len(retryQueue))
+	fmt.Printf("\n🔄 Processing retry queue (%d failed items) ===========================\n", retryTotal)
+	retrySucceeded, failedHosts, err := e.processGlobalRetryQueue(ctx, retryQueue, printer)
+	printer.AddRetryStats(retryTotal, retrySucceeded)
+	return len(failedHosts), err
 }
 
 // processRetryGroup retries a group of items for a single host (sequential within the host).
 // Returns the remaining retry items and the number of items that succeeded.
-func (e *ExporterHostSyncer) processRetryGroup(items []RetryItem, out *OutputBuffer) ([]RetryItem, int) {
+func (e *ExporterHostSyncer) processRetryGroup(ctx context.Context, items []RetryItem, out *OutputBuffer) ([]RetryItem, int) {
 	var localRetries []RetryItem
 	succeeded := 0
 
 	// Create a single SSH connection for all items on this host
-	var hostSsh ssh.HostManager
+	var hostSsh manager.HostManager
 	var sshErr error
 
 	if len(items) > 0 {
-		hostSsh, sshErr = ssh.NewSSHHostManager(items[0].RenderedHost)
+		hostSsh, sshErr = e.newHostManager(ctx, items[0].RenderedHost, out)
 		if sshErr == nil {
 			hostSsh.SetWriter(out.Writer())
+			hostSsh.SetRecorder(e.recorder)
+			hostSsh.SetBootcScheduler(e.bootcScheduler(ctx, items[0].RenderedHost, items[0].HostName, out))
 			_, sshErr = hostSsh.Status()
 		}
+		if sshErr == nil {
+			e.sshConnected(items[0].HostName)
+		}
 	}
 
+	if ssh.IsHostKeyError(sshErr) {
+		e.hostKeyFailed(items[0].HostName, sshErr, out)
+		return nil, 0
+	}
 	if sshErr != nil {
 		out.Printf("❌ SSH connection failed for %s: %v\n", items[0].HostName, sshErr)
 		out.MarkError()
 		for i := range items {
 			e.addToRetryQueue(&items[i], sshErr, &localRetries)
 		}
+		e.sshFailed(ctx, items[0].RenderedHost, items[0].HostName, sshErr, localRetries, out)
 		return localRetries, 0
 	}
 
@@ -449,6 +702,11 @@ func (e *ExporterHostSyncer) processRetryGroup(items []RetryItem, out *OutputBuf
 	}
 
 	for i := range items {
+		if ctx.Err() != nil {
+			// leave the remaining items in the queue, it is given up as a whole
+			out.MarkInterrupted(len(items) - i)
+			return append(localRetries, items[i:]...), succeeded
+		}
 		retryItem := &items[i]
 		out.Printf("  🔄 Retrying %s (attempt %d/%d)...\n",
 			getRetryItemDescription(*retryItem), retryItem.Attempts+1, e.retryConfig.MaxAttempts)
@@ -467,6 +725,10 @@ func (e *ExporterHostSyncer) processRetryGroup(items []RetryItem, out *OutputBuf
 		} else {
 			// This was an exporter instance failure
 			if err := e.processExporterInstance(retryItem.ExporterInstance, hostSsh, out); err != nil {
+				if manager.IsHealthError(err) {
+					e.healthCheckFailed(retryItem.ExporterInstance.Name, err, out)
+					continue
+				}
 				out.Printf("  ❌ Retry failed for %s: %v\n", retryItem.ExporterInstance.Name, err)
 				out.MarkError()
 				e.addToRetryQueue(retryItem, err, &localRetries)
@@ -481,13 +743,9 @@ func (e *ExporterHostSyncer) processRetryGroup(items []RetryItem, out *OutputBuf
 	return localRetries, succeeded
 }
 
-// SyncExporterHosts synchronizes exporter hosts via SSH, processing hosts in parallel.
-func (e *ExporterHostSyncer) SyncExporterHosts() error {
-	fmt.Print("\n🔄 Syncing exporter hosts via SSH ===========================\n")
-
-	printer := NewSyncPrinter()
-
-	// Pre-filter and template all hosts (fast, no SSH involved)
+// collectHostWork filters the exporter instances of every host and applies the templates to the hosts
+// (fast, no SSH involved). The output of the hosts skipped by the filters is passed to skipped.
+func (e *ExporterHostSyncer) collectHostWork(skipped func(out *OutputBuffer)) ([]hostWork, error) {
 	work := make([]hostWork, 0, len(e.cfg.Loaded.ExporterHosts))
 	for _, host := range e.cfg.Loaded.ExporterHosts {
 		exporterInstances := e.cfg.Loaded.GetExporterInstancesByExporterHost(host.Name)
@@ -499,19 +757,23 @@ func (e *ExporterHostSyncer) SyncExporterHosts() error {
 		// Skip the host if no viable exporter instances remain
 		if len(exporterInstances) == 0 {
 			// Flush any filter messages (like "all dead" skips)
-			if filterOut.buf.Len() > 0 {
-				printer.FlushBuffer(filterOut)
+			if filterOut.buf.Len() > 0 && skipped != nil {
+				skipped(filterOut)
+			}
+			// the dead exporters must still be stopped, and when pruning the host is still
+			// checked for exporters removed from the configuration
+			if !e.prunesHost(host.Name) && len(e.deadExporterInstances(host.Name)) == 0 {
+				continue
 			}
-			continue
 		}
 
 		// Apply templates to the host
 		hostCopy := host.DeepCopy()
 		if err := e.tapplier.Apply(hostCopy); err != nil {
-			return fmt.Errorf("error applying template for %s: %w", host.Name, err)
+			return nil, fmt.Errorf("error applying template for %s: %w", host.Name, err)
 		}
-		// if there are no addresses, skip the host
-		if len(hostCopy.Spec.Addresses) == 0 {
+		// the SSH backend needs an address, the other backends don't connect to the host
+		if hostCopy.Spec.Management.SSH.Host != "" && len(hostCopy.Spec.Addresses) == 0 {
 			fmt.Printf("    ❌ Skipping %s - no addresses\n", host.Name)
 			continue
 		}
@@ -522,27 +784,45 @@ func (e *ExporterHostSyncer) SyncExporterHosts() error {
 			instances: exporterInstances,
 		})
 	}
+	return work, nil
+}
 
-	// Process hosts in parallel
+// syncHosts syncs hosts in parallel, collecting their failed items in retryQueue. It returns the
+// number of hosts which failed without queueing items for retry, the others are judged once retried.
+func (e *ExporterHostSyncer) syncHosts(ctx context.Context, hosts []hostWork, printer *SyncPrinter, retryQueue *[]RetryItem) int {
 	var retryMu sync.Mutex
-	retryQueue := make([]RetryItem, 0)
+	var failures atomic.Int32
 
-	g, _ := errgroup.WithContext(context.Background())
+	g, _ := errgroup.WithContext(ctx)
 	if e.parallelism > 0 {
 		g.SetLimit(e.parallelism)
 	}
 
-	for _, w := range work {
+	for _, w := range hosts {
 		g.Go(func() error {
-			out := NewOutputBuffer(w.host.Spec.Addresses[0], len(w.instances))
-			out.Printf("\n💻  Exporter host: %s\n", w.host.Spec.Addresses[0])
+			out := NewOutputBuffer(w.hostName, len(w.instances))
+			if ctx.Err() != nil {
+				out.Printf("  ⏹️  %s not processed (interrupted)\n", w.hostName)
+				out.MarkInterrupted(len(w.instances))
+				out.Done()
+				printer.FlushBuffer(out)
+				return nil
+			}
+			out.Printf("\n💻  Exporter host: %s\n", w.hostName)
 
-			e.processExporterInstancesAndBootc(w.instances, w.hostName, w.host, out)
+			if len(w.instances) == 0 {
+				e.maintainHost(ctx, w, out)
+			} else {
+				e.processExporterInstancesAndBootc(ctx, w.instances, w.hostName, w.host, out)
+			}
 			out.Done()
+			if out.hasErrors && len(out.retryItems) == 0 {
+				failures.Add(1)
+			}
 
 			// Collect retry items under lock
 			retryMu.Lock()
-			retryQueue = append(retryQueue, out.retryItems...)
+			*retryQueue = append(*retryQueue, out.retryItems...)
 			retryMu.Unlock()
 
 			// Atomically flush output for this host
@@ -551,20 +831,97 @@ func (e *ExporterHostSyncer) SyncExporterHosts() error {
 		})
 	}
 	_ = g.Wait()
+	return int(failures.Load())
+}
 
-	// Second pass: retry all failed instances globally
-	if len(retryQueue) > 0 {
-		retryTotal := int32(len(retryQueue))
-		fmt.Printf("\n🔄 Processing retry queue (%d failed items) ===========================\n", retryTotal)
-		retrySucceeded, err := e.processGlobalRetryQueue(retryQueue, printer)
-		printer.AddRetryStats(retryTotal, retrySucceeded)
-		if err != nil {
-			return fmt.Errorf("error syncing exporter hosts: %w", err)
+// SyncExporterHosts synchronizes exporter hosts via SSH, processing hosts in parallel, in the
+// stages of the rollout strategy. When ctx is done no new host or instance is started, and the
+// summary lists what was interrupted.
+func (e *ExporterHostSyncer) SyncExporterHosts(ctx context.Context) error {
+	fmt.Print("\n🔄 Syncing exporter hosts via SSH ===========================\n")
+
+	if err := e.checkKnownHosts(); err != nil {
+		return err
+	}
+
+	printer := NewSyncPrinter()
+
+	e.jumpHosts = ssh.NewJumpHostPool()
+	defer func() {
+		_ = e.jumpHosts.Close()
+	}()
+
+	work, err := e.collectHostWork(printer.FlushBuffer)
+	if err != nil {
+		return err
+	}
+	if err := e.rollout.validate(e.cfg.Loaded.ExporterHosts, work); err != nil {
+		return fmt.Errorf("invalid rollout: %w", err)
+	}
+	e.countBootcUpdates(ctx, work)
+
+	// Process the hosts in parallel, stage after stage, retrying the failed items of a stage
+	// before the next one
+	stages := e.rollout.stages(work)
+	var rolloutErr, retryErr error
+	for idx, stage := range stages {
+		if len(stages) > 1 {
+			fmt.Printf("\n🚦 Rollout %s: %d hosts\n", stage.name, len(stage.hosts))
 		}
+		var retryQueue []RetryItem
+		failures := e.syncHosts(ctx, stage.hosts, printer, &retryQueue)
+		retryFailures, err := e.retryStage(ctx, retryQueue, printer)
+		retryErr = errors.Join(retryErr, err)
+		failures += retryFailures
+		if idx == len(stages)-1 || !e.rollout.failed(failures, len(stage.hosts)) {
+			continue
+		}
+		if e.dryRun {
+			fmt.Printf("🛑 dry run: %d of %d hosts failed in the rollout %s, the rollout would stop here\n",
+				failures, len(stage.hosts), stage.name)
+			continue
+		}
+		var notSynced []string
+		for _, later := range stages[idx+1:] {
+			for _, w := range later.hosts {
+				notSynced = append(notSynced, w.hostName)
+			}
+		}
+		fmt.Printf("🛑 %d of %d hosts failed in the rollout %s after their retries, over the %d%% limit, "+
+			"stopping the rollout: %d hosts not synced\n",
+			failures, len(stage.hosts), stage.name, e.rollout.MaxFailurePercent, len(notSynced))
+		rolloutErr = fmt.Errorf("rollout stopped after %d of %d hosts failed in the %s, not synced: %s",
+			failures, len(stage.hosts), stage.name, strings.Join(notSynced, ", "))
+		break
+	}
+	if retryErr != nil {
+		e.addPowerCycles(printer)
+		printer.PrintSummary()
+		e.printPendingUpgrades()
+		return fmt.Errorf("error syncing exporter hosts: %w", errors.Join(retryErr, rolloutErr))
 	}
 
 	// Print final summary
+	e.addPowerCycles(printer)
 	printer.PrintSummary()
+	e.printPendingUpgrades()
 
-	return nil
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("exporter host sync interrupted: %w", err)
+	}
+	var errs []error
+	if rolloutErr != nil {
+		errs = append(errs, rolloutErr)
+	}
+	if len(e.hostKeyFailures) > 0 {
+		slices.Sort(e.hostKeyFailures)
+		errs = append(errs, fmt.Errorf("host key verification failed for %d exporter hosts: %s",
+			len(e.hostKeyFailures), strings.Join(e.hostKeyFailures, ", ")))
+	}
+	if len(e.unhealthyExporters) > 0 {
+		slices.Sort(e.unhealthyExporters)
+		errs = append(errs, fmt.Errorf("%d exporters were not healthy after their update: %s",
+			len(e.unhealthyExporters), strings.Join(e.unhealthyExporters, ", ")))
+	}
+	return errors.Join(errs...)
 }
@@ -1,14 +1,29 @@
 package host
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/jumpstarter-dev/jumpstarter-lab-config/api/v1alpha1"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/config"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/exporter/manager"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/exporter/ssh"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/exporter/template"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/templating"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 func TestDeadAnnotationFiltering(t *testing.T) {
@@ -226,6 +241,60 @@ func TestHostSkippingBehavior(t *testing.T) {
 	}
 }
 
+func TestCollectHostWork_Addresses(t *testing.T) {
+	loaded := &config.LoadedLabConfig{
+		ExporterHosts: map[string]*v1alpha1.ExporterHost{
+			"ssh-host": {ObjectMeta: metav1.ObjectMeta{Name: "ssh-host"},
+				Spec: v1alpha1.ExporterHostSpec{Management: v1alpha1.Management{
+					SSH: v1alpha1.SSHCredentials{Host: "ssh-host.example.com"}}}},
+			"local-host": {ObjectMeta: metav1.ObjectMeta{Name: "local-host"},
+				Spec: v1alpha1.ExporterHostSpec{Management: v1alpha1.Management{
+					Local: &v1alpha1.LocalManagement{Root: t.TempDir()}}}},
+		},
+		ExporterInstances: map[string]*v1alpha1.ExporterInstance{
+			"ssh-exporter": {ObjectMeta: metav1.ObjectMeta{Name: "ssh-exporter"},
+				Spec: v1alpha1.ExporterInstanceSpec{ExporterHostRef: v1alpha1.ExporterHostRef{Name: "ssh-host"}}},
+			"local-exporter": {ObjectMeta: metav1.ObjectMeta{Name: "local-exporter"},
+				Spec: v1alpha1.ExporterInstanceSpec{ExporterHostRef: v1alpha1.ExporterHostRef{Name: "local-host"}}},
+		},
+	}
+	cfg := &config.Config{Loaded: loaded}
+	tapplier, err := templating.NewTemplateApplier(cfg, nil)
+	require.NoError(t, err)
+	e := NewExporterHostSyncer(cfg, tapplier, nil, true, false, nil, 1)
+
+	work, err := e.collectHostWork(nil)
+	require.NoError(t, err)
+	require.Len(t, work, 1, "the SSH host without addresses is skipped")
+	assert.Equal(t, "local-host", work[0].hostName, "the local backend doesn't need addresses")
+}
+
+func TestDiffExporterHosts_NotCompared(t *testing.T) {
+	loaded := &config.LoadedLabConfig{
+		ExporterHosts: map[string]*v1alpha1.ExporterHost{
+			"local-host": {ObjectMeta: metav1.ObjectMeta{Name: "local-host"},
+				Spec: v1alpha1.ExporterHostSpec{Management: v1alpha1.Management{
+					Local: &v1alpha1.LocalManagement{Root: t.TempDir()}}}},
+		},
+		ExporterInstances: map[string]*v1alpha1.ExporterInstance{
+			"new-exporter": {ObjectMeta: metav1.ObjectMeta{Name: "new-exporter"},
+				Spec: v1alpha1.ExporterInstanceSpec{
+					ExporterHostRef:        v1alpha1.ExporterHostRef{Name: "local-host"},
+					JumpstarterInstanceRef: v1alpha1.JumsptarterInstanceRef{Name: "prod"}}},
+		},
+	}
+	cfg := &config.Config{Loaded: loaded}
+	tapplier, err := templating.NewTemplateApplier(cfg, nil)
+	require.NoError(t, err)
+	e := NewExporterHostSyncer(cfg, tapplier, map[string]template.ServiceParameters{}, true, false, nil, 1)
+
+	var out bytes.Buffer
+	drifted, err := e.DiffExporterHosts(context.Background(), &out)
+	require.NoError(t, err)
+	assert.Zero(t, drifted, "an exporter without credentials isn't drift")
+	assert.Contains(t, out.String(), "new-exporter not compared, its credentials are not issued yet")
+}
+
 func TestOutputBuffer(t *testing.T) {
 	t.Run("Printf writes to buffer", func(t *testing.T) {
 		out := NewOutputBuffer("host-1", 3)
@@ -316,6 +385,33 @@ func TestSyncPrinterCounters(t *testing.T) {
 		assert.Equal(t, int32(1), printer.failedCount.Load())
 	})
 
+	t.Run("interrupted takes precedence over error", func(t *testing.T) {
+		printer := NewSyncPrinter()
+
+		buf := NewOutputBuffer("interrupted-host", 3)
+		buf.MarkError()
+		buf.MarkInterrupted(2)
+		buf.Done()
+		printer.FlushBuffer(buf)
+
+		assert.Equal(t, int32(0), printer.failedCount.Load())
+		assert.Equal(t, int32(1), printer.interrupted.Load())
+		assert.Equal(t, int32(2), printer.notProcessed.Load())
+		assert.Equal(t, []string{"interrupted-host"}, printer.interruptedHosts)
+	})
+
+	t.Run("deferred actions are listed per host", func(t *testing.T) {
+		printer := NewSyncPrinter()
+
+		buf := NewOutputBuffer("leased-host", 2)
+		buf.MarkDeferred("restart of exporter-1, leased by lease-1")
+		buf.Done()
+		printer.FlushBuffer(buf)
+
+		assert.Equal(t, int32(1), printer.okCount.Load())
+		assert.Equal(t, []string{"leased-host: restart of exporter-1, leased by lease-1"}, printer.deferred)
+	})
+
 	t.Run("AddRetryStats accumulates", func(t *testing.T) {
 		printer := NewSyncPrinter()
 		printer.AddRetryStats(5, 3)
@@ -476,3 +572,645 @@ func TestFilterExporterInstances_SkipsUnmanaged(t *testing.T) {
 		assert.Nil(t, filtered)
 	})
 }
+
+func TestProcessGlobalRetryQueue_Interrupted(t *testing.T) {
+	e := NewExporterHostSyncer(nil, nil, nil, false, false, nil, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	retryQueue := []RetryItem{
+		{HostName: "host-1", Attempts: 1},
+		{HostName: "host-2", Attempts: 1, ExporterInstance: &v1alpha1.ExporterInstance{
+			ObjectMeta: metav1.ObjectMeta{Name: "exporter-1"},
+		}},
+	}
+
+	start := time.Now()
+	succeeded, failedHosts, err := e.processGlobalRetryQueue(ctx, retryQueue, NewSyncPrinter())
+	assert.Equal(t, int32(0), succeeded)
+	assert.Equal(t, []string{"host-1", "host-2"}, failedHosts)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "bootc upgrade on host-1: interrupted")
+	assert.Contains(t, err.Error(), "instance exporter-1 on host-2: interrupted")
+	assert.Less(t, time.Since(start), time.Second, "no retry should be attempted")
+}
+
+func TestRetryStage(t *testing.T) {
+	e := NewExporterHostSyncer(&config.Config{Loaded: &config.LoadedLabConfig{}}, nil, nil, false, false, nil, 1)
+	localHost := func(name string) *v1alpha1.ExporterHost {
+		return &v1alpha1.ExporterHost{ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: v1alpha1.ExporterHostSpec{Management: v1alpha1.Management{
+				Local: &v1alpha1.LocalManagement{Root: t.TempDir()}}}}
+	}
+
+	failures, err := e.retryStage(context.Background(), nil, NewSyncPrinter())
+	require.NoError(t, err)
+	assert.Zero(t, failures)
+
+	recovered := []RetryItem{{HostName: "host-1", RenderedHost: localHost("host-1"), Attempts: 1,
+		LastError: errors.New("unreachable")}}
+	failures, err = e.retryStage(context.Background(), recovered, NewSyncPrinter())
+	require.NoError(t, err)
+	assert.Zero(t, failures, "the host recovered on retry")
+
+	givenUp := []RetryItem{
+		{HostName: "host-1", RenderedHost: localHost("host-1"), Attempts: 1, LastError: errors.New("unreachable")},
+		{HostName: "host-2", RenderedHost: localHost("host-2"), Attempts: e.retryConfig.MaxAttempts,
+			LastError: errors.New("unreachable")},
+	}
+	failures, err = e.retryStage(context.Background(), givenUp, NewSyncPrinter())
+	assert.ErrorContains(t, err, "bootc upgrade on host-2: unreachable")
+	assert.Equal(t, 1, failures, "only the host whose retries were given up counts as failed")
+}
+
+func TestHostKeyVerifier_PinsOnFirstUse(t *testing.T) {
+	sourceFile := filepath.Join(t.TempDir(), "sidekick.yaml")
+	require.NoError(t, os.WriteFile(sourceFile, []byte(`apiVersion: meta.jumpstarter.dev/v1alpha1
+kind: ExporterHost
+metadata:
+  name: sidekick-1
+spec: {}
+`), 0644))
+	cfg := &config.Config{Loaded: &config.LoadedLabConfig{
+		SourceFiles: map[string]map[string]string{"ExporterHost": {"sidekick-1": sourceFile}},
+	}}
+	host := &v1alpha1.ExporterHost{ObjectMeta: metav1.ObjectMeta{Name: "sidekick-1"}}
+
+	t.Run("dry run", func(t *testing.T) {
+		e := NewExporterHostSyncer(cfg, nil, nil, true, false, nil, 1)
+		out := NewOutputBuffer("sidekick-1", 0)
+		require.NoError(t, e.hostKeyVerifier(out).OnFirstUse(host, "SHA256:abc"))
+		assert.Contains(t, out.buf.String(), "Would trust host key SHA256:abc")
+		data, err := os.ReadFile(sourceFile)
+		require.NoError(t, err)
+		assert.NotContains(t, string(data), v1alpha1.SSHHostKeyAnnotation)
+	})
+
+	t.Run("apply", func(t *testing.T) {
+		e := NewExporterHostSyncer(cfg, nil, nil, false, false, nil, 1)
+		e.SetTrustOnFirstUse(true)
+		out := NewOutputBuffer("sidekick-1", 0)
+		verifier := e.hostKeyVerifier(out)
+		assert.True(t, verifier.TrustOnFirstUse)
+		require.NoError(t, verifier.OnFirstUse(host, "SHA256:abc"))
+		assert.Equal(t, "SHA256:abc", host.Annotations[v1alpha1.SSHHostKeyAnnotation])
+		data, err := os.ReadFile(sourceFile)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), v1alpha1.SSHHostKeyAnnotation+`: "SHA256:abc"`)
+	})
+
+	t.Run("unknown source file", func(t *testing.T) {
+		e := NewExporterHostSyncer(cfg, nil, nil, false, false, nil, 1)
+		other := &v1alpha1.ExporterHost{ObjectMeta: metav1.ObjectMeta{Name: "other"}}
+		err := e.hostKeyVerifier(NewOutputBuffer("other", 0)).OnFirstUse(other, "SHA256:abc")
+		require.ErrorContains(t, err, "source file of exporter host other is unknown")
+	})
+}
+
+func TestSyncExporterHosts_MissingKnownHosts(t *testing.T) {
+	cfg := &config.Config{BaseDir: t.TempDir(), KnownHosts: "known_hosts", Loaded: &config.LoadedLabConfig{}}
+	e := NewExporterHostSyncer(cfg, nil, nil, false, false, nil, 1)
+
+	err := e.SyncExporterHosts(context.Background())
+	require.ErrorContains(t, err, "known_hosts file "+filepath.Join(cfg.BaseDir, "known_hosts")+" not found")
+
+	_, err = e.DiffExporterHosts(context.Background(), io.Discard)
+	require.ErrorContains(t, err, "not found")
+}
+
+// fakeHostManager records the stopped, decommissioned and verified exporters, the other HostManager
+// methods are not implemented
+type fakeHostManager struct {
+	manager.HostManager
+	managed        map[string]string
+	stopped        []string
+	decommissioned []string
+	decommissionFn func(svcName string) error
+	verified       []string
+	bootcImages    *manager.BootcImages
+	bootcUpdating  bool
+}
+
+func (f *fakeHostManager) BootcImages() (*manager.BootcImages, error) {
+	return f.bootcImages, nil
+}
+
+func (f *fakeHostManager) BootcUpdating() bool {
+	return f.bootcUpdating
+}
+
+func (f *fakeHostManager) Close() error {
+	return nil
+}
+
+func (f *fakeHostManager) VerifyExporter(exporterConfig *v1alpha1.ExporterConfigTemplate, timeout time.Duration, online manager.OnlineCheck) error {
+	svcName := exporterConfig.Spec.ExporterMetadata.Name
+	f.verified = append(f.verified, svcName)
+	if isOnline, err := online(context.Background()); err != nil || !isOnline {
+		return &manager.HealthError{Service: svcName, Reason: "offline", RolledBack: true}
+	}
+	return nil
+}
+
+func (f *fakeHostManager) StopExporter(svcName string, dryRun bool) (bool, error) {
+	f.stopped = append(f.stopped, svcName)
+	return true, nil
+}
+
+func (f *fakeHostManager) ManagedExporters() (map[string]string, error) {
+	return f.managed, nil
+}
+
+func (f *fakeHostManager) Decommission(svcName string, dryRun bool) error {
+	if f.decommissionFn != nil {
+		if err := f.decommissionFn(svcName); err != nil {
+			return err
+		}
+	}
+	f.decommissioned = append(f.decommissioned, svcName)
+	return nil
+}
+
+func TestPruneOrphanedExporters(t *testing.T) {
+	instance := func(name, hostName string) *v1alpha1.ExporterInstance {
+		return &v1alpha1.ExporterInstance{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       v1alpha1.ExporterInstanceSpec{ExporterHostRef: v1alpha1.ExporterHostRef{Name: hostName}},
+		}
+	}
+	cfg := &config.Config{Loaded: &config.LoadedLabConfig{ExporterInstances: map[string]*v1alpha1.ExporterInstance{
+		"kept":  instance("kept", "host-1"),
+		"moved": instance("moved", "host-2"),
+	}}}
+	managed := map[string]string{
+		"kept-svc":    "kept",
+		"moved-svc":   "moved",
+		"deleted-svc": "deleted",
+		"lab2-svc":    "lab2-deleted",
+	}
+
+	t.Run("decommissions the removed and moved exporters", func(t *testing.T) {
+		e := NewExporterHostSyncer(cfg, nil, nil, false, false, nil, 1)
+		hostSsh := &fakeHostManager{managed: managed}
+		out := NewOutputBuffer("host-1", 0)
+		e.pruneOrphanedExporters("host-1", hostSsh, out)
+		assert.Equal(t, []string{"deleted-svc", "lab2-svc", "moved-svc"}, hostSsh.decommissioned)
+		assert.Contains(t, out.buf.String(), "Exporter deleted-svc (instance deleted) is no longer in the configuration")
+		assert.True(t, out.hasChanges)
+	})
+
+	t.Run("honors the exporter filter", func(t *testing.T) {
+		e := NewExporterHostSyncer(cfg, nil, nil, false, false, regexp.MustCompile("^lab2-"), 1)
+		hostSsh := &fakeHostManager{managed: managed}
+		e.pruneOrphanedExporters("host-1", hostSsh, NewOutputBuffer("host-1", 0))
+		assert.Equal(t, []string{"lab2-svc"}, hostSsh.decommissioned)
+	})
+
+	t.Run("reports failures", func(t *testing.T) {
+		e := NewExporterHostSyncer(cfg, nil, nil, false, false, nil, 1)
+		hostSsh := &fakeHostManager{managed: managed, decommissionFn: func(svcName string) error {
+			if svcName == "lab2-svc" {
+				return fmt.Errorf("boom")
+			}
+			return nil
+		}}
+		out := NewOutputBuffer("host-1", 0)
+		e.pruneOrphanedExporters("host-1", hostSsh, out)
+		assert.Equal(t, []string{"deleted-svc", "moved-svc"}, hostSsh.decommissioned)
+		assert.Contains(t, out.buf.String(), "Failed to decommission lab2-svc: boom")
+		assert.True(t, out.hasErrors)
+	})
+
+	t.Run("reports render failures", func(t *testing.T) {
+		e := NewExporterHostSyncer(cfg, nil, map[string]template.ServiceParameters{":kept": {}}, false, false, nil, 1)
+		hostSsh := &fakeHostManager{managed: managed}
+		out := NewOutputBuffer("host-1", 0)
+		e.pruneOrphanedExporters("host-1", hostSsh, out)
+		assert.Equal(t, []string{"deleted-svc", "lab2-svc", "moved-svc"}, hostSsh.decommissioned,
+			"the service of the instance which can't be rendered is kept")
+		assert.Contains(t, out.buf.String(), "kept-svc kept, failed to render instance kept")
+		assert.True(t, out.hasErrors)
+	})
+}
+
+func TestPrunesHost(t *testing.T) {
+	cfg := &config.Config{Loaded: &config.LoadedLabConfig{ExporterInstances: map[string]*v1alpha1.ExporterInstance{
+		"lab1-exporter": {
+			ObjectMeta: metav1.ObjectMeta{Name: "lab1-exporter"},
+			Spec:       v1alpha1.ExporterInstanceSpec{ExporterHostRef: v1alpha1.ExporterHostRef{Name: "host-1"}},
+		},
+	}}}
+
+	e := NewExporterHostSyncer(cfg, nil, nil, false, false, nil, 1)
+	assert.False(t, e.prunesHost("host-1"))
+
+	e.SetPrune(true)
+	assert.True(t, e.prunesHost("host-1"))
+	assert.True(t, e.prunesHost("host-2"), "without a filter every host is checked")
+
+	e = NewExporterHostSyncer(cfg, nil, nil, false, false, regexp.MustCompile("^lab1-"), 1)
+	e.SetPrune(true)
+	assert.True(t, e.prunesHost("host-1"))
+	assert.False(t, e.prunesHost("host-2"), "the hosts without a matching exporter instance are not checked")
+}
+
+func TestStopDeadExporters(t *testing.T) {
+	dead := func(name string) *v1alpha1.ExporterInstance {
+		return &v1alpha1.ExporterInstance{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: map[string]string{v1alpha1.DeadAnnotation: "broken DUT"}},
+			Spec:       v1alpha1.ExporterInstanceSpec{ExporterHostRef: v1alpha1.ExporterHostRef{Name: "host-1"}},
+		}
+	}
+	unmanaged := dead("unmanaged")
+	unmanaged.Annotations[v1alpha1.UnmanagedAnnotation] = "2026-02-10"
+	cfg := &config.Config{Loaded: &config.LoadedLabConfig{ExporterInstances: map[string]*v1alpha1.ExporterInstance{
+		"dead":      dead("dead"),
+		"unknown":   dead("unknown"),
+		"unmanaged": unmanaged,
+		"alive": {
+			ObjectMeta: metav1.ObjectMeta{Name: "alive"},
+			Spec:       v1alpha1.ExporterInstanceSpec{ExporterHostRef: v1alpha1.ExporterHostRef{Name: "host-1"}},
+		},
+	}}}
+
+	e := NewExporterHostSyncer(cfg, nil, nil, false, false, nil, 1)
+	hostSsh := &fakeHostManager{managed: map[string]string{"dead-svc": "dead", "alive-svc": "alive", "unmanaged-svc": "unmanaged"}}
+	out := NewOutputBuffer("host-1", 0)
+	e.stopDeadExporters("host-1", hostSsh, out)
+
+	assert.Equal(t, []string{"dead-svc"}, hostSsh.stopped)
+	assert.Contains(t, out.buf.String(), "Exporter instance: dead is dead: broken DUT")
+	assert.Contains(t, out.buf.String(), "Failed to stop dead exporter unknown: unknown exporter service")
+	assert.True(t, out.hasChanges)
+	assert.True(t, out.hasErrors)
+}
+
+func TestVerifyExporter(t *testing.T) {
+	exporterInstance := func(name string) *v1alpha1.ExporterInstance {
+		return &v1alpha1.ExporterInstance{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	}
+	tcfg := func(name string) *v1alpha1.ExporterConfigTemplate {
+		tcfg := &v1alpha1.ExporterConfigTemplate{}
+		tcfg.Spec.ExporterMetadata.Name = name + "-svc"
+		return tcfg
+	}
+	online := func(_ context.Context, exporterInstance *v1alpha1.ExporterInstance) (bool, error) {
+		return exporterInstance.Name == "online", nil
+	}
+
+	t.Run("checks the exporter of the instance", func(t *testing.T) {
+		e := NewExporterHostSyncer(&config.Config{}, nil, nil, false, false, nil, 1)
+		e.SetHealthCheck(time.Minute, online)
+		hostSsh := &fakeHostManager{}
+
+		require.NoError(t, e.verifyExporter(exporterInstance("online"), tcfg("online"), hostSsh))
+		err := e.verifyExporter(exporterInstance("offline"), tcfg("offline"), hostSsh)
+		require.True(t, manager.IsHealthError(err))
+		assert.Equal(t, []string{"online-svc", "offline-svc"}, hostSsh.verified)
+
+		out := NewOutputBuffer("host-1", 1)
+		e.healthCheckFailed("offline", err, out)
+		assert.Contains(t, out.buf.String(), "offline-svc is not healthy after the update, rolled back: offline")
+		assert.True(t, out.hasErrors)
+		assert.Equal(t, []string{"offline"}, e.unhealthyExporters)
+	})
+
+	t.Run("skipped on dry run or without timeout", func(t *testing.T) {
+		hostSsh := &fakeHostManager{}
+		dryRun := NewExporterHostSyncer(&config.Config{}, nil, nil, true, false, nil, 1)
+		dryRun.SetHealthCheck(time.Minute, online)
+		require.NoError(t, dryRun.verifyExporter(exporterInstance("offline"), tcfg("offline"), hostSsh))
+
+		disabled := NewExporterHostSyncer(&config.Config{}, nil, nil, false, false, nil, 1)
+		disabled.SetHealthCheck(0, online)
+		require.NoError(t, disabled.verifyExporter(exporterInstance("offline"), tcfg("offline"), hostSsh))
+		assert.Empty(t, hostSsh.verified)
+	})
+}
+
+func TestBootcImageDiff(t *testing.T) {
+	host := &v1alpha1.ExporterHost{}
+	hostSsh := &fakeHostManager{bootcImages: &manager.BootcImages{Booted: "quay.io/lab/sidekick:1.0"}}
+
+	diff, err := bootcImageDiff(host, hostSsh)
+	require.NoError(t, err)
+	assert.Empty(t, diff, "the image isn't checked without ContainerImage")
+
+	host.Spec.ContainerImage = "quay.io/lab/sidekick:1.1"
+	diff, err = bootcImageDiff(host, hostSsh)
+	require.NoError(t, err)
+	assert.Equal(t, "🖼️  Bootc image: booted quay.io/lab/sidekick:1.0, want quay.io/lab/sidekick:1.1\n", diff)
+
+	hostSsh.bootcImages.Staged = "quay.io/lab/sidekick:1.1"
+	diff, err = bootcImageDiff(host, hostSsh)
+	require.NoError(t, err)
+	assert.Equal(t, "🖼️  Bootc image: booted quay.io/lab/sidekick:1.0, staged quay.io/lab/sidekick:1.1, want quay.io/lab/sidekick:1.1\n",
+		diff, "the desired image is staged but not booted")
+
+	hostSsh.bootcImages = &manager.BootcImages{Booted: "quay.io/lab/sidekick:1.1"}
+	diff, err = bootcImageDiff(host, hostSsh)
+	require.NoError(t, err)
+	assert.Empty(t, diff, "the desired image is booted")
+
+	diff, err = bootcImageDiff(host, &fakeHostManager{})
+	require.NoError(t, err)
+	assert.Empty(t, diff, "not a bootc host")
+}
+
+func TestRolloutStages(t *testing.T) {
+	hostWorks := func(names ...string) []hostWork {
+		var work []hostWork
+		for _, name := range names {
+			host := &v1alpha1.ExporterHost{ObjectMeta: metav1.ObjectMeta{Name: name}}
+			if name == "labeled" {
+				host.Labels = map[string]string{"rollout": "canary"}
+			}
+			work = append(work, hostWork{host: host, hostName: name})
+		}
+		return work
+	}
+	stageHosts := func(stages []rolloutStage) map[string][]string {
+		hosts := make(map[string][]string)
+		for _, stage := range stages {
+			for _, w := range stage.hosts {
+				hosts[stage.name] = append(hosts[stage.name], w.hostName)
+			}
+		}
+		return hosts
+	}
+	work := hostWorks("host-1", "host-2", "labeled", "host-3", "host-4", "host-5")
+
+	stages := RolloutStrategy{}.stages(work)
+	require.Len(t, stages, 1, "all the hosts are synced together by default")
+	assert.Len(t, stages[0].hosts, 6)
+
+	selector, err := labels.Parse("rollout=canary")
+	require.NoError(t, err)
+	stages = RolloutStrategy{CanaryHosts: []string{"host-3"}, CanarySelector: selector, MaxUnavailable: 2}.stages(work)
+	require.Len(t, stages, 3)
+	assert.Equal(t, map[string][]string{
+		"canaries":  {"labeled", "host-3"},
+		"batch 1/2": {"host-1", "host-2"},
+		"batch 2/2": {"host-4", "host-5"},
+	}, stageHosts(stages))
+	assert.Equal(t, "canaries", stages[0].name)
+
+	assert.Empty(t, RolloutStrategy{MaxUnavailable: 2}.stages(nil))
+}
+
+func TestRolloutValidate(t *testing.T) {
+	hosts := map[string]*v1alpha1.ExporterHost{
+		"host-1": {ObjectMeta: metav1.ObjectMeta{Name: "host-1"}},
+		"host-2": {ObjectMeta: metav1.ObjectMeta{Name: "host-2", Labels: map[string]string{"rollout": "canary"}}},
+	}
+	work := []hostWork{{host: hosts["host-1"], hostName: "host-1"}}
+	selector, err := labels.Parse("rollout=canary")
+	require.NoError(t, err)
+
+	require.NoError(t, RolloutStrategy{}.validate(hosts, work))
+	require.NoError(t, RolloutStrategy{CanaryHosts: []string{"host-1"}}.validate(hosts, work))
+	require.ErrorContains(t, RolloutStrategy{CanaryHosts: []string{"host-1", "hots-2"}}.validate(hosts, work),
+		"unknown --canary-hosts: hots-2")
+	require.ErrorContains(t, RolloutStrategy{CanaryHosts: []string{"host-2"}}.validate(hosts, work),
+		"the canary stage is empty", "the canary is filtered out")
+	require.ErrorContains(t, RolloutStrategy{CanarySelector: selector}.validate(hosts, work),
+		"the canary stage is empty")
+}
+
+func TestRolloutFailed(t *testing.T) {
+	strict := RolloutStrategy{}
+	assert.False(t, strict.failed(0, 3))
+	assert.True(t, strict.failed(1, 3), "any failure stops the rollout by default")
+
+	tolerant := RolloutStrategy{MaxFailurePercent: 25}
+	assert.False(t, tolerant.failed(1, 4))
+	assert.True(t, tolerant.failed(2, 4))
+}
+
+func TestBootcScheduler(t *testing.T) {
+	restore := now
+	t.Cleanup(func() { now = restore })
+	// a Wednesday
+	now = func() time.Time { return time.Date(2025, 6, 4, 12, 0, 0, 0, time.UTC) }
+
+	cfg := &config.Config{Loaded: &config.LoadedLabConfig{PhysicalLocations: map[string]*v1alpha1.PhysicalLocation{
+		"lab": {Spec: v1alpha1.PhysicalLocationSpec{MaintenanceWindows: []v1alpha1.MaintenanceWindow{
+			{Days: []string{"Sat", "Sun"}, Start: "02:00", End: "06:00"},
+		}}},
+	}}}
+	e := NewExporterHostSyncer(cfg, nil, nil, false, false, nil, 1)
+	e.SetMaxConcurrentUpgrades(2)
+	host := func(location string, windows ...v1alpha1.MaintenanceWindow) *v1alpha1.ExporterHost {
+		return &v1alpha1.ExporterHost{Spec: v1alpha1.ExporterHostSpec{
+			LocationRef: v1alpha1.LocationRef{Name: location}, MaintenanceWindows: windows}}
+	}
+	scheduler := func(host *v1alpha1.ExporterHost, hostName string) manager.BootcScheduler {
+		return e.bootcScheduler(context.Background(), host, hostName, NewOutputBuffer(hostName, 0))
+	}
+
+	pending := scheduler(host("lab"), "in-lab").Start()
+	assert.Equal(t, "outside the maintenance windows Sat,Sun 02:00-06:00 UTC", pending)
+
+	ownWindow := v1alpha1.MaintenanceWindow{Start: "11:00", End: "13:00"}
+	assert.Empty(t, scheduler(host("lab", ownWindow), "own-window").Start(),
+		"the windows of the host replace the ones of its location")
+
+	scheduler(host(""), "updating").Updating()
+	assert.Equal(t, "2 exporter hosts are already updating their bootc image",
+		scheduler(host(""), "over-cap").Start())
+	assert.Empty(t, scheduler(host(""), "own-window").Start(), "a started update keeps its slot")
+
+	assert.Equal(t, map[string]string{
+		"in-lab":   "outside the maintenance windows Sat,Sun 02:00-06:00 UTC",
+		"over-cap": "2 exporter hosts are already updating their bootc image",
+	}, e.bootcUpdates.pending)
+}
+
+func TestCountBootcUpdates(t *testing.T) {
+	restore := backends
+	t.Cleanup(func() { backends = restore })
+	backends = []Backend{{
+		Name:    "fake",
+		Manages: func(*v1alpha1.Management) bool { return true },
+		New: func(_ context.Context, exporterHost *v1alpha1.ExporterHost, _ BackendOptions) (manager.HostManager, error) {
+			if exporterHost.Name == "unreachable" {
+				return nil, errors.New("unreachable")
+			}
+			return &fakeHostManager{bootcUpdating: strings.HasPrefix(exporterHost.Name, "updating")}, nil
+		},
+	}}
+	var work []hostWork
+	for _, name := range []string{"idle-1", "unreachable", "updating-1", "idle-2", "updating-2"} {
+		work = append(work, hostWork{host: &v1alpha1.ExporterHost{ObjectMeta: metav1.ObjectMeta{Name: name}}, hostName: name})
+	}
+
+	e := NewExporterHostSyncer(&config.Config{Loaded: &config.LoadedLabConfig{}}, nil, nil, false, false, nil, 2)
+	e.countBootcUpdates(context.Background(), work)
+	assert.Empty(t, e.bootcUpdates.updating, "not checked without a cap")
+
+	e.SetMaxConcurrentUpgrades(2)
+	e.countBootcUpdates(context.Background(), work)
+	assert.Equal(t, map[string]bool{"updating-1": true, "updating-2": true}, e.bootcUpdates.updating)
+	scheduler := e.bootcScheduler(context.Background(), work[0].host, "idle-1", NewOutputBuffer("idle-1", 0))
+	assert.Equal(t, "2 exporter hosts are already updating their bootc image", scheduler.Start(),
+		"the updates in progress on the hosts synced later count too")
+}
+
+func TestLeaseCheck(t *testing.T) {
+	instance := func(name string, annotations map[string]string) *v1alpha1.ExporterInstance {
+		return &v1alpha1.ExporterInstance{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: annotations},
+			Spec:       v1alpha1.ExporterInstanceSpec{ExporterHostRef: v1alpha1.ExporterHostRef{Name: "host-1"}},
+		}
+	}
+	cfg := &config.Config{Loaded: &config.LoadedLabConfig{ExporterInstances: map[string]*v1alpha1.ExporterInstance{
+		"idle":   instance("idle", nil),
+		"leased": instance("leased", nil),
+		"dead":   instance("dead", map[string]string{v1alpha1.DeadAnnotation: "broken board"}),
+	}}}
+	leases := map[string]string{"leased": "lease-1", "dead": "lease-2"}
+	leased := func(ctx context.Context, exporterInstance *v1alpha1.ExporterInstance) (string, error) {
+		return leases[exporterInstance.Name], nil
+	}
+
+	e := NewExporterHostSyncer(cfg, nil, nil, false, false, nil, 1)
+	e.SetLeaseCheck(false, leased)
+	out := NewOutputBuffer("host-1", 2)
+
+	lease, err := e.leaseCheck(cfg.Loaded.ExporterInstances["leased"], out)(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "lease-1", lease)
+	lease, err = e.leaseCheck(cfg.Loaded.ExporterInstances["idle"], out)(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, lease)
+
+	pending := e.bootcScheduler(context.Background(), &v1alpha1.ExporterHost{}, "host-1", out).Start()
+	assert.Equal(t, "exporter leased is leased by lease-1", pending)
+	assert.Equal(t, []string{
+		"restart of leased, leased by lease-1",
+		"bootc update, exporter leased is leased by lease-1",
+	}, out.deferred)
+
+	e.SetLeaseCheck(true, leased)
+	assert.Nil(t, e.leaseCheck(cfg.Loaded.ExporterInstances["leased"], out), "the restarts are forced")
+	assert.Empty(t, e.bootcScheduler(context.Background(), &v1alpha1.ExporterHost{}, "host-2", out).Start())
+}
+
+func TestRemediation(t *testing.T) {
+	restore, restoreInterval := now, bootPollInterval
+	t.Cleanup(func() { now, bootPollInterval = restore, restoreInterval })
+	now = func() time.Time { return time.Date(2025, 6, 4, 12, 0, 0, 0, time.UTC) }
+	bootPollInterval = time.Millisecond
+
+	cfg := &config.Config{Loaded: &config.LoadedLabConfig{}}
+	host := func(name string) *v1alpha1.ExporterHost {
+		return &v1alpha1.ExporterHost{ObjectMeta: metav1.ObjectMeta{Name: name}, Spec: v1alpha1.ExporterHostSpec{
+			Power: v1alpha1.Power{SNMP: v1alpha1.SNMPPower{Host: "pdu", User: "admin", OID: "1.3.6.1", Plug: 3}}}}
+	}
+	unreachable := &ssh.UnreachableError{Addr: "sidekick:22", Err: errors.New("connection refused")}
+	newSyncer := func(t *testing.T, policy RemediationPolicy, reachableAfter int) (*ExporterHostSyncer, *[]string) {
+		e := NewExporterHostSyncer(cfg, nil, nil, false, false, nil, 1)
+		require.NoError(t, e.SetRemediation(policy))
+		var cycled []string
+		e.remediation.cycle = func(ctx context.Context, host *v1alpha1.ExporterHost) error {
+			cycled = append(cycled, host.Name)
+			return nil
+		}
+		attempts := 0
+		e.remediation.reachable = func(ctx context.Context, host *v1alpha1.ExporterHost) error {
+			if attempts++; attempts < reachableAfter {
+				return fmt.Errorf("connection refused")
+			}
+			return nil
+		}
+		return e, &cycled
+	}
+	stateFile := filepath.Join(t.TempDir(), "state", "power-cycles.json")
+	policy := RemediationPolicy{After: 2, MaxPowerCycles: 1, Cooldown: time.Hour, BootTimeout: time.Second,
+		StateFile: stateFile}
+
+	t.Run("power cycles after the failed attempts", func(t *testing.T) {
+		e, cycled := newSyncer(t, policy, 3)
+		sidekick := host("sidekick-1")
+		items := []RetryItem{{HostName: "sidekick-1", LastAttemptTime: now()}}
+		out := NewOutputBuffer("sidekick-1", 1)
+
+		e.sshFailed(context.Background(), sidekick, "sidekick-1", unreachable, items, out)
+		assert.Empty(t, *cycled, "not before the second failed attempt")
+		e.sshFailed(context.Background(), sidekick, "sidekick-1", unreachable, items, out)
+		assert.Equal(t, []string{"sidekick-1"}, *cycled)
+		assert.Contains(t, out.buf.String(), "sidekick-1 is back after the power cycle")
+		assert.True(t, items[0].LastAttemptTime.IsZero(), "the items are retried right away")
+		data, err := os.ReadFile(stateFile)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"sidekick-1": "2025-06-04T12:00:00Z"}`, string(data))
+
+		e.sshFailed(context.Background(), host("sidekick-2"), "sidekick-2", unreachable, nil, out)
+		e.sshFailed(context.Background(), host("sidekick-2"), "sidekick-2", unreachable, nil, out)
+		assert.Equal(t, []string{"sidekick-1"}, *cycled)
+		assert.Contains(t, out.buf.String(), "Not power cycling sidekick-2: 1 exporter hosts were already power cycled in this run")
+	})
+
+	t.Run("cooldown", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(stateFile, []byte(`{"sidekick-1": "2025-06-04T11:30:00Z"}`), 0o644))
+		e, cycled := newSyncer(t, RemediationPolicy{After: 1, Cooldown: time.Hour, BootTimeout: time.Second,
+			StateFile: stateFile}, 1)
+		out := NewOutputBuffer("sidekick-1", 1)
+		e.sshFailed(context.Background(), host("sidekick-1"), "sidekick-1", unreachable, nil, out)
+		assert.Empty(t, *cycled)
+		assert.Contains(t, out.buf.String(), "power cycled 30m0s ago, within the 1h0m0s cooldown")
+	})
+
+	t.Run("not back in time", func(t *testing.T) {
+		e, cycled := newSyncer(t, RemediationPolicy{After: 1, BootTimeout: 20 * time.Millisecond}, 1000)
+		items := []RetryItem{{HostName: "sidekick-3", LastAttemptTime: now()}}
+		out := NewOutputBuffer("sidekick-3", 1)
+		e.sshFailed(context.Background(), host("sidekick-3"), "sidekick-3", unreachable, items, out)
+		assert.Equal(t, []string{"sidekick-3"}, *cycled)
+		assert.Contains(t, out.buf.String(), "sidekick-3 is not reachable over SSH after the power cycle")
+		assert.False(t, items[0].LastAttemptTime.IsZero())
+	})
+
+	t.Run("only unreachable hosts", func(t *testing.T) {
+		e, cycled := newSyncer(t, RemediationPolicy{After: 1, BootTimeout: time.Second}, 1)
+		out := NewOutputBuffer("sidekick-4", 1)
+		for _, err := range []error{
+			errors.New("ssh: unable to authenticate"),
+			fmt.Errorf("failed to connect to jump host: %w", errors.New("connection refused")),
+		} {
+			e.sshFailed(context.Background(), host("sidekick-4"), "sidekick-4", err, nil, out)
+		}
+		assert.Empty(t, *cycled)
+		assert.Empty(t, out.buf.String())
+	})
+
+	t.Run("leased", func(t *testing.T) {
+		leasedCfg := &config.Config{Loaded: &config.LoadedLabConfig{ExporterInstances: map[string]*v1alpha1.ExporterInstance{
+			"exporter": {ObjectMeta: metav1.ObjectMeta{Name: "exporter"},
+				Spec: v1alpha1.ExporterInstanceSpec{ExporterHostRef: v1alpha1.ExporterHostRef{Name: "sidekick-5"}}},
+		}}}
+		e, cycled := newSyncer(t, RemediationPolicy{After: 1, MaxPowerCycles: 1, BootTimeout: time.Second}, 1)
+		e.cfg = leasedCfg
+		e.SetLeaseCheck(false, func(context.Context, *v1alpha1.ExporterInstance) (string, error) {
+			return "lease-1", nil
+		})
+		out := NewOutputBuffer("sidekick-5", 1)
+		e.sshFailed(context.Background(), host("sidekick-5"), "sidekick-5", unreachable, nil, out)
+		assert.Empty(t, *cycled)
+		assert.Contains(t, out.buf.String(), "Not power cycling sidekick-5: exporter exporter is leased by lease-1")
+		assert.Empty(t, e.remediation.cycled, "the leased host doesn't count in the cap")
+	})
+
+	t.Run("policy", func(t *testing.T) {
+		e := NewExporterHostSyncer(cfg, nil, nil, false, false, nil, 1)
+		assert.Error(t, e.SetRemediation(RemediationPolicy{After: 9}), "the retries would be given up first")
+		corrupted := filepath.Join(t.TempDir(), "power-cycles.json")
+		require.NoError(t, os.WriteFile(corrupted, []byte("{"), 0o644))
+		assert.ErrorContains(t, e.SetRemediation(RemediationPolicy{After: 1, StateFile: corrupted}),
+			"failed to parse the power cycle state", "the run fails before the sync")
+		require.NoError(t, e.SetRemediation(RemediationPolicy{}))
+		out := NewOutputBuffer("sidekick-1", 1)
+		e.sshFailed(context.Background(), host("sidekick-1"), "sidekick-1", unreachable, nil, out)
+		assert.Empty(t, out.buf.String(), "disabled by default")
+	})
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2025. The Jumpstarter Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package host
+
+import (
+	"context"
+	"fmt"
+
+	api "github.com/jumpstarter-dev/jumpstarter-lab-config/api/v1alpha1"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/exporter/manager"
+)
+
+// ExporterLeaseFunc returns the active lease of the exporter of an instance, empty when it isn't leased
+type ExporterLeaseFunc func(ctx context.Context, exporterInstance *api.ExporterInstance) (string, error)
+
+// SetLeaseCheck defers the disruptive restarts of the exporters leased according to leased (when not
+// nil), and the bootc updates of their hosts, unless force is set
+func (e *ExporterHostSyncer) SetLeaseCheck(force bool, leased ExporterLeaseFunc) {
+	e.forceRestarts = force
+	e.exporterLeased = leased
+}
+
+// exporterLease returns why the disruptive actions on the exporter of an instance are deferred, or
+// an empty string
+func (e *ExporterHostSyncer) exporterLease(ctx context.Context, exporterInstance *api.ExporterInstance) string {
+	if e.forceRestarts || e.exporterLeased == nil {
+		return ""
+	}
+	lease, err := e.exporterLeased(ctx, exporterInstance)
+	if err != nil {
+		return fmt.Sprintf("lease check failed: %v", err)
+	}
+	if lease == "" {
+		return ""
+	}
+	return "leased by " + lease
+}
+
+// leaseCheck returns the lease check of the restarts of the exporter of an instance, the deferred
+// restarts are reported in out
+func (e *ExporterHostSyncer) leaseCheck(exporterInstance *api.ExporterInstance, out *OutputBuffer) manager.LeaseCheck {
+	if e.forceRestarts || e.exporterLeased == nil {
+		return nil
+	}
+	return func(ctx context.Context) (string, error) {
+		lease, err := e.exporterLeased(ctx, exporterInstance)
+		if err != nil {
+			out.MarkDeferred(fmt.Sprintf("restart of %s, lease check failed: %v", exporterInstance.Name, err))
+		} else if lease != "" {
+			out.MarkDeferred(fmt.Sprintf("restart of %s, leased by %s", exporterInstance.Name, lease))
+		}
+		return lease, err
+	}
+}
+
+// hostLease returns why the bootc updates of a host are deferred because of the leases of its
+// exporters, or an empty string
+func (e *ExporterHostSyncer) hostLease(ctx context.Context, hostName string) string {
+	if e.forceRestarts || e.exporterLeased == nil {
+		return ""
+	}
+	for _, exporterInstance := range e.cfg.Loaded.GetExporterInstancesByExporterHost(hostName) {
+		if dead, _ := exporterInstance.IsDead(); dead {
+			continue
+		}
+		if reason := e.exporterLease(ctx, exporterInstance); reason != "" {
+			return fmt.Sprintf("exporter %s is %s", exporterInstance.Name, reason)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,206 @@
+/*
+Copyright 2025. The Jumpstarter Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package host
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	api "github.com/jumpstarter-dev/jumpstarter-lab-config/api/v1alpha1"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/exporter/manager"
+)
+
+// now returns the current time, the maintenance windows are checked against it
+var now = time.Now
+
+// bootcUpdates tracks the hosts updating their bootc image during a sync, across the fleet
+type bootcUpdates struct {
+	mu sync.Mutex
+	// limit caps the hosts updating at once, unlimited when zero
+	limit int
+	// updating holds the hosts already updating or whose update was started
+	updating map[string]bool
+	// pending holds the hosts whose update was deferred, with the reason
+	pending map[string]string
+}
+
+// SetMaxConcurrentUpgrades caps the exporter hosts updating their bootc image at once across the
+// fleet, counting the updates already in progress, unlimited when zero
+func (e *ExporterHostSyncer) SetMaxConcurrentUpgrades(limit int) {
+	e.bootcUpdates.limit = limit
+}
+
+// countBootcUpdates records the hosts already updating their bootc image before any update is
+// started, so the cap of concurrent updates counts them whatever order the hosts are synced in.
+// The hosts which can't be reached are checked again when they are synced.
+func (e *ExporterHostSyncer) countBootcUpdates(ctx context.Context, work []hostWork) {
+	if e.bootcUpdates.limit <= 0 {
+		return
+	}
+	g, _ := errgroup.WithContext(ctx)
+	if e.parallelism > 0 {
+		g.SetLimit(e.parallelism)
+	}
+	for _, w := range work {
+		g.Go(func() error {
+			if ctx.Err() != nil {
+				return nil
+			}
+			hostSsh, err := e.newHostManager(ctx, w.host, NewOutputBuffer(w.hostName, 0))
+			if err != nil {
+				return nil
+			}
+			defer func() {
+				_ = hostSsh.Close()
+			}()
+			if hostSsh.BootcUpdating() {
+				e.bootcUpdates.mu.Lock()
+				defer e.bootcUpdates.mu.Unlock()
+				e.bootcUpdates.markUpdating(w.hostName)
+			}
+			return nil
+		})
+	}
+	_ = g.Wait()
+	if updating := len(e.bootcUpdates.updating); updating > 0 {
+		fmt.Printf("🖼️  %d exporter hosts are already updating their bootc image\n", updating)
+	}
+}
+
+// maintenanceWindows returns the maintenance windows of a host, the ones of its location unless
+// it has its own
+func (e *ExporterHostSyncer) maintenanceWindows(host *api.ExporterHost) []api.MaintenanceWindow {
+	if len(host.Spec.MaintenanceWindows) > 0 {
+		return host.Spec.MaintenanceWindows
+	}
+	if location := e.cfg.Loaded.GetPhysicalLocations()[host.Spec.LocationRef.Name]; location != nil {
+		return location.Spec.MaintenanceWindows
+	}
+	return nil
+}
+
+// bootcScheduler returns the scheduler of the bootc updates of a host, the updates deferred by the
+// leases of its exporters are reported in out
+func (e *ExporterHostSyncer) bootcScheduler(ctx context.Context, host *api.ExporterHost, hostName string,
+	out *OutputBuffer) manager.BootcScheduler {
+	return &hostBootcScheduler{
+		updates:  &e.bootcUpdates,
+		hostName: hostName,
+		windows:  e.maintenanceWindows(host),
+		leased: func() string {
+			reason := e.hostLease(ctx, hostName)
+			if reason != "" {
+				out.MarkDeferred("bootc update, " + reason)
+			}
+			return reason
+		},
+	}
+}
+
+// hostBootcScheduler starts the bootc updates of a host within its maintenance windows, while the
+// fleet is under the cap of concurrent updates and none of its exporters is leased
+type hostBootcScheduler struct {
+	updates  *bootcUpdates
+	hostName string
+	windows  []api.MaintenanceWindow
+	// leased returns why the exporters of the host can't be restarted, or an empty string
+	leased func() string
+}
+
+func (s *hostBootcScheduler) Start() string {
+	pending := s.start()
+	s.updates.mu.Lock()
+	defer s.updates.mu.Unlock()
+	if pending == "" {
+		delete(s.updates.pending, s.hostName)
+		return ""
+	}
+	if s.updates.pending == nil {
+		s.updates.pending = make(map[string]string)
+	}
+	s.updates.pending[s.hostName] = pending
+	return pending
+}
+
+// start reserves the update, it returns why it is pending otherwise
+func (s *hostBootcScheduler) start() string {
+	in, err := api.InMaintenanceWindows(s.windows, now())
+	if err != nil {
+		return fmt.Sprintf("invalid maintenance window: %v", err)
+	}
+	if !in {
+		windows := make([]string, 0, len(s.windows))
+		for i := range s.windows {
+			windows = append(windows, s.windows[i].String())
+		}
+		return "outside the maintenance windows " + strings.Join(windows, ", ")
+	}
+	if s.leased != nil {
+		if reason := s.leased(); reason != "" {
+			return reason
+		}
+	}
+
+	s.updates.mu.Lock()
+	defer s.updates.mu.Unlock()
+	if s.updates.updating[s.hostName] {
+		return ""
+	}
+	if s.updates.limit > 0 && len(s.updates.updating) >= s.updates.limit {
+		return fmt.Sprintf("%d exporter hosts are already updating their bootc image", len(s.updates.updating))
+	}
+	s.updates.markUpdating(s.hostName)
+	return ""
+}
+
+func (s *hostBootcScheduler) Updating() {
+	s.updates.mu.Lock()
+	defer s.updates.mu.Unlock()
+	s.updates.markUpdating(s.hostName)
+}
+
+// markUpdating records a host as updating, u.mu must be held
+func (u *bootcUpdates) markUpdating(hostName string) {
+	if u.updating == nil {
+		u.updating = make(map[string]bool)
+	}
+	u.updating[hostName] = true
+}
+
+// printPendingUpgrades reports the hosts whose bootc update was deferred
+func (e *ExporterHostSyncer) printPendingUpgrades() {
+	e.bootcUpdates.mu.Lock()
+	defer e.bootcUpdates.mu.Unlock()
+	if len(e.bootcUpdates.pending) == 0 {
+		return
+	}
+	hosts := make([]string, 0, len(e.bootcUpdates.pending))
+	for hostName := range e.bootcUpdates.pending {
+		hosts = append(hosts, hostName)
+	}
+	slices.Sort(hosts)
+	fmt.Printf("\n⏸️  Bootc upgrade pending on %d exporter hosts:\n", len(hosts))
+	for _, hostName := range hosts {
+		fmt.Printf("    %s: %s\n", hostName, e.bootcUpdates.pending[hostName])
+	}
+}
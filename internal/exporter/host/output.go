@@ -17,25 +17,31 @@ limitations under the License.
 package host
 
 import (
-	"bytes"
 	"fmt"
 	"io"
 	"os"
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/output"
 )
 
-// OutputBuffer collects output for a single host, allowing atomic flush to stdout.
+// OutputBuffer collects output for a single host in an output.Buffer, allowing atomic flush to stdout.
 type OutputBuffer struct {
-	buf           bytes.Buffer
+	buf           output.Buffer
 	hostName      string
 	hasChanges    bool
 	hasErrors     bool
+	interrupted   bool
 	instanceCount int
-	retryItems    []RetryItem
-	startTime     time.Time
-	duration      time.Duration
+	// notProcessed counts the instances left untouched because the sync was interrupted
+	notProcessed int
+	retryItems   []RetryItem
+	// deferred lists the disruptive actions deferred because their exporters are leased
+	deferred  []string
+	startTime time.Time
+	duration  time.Duration
 }
 
 // NewOutputBuffer creates a new OutputBuffer for the given host.
@@ -72,6 +78,17 @@ func (o *OutputBuffer) MarkError() {
 	o.hasErrors = true
 }
 
+// MarkInterrupted marks this host as interrupted, with notProcessed instances left untouched.
+func (o *OutputBuffer) MarkInterrupted(notProcessed int) {
+	o.interrupted = true
+	o.notProcessed += notProcessed
+}
+
+// MarkDeferred records a disruptive action deferred on this host.
+func (o *OutputBuffer) MarkDeferred(item string) {
+	o.deferred = append(o.deferred, item)
+}
+
 // AddRetryItem adds a retry item to this buffer's collection.
 func (o *OutputBuffer) AddRetryItem(item RetryItem) {
 	o.retryItems = append(o.retryItems, item)
@@ -79,15 +96,20 @@ func (o *OutputBuffer) AddRetryItem(item RetryItem) {
 
 // SyncPrinter handles synchronized output to stdout from multiple goroutines.
 type SyncPrinter struct {
-	mu             sync.Mutex
-	startTime      time.Time
-	okCount        atomic.Int32
-	changedCount   atomic.Int32
-	failedCount    atomic.Int32
-	totalInstances atomic.Int32
-	retryCount     atomic.Int32
-	retrySuccess   atomic.Int32
-	failedHosts    []string
+	mu               sync.Mutex
+	startTime        time.Time
+	okCount          atomic.Int32
+	changedCount     atomic.Int32
+	failedCount      atomic.Int32
+	interrupted      atomic.Int32
+	notProcessed     atomic.Int32
+	totalInstances   atomic.Int32
+	retryCount       atomic.Int32
+	retrySuccess     atomic.Int32
+	failedHosts      []string
+	interruptedHosts []string
+	deferred         []string
+	powerCycles      []string
 }
 
 // NewSyncPrinter creates a new SyncPrinter.
@@ -103,6 +125,13 @@ func (p *SyncPrinter) AddRetryStats(queued, succeeded int32) {
 	p.retrySuccess.Add(succeeded)
 }
 
+// AddPowerCycle records the power cycle of an exporter host, with its outcome.
+func (p *SyncPrinter) AddPowerCycle(item string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.powerCycles = append(p.powerCycles, item)
+}
+
 // FlushBuffer atomically writes a host's buffered output to stdout.
 // Hosts with no changes get a compact one-line summary.
 // Hosts with changes or errors show their full buffered output.
@@ -111,16 +140,24 @@ func (p *SyncPrinter) FlushBuffer(ob *OutputBuffer) {
 	defer p.mu.Unlock()
 
 	p.totalInstances.Add(int32(ob.instanceCount))
+	for _, item := range ob.deferred {
+		p.deferred = append(p.deferred, ob.hostName+": "+item)
+	}
 
-	if ob.hasErrors {
+	if ob.interrupted {
+		p.interrupted.Add(1)
+		p.notProcessed.Add(int32(ob.notProcessed))
+		p.interruptedHosts = append(p.interruptedHosts, ob.hostName)
+		_ = ob.buf.Flush(os.Stdout)
+	} else if ob.hasErrors {
 		p.failedCount.Add(1)
 		p.failedHosts = append(p.failedHosts, ob.hostName)
 		// Print full output for hosts with errors
-		_, _ = fmt.Fprint(os.Stdout, ob.buf.String())
+		_ = ob.buf.Flush(os.Stdout)
 	} else if ob.hasChanges {
 		p.changedCount.Add(1)
 		// Print full output for hosts with changes
-		_, _ = fmt.Fprint(os.Stdout, ob.buf.String())
+		_ = ob.buf.Flush(os.Stdout)
 	} else {
 		p.okCount.Add(1)
 		// Compact one-liner for hosts with no changes
@@ -134,7 +171,7 @@ func (p *SyncPrinter) PrintSummary() {
 	defer p.mu.Unlock()
 
 	elapsed := time.Since(p.startTime)
-	totalHosts := p.okCount.Load() + p.changedCount.Load() + p.failedCount.Load()
+	totalHosts := p.okCount.Load() + p.changedCount.Load() + p.failedCount.Load() + p.interrupted.Load()
 	if totalHosts == 0 {
 		return
 	}
@@ -144,11 +181,24 @@ func (p *SyncPrinter) PrintSummary() {
 		totalHosts, p.okCount.Load(), p.changedCount.Load(), p.failedCount.Load())
 	_, _ = fmt.Fprintf(os.Stdout, "  Instances:  %d total\n", p.totalInstances.Load())
 
+	if p.interrupted.Load() > 0 {
+		_, _ = fmt.Fprintf(os.Stdout, "  Interrupted: %d hosts, %d instances not processed\n",
+			p.interrupted.Load(), p.notProcessed.Load())
+	}
+
 	if p.retryCount.Load() > 0 {
 		_, _ = fmt.Fprintf(os.Stdout, "  Retries:    %d queued, %d succeeded, %d gave up\n",
 			p.retryCount.Load(), p.retrySuccess.Load(), p.retryCount.Load()-p.retrySuccess.Load())
 	}
 
+	if len(p.deferred) > 0 {
+		_, _ = fmt.Fprintf(os.Stdout, "  Deferred:   %d disruptive actions on leased exporters\n", len(p.deferred))
+	}
+
+	if len(p.powerCycles) > 0 {
+		_, _ = fmt.Fprintf(os.Stdout, "  Power cycles: %d exporter hosts\n", len(p.powerCycles))
+	}
+
 	_, _ = fmt.Fprintf(os.Stdout, "  Runtime:    %s\n", formatDuration(elapsed))
 
 	if len(p.failedHosts) > 0 {
@@ -157,6 +207,27 @@ func (p *SyncPrinter) PrintSummary() {
 			_, _ = fmt.Fprintf(os.Stdout, "    ❌ %s\n", host)
 		}
 	}
+
+	if len(p.interruptedHosts) > 0 {
+		_, _ = fmt.Fprintf(os.Stdout, "  Interrupted hosts:\n")
+		for _, host := range p.interruptedHosts {
+			_, _ = fmt.Fprintf(os.Stdout, "    ⏹️  %s\n", host)
+		}
+	}
+
+	if len(p.deferred) > 0 {
+		_, _ = fmt.Fprintf(os.Stdout, "  Deferred:\n")
+		for _, item := range p.deferred {
+			_, _ = fmt.Fprintf(os.Stdout, "    ⏸️  %s\n", item)
+		}
+	}
+
+	if len(p.powerCycles) > 0 {
+		_, _ = fmt.Fprintf(os.Stdout, "  Power cycled:\n")
+		for _, item := range p.powerCycles {
+			_, _ = fmt.Fprintf(os.Stdout, "    🔌 %s\n", item)
+		}
+	}
 }
 
 // formatDuration formats a duration into a human-friendly string.
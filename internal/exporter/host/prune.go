@@ -0,0 +1,112 @@
+/*
+Copyright 2025. The Jumpstarter Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package host
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	api "github.com/jumpstarter-dev/jumpstarter-lab-config/api/v1alpha1"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/exporter/manager"
+)
+
+// SetPrune decommissions the exporters deployed by this tool on the hosts which were removed
+// from the configuration: their service is stopped and disabled, and their files removed
+func (e *ExporterHostSyncer) SetPrune(prune bool) {
+	e.prune = prune
+}
+
+// prunesHost reports whether the host is checked for orphaned exporters. With an exporter filter
+// only the hosts with a configured exporter instance matching it are, so a filtered run doesn't
+// connect to every host.
+func (e *ExporterHostSyncer) prunesHost(hostName string) bool {
+	if !e.prune {
+		return false
+	}
+	if e.exporterFilter == nil {
+		return true
+	}
+	for _, exporterInstance := range e.cfg.Loaded.GetExporterInstancesByExporterHost(hostName) {
+		if exporterInstance != nil && e.exporterFilter.MatchString(exporterInstance.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// orphanedExporters returns the exporter services managed on the host which no longer belong to
+// an exporter instance of the host, because the instance was removed, moved to another host, or
+// renders another service name. Only the instances matching the exporter filter are considered.
+// The services of the instances which fail to render are kept, and the failures returned.
+func (e *ExporterHostSyncer) orphanedExporters(hostName string, managed map[string]string) ([]string, error) {
+	configured := make(map[string]*api.ExporterInstance)
+	for _, exporterInstance := range e.cfg.Loaded.GetExporterInstancesByExporterHost(hostName) {
+		configured[exporterInstance.Name] = exporterInstance
+	}
+
+	var orphans []string
+	var errs []error
+	for svcName, instanceName := range managed {
+		if e.exporterFilter != nil && !e.exporterFilter.MatchString(instanceName) {
+			continue
+		}
+		if exporterInstance, ok := configured[instanceName]; ok {
+			// keep the service when the current name is unknown, i.e. for dead or unmanaged instances
+			if !e.hasServiceParameters(exporterInstance) {
+				continue
+			}
+			tcfg, err := e.renderExporterConfig(exporterInstance)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s kept, failed to render instance %s: %w", svcName, instanceName, err))
+				continue
+			}
+			if tcfg.Spec.ExporterMetadata.Name == svcName {
+				continue
+			}
+		}
+		orphans = append(orphans, svcName)
+	}
+	slices.Sort(orphans)
+	return orphans, errors.Join(errs...)
+}
+
+// pruneOrphanedExporters decommissions the orphaned exporters of a host, the failures are
+// reported on out and left for the next run
+func (e *ExporterHostSyncer) pruneOrphanedExporters(hostName string, hostSsh manager.HostManager, out *OutputBuffer) {
+	managed, err := hostSsh.ManagedExporters()
+	if err != nil {
+		out.Printf("    ❌ Failed to list the managed exporters: %v\n", err)
+		out.MarkError()
+		return
+	}
+
+	orphans, err := e.orphanedExporters(hostName, managed)
+	if err != nil {
+		out.Printf("    ❌ Failed to check the managed exporters: %v\n", err)
+		out.MarkError()
+	}
+	for _, svcName := range orphans {
+		out.Printf("    🗑️  Exporter %s (instance %s) is no longer in the configuration\n", svcName, managed[svcName])
+		if err := hostSsh.Decommission(svcName, e.dryRun); err != nil {
+			out.Printf("    ❌ Failed to decommission %s: %v\n", svcName, err)
+			out.MarkError()
+			continue
+		}
+		out.MarkChanged()
+	}
+}
@@ -0,0 +1,301 @@
+/*
+Copyright 2025. The Jumpstarter Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package host
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	api "github.com/jumpstarter-dev/jumpstarter-lab-config/api/v1alpha1"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/exporter/ssh"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/power"
+)
+
+// RemediationPolicy power cycles the exporter hosts which can't be reached over the network, through
+// the outlet of their power configuration
+type RemediationPolicy struct {
+	// After is the number of failed SSH attempts before a host is power cycled, disabled when zero
+	After int
+	// MaxPowerCycles caps the hosts power cycled in a run, unlimited when zero
+	MaxPowerCycles int
+	// Cooldown is the minimum time between two power cycles of a host, across runs
+	Cooldown time.Duration
+	// StateFile records the power cycles of the hosts on the runner for the cooldown, it is only
+	// enforced within a run when empty
+	StateFile string
+	// CycleDelay is the time the outlet is kept off
+	CycleDelay time.Duration
+	// BootTimeout bounds the wait for SSH to come back after the power cycle
+	BootTimeout time.Duration
+}
+
+// bootPollInterval is the interval of the SSH attempts while a power cycled host boots
+var bootPollInterval = 10 * time.Second
+
+// remediation tracks the SSH failures and the power cycles of the hosts during a sync
+type remediation struct {
+	policy RemediationPolicy
+	// cycle power cycles a host, reachable checks whether it accepts SSH connections again
+	cycle     func(ctx context.Context, host *api.ExporterHost) error
+	reachable func(ctx context.Context, host *api.ExporterHost) error
+
+	mu sync.Mutex
+	// failures counts the consecutive failed SSH attempts of the hosts
+	failures map[string]int
+	// cycled holds the hosts power cycled in this run, with the outcome
+	cycled map[string]string
+	// lastCycles holds the time of the last power cycle of the hosts, loaded from the state file
+	lastCycles map[string]time.Time
+
+	// stateMu serializes the updates of the state file
+	stateMu sync.Mutex
+}
+
+// SetRemediation power cycles the exporter hosts after policy.After failed SSH attempts, the
+// policy must trigger before the retries are given up. The state file is loaded once, before the
+// sync, so an unreadable state fails the run rather than every power cycle.
+func (e *ExporterHostSyncer) SetRemediation(policy RemediationPolicy) error {
+	if policy.After < 0 || policy.After >= e.retryConfig.MaxAttempts {
+		return fmt.Errorf("the power cycle must happen after 1 to %d failed SSH attempts, got %d",
+			e.retryConfig.MaxAttempts-1, policy.After)
+	}
+	lastCycles := make(map[string]time.Time)
+	if policy.After > 0 {
+		var err error
+		if lastCycles, err = readPowerCycles(policy.StateFile); err != nil {
+			return err
+		}
+	}
+	e.remediation.policy = policy
+	e.remediation.lastCycles = lastCycles
+	e.remediation.cycle = func(ctx context.Context, host *api.ExporterHost) error {
+		outlet, err := power.NewSNMPOutlet(host.Spec.Power.SNMP)
+		if err != nil {
+			return err
+		}
+		return outlet.Cycle(ctx, policy.CycleDelay)
+	}
+	e.remediation.reachable = func(ctx context.Context, host *api.ExporterHost) error {
+		hostSsh, err := e.newHostManager(ctx, host, NewOutputBuffer(host.Name, 0))
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = hostSsh.Close()
+		}()
+		_, err = hostSsh.Status()
+		return err
+	}
+	return nil
+}
+
+// sshConnected resets the failed SSH attempts of a host
+func (e *ExporterHostSyncer) sshConnected(hostName string) {
+	e.remediation.mu.Lock()
+	defer e.remediation.mu.Unlock()
+	delete(e.remediation.failures, hostName)
+}
+
+// sshFailed counts a failed SSH attempt of a host, and power cycles it once the policy triggers.
+// Only the hosts which can't be reached over the network are counted, not the authentication,
+// jump host or configuration errors, and neither the hosts of the other backends. The hosts with
+// a leased exporter are not power cycled. When the host is back, its retry items are made ready
+// to be retried right away.
+func (e *ExporterHostSyncer) sshFailed(ctx context.Context, host *api.ExporterHost, hostName string, err error,
+	items []RetryItem, out *OutputBuffer) {
+	r := &e.remediation
+	if r.policy.After == 0 || !ssh.IsUnreachable(err) || ctx.Err() != nil {
+		return
+	}
+	if skip := r.reserve(host, hostName); skip != "" {
+		if skip != "-" {
+			out.Printf("    🔌 Not power cycling %s: %s\n", hostName, skip)
+		}
+		return
+	}
+	// the exporters may still be connected to the controller, a power cycle would end their leases
+	if reason := e.hostLease(ctx, hostName); reason != "" {
+		out.Printf("    🔌 Not power cycling %s: %s\n", hostName, reason)
+		r.release(hostName)
+		return
+	}
+
+	plug := host.Spec.Power.SNMP.Plug
+	if e.dryRun {
+		out.Printf("    🔌 dry run: would power cycle %s (plug %d on %s)\n", hostName, plug, host.Spec.Power.SNMP.Host)
+		r.done(hostName, "would be power cycled")
+		return
+	}
+	out.Printf("    🔌 Power cycling %s (plug %d on %s) after %d failed SSH attempts...\n",
+		hostName, plug, host.Spec.Power.SNMP.Host, r.policy.After)
+	if err := r.cycle(ctx, host); err != nil {
+		out.Printf("    ❌ Failed to power cycle %s: %v\n", hostName, err)
+		r.done(hostName, fmt.Sprintf("power cycle failed: %v", err))
+		return
+	}
+	if err := r.recordPowerCycle(hostName, now()); err != nil {
+		out.Printf("    ⚠️  Failed to record the power cycle of %s: %v\n", hostName, err)
+	}
+
+	if err := r.waitReachable(ctx, host); err != nil {
+		out.Printf("    ❌ %s is not reachable over SSH after the power cycle: %v\n", hostName, err)
+		r.done(hostName, "not reachable after the power cycle")
+		return
+	}
+	out.Printf("    ✅ %s is back after the power cycle\n", hostName)
+	r.done(hostName, "back after the power cycle")
+	for i := range items {
+		items[i].LastAttemptTime = time.Time{}
+	}
+}
+
+// reserve counts a failed SSH attempt and reserves the power cycle of a host, it returns why the
+// host is not power cycled otherwise, "-" when the policy didn't trigger yet
+func (r *remediation) reserve(host *api.ExporterHost, hostName string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failures == nil {
+		r.failures = make(map[string]int)
+	}
+	r.failures[hostName]++
+	switch {
+	case r.failures[hostName] != r.policy.After:
+		return "-"
+	case r.cycled[hostName] != "":
+		return "already power cycled in this run"
+	case host.Spec.Power.SNMP.Host == "":
+		return "no power configuration"
+	case r.policy.MaxPowerCycles > 0 && len(r.cycled) >= r.policy.MaxPowerCycles:
+		return fmt.Sprintf("%d exporter hosts were already power cycled in this run", len(r.cycled))
+	}
+	if last, ok := r.lastCycles[hostName]; ok {
+		if since := now().Sub(last); since < r.policy.Cooldown {
+			return fmt.Sprintf("power cycled %s ago, within the %s cooldown", since.Round(time.Minute), r.policy.Cooldown)
+		}
+	}
+	if r.cycled == nil {
+		r.cycled = make(map[string]string)
+	}
+	r.cycled[hostName] = "power cycling"
+	return ""
+}
+
+// release gives up the power cycle reserved for a host, it doesn't count in the cap of the run
+func (r *remediation) release(hostName string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.cycled, hostName)
+}
+
+// done records the outcome of the power cycle of a host
+func (r *remediation) done(hostName, outcome string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cycled[hostName] = outcome
+}
+
+// waitReachable waits for a power cycled host to accept SSH connections, up to the boot timeout
+func (r *remediation) waitReachable(ctx context.Context, host *api.ExporterHost) error {
+	ctx, cancel := context.WithTimeout(ctx, r.policy.BootTimeout)
+	defer cancel()
+	for {
+		err := r.reachable(ctx, host)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(bootPollInterval):
+		}
+	}
+}
+
+// readPowerCycles returns the time of the last power cycle of the hosts from a state file, none
+// when there is no state file yet
+func readPowerCycles(stateFile string) (map[string]time.Time, error) {
+	powerCycles := make(map[string]time.Time)
+	if stateFile == "" {
+		return powerCycles, nil
+	}
+	data, err := os.ReadFile(stateFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return powerCycles, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the power cycle state: %w", err)
+	}
+	if err := json.Unmarshal(data, &powerCycles); err != nil {
+		return nil, fmt.Errorf("failed to parse the power cycle state %s: %w", stateFile, err)
+	}
+	return powerCycles, nil
+}
+
+// recordPowerCycle records the time of the power cycle of a host, and writes it to the state file
+// where the cooldown of the next runs starts from it
+func (r *remediation) recordPowerCycle(hostName string, at time.Time) error {
+	r.stateMu.Lock()
+	defer r.stateMu.Unlock()
+	r.mu.Lock()
+	r.lastCycles[hostName] = at.UTC()
+	data, err := json.MarshalIndent(r.lastCycles, "", "  ")
+	r.mu.Unlock()
+	if err != nil || r.policy.StateFile == "" {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.policy.StateFile), 0o755); err != nil {
+		return fmt.Errorf("failed to create the power cycle state directory: %w", err)
+	}
+	file, err := os.CreateTemp(filepath.Dir(r.policy.StateFile), "."+filepath.Base(r.policy.StateFile)+".tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write the power cycle state: %w", err)
+	}
+	_, err = file.Write(append(data, '\n'))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), r.policy.StateFile)
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+		return fmt.Errorf("failed to write the power cycle state: %w", err)
+	}
+	return nil
+}
+
+// addPowerCycles adds the hosts power cycled during the sync to the summary of the printer
+func (e *ExporterHostSyncer) addPowerCycles(printer *SyncPrinter) {
+	e.remediation.mu.Lock()
+	defer e.remediation.mu.Unlock()
+	hosts := make([]string, 0, len(e.remediation.cycled))
+	for hostName := range e.remediation.cycled {
+		hosts = append(hosts, hostName)
+	}
+	slices.Sort(hosts)
+	for _, hostName := range hosts {
+		printer.AddPowerCycle(hostName + ": " + e.remediation.cycled[hostName])
+	}
+}
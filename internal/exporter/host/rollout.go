@@ -0,0 +1,119 @@
+/*
+Copyright 2025. The Jumpstarter Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package host
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/labels"
+
+	api "github.com/jumpstarter-dev/jumpstarter-lab-config/api/v1alpha1"
+)
+
+// RolloutStrategy stages the sync of the exporter hosts, so a bad template or image doesn't break
+// every host at once: the canary hosts are synced first, then the other hosts in batches. The
+// rollout stops when too many hosts of a stage fail.
+type RolloutStrategy struct {
+	// CanaryHosts are the names of the exporter hosts synced first
+	CanaryHosts []string
+	// CanarySelector selects the exporter hosts synced first by label, none when nil
+	CanarySelector labels.Selector
+	// MaxUnavailable is the number of hosts of each batch after the canaries, all of them when zero
+	MaxUnavailable int
+	// MaxFailurePercent stops the rollout when a larger percentage of the hosts of a stage failed
+	MaxFailurePercent int
+}
+
+// rolloutStage is a group of hosts synced together
+type rolloutStage struct {
+	name  string
+	hosts []hostWork
+}
+
+// SetRollout stages the sync of the exporter hosts with strategy, all the hosts are synced
+// together by default
+func (e *ExporterHostSyncer) SetRollout(strategy RolloutStrategy) {
+	e.rollout = strategy
+}
+
+// isCanary checks whether a host is a canary of the rollout
+func (s RolloutStrategy) isCanary(w hostWork) bool {
+	if slices.Contains(s.CanaryHosts, w.hostName) {
+		return true
+	}
+	return s.CanarySelector != nil && !s.CanarySelector.Empty() &&
+		s.CanarySelector.Matches(labels.Set(w.host.Labels))
+}
+
+// validate checks the canaries of the rollout against the exporter hosts of the configuration and
+// the hosts to sync: a misspelled canary host, or canaries which are all filtered out, would
+// otherwise sync the whole lab in the first batch
+func (s RolloutStrategy) validate(hosts map[string]*api.ExporterHost, work []hostWork) error {
+	var unknown []string
+	for _, name := range s.CanaryHosts {
+		if _, ok := hosts[name]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("unknown --canary-hosts: %s", strings.Join(unknown, ", "))
+	}
+
+	hasSelector := s.CanarySelector != nil && !s.CanarySelector.Empty()
+	if len(s.CanaryHosts) == 0 && !hasSelector {
+		return nil
+	}
+	if !slices.ContainsFunc(work, s.isCanary) {
+		return fmt.Errorf("no exporter host to sync matches the canaries of the rollout, the canary stage is empty")
+	}
+	return nil
+}
+
+// stages splits the hosts into the canary stage and the batches, in the order of work
+func (s RolloutStrategy) stages(work []hostWork) []rolloutStage {
+	var canaries, others []hostWork
+	for _, w := range work {
+		if s.isCanary(w) {
+			canaries = append(canaries, w)
+		} else {
+			others = append(others, w)
+		}
+	}
+
+	var stages []rolloutStage
+	if len(canaries) > 0 {
+		stages = append(stages, rolloutStage{name: "canaries", hosts: canaries})
+	}
+	batchSize := s.MaxUnavailable
+	if batchSize <= 0 {
+		batchSize = max(len(others), 1)
+	}
+	batches := slices.Collect(slices.Chunk(others, batchSize))
+	for idx, batch := range batches {
+		stages = append(stages, rolloutStage{name: fmt.Sprintf("batch %d/%d", idx+1, len(batches)), hosts: batch})
+	}
+	return stages
+}
+
+// failed checks whether the failures of a stage of hosts are over the limit. The failures are
+// counted once the retry queue of the stage is drained: a host whose items were queued for retry,
+// i.e. a host unreachable at first, only counts as failed when the retries are given up.
+func (s RolloutStrategy) failed(failures, hosts int) bool {
+	return failures*100 > s.MaxFailurePercent*hosts
+}
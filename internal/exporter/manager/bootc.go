@@ -0,0 +1,162 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/plan"
+)
+
+// bootcSwitchUnit is the transient unit running `bootc switch --apply`, which reboots the host
+const bootcSwitchUnit = "jumpstarter-bootc-switch.service"
+
+// BootcImages are the images deployed on a bootc host
+type BootcImages struct {
+	// Booted is the image the host is running
+	Booted string
+	// Staged is the image the host boots next, empty when no deployment is staged
+	Staged string
+}
+
+// String reports the booted and staged images
+func (i *BootcImages) String() string {
+	switch {
+	case i.Booted == "":
+		return "staged " + i.Staged
+	case i.Staged == "" || i.Staged == i.Booted:
+		return "booted " + i.Booted
+	}
+	return fmt.Sprintf("booted %s, staged %s", i.Booted, i.Staged)
+}
+
+// BootcScheduler decides when the bootc image of a host can be updated
+type BootcScheduler interface {
+	// Start reserves the update of the bootc image of the host, it returns why the update is
+	// pending otherwise
+	Start() (pending string)
+	// Updating records that the host is already updating its bootc image
+	Updating()
+}
+
+// startBootcUpdate reserves an update of the bootc image with the scheduler, it returns why the
+// update is pending otherwise
+func (m *Manager) startBootcUpdate() string {
+	if m.bootcScheduler == nil {
+		return ""
+	}
+	return m.bootcScheduler.Start()
+}
+
+// bootcHost is the part of the output of `bootc status --json` describing the deployments
+type bootcHost struct {
+	Status struct {
+		Booted *bootcDeployment `json:"booted"`
+		Staged *bootcDeployment `json:"staged"`
+	} `json:"status"`
+}
+
+type bootcDeployment struct {
+	Image *struct {
+		Image struct {
+			Image string `json:"image"`
+		} `json:"image"`
+	} `json:"image"`
+}
+
+func (d *bootcDeployment) image() string {
+	if d == nil || d.Image == nil {
+		return ""
+	}
+	return d.Image.Image.Image
+}
+
+// parseBootcStatus parses the output of `bootc status --json`
+func parseBootcStatus(output string) (*BootcImages, error) {
+	var host bootcHost
+	if err := json.Unmarshal([]byte(output), &host); err != nil {
+		return nil, fmt.Errorf("failed to parse bootc status: %w", err)
+	}
+	images := &BootcImages{Booted: host.Status.Booted.image(), Staged: host.Status.Staged.image()}
+	if images.Booted == "" {
+		return nil, fmt.Errorf("bootc status has no booted image")
+	}
+	return images, nil
+}
+
+// BootcImages returns the images deployed on the host, nil when it isn't a bootc host
+func (m *Manager) BootcImages() (*BootcImages, error) {
+	if !m.transport.RunsCommands() {
+		return nil, nil
+	}
+	result, err := m.runCommand("if [ -f /run/ostree-booted ]; then bootc status --json; fi")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bootc status: %w", err)
+	}
+	if strings.TrimSpace(result.Stdout) == "" {
+		return nil, nil
+	}
+	return parseBootcStatus(result.Stdout)
+}
+
+// BootcUpdating reports whether a bootc upgrade or switch is running on the host
+func (m *Manager) BootcUpdating() bool {
+	if !m.transport.RunsCommands() {
+		return false
+	}
+	statusCmd, _ := m.RunHostCommand("systemctl is-active bootc-fetch-apply-updates.service bootc-fetch-apply-updates.timer " +
+		bootcSwitchUnit)
+	if statusCmd == nil {
+		return false
+	}
+	for _, status := range strings.Fields(statusCmd.Stdout) {
+		if status == systemdStateActive || status == "activating" {
+			return true
+		}
+	}
+	return false
+}
+
+// handleBootcSwitch switches the host to the image of ContainerImage when it doesn't boot it, a
+// staged image isn't booted until the host reboots. The switch runs in the background and reboots
+// the host into the image, it reports whether the host is switching, the bootc upgrades are only
+// checked when it isn't.
+func (m *Manager) handleBootcSwitch(dryRun bool) (bool, error) {
+	desired := m.ExporterHost.Spec.ContainerImage
+	images, err := m.BootcImages()
+	if err != nil || images == nil {
+		return false, err
+	}
+	if images.Booted == desired {
+		_, _ = fmt.Fprintf(m.writer, "    🖼️  Bootc image: %s\n", images)
+		return false, nil
+	}
+	_, _ = fmt.Fprintf(m.writer, "    🖼️  Bootc image: %s, desired %s\n", images, desired)
+	if m.BootcUpdating() {
+		_, _ = fmt.Fprintf(m.writer, "    ⚠️  Bootc update in progress\n")
+		if m.bootcScheduler != nil {
+			m.bootcScheduler.Updating()
+		}
+		return true, nil
+	}
+	if pending := m.startBootcUpdate(); pending != "" {
+		_, _ = fmt.Fprintf(m.writer, "    ⏸️  Bootc image switch pending: %s\n", pending)
+		return true, nil
+	}
+
+	err = m.record(plan.Change{Kind: "bootc", Name: "image", Action: plan.ActionSwitch,
+		Before: plan.HashContent(images.Booted), After: plan.HashContent(desired)})
+	if err != nil {
+		return false, err
+	}
+	if dryRun {
+		_, _ = fmt.Fprintf(m.writer, "    📄 Would switch bootc image to %s and reboot\n", desired)
+		return true, nil
+	}
+	command := fmt.Sprintf("systemd-run --unit=%s --collect bootc switch --apply %q", bootcSwitchUnit, desired)
+	if _, err := m.runCommand(command); err != nil {
+		return false, fmt.Errorf("error switching bootc image to %s: %w", desired, err)
+	}
+	_, _ = fmt.Fprintf(m.writer, "    ✅ Bootc image switch to %s started, the host reboots into it\n", desired)
+	return true, nil
+}
@@ -0,0 +1,145 @@
+package manager
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/plan"
+)
+
+// bootcStatusJSON returns the output of `bootc status --json` for the booted and staged images
+func bootcStatusJSON(booted, staged string) string {
+	deployment := func(image string) string {
+		if image == "" {
+			return "null"
+		}
+		return `{"image":{"image":{"image":"` + image + `","transport":"registry"},"imageDigest":"sha256:0123"},"pinned":false}`
+	}
+	return `{"apiVersion":"org.containers.bootc/v1","kind":"BootcHost","spec":{"image":{"image":"` + booted +
+		`","transport":"registry"}},"status":{"staged":` + deployment(staged) + `,"booted":` + deployment(booted) +
+		`,"rollback":null,"type":"bootcHost"}}`
+}
+
+func TestParseBootcStatus(t *testing.T) {
+	images, err := parseBootcStatus(bootcStatusJSON("quay.io/lab/sidekick:1.0", ""))
+	require.NoError(t, err)
+	assert.Equal(t, &BootcImages{Booted: "quay.io/lab/sidekick:1.0"}, images)
+	assert.Equal(t, "booted quay.io/lab/sidekick:1.0", images.String())
+
+	images, err = parseBootcStatus(bootcStatusJSON("quay.io/lab/sidekick:1.0", "quay.io/lab/sidekick:1.1"))
+	require.NoError(t, err)
+	assert.Equal(t, "booted quay.io/lab/sidekick:1.0, staged quay.io/lab/sidekick:1.1", images.String())
+
+	_, err = parseBootcStatus(`{"status":{"booted":null}}`)
+	assert.Error(t, err)
+	_, err = parseBootcStatus("not json")
+	assert.Error(t, err)
+}
+
+// bootcCommands answers the bootc commands of a host running booted with staged staged
+func bootcCommands(booted, staged string) func(command string) (string, uint32) {
+	return func(command string) (string, uint32) {
+		switch {
+		case strings.Contains(command, "bootc status --json"):
+			return bootcStatusJSON(booted, staged), 0
+		case strings.HasPrefix(command, "systemctl is-active bootc"):
+			return "inactive\ninactive\n", 3
+		case strings.Contains(command, "bootc upgrade --check"):
+			return "No changes in: quay.io/lab/sidekick:1.0\n", 0
+		}
+		return "", 0
+	}
+}
+
+func TestHandleBootcUpgrade_Switch(t *testing.T) {
+	t.Run("switches to the desired image", func(t *testing.T) {
+		host := newFakeHost(t, bootcCommands("quay.io/lab/sidekick:1.0", ""))
+		host.manager.ExporterHost.Spec.ContainerImage = "quay.io/lab/sidekick:1.1"
+		recorder := plan.NewRecorder()
+		host.manager.SetRecorder(recorder)
+
+		require.NoError(t, host.manager.HandleBootcUpgrade(true))
+		assert.Contains(t, host.out.String(), "Bootc image: booted quay.io/lab/sidekick:1.0, desired quay.io/lab/sidekick:1.1")
+		assert.False(t, host.ran("systemd-run"), "nothing is switched on dry run")
+		changes := recorder.Plan(plan.Options{}).Changes
+		require.Len(t, changes, 1)
+		assert.Equal(t, plan.ActionSwitch, changes[0].Action)
+
+		require.NoError(t, host.manager.HandleBootcUpgrade(false))
+		assert.True(t, host.ran(`systemd-run --unit=jumpstarter-bootc-switch.service --collect bootc switch --apply "quay.io/lab/sidekick:1.1"`))
+		assert.False(t, host.ran("[ -f /run/ostree-booted ] && bootc upgrade --check"), "the upgrades are not checked")
+	})
+
+	t.Run("desired image is booted", func(t *testing.T) {
+		host := newFakeHost(t, bootcCommands("quay.io/lab/sidekick:1.1", ""))
+		host.manager.ExporterHost.Spec.ContainerImage = "quay.io/lab/sidekick:1.1"
+
+		require.NoError(t, host.manager.HandleBootcUpgrade(false))
+		assert.False(t, host.ran("systemd-run"))
+		assert.True(t, host.ran("[ -f /run/ostree-booted ] && bootc upgrade --check"))
+		assert.Contains(t, host.out.String(), "Bootc image is up to date")
+	})
+
+	t.Run("staged image is applied", func(t *testing.T) {
+		host := newFakeHost(t, bootcCommands("quay.io/lab/sidekick:1.0", "quay.io/lab/sidekick:1.1"))
+		host.manager.ExporterHost.Spec.ContainerImage = "quay.io/lab/sidekick:1.1"
+
+		require.NoError(t, host.manager.HandleBootcUpgrade(false))
+		assert.True(t, host.ran(`systemd-run --unit=jumpstarter-bootc-switch.service --collect bootc switch --apply "quay.io/lab/sidekick:1.1"`),
+			"a staged image isn't booted until the host reboots")
+	})
+
+	t.Run("switch in progress", func(t *testing.T) {
+		host := newFakeHost(t, func(command string) (string, uint32) {
+			if strings.HasPrefix(command, "systemctl is-active bootc") {
+				return "inactive\ninactive\nactive\n", 0
+			}
+			return bootcCommands("quay.io/lab/sidekick:1.0", "quay.io/lab/sidekick:1.1")(command)
+		})
+		host.manager.ExporterHost.Spec.ContainerImage = "quay.io/lab/sidekick:1.1"
+
+		require.NoError(t, host.manager.HandleBootcUpgrade(false))
+		assert.Contains(t, host.out.String(), "Bootc update in progress")
+		assert.False(t, host.ran("systemd-run"))
+		assert.Equal(t, BOOTC_UPDATING, host.manager.GetBootcStatus())
+	})
+
+	t.Run("no desired image", func(t *testing.T) {
+		host := newFakeHost(t, bootcCommands("quay.io/lab/sidekick:1.0", ""))
+
+		require.NoError(t, host.manager.HandleBootcUpgrade(false))
+		assert.False(t, host.ran("if [ -f /run/ostree-booted ]; then bootc status --json; fi"))
+		assert.False(t, host.ran("systemd-run"))
+	})
+}
+
+// pendingScheduler defers every bootc update
+type pendingScheduler struct{}
+
+func (pendingScheduler) Start() string { return "outside the maintenance windows" }
+func (pendingScheduler) Updating()     {}
+
+func TestHandleBootcUpgrade_Pending(t *testing.T) {
+	host := newFakeHost(t, func(command string) (string, uint32) {
+		if strings.Contains(command, "bootc upgrade --check") {
+			return "Update available for: quay.io/lab/sidekick:1.0\n", 0
+		}
+		return bootcCommands("quay.io/lab/sidekick:1.0", "")(command)
+	})
+	recorder := plan.NewRecorder()
+	host.manager.SetRecorder(recorder)
+	host.manager.SetBootcScheduler(pendingScheduler{})
+
+	require.NoError(t, host.manager.HandleBootcUpgrade(false))
+	assert.Contains(t, host.out.String(), "Bootc upgrade pending: outside the maintenance windows")
+	assert.False(t, host.ran("systemctl restart bootc-fetch-apply-updates.timer"))
+
+	host.manager.ExporterHost.Spec.ContainerImage = "quay.io/lab/sidekick:1.1"
+	require.NoError(t, host.manager.HandleBootcUpgrade(false))
+	assert.Contains(t, host.out.String(), "Bootc image switch pending: outside the maintenance windows")
+	assert.False(t, host.ran("systemd-run"))
+	assert.Empty(t, recorder.Plan(plan.Options{}).Changes, "the pending updates are not planned")
+}
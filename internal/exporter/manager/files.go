@@ -0,0 +1,129 @@
+package manager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/api/v1alpha1"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/plan"
+)
+
+// managedFileAttrs declares the default mode of the files managed on the hosts by directory, the
+// exporter configs hold the exporter token. Their owner is kept unless the template declares one.
+var managedFileAttrs = map[string]FileAttrs{
+	"/etc/jumpstarter/exporters": {Mode: 0o600, UID: KeepOwner, GID: KeepOwner},
+	"/etc/jumpstarter":           {Mode: 0o644, UID: KeepOwner, GID: KeepOwner},
+	"/etc/containers/systemd":    {Mode: 0o644, UID: KeepOwner, GID: KeepOwner},
+	"/etc/systemd/system":        {Mode: 0o644, UID: KeepOwner, GID: KeepOwner},
+}
+
+// defaultFileAttrs are the attributes of the managed files outside of the managedFileAttrs directories
+var defaultFileAttrs = FileAttrs{Mode: 0o644, UID: KeepOwner, GID: KeepOwner}
+
+// DeclaredAttrs returns the default mode and ownership of a managed file
+func DeclaredAttrs(path string) FileAttrs {
+	if attrs, ok := managedFileAttrs[filepath.Dir(path)]; ok {
+		return attrs
+	}
+	return defaultFileAttrs
+}
+
+// TemplateFileAttrs returns the mode and ownership of the files of an exporter, keyed by path, the
+// attributes declared by the template override the defaults of DeclaredAttrs
+func TemplateFileAttrs(exporterConfig *v1alpha1.ExporterConfigTemplate) (map[string]FileAttrs, error) {
+	containerSystemdFile, serviceSystemdFile, exporterConfigFile := ExporterFiles(exporterConfig.Spec.ExporterMetadata.Name)
+	files := exporterConfig.Spec.Files
+	attrs := make(map[string]FileAttrs)
+	for path, declared := range map[string]*v1alpha1.FileAttributes{
+		containerSystemdFile: files.Systemd,
+		serviceSystemdFile:   files.Systemd,
+		exporterConfigFile:   files.Config,
+	} {
+		fileAttrs, err := overrideAttrs(DeclaredAttrs(path), declared)
+		if err != nil {
+			return nil, fmt.Errorf("invalid attributes of %s in %s: %w", path, exporterConfig.Name, err)
+		}
+		attrs[path] = fileAttrs
+	}
+	return attrs, nil
+}
+
+// overrideAttrs returns attrs overridden by the attributes declared, if any
+func overrideAttrs(attrs FileAttrs, declared *v1alpha1.FileAttributes) (FileAttrs, error) {
+	if declared == nil {
+		return attrs, nil
+	}
+	if declared.Mode != "" {
+		mode, err := strconv.ParseUint(declared.Mode, 8, 32)
+		if err != nil || mode > 0o777 {
+			return attrs, fmt.Errorf("mode %q is not an octal permission mode", declared.Mode)
+		}
+		attrs.Mode = os.FileMode(mode)
+	}
+	if declared.Owner != nil {
+		attrs.UID = *declared.Owner
+	}
+	if declared.Group != nil {
+		attrs.GID = *declared.Group
+	}
+	if attrs.UID < KeepOwner || attrs.GID < KeepOwner {
+		return attrs, fmt.Errorf("negative owner or group")
+	}
+	return attrs, nil
+}
+
+// declaredAttrs returns the declared mode and ownership of a managed file, from the template
+// applied last when it renders the file
+func (m *Manager) declaredAttrs(path string) FileAttrs {
+	if attrs, ok := m.fileAttrs[path]; ok {
+		return attrs
+	}
+	return DeclaredAttrs(path)
+}
+
+// writeFile writes content to path atomically, with the declared mode and ownership of path
+func (m *Manager) writeFile(path, content string) error {
+	return m.transport.WriteFile(path, content, m.declaredAttrs(path))
+}
+
+// attrsDiff describes the mode and ownership drift of an existing managed file, empty when there is none
+func (m *Manager) attrsDiff(path string) (string, error) {
+	attrs, err := m.transport.FileAttrs(path)
+	if err != nil {
+		return "", err
+	}
+	if declared := m.declaredAttrs(path); !declared.Matches(attrs) {
+		return fmt.Sprintf("~ mode and owner of %s: %s, want %s\n", path, attrs, declared), nil
+	}
+	return "", nil
+}
+
+// reconcileAttrs sets the declared mode and ownership of an existing managed file when they drifted
+func (m *Manager) reconcileAttrs(path string, dryRun bool) error {
+	attrs, err := m.transport.FileAttrs(path)
+	if err != nil {
+		return err
+	}
+	declared := m.declaredAttrs(path)
+	if declared.Matches(attrs) {
+		return nil
+	}
+	want := declared.Resolve(attrs)
+
+	err = m.record(plan.Change{Kind: "file", Name: path, Action: plan.ActionUpdate,
+		Before: plan.HashContent(attrs.String()), After: plan.HashContent(want.String())})
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		_, _ = fmt.Fprintf(m.writer, "            🔒 Would change mode and owner of %s: %s to %s\n", path, attrs, want)
+		return nil
+	}
+	if err := m.transport.SetFileAttrs(path, declared); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(m.writer, "            🔒 Changed mode and owner of %s: %s to %s\n", path, attrs, want)
+	return nil
+}
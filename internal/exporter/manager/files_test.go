@@ -0,0 +1,123 @@
+package manager
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/utils/ptr"
+
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/api/v1alpha1"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/plan"
+)
+
+func TestReconcileFile_DeclaredAttrs(t *testing.T) {
+	transport := newMemTransport(nil)
+	var out bytes.Buffer
+	manager := New(context.Background(), createTestExporterHost("files"), transport)
+	manager.SetWriter(&out)
+	const path = "/etc/jumpstarter/exporters/exporter.yaml"
+
+	changed, err := manager.reconcileFile(path, "token: first\n", false)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	attrs, err := transport.FileAttrs(path)
+	require.NoError(t, err)
+	assert.Equal(t, FileAttrs{Mode: 0o600}, attrs, "the exporter configs hold the token")
+
+	changed, err = manager.reconcileFile(path, "token: second\n", false)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	content, err := manager.readFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "token: second\n", content)
+}
+
+func TestReconcileFile_AttrsDrift(t *testing.T) {
+	transport := newMemTransport(nil)
+	var out bytes.Buffer
+	recorder := plan.NewRecorder()
+	manager := New(context.Background(), createTestExporterHost("files"), transport)
+	manager.SetWriter(&out)
+	manager.SetRecorder(recorder)
+	const path = "/etc/jumpstarter/exporters/exporter.yaml"
+	const content = "token: secret\n"
+
+	require.NoError(t, transport.WriteFile(path, content, FileAttrs{Mode: 0o644, UID: 1000, GID: 1000}))
+
+	diff, err := manager.attrsDiff(path)
+	require.NoError(t, err)
+	assert.Equal(t, "~ mode and owner of "+path+": 0644 1000:1000, want 0600\n", diff)
+
+	changed, err := manager.reconcileFile(path, content, true)
+	require.NoError(t, err)
+	assert.False(t, changed, "the exporter doesn't need a restart")
+	assert.Contains(t, out.String(), "Would change mode and owner of "+path)
+	attrs, err := transport.FileAttrs(path)
+	require.NoError(t, err)
+	assert.Equal(t, FileAttrs{Mode: 0o644, UID: 1000, GID: 1000}, attrs, "nothing is changed on dry run")
+	assert.Len(t, recorder.Plan(plan.Options{}).Changes, 1)
+
+	changed, err = manager.reconcileFile(path, content, false)
+	require.NoError(t, err)
+	assert.False(t, changed)
+	attrs, err = transport.FileAttrs(path)
+	require.NoError(t, err)
+	assert.Equal(t, FileAttrs{Mode: 0o600, UID: 1000, GID: 1000}, attrs, "the owner isn't declared, it is kept")
+
+	diff, err = manager.attrsDiff(path)
+	require.NoError(t, err)
+	assert.Empty(t, diff)
+}
+
+func TestTemplateFileAttrs(t *testing.T) {
+	exporterConfig := &v1alpha1.ExporterConfigTemplate{Spec: v1alpha1.ExporterConfigTemplateSpec{
+		ExporterMetadata: v1alpha1.ExporterMeta{Name: "exporter"},
+		Files: v1alpha1.ExporterFiles{
+			Config: &v1alpha1.FileAttributes{Mode: "0640", Group: ptr.To(1000)},
+		},
+	}}
+	containerSystemdFile, serviceSystemdFile, exporterConfigFile := ExporterFiles("exporter")
+
+	attrs, err := TemplateFileAttrs(exporterConfig)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]FileAttrs{
+		containerSystemdFile: {Mode: 0o644, UID: KeepOwner, GID: KeepOwner},
+		serviceSystemdFile:   {Mode: 0o644, UID: KeepOwner, GID: KeepOwner},
+		exporterConfigFile:   {Mode: 0o640, UID: KeepOwner, GID: 1000},
+	}, attrs)
+
+	exporterConfig.Spec.Files.Systemd = &v1alpha1.FileAttributes{Mode: "0855"}
+	_, err = TemplateFileAttrs(exporterConfig)
+	assert.ErrorContains(t, err, `mode "0855" is not an octal permission mode`)
+}
+
+func TestApply_DeclaredOwner(t *testing.T) {
+	transport := newMemTransport(nil)
+	var out bytes.Buffer
+	manager := New(context.Background(), createTestExporterHost("files"), transport)
+	manager.SetWriter(&out)
+	_, _, exporterConfigFile := ExporterFiles("exporter")
+	exporterConfig := &v1alpha1.ExporterConfigTemplate{Spec: v1alpha1.ExporterConfigTemplateSpec{
+		ExporterMetadata: v1alpha1.ExporterMeta{Name: "exporter"},
+		ConfigTemplate:   "token: secret\n",
+	}}
+
+	require.NoError(t, manager.Apply(exporterConfig, false))
+	attrs, err := transport.FileAttrs(exporterConfigFile)
+	require.NoError(t, err)
+	assert.Equal(t, FileAttrs{Mode: 0o600}, attrs, "created by root without a declared owner")
+
+	exporterConfig.Spec.Files.Config = &v1alpha1.FileAttributes{Mode: "0640", Owner: ptr.To(1000), Group: ptr.To(1000)}
+	diff, err := manager.Diff(exporterConfig)
+	require.NoError(t, err)
+	assert.Equal(t, "~ mode and owner of "+exporterConfigFile+": 0600 0:0, want 0640 1000:1000\n", diff)
+
+	require.NoError(t, manager.Apply(exporterConfig, false))
+	attrs, err = transport.FileAttrs(exporterConfigFile)
+	require.NoError(t, err)
+	assert.Equal(t, FileAttrs{Mode: 0o640, UID: 1000, GID: 1000}, attrs)
+}
@@ -0,0 +1,311 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/api/v1alpha1"
+)
+
+// healthCheckInterval is the delay between two health checks of an exporter
+var healthCheckInterval = 5 * time.Second
+
+// OnlineCheck checks whether the controller reports an exporter as online
+type OnlineCheck func(ctx context.Context) (bool, error)
+
+// HealthError is returned when an exporter isn't healthy after an update. Retrying the update
+// doesn't help, it needs a fix of the configuration.
+type HealthError struct {
+	Service string
+	Reason  string
+	// RolledBack is set when the previous exporter files were restored
+	RolledBack bool
+	// RestartDeferred is set when the rolled back exporter is leased, it uses the previous files
+	// from its next restart
+	RestartDeferred bool
+}
+
+func (e *HealthError) Error() string {
+	if e.RolledBack && e.RestartDeferred {
+		return fmt.Sprintf("exporter %s is not healthy after the update, rolled back but not restarted while leased: %s",
+			e.Service, e.Reason)
+	}
+	if e.RolledBack {
+		return fmt.Sprintf("exporter %s is not healthy after the update, rolled back: %s", e.Service, e.Reason)
+	}
+	return fmt.Sprintf("exporter %s is not healthy after the update: %s", e.Service, e.Reason)
+}
+
+// IsHealthError checks if err is caused by an exporter which isn't healthy after an update
+func IsHealthError(err error) bool {
+	var healthErr *HealthError
+	return errors.As(err, &healthErr)
+}
+
+// restartKind is how Apply restarted the exporter service
+type restartKind int
+
+const (
+	// notRestarted means the service wasn't touched
+	notRestarted restartKind = iota
+	// restartDeferred means the restart waits for the end of the lease of the exporter
+	restartDeferred
+	// restartSignalled means the service was signalled to restart once it isn't leased
+	restartSignalled
+	// restarted means the service was started or restarted
+	restarted
+)
+
+// appliedExporter is what the last Apply changed on the host, so it can be verified and rolled back
+type appliedExporter struct {
+	svcName string
+	// previous holds the content of the files changed by Apply before the change, nil for the created files
+	previous map[string]*string
+	restart  restartKind
+	// invocation is the systemd invocation ID of the signalled service, it changes once the service restarted
+	invocation string
+}
+
+// keepPrevious keeps the content of a file before Apply changes it
+func (m *Manager) keepPrevious(path string, existed bool, content string) {
+	if m.applied == nil {
+		return
+	}
+	if _, ok := m.applied.previous[path]; ok {
+		return
+	}
+	if !existed {
+		m.applied.previous[path] = nil
+		return
+	}
+	m.applied.previous[path] = &content
+}
+
+// markRestart records how Apply restarted the service
+func (m *Manager) markRestart(restart restartKind) {
+	if m.applied != nil {
+		m.applied.restart = restart
+	}
+}
+
+// markSignalled records that Apply signalled the service to restart, invocation is its invocation ID
+// before the signal
+func (m *Manager) markSignalled(invocation string) {
+	if m.applied != nil {
+		m.applied.restart = restartSignalled
+		m.applied.invocation = invocation
+	}
+}
+
+// invocationID returns the systemd invocation ID of a service, empty when it can't be read
+func (m *Manager) invocationID(svcName string) string {
+	result, err := m.runCommand(fmt.Sprintf("systemctl show -p InvocationID --value %q", svcName))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(result.Stdout)
+}
+
+// VerifyExporter waits up to timeout for the exporter service restarted by the last Apply to be
+// healthy: active and reported online by online when not nil, an exporter without an online check
+// must also have no errors in its journal since the restart. When it isn't, the previous exporter
+// files are restored, the service is restarted and a *HealthError is returned. A signalled service
+// is verified once it restarted, the restarts deferred by a lease are reported as not verified.
+// Nothing is checked when the last Apply didn't restart the service.
+func (m *Manager) VerifyExporter(exporterConfig *v1alpha1.ExporterConfigTemplate, timeout time.Duration, online OnlineCheck) error {
+	svcName := exporterConfig.Spec.ExporterMetadata.Name
+	applied := m.applied
+	m.applied = nil
+	if applied == nil || applied.svcName != svcName || timeout <= 0 {
+		return nil
+	}
+	switch applied.restart {
+	case notRestarted:
+		return nil
+	case restartDeferred:
+		_, _ = fmt.Fprintf(m.writer, "        ⏸️  %s not restarted yet, its health is not verified\n", svcName)
+		return nil
+	case restartSignalled:
+		start := time.Now()
+		ok, err := m.waitRestarted(svcName, applied.invocation, timeout)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			_, _ = fmt.Fprintf(m.writer, "        ⏸️  %s didn't restart within %s, i.e. it is leased, its health is not verified\n",
+				svcName, timeout)
+			return nil
+		}
+		timeout -= time.Since(start)
+	}
+
+	reason, err := m.waitHealthy(svcName, timeout, online)
+	if err != nil {
+		return err
+	}
+	if reason == "" {
+		_, _ = fmt.Fprintf(m.writer, "        💚 %s is healthy\n", svcName)
+		return nil
+	}
+	_, _ = fmt.Fprintf(m.writer, "        💔 %s is not healthy after the update: %s\n", svcName, reason)
+
+	if len(applied.previous) == 0 {
+		return &HealthError{Service: svcName, Reason: reason}
+	}
+	deferred, err := m.rollback(svcName, applied.previous)
+	if err != nil {
+		return fmt.Errorf("failed to roll back %s: %w", svcName, err)
+	}
+	return &HealthError{Service: svcName, Reason: reason, RolledBack: true, RestartDeferred: deferred}
+}
+
+// waitRestarted waits up to timeout for the signalled service to restart, i.e. for its invocation
+// ID to change from invocation. It reports false when the service didn't restart.
+func (m *Manager) waitRestarted(svcName, invocation string, timeout time.Duration) (bool, error) {
+	if invocation == "" {
+		// without an invocation ID the restart can't be told apart from the previous process
+		return false, nil
+	}
+	ctx := m.context()
+	deadline := time.Now().Add(timeout)
+	for {
+		if current := m.invocationID(svcName); current != "" && current != invocation {
+			return true, nil
+		}
+		wait := min(healthCheckInterval, time.Until(deadline))
+		if wait <= 0 {
+			return false, nil
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return false, fmt.Errorf("health check of %s interrupted: %w", svcName, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// waitHealthy checks the health of the exporter service every healthCheckInterval until it is healthy
+// or timeout elapses, it returns why the service isn't healthy, or an empty string
+func (m *Manager) waitHealthy(svcName string, timeout time.Duration, online OnlineCheck) (string, error) {
+	ctx := m.context()
+	since, err := m.remoteTime()
+	if err != nil {
+		return "", err
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		// the first check waits too, the controller still reports the previous process online for a moment
+		wait := min(healthCheckInterval, time.Until(deadline))
+		timer := time.NewTimer(max(wait, 0))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return "", fmt.Errorf("health check of %s interrupted: %w", svcName, ctx.Err())
+		case <-timer.C:
+		}
+
+		reason := m.healthProblem(ctx, svcName, since, online)
+		if reason == "" || !time.Now().Before(deadline) {
+			return reason, nil
+		}
+	}
+}
+
+// remoteTime returns the current time of the host as a unix timestamp, so the journal can
+// be read from there regardless of the clock skew
+func (m *Manager) remoteTime() (int64, error) {
+	result, err := m.runCommand("date +%s")
+	if err != nil {
+		return 0, fmt.Errorf("failed to get the time of the host: %w", err)
+	}
+	timestamp, err := strconv.ParseInt(strings.TrimSpace(result.Stdout), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse the time of the host %q: %w", result.Stdout, err)
+	}
+	return timestamp, nil
+}
+
+// healthProblem returns why the exporter service isn't healthy, or an empty string when it is. The
+// errors in the journal, i.e. a transient connection error while starting, only matter when the
+// exporter isn't online too, or when there is no online check.
+func (m *Manager) healthProblem(ctx context.Context, svcName string, since int64, online OnlineCheck) string {
+	if active, _ := m.serviceState(svcName); active != systemdStateActive {
+		return fmt.Sprintf("service is %s%s", active, m.journalErrors(svcName, since))
+	}
+
+	if online == nil {
+		if errs := m.journalErrors(svcName, since); errs != "" {
+			return "service is active" + errs
+		}
+		return ""
+	}
+	isOnline, err := online(ctx)
+	if err != nil {
+		return fmt.Sprintf("failed to check the exporter status: %v", err)
+	}
+	if !isOnline {
+		return "the controller doesn't report the exporter online" + m.journalErrors(svcName, since)
+	}
+	return ""
+}
+
+// journalErrors summarizes the errors logged by the service since the unix timestamp since, it is
+// empty without errors
+func (m *Manager) journalErrors(svcName string, since int64) string {
+	journal, err := m.runCommand(fmt.Sprintf("journalctl -u %q --since @%d -p err -q --no-pager", svcName, since))
+	if err != nil {
+		return fmt.Sprintf(", failed to read the journal: %v", err)
+	}
+	errs := strings.TrimSpace(journal.Stdout)
+	if errs == "" {
+		return ""
+	}
+	lines := strings.Split(errs, "\n")
+	return fmt.Sprintf(", %d errors in the journal, last: %s", len(lines), lines[len(lines)-1])
+}
+
+// restorePrevious restores the content of the exporter files before the last Apply and reloads
+// systemd. It undoes an applied change, so it's never in a plan.
+func (m *Manager) restorePrevious(previous map[string]*string) error {
+	recorder := m.recorder
+	m.recorder = nil
+	defer func() { m.recorder = recorder }()
+	for path, content := range previous {
+		restored := ""
+		if content != nil {
+			restored = *content
+		}
+		if _, err := m.reconcileFile(path, restored, false); err != nil {
+			return err
+		}
+	}
+	if _, err := m.runCommand("systemctl daemon-reload"); err != nil {
+		return fmt.Errorf("failed to reload systemd: %w", err)
+	}
+	return nil
+}
+
+// rollback restores the previous content of the exporter files and restarts the service, unless
+// it is leased: the restart is then deferred and reported as such
+func (m *Manager) rollback(svcName string, previous map[string]*string) (deferred bool, err error) {
+	_, _ = fmt.Fprintf(m.writer, "        ⏪ Rolling back %s\n", svcName)
+	if err := m.restorePrevious(previous); err != nil {
+		return false, err
+	}
+	if m.deferredByLease(svcName) {
+		_, _ = fmt.Fprintf(m.writer, "        ⏸️  Rollback of %s deferred, the previous configuration is used from its next restart\n", svcName)
+		return true, nil
+	}
+	if _, err := m.runCommand(fmt.Sprintf("systemctl restart %q", svcName)); err != nil {
+		return false, fmt.Errorf("failed to restart %s: %w", svcName, err)
+	}
+	_, _ = fmt.Fprintf(m.writer, "        ⏪ %s rolled back to its previous configuration\n", svcName)
+	return false, nil
+}
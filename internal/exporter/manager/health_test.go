@@ -0,0 +1,203 @@
+package manager
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/api/v1alpha1"
+)
+
+// fakeHost is an exporter host with an in-memory file system, running the commands with exec
+type fakeHost struct {
+	manager   *Manager
+	transport *memTransport
+	out       *bytes.Buffer
+}
+
+func newFakeHost(t *testing.T, exec func(command string) (string, uint32)) *fakeHost {
+	t.Helper()
+	host := &fakeHost{transport: newMemTransport(exec), out: &bytes.Buffer{}}
+	host.manager = New(context.Background(), createTestExporterHost("health"), host.transport)
+	host.manager.SetWriter(host.out)
+	return host
+}
+
+func (h *fakeHost) ran(command string) bool {
+	return h.transport.ran(command)
+}
+
+// exporterCommands answers the commands of Apply and of the health checks for an active exporter,
+// journalErrors is the output of journalctl. A signalled exporter restarts right away.
+func exporterCommands(journalErrors string) func(command string) (string, uint32) {
+	signalled := false
+	return func(command string) (string, uint32) {
+		switch {
+		case strings.Contains(command, "kill -s SIGHUP"):
+			signalled = true
+		case strings.HasPrefix(command, "systemctl show -p InvocationID"):
+			if signalled {
+				return "restarted\n", 0
+			}
+			return "started\n", 0
+		case command == "date +%s":
+			return "1700000000\n", 0
+		case strings.HasPrefix(command, "systemctl is-active bootc"):
+			return "inactive\ninactive\n", 3
+		case strings.HasPrefix(command, "systemctl is-active"):
+			return "active\n", 0
+		case strings.HasPrefix(command, "journalctl"):
+			return journalErrors, 0
+		case strings.Contains(command, "bootc upgrade --check"):
+			return "", 1
+		}
+		return "", 0
+	}
+}
+
+func TestVerifyExporter(t *testing.T) {
+	interval := healthCheckInterval
+	healthCheckInterval = 5 * time.Millisecond
+	t.Cleanup(func() {
+		healthCheckInterval = interval
+	})
+
+	const oldContainer = "[Container]\nImage=quay.io/jumpstarter/exporter:old\n"
+	exporterConfig := &v1alpha1.ExporterConfigTemplate{}
+	exporterConfig.Spec.ExporterMetadata.Name = "exporter"
+	exporterConfig.Spec.SystemdContainerTemplate = "[Container]\nImage=quay.io/jumpstarter/exporter:new\n"
+	exporterConfig.Spec.ConfigTemplate = "endpoint: grpc.example.com\n"
+
+	tests := []struct {
+		name          string
+		journalErrors string
+		online        bool
+		wantErr       string
+	}{
+		{name: "healthy", online: true},
+		{name: "offline", online: false, wantErr: "the controller doesn't report the exporter online"},
+		{name: "transient journal errors", online: true, journalErrors: "error: connection refused\n"},
+		{name: "offline with journal errors", online: false, journalErrors: "error: invalid driver\n",
+			wantErr: "the controller doesn't report the exporter online, 1 errors in the journal, last: error: invalid driver"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host := newFakeHost(t, exporterCommands(tt.journalErrors))
+			writeRemoteFile(t, host.transport, "/etc/containers/systemd/exporter.container", oldContainer)
+
+			require.NoError(t, host.manager.Apply(exporterConfig, false))
+			err := host.manager.VerifyExporter(exporterConfig, 50*time.Millisecond, func(context.Context) (bool, error) {
+				return tt.online, nil
+			})
+
+			container, readErr := host.manager.readFile("/etc/containers/systemd/exporter.container")
+			require.NoError(t, readErr)
+			config, readErr := host.manager.readFile("/etc/jumpstarter/exporters/exporter.yaml")
+			require.NoError(t, readErr)
+
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				assert.Contains(t, host.out.String(), "exporter is healthy")
+				assert.Equal(t, exporterConfig.Spec.SystemdContainerTemplate, container)
+				assert.Equal(t, exporterConfig.Spec.ConfigTemplate, config)
+				assert.False(t, host.ran("systemctl restart"))
+				return
+			}
+			require.ErrorContains(t, err, tt.wantErr)
+			assert.True(t, IsHealthError(err))
+			assert.Contains(t, err.Error(), "rolled back")
+			assert.Equal(t, oldContainer, container, "the previous container unit is restored")
+			assert.Empty(t, config, "the created exporter config is removed")
+			assert.True(t, host.ran(`systemctl restart "exporter"`))
+		})
+	}
+}
+
+func TestVerifyExporter_NotRestarted(t *testing.T) {
+	host := newFakeHost(t, exporterCommands(""))
+	exporterConfig := &v1alpha1.ExporterConfigTemplate{}
+	exporterConfig.Spec.ExporterMetadata.Name = "exporter"
+	exporterConfig.Spec.ConfigTemplate = "endpoint: grpc.example.com\n"
+	writeRemoteFile(t, host.transport, "/etc/jumpstarter/exporters/exporter.yaml", exporterConfig.Spec.ConfigTemplate)
+
+	require.NoError(t, host.manager.Apply(exporterConfig, false))
+	err := host.manager.VerifyExporter(exporterConfig, time.Minute, func(context.Context) (bool, error) {
+		return false, nil
+	})
+	require.NoError(t, err, "an exporter which wasn't restarted isn't verified")
+	assert.False(t, host.ran("journalctl"))
+}
+
+func TestVerifyExporter_Unverified(t *testing.T) {
+	interval := healthCheckInterval
+	healthCheckInterval = 5 * time.Millisecond
+	t.Cleanup(func() {
+		healthCheckInterval = interval
+	})
+
+	exporterConfig := &v1alpha1.ExporterConfigTemplate{}
+	exporterConfig.Spec.ExporterMetadata.Name = "exporter"
+	exporterConfig.Spec.ConfigTemplate = "endpoint: grpc.example.com\n"
+	offline := func(context.Context) (bool, error) {
+		return false, nil
+	}
+
+	t.Run("signalled and still leased", func(t *testing.T) {
+		// the invocation ID doesn't change, the exporter waits for the end of its lease
+		host := newFakeHost(t, func(command string) (string, uint32) {
+			if strings.HasPrefix(command, "systemctl show -p InvocationID") {
+				return "started\n", 0
+			}
+			return exporterCommands("")(command)
+		})
+		require.NoError(t, host.manager.Apply(exporterConfig, false))
+		require.NoError(t, host.manager.VerifyExporter(exporterConfig, 20*time.Millisecond, offline))
+		assert.Contains(t, host.out.String(), "exporter didn't restart within 20ms, i.e. it is leased, its health is not verified")
+		assert.False(t, host.ran("journalctl"))
+	})
+
+	t.Run("restart deferred by a lease", func(t *testing.T) {
+		host := newFakeHost(t, func(command string) (string, uint32) {
+			if strings.HasPrefix(command, "systemctl is-active") {
+				return "inactive\n", 3
+			}
+			return "", 0
+		})
+		host.manager.SetLeaseCheck(func(context.Context) (string, error) { return "lease-1", nil })
+		require.NoError(t, host.manager.Apply(exporterConfig, false))
+		require.NoError(t, host.manager.VerifyExporter(exporterConfig, time.Minute, offline))
+		assert.Contains(t, host.out.String(), "exporter not restarted yet, its health is not verified")
+	})
+}
+
+func TestVerifyExporter_RollbackDeferred(t *testing.T) {
+	interval := healthCheckInterval
+	healthCheckInterval = 5 * time.Millisecond
+	t.Cleanup(func() {
+		healthCheckInterval = interval
+	})
+
+	exporterConfig := &v1alpha1.ExporterConfigTemplate{}
+	exporterConfig.Spec.ExporterMetadata.Name = "exporter"
+	exporterConfig.Spec.ConfigTemplate = "endpoint: grpc.example.com\n"
+	host := newFakeHost(t, exporterCommands(""))
+	writeRemoteFile(t, host.transport, "/etc/jumpstarter/exporters/exporter.yaml", "endpoint: grpc.old.example.com\n")
+
+	require.NoError(t, host.manager.Apply(exporterConfig, false))
+	// leased during the health check
+	host.manager.SetLeaseCheck(func(context.Context) (string, error) { return "lease-1", nil })
+	err := host.manager.VerifyExporter(exporterConfig, 20*time.Millisecond, func(context.Context) (bool, error) {
+		return false, nil
+	})
+	require.ErrorContains(t, err, "rolled back but not restarted while leased")
+	config, readErr := host.manager.readFile("/etc/jumpstarter/exporters/exporter.yaml")
+	require.NoError(t, readErr)
+	assert.Equal(t, "endpoint: grpc.old.example.com\n", config, "the previous config is restored")
+	assert.False(t, host.ran(`systemctl restart "exporter"`))
+	assert.Contains(t, host.out.String(), "Rollback of exporter deferred")
+}
@@ -0,0 +1,60 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// LeaseCheck returns the active lease of the exporter about to be started or restarted, empty
+// when it isn't leased
+type LeaseCheck func(ctx context.Context) (lease string, err error)
+
+// deferredByLease checks whether the start or restart of the exporter service svcName must wait
+// for the end of its lease
+func (m *Manager) deferredByLease(svcName string) bool {
+	if m.leaseCheck == nil {
+		return false
+	}
+	lease, err := m.leaseCheck(m.context())
+	switch {
+	case err != nil:
+		_, _ = fmt.Fprintf(m.writer, "        ⏸️  Restart of %s deferred, its lease couldn't be checked: %v\n", svcName, err)
+		return true
+	case lease != "":
+		_, _ = fmt.Fprintf(m.writer, "        ⏸️  Restart of %s deferred until the end of lease %s\n", svcName, lease)
+		return true
+	}
+	return false
+}
+
+// deferredUpdate checks, before any file is written, whether the update of the files of the running
+// exporter service svcName to their content in files must wait for the end of its lease. The files
+// are then left unchanged, so the next apply finds the change again.
+func (m *Manager) deferredUpdate(svcName string, files map[string]string) (bool, error) {
+	outdated := false
+	for path, content := range files {
+		existing, err := m.readFile(path)
+		if err != nil {
+			return false, err
+		}
+		outdated = outdated || existing != content
+	}
+	if !outdated {
+		return false, nil
+	}
+	statusResult, _ := m.runCommand(fmt.Sprintf("systemctl is-active %q", svcName))
+	serviceRunning := statusResult != nil && statusResult.ExitCode == 0 && strings.TrimSpace(statusResult.Stdout) == systemdStateActive
+	if !serviceRunning || !m.deferredByLease(svcName) {
+		return false, nil
+	}
+	_, _ = fmt.Fprintf(m.writer, "        ⏸️  Update of %s deferred, its files are left unchanged\n", svcName)
+	m.markRestart(restartDeferred)
+	return true, nil
+}
+
+// SetLeaseCheck defers the starts and restarts of the exporter applied next while check reports
+// it leased
+func (m *Manager) SetLeaseCheck(check LeaseCheck) {
+	m.leaseCheck = check
+}
@@ -0,0 +1,75 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/api/v1alpha1"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/plan"
+)
+
+func TestApply_LeasedExporter(t *testing.T) {
+	exporterConfig := &v1alpha1.ExporterConfigTemplate{}
+	exporterConfig.Spec.ExporterMetadata.Name = "exporter"
+	exporterConfig.Spec.ConfigTemplate = "endpoint: grpc.example.com\n"
+	stopped := func(command string) (string, uint32) {
+		if strings.HasPrefix(command, "systemctl is-active") {
+			return "inactive\n", 3
+		}
+		return "", 0
+	}
+
+	tests := []struct {
+		name      string
+		check     LeaseCheck
+		deferred  string
+		restarted bool
+	}{
+		{name: "leased", check: func(context.Context) (string, error) { return "lease-1", nil },
+			deferred: "Restart of exporter deferred until the end of lease lease-1"},
+		{name: "lease check failed", check: func(context.Context) (string, error) { return "", errors.New("unreachable") },
+			deferred: "Restart of exporter deferred, its lease couldn't be checked: unreachable"},
+		{name: "not leased", check: func(context.Context) (string, error) { return "", nil }, restarted: true},
+		{name: "forced", restarted: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host := newFakeHost(t, stopped)
+			recorder := plan.NewRecorder()
+			host.manager.SetRecorder(recorder)
+			host.manager.SetLeaseCheck(tt.check)
+
+			require.NoError(t, host.manager.Apply(exporterConfig, false))
+			assert.Equal(t, tt.restarted, host.ran(`systemctl start "exporter"`))
+			changes := recorder.Plan(plan.Options{}).Changes
+			require.NotEmpty(t, changes, "the exporter config is written regardless")
+			assert.Equal(t, tt.restarted, changes[len(changes)-1].Action == plan.ActionRestart)
+			if !tt.restarted {
+				assert.Contains(t, host.out.String(), tt.deferred)
+			}
+		})
+	}
+}
+
+func TestApply_LeasedRunningExporter(t *testing.T) {
+	exporterConfig := &v1alpha1.ExporterConfigTemplate{}
+	exporterConfig.Spec.ExporterMetadata.Name = "exporter"
+	exporterConfig.Spec.ConfigTemplate = "endpoint: grpc.example.com\n"
+	host := newFakeHost(t, exporterCommands(""))
+	writeRemoteFile(t, host.transport, "/etc/jumpstarter/exporters/exporter.yaml", "endpoint: grpc.old.example.com\n")
+	host.manager.SetLeaseCheck(func(context.Context) (string, error) { return "lease-1", nil })
+
+	require.NoError(t, host.manager.Apply(exporterConfig, false))
+	assert.False(t, host.ran("command -v podman"), "a leased exporter isn't signalled")
+	assert.False(t, host.ran("systemctl daemon-reload"), "nothing is reloaded")
+	assert.NotContains(t, host.out.String(), "Updated file", "the lease is checked before the files are written")
+	config, err := host.manager.readFile("/etc/jumpstarter/exporters/exporter.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, "endpoint: grpc.old.example.com\n", config, "the update is left for the next apply")
+	assert.Contains(t, host.out.String(), "Update of exporter deferred, its files are left unchanged")
+}
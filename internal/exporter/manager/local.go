@@ -0,0 +1,101 @@
+package manager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/api/v1alpha1"
+)
+
+// LocalTransport reaches the files of an exporter host in a local directory standing for the root of its
+// file system, for offline end-to-end testing. It runs no command: the exporter services are neither
+// started nor stopped, and the ownership of the files is left to the local user.
+type LocalTransport struct {
+	hostName string
+	root     string
+}
+
+// NewLocalTransport creates the transport of an exporter host reconciled into its local root directory
+func NewLocalTransport(exporterHost *v1alpha1.ExporterHost) (*LocalTransport, error) {
+	root := exporterHost.Spec.Management.Local.Root
+	if root == "" {
+		return nil, fmt.Errorf("local root directory of %q is not set", exporterHost.Name)
+	}
+	return &LocalTransport{hostName: exporterHost.Name, root: root}, nil
+}
+
+// path returns the local path of a path of the exporter host
+func (t *LocalTransport) path(path string) string {
+	return filepath.Join(t.root, path)
+}
+
+func (t *LocalTransport) ReadFile(path string) (string, error) {
+	content, err := os.ReadFile(t.path(path))
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+func (t *LocalTransport) WriteFile(path, content string, attrs FileAttrs) error {
+	localPath := t.path(path)
+	if err := os.MkdirAll(filepath.Dir(localPath), 0o755); err != nil {
+		return fmt.Errorf("failed to create parent directories for %s: %w", path, err)
+	}
+	file, err := os.CreateTemp(filepath.Dir(localPath), "."+filepath.Base(localPath)+".tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file for %s: %w", path, err)
+	}
+	err = file.Chmod(attrs.Mode)
+	if err == nil {
+		_, err = file.WriteString(content)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), localPath)
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+func (t *LocalTransport) RemoveFile(path string) error {
+	if err := os.Remove(t.path(path)); err != nil {
+		return fmt.Errorf("failed to delete file %s: %w", path, err)
+	}
+	return nil
+}
+
+// FileAttrs returns the mode of a file, its ownership isn't managed and is reported as KeepOwner
+func (t *LocalTransport) FileAttrs(path string) (FileAttrs, error) {
+	info, err := os.Stat(t.path(path))
+	if err != nil {
+		return FileAttrs{}, fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+	return FileAttrs{Mode: info.Mode().Perm(), UID: KeepOwner, GID: KeepOwner}, nil
+}
+
+// SetFileAttrs sets the mode of a file, its ownership is left to the local user
+func (t *LocalTransport) SetFileAttrs(path string, attrs FileAttrs) error {
+	if err := os.Chmod(t.path(path), attrs.Mode); err != nil {
+		return fmt.Errorf("failed to set mode of %s: %w", path, err)
+	}
+	return nil
+}
+
+func (t *LocalTransport) RunsCommands() bool {
+	return false
+}
+
+func (t *LocalTransport) Run(command string) (*CommandResult, error) {
+	return nil, fmt.Errorf("the local backend of %q doesn't run commands", t.hostName)
+}
+
+func (t *LocalTransport) Close() error {
+	return nil
+}
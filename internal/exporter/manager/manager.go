@@ -0,0 +1,833 @@
+// Package manager deploys the exporters on the exporter hosts, through the file system and the
+// commands of a Transport
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/api/v1alpha1"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/container"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/plan"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// BootcStatus represents the status of bootc upgrade
+type BootcStatus int
+
+const (
+	BOOTC_UP_TO_DATE BootcStatus = iota
+	BOOTC_UPDATING
+	BOOTC_WILL_UPDATE
+	BOOTC_NOT_MANAGED
+)
+
+const (
+	noValuePlaceholder = "<no value>"
+	systemdStateActive = "active"
+)
+
+type HostManager interface {
+	Status() (string, error)
+	NeedsUpdate(exporterConfig *v1alpha1.ExporterConfigTemplate) (bool, error)
+	Diff(exporterConfig *v1alpha1.ExporterConfigTemplate) (string, error)
+	Apply(exporterConfig *v1alpha1.ExporterConfigTemplate, dryRun bool) error
+	VerifyExporter(exporterConfig *v1alpha1.ExporterConfigTemplate, timeout time.Duration, online OnlineCheck) error
+	RunHostCommand(command string) (*CommandResult, error)
+	GetBootcStatus() BootcStatus
+	BootcUpdating() bool
+	BootcImages() (*BootcImages, error)
+	HandleBootcUpgrade(dryRun bool) error
+	SetBootcScheduler(s BootcScheduler)
+	SetLeaseCheck(check LeaseCheck)
+	StopExporter(svcName string, dryRun bool) (bool, error)
+	ManagedExporters() (map[string]string, error)
+	MarkManaged(svcName, instanceName string, dryRun bool) error
+	Decommission(svcName string, dryRun bool) error
+	SetWriter(w io.Writer)
+	SetRecorder(r *plan.Recorder)
+	SetSecrets(secrets ...string)
+	Close() error
+}
+
+// Manager deploys the exporters on an exporter host through its Transport
+type Manager struct {
+	ExporterHost *v1alpha1.ExporterHost `json:"exporterHost,omitempty"`
+	transport    Transport
+	writer       io.Writer
+	// recorder receives the file and service changes, nil unless a plan is being made or checked
+	recorder *plan.Recorder
+	// ctx bounds the lifetime of the manager, no file is touched once it is done
+	ctx context.Context
+	// applied is what the last Apply changed, until it is verified by VerifyExporter
+	applied *appliedExporter
+	// bootcScheduler decides when the bootc image can be updated, at any time when nil
+	bootcScheduler BootcScheduler
+	// leaseCheck reports the lease of the exporter being applied, it is restarted regardless when nil
+	leaseCheck LeaseCheck
+	// architecture is the container image architecture of the host, detected once
+	architecture *string
+	// secrets are masked in the hashes of the recorded file changes
+	secrets []string
+	// fileAttrs are the mode and ownership of the files of the exporter applied last, keyed by path
+	fileAttrs map[string]FileAttrs
+}
+
+// New creates the Manager of an exporter host reached through transport, which is closed with it
+func New(ctx context.Context, exporterHost *v1alpha1.ExporterHost, transport Transport) *Manager {
+	return &Manager{
+		ExporterHost: exporterHost,
+		transport:    transport,
+		writer:       os.Stdout,
+		ctx:          ctx,
+	}
+}
+
+func (m *Manager) Status() (string, error) {
+	if !m.transport.RunsCommands() {
+		if _, err := m.readFile(managedExportersFile); err != nil {
+			return "", fmt.Errorf("failed to check %q: %w", m.ExporterHost.Name, err)
+		}
+		return "ok", nil
+	}
+	result, err := m.runCommand("ls -la")
+	if err != nil {
+		return "", fmt.Errorf("failed to run status command for %q: %w", m.ExporterHost.Name, err)
+	}
+
+	// For now, return a simple status based on exit code
+	if result.ExitCode == 0 {
+		return "ok", nil
+	}
+	return fmt.Sprintf("error (exit code: %d)", result.ExitCode), nil
+}
+
+// context returns the context of the connection
+func (m *Manager) context() context.Context {
+	if m.ctx == nil {
+		return context.Background()
+	}
+	return m.ctx
+}
+
+// runCommand executes a command on the host and returns the result
+func (m *Manager) runCommand(command string) (*CommandResult, error) {
+	return m.transport.Run(command)
+}
+
+// NeedsUpdate checks whether Apply would change the host for exporterConfig
+func (m *Manager) NeedsUpdate(exporterConfig *v1alpha1.ExporterConfigTemplate) (bool, error) {
+	diff, err := m.Diff(exporterConfig)
+	if err != nil {
+		return false, err
+	}
+	return diff != "", nil
+}
+
+// Diff returns the unified diff between the exporter files on the host and the rendered exporterConfig,
+// followed by the state of the exporter service and its container image when they are outdated.
+// It is empty when the host is up to date, the secrets are masked.
+func (m *Manager) Diff(exporterConfig *v1alpha1.ExporterConfigTemplate) (string, error) {
+	diff, err := m.fileDiff(exporterConfig)
+	if err != nil {
+		return "", err
+	}
+
+	if !m.transport.RunsCommands() {
+		return diff, nil
+	}
+	svcName := exporterConfig.Spec.ExporterMetadata.Name
+	statusResult, err := m.runCommand("systemctl is-active " + fmt.Sprintf("%q", svcName))
+	if statusResult == nil {
+		return "", fmt.Errorf("failed to check service %s: %w", svcName, err)
+	}
+	if state := strings.TrimSpace(statusResult.Stdout); state != systemdStateActive {
+		diff += fmt.Sprintf("~ service %s is %s, it would be started\n", svcName, state)
+		return diff, nil
+	}
+
+	if exporterConfig.Spec.SystemdContainerTemplate == "" || exporterConfig.Spec.ContainerImage == "" {
+		return diff, nil
+	}
+	expectedLabels, err := container.GetImageLabelsFromRegistry(m.context(), exporterConfig.Spec.ContainerImage,
+		m.imageArchitecture())
+	if err != nil {
+		return "", fmt.Errorf("failed to check container version of %s: %w", svcName, err)
+	}
+	if expectedLabels.IsEmpty() {
+		return diff, nil
+	}
+	runningLabels, err := m.getRunningContainerLabels(svcName)
+	if err != nil {
+		return "", fmt.Errorf("failed to check running container version of %s: %w", svcName, err)
+	}
+	if !expectedLabels.Matches(runningLabels) {
+		diff += fmt.Sprintf("~ container image of %s: running %s, latest %s\n", svcName,
+			runningLabels.String(), expectedLabels.String())
+	}
+	return diff, nil
+}
+
+// ExporterFiles returns the paths of the container unit, service unit and exporter config of an exporter
+func ExporterFiles(svcName string) (containerSystemdFile, serviceSystemdFile, exporterConfigFile string) {
+	return "/etc/containers/systemd/" + svcName + ".container",
+		"/etc/systemd/system/" + svcName + ".service",
+		"/etc/jumpstarter/exporters/" + svcName + ".yaml"
+}
+
+// fileDiff returns the unified diff between the exporter files on the host and their rendered content
+func (m *Manager) fileDiff(exporterConfig *v1alpha1.ExporterConfigTemplate) (string, error) {
+	containerSystemdFile, serviceSystemdFile, exporterConfigFile := ExporterFiles(exporterConfig.Spec.ExporterMetadata.Name)
+	fileAttrs, err := TemplateFileAttrs(exporterConfig)
+	if err != nil {
+		return "", err
+	}
+	m.fileAttrs = fileAttrs
+	var diff strings.Builder
+	for _, file := range []struct{ path, content string }{
+		{containerSystemdFile, exporterConfig.Spec.SystemdContainerTemplate},
+		{serviceSystemdFile, exporterConfig.Spec.SystemdServiceTemplate},
+		{exporterConfigFile, exporterConfig.Spec.ConfigTemplate},
+	} {
+		existing, err := m.readFile(file.path)
+		if err != nil {
+			return "", err
+		}
+		fileDiff, err := UnifiedDiff(file.path, existing, file.content)
+		if err != nil {
+			return "", err
+		}
+		diff.WriteString(fileDiff)
+		if existing != "" && existing == file.content {
+			attrsDiff, err := m.attrsDiff(file.path)
+			if err != nil {
+				return "", err
+			}
+			diff.WriteString(attrsDiff)
+		}
+	}
+	return SanitizeDiff(diff.String()), nil
+}
+
+// readFile reads a file on the host, a missing file is read as empty
+func (m *Manager) readFile(path string) (string, error) {
+	content, err := m.transport.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return content, nil
+}
+
+// splitLines splits content in lines keeping their line ending, which is added to the last line when missing
+func splitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		return lines[:len(lines)-1]
+	}
+	lines[len(lines)-1] += "\n"
+	return lines
+}
+
+// UnifiedDiff returns the unified diff from the existing to the desired content of path,
+// an empty content stands for a missing file
+func UnifiedDiff(path, existing, desired string) (string, error) {
+	if existing == desired {
+		return "", nil
+	}
+	fromFile, toFile := "a"+path, "b"+path
+	if existing == "" {
+		fromFile = "/dev/null"
+	}
+	if desired == "" {
+		toFile = "/dev/null"
+	}
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(existing),
+		B:        splitLines(desired),
+		FromFile: fromFile,
+		ToFile:   toFile,
+		Context:  3,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to diff %s: %w", path, err)
+	}
+	return diff, nil
+}
+
+func (m *Manager) Apply(exporterConfig *v1alpha1.ExporterConfigTemplate, dryRun bool) error {
+	// Validate mutual exclusivity: both templates cannot be specified simultaneously
+	if exporterConfig.Spec.SystemdContainerTemplate != "" && exporterConfig.Spec.SystemdServiceTemplate != "" {
+		return fmt.Errorf("both SystemdContainerTemplate and SystemdServiceTemplate specified - only one should be used")
+	}
+
+	// Helper function to restart service, gracefully wating on lease exit, the restart is recorded by the caller
+	restartGracefully := func(serviceName string, dryRun bool) {
+		if !dryRun {
+			invocation := m.invocationID(serviceName)
+			_, enableErr := m.runCommand(fmt.Sprintf("command -v podman >/dev/null 2>&1 && podman kill -s SIGHUP %q || systemctl kill -s SIGHUP %q", serviceName, serviceName))
+			if enableErr != nil {
+				_, _ = fmt.Fprintf(m.writer, "        ❌ Failed to signal %s: %v\n", serviceName, enableErr)
+			} else {
+				_, _ = fmt.Fprintf(m.writer, "        ✅ %s signalled to restart when not leased\n", serviceName)
+				m.markSignalled(invocation)
+			}
+		} else {
+			_, _ = fmt.Fprintf(m.writer, "        📄 Would trigger restart of %s\n", serviceName)
+		}
+	}
+
+	svcName := exporterConfig.Spec.ExporterMetadata.Name
+	containerSystemdFile, serviceSystemdFile, exporterConfigFile := ExporterFiles(svcName)
+	fileAttrs, err := TemplateFileAttrs(exporterConfig)
+	if err != nil {
+		return err
+	}
+	m.fileAttrs = fileAttrs
+
+	m.applied = nil
+	if !dryRun {
+		m.applied = &appliedExporter{svcName: svcName, previous: make(map[string]*string)}
+	}
+
+	if !dryRun && m.transport.RunsCommands() {
+		deferred, err := m.deferredUpdate(svcName, map[string]string{
+			containerSystemdFile: exporterConfig.Spec.SystemdContainerTemplate,
+			serviceSystemdFile:   exporterConfig.Spec.SystemdServiceTemplate,
+			exporterConfigFile:   exporterConfig.Spec.ConfigTemplate,
+		})
+		if err != nil || deferred {
+			return err
+		}
+	}
+
+	changedContainer, err := m.reconcileFile(containerSystemdFile, exporterConfig.Spec.SystemdContainerTemplate, dryRun)
+	if err != nil {
+		return fmt.Errorf("failed to reconcile container systemd file: %w", err)
+	}
+
+	changedService, err := m.reconcileFile(serviceSystemdFile, exporterConfig.Spec.SystemdServiceTemplate, dryRun)
+	if err != nil {
+		return fmt.Errorf("failed to reconcile service systemd file: %w", err)
+	}
+
+	changedExporterConfig, err := m.reconcileFile(exporterConfigFile, exporterConfig.Spec.ConfigTemplate, dryRun)
+	if err != nil {
+		return fmt.Errorf("failed to reconcile exporter config file: %w", err)
+	}
+
+	if !m.transport.RunsCommands() {
+		if changedExporterConfig || changedContainer || changedService {
+			if err := m.recordService(svcName, plan.ActionRestart, "", ""); err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(m.writer, "        ℹ️ %s not restarted, the exporter services aren't run on this host\n", svcName)
+		}
+		return nil
+	}
+
+	if m.GetBootcStatus() == BOOTC_UPDATING {
+		if dryRun {
+			_, _ = fmt.Fprintf(m.writer, "        📄 Bootc upgrade in progress, would skip exporter service restarts/container updates\n")
+		} else {
+			_, _ = fmt.Fprintf(m.writer, "        ⚠️ Bootc upgrade in progress, skipping exporter service restarts/container updates\n")
+			return nil
+		}
+	}
+
+	// Only if bootc is not updating, we restart/start services and pull containers
+	// otherwise it's too much pressure on the system
+
+	if changedExporterConfig || changedContainer || changedService {
+		if !dryRun {
+			_, err := m.runCommand("systemctl daemon-reload")
+			if err != nil {
+				return fmt.Errorf("failed to reload systemd: %w", err)
+			}
+			if changedService {
+				_, err = m.runCommand("systemctl enable " + fmt.Sprintf("%q", svcName))
+				if err != nil {
+					return fmt.Errorf("failed to enable exporter: %w", err)
+				}
+			}
+
+			statusResult, _ := m.runCommand("systemctl is-active " + fmt.Sprintf("%q", svcName))
+			serviceRunning := statusResult != nil && statusResult.ExitCode == 0 && strings.TrimSpace(statusResult.Stdout) == systemdStateActive
+
+			if serviceRunning {
+				// the lease was checked by deferredUpdate before the files were written
+				if err := m.recordService(svcName, plan.ActionRestart, "", ""); err != nil {
+					return err
+				}
+				restartGracefully(svcName, dryRun)
+			} else {
+				if m.deferredByLease(svcName) {
+					m.markRestart(restartDeferred)
+					return nil
+				}
+				if err := m.recordService(svcName, plan.ActionRestart, "", ""); err != nil {
+					return err
+				}
+				if err := m.reviveService(svcName, exporterConfig.Spec.SystemdServiceTemplate != ""); err != nil {
+					return err
+				}
+				_, startErr := m.runCommand("systemctl start " + fmt.Sprintf("%q", svcName))
+				if startErr != nil {
+					_, _ = fmt.Fprintf(m.writer, "        ❌ Failed to start service %s: %v\n", svcName, startErr)
+				} else {
+					_, _ = fmt.Fprintf(m.writer, "        ✅ Service %s started\n", svcName)
+					m.markRestart(restarted)
+				}
+			}
+		} else {
+			if err := m.recordService(svcName, plan.ActionRestart, "", ""); err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(m.writer, "        📄 Would reload systemd and start/restart %s\n", svcName)
+		}
+	} else {
+		// Check if service is running and start if needed
+		statusResult, err := m.runCommand("systemctl is-active " + fmt.Sprintf("%q", svcName))
+		serviceRunning := err == nil && strings.TrimSpace(statusResult.Stdout) == systemdStateActive
+
+		if !serviceRunning {
+			_, _ = fmt.Fprintf(m.writer, "        ⚠️ Service %s is not running...\n", svcName)
+			if m.deferredByLease(svcName) {
+				m.markRestart(restartDeferred)
+				return nil
+			}
+			if err := m.recordService(svcName, plan.ActionRestart, "", ""); err != nil {
+				return err
+			}
+			if !dryRun {
+				if err := m.reviveService(svcName, exporterConfig.Spec.SystemdServiceTemplate != ""); err != nil {
+					return err
+				}
+				_, enableErr := m.runCommand("systemctl restart " + fmt.Sprintf("%q", svcName))
+				if enableErr != nil {
+					_, _ = fmt.Fprintf(m.writer, "        ❌ Failed to restart service %s: %v\n", svcName, enableErr)
+				} else {
+					_, _ = fmt.Fprintf(m.writer, "        ✅ Service %s restarted\n", svcName)
+					m.markRestart(restarted)
+				}
+			} else {
+				_, _ = fmt.Fprintf(m.writer, "        📄 Would restart service %s\n", svcName)
+			}
+		} else {
+			// Only check container version if service is running
+			err = m.checkContainerVersion(exporterConfig, svcName, dryRun, restartGracefully)
+			if err != nil {
+				return fmt.Errorf("container version check failed: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkContainerVersion checks if container needs updating using detailed version comparison
+func (m *Manager) checkContainerVersion(exporterConfig *v1alpha1.ExporterConfigTemplate, svcName string, dryRun bool, restartService func(string, bool)) error {
+	// Only check version for container-based exporters
+	if exporterConfig.Spec.SystemdContainerTemplate == "" {
+		return nil
+	}
+
+	// Check detailed version comparison (if we have container image info)
+	if exporterConfig.Spec.ContainerImage != "" {
+		return m.checkDetailedContainerVersion(exporterConfig.Spec.ContainerImage, svcName, dryRun, restartService)
+	}
+
+	// No container image specified, nothing to check
+	return nil
+}
+
+// checkDetailedContainerVersion compares the labels of the image in the registry with the ones of the
+// running container
+func (m *Manager) checkDetailedContainerVersion(containerImage, svcName string, dryRun bool, restartService func(string, bool)) error {
+	// Get expected version from registry
+	expectedLabels, err := container.GetImageLabelsFromRegistry(m.context(), containerImage, m.imageArchitecture())
+	if err != nil {
+		_, _ = fmt.Fprintf(m.writer, "        ⚠️ Could not check container version: %v\n", err)
+		return nil // Don't fail the entire operation, just skip version check
+	}
+
+	if expectedLabels.IsEmpty() {
+		_, _ = fmt.Fprintf(m.writer, "        ℹ️ No version info available for image %s\n", containerImage)
+		return nil // Don't fail, just skip version check
+	}
+
+	// Get running container version
+	runningLabels, err := m.getRunningContainerLabels(svcName)
+	if err != nil {
+		_, _ = fmt.Fprintf(m.writer, "        ⚠️ Could not check running container version: %v\n", err)
+		return nil // Container might not be running yet, which is fine
+	}
+
+	// Compare versions
+	if expectedLabels.Matches(runningLabels) {
+		if dryRun {
+			_, _ = fmt.Fprintf(m.writer, "        ✅ Exporter container image running latest version\n")
+		}
+		// In non-dry-run mode, print nothing for matching versions as requested
+	} else {
+		err := m.recordService(svcName, plan.ActionUpgrade, plan.HashContent(runningLabels.String()), plan.HashContent(expectedLabels.String()))
+		if err != nil {
+			return err
+		}
+		if dryRun {
+			_, _ = fmt.Fprintf(m.writer, "        🔄 Would restart service for container update (running: %s, latest: %s)\n",
+				runningLabels.String(), expectedLabels.String())
+		} else {
+			if m.deferredByLease(svcName) {
+				m.markRestart(restartDeferred)
+				return nil
+			}
+			_, _ = fmt.Fprintf(m.writer, "        🔄 Restarting service for container update (running: %s, latest: %s)\n",
+				runningLabels.String(), expectedLabels.String())
+			restartService(svcName, dryRun)
+		}
+	}
+
+	return nil
+}
+
+// imageArchitecture returns the container image architecture of the host, the default one when
+// it can't be detected
+func (m *Manager) imageArchitecture() string {
+	if m.architecture != nil {
+		return *m.architecture
+	}
+	architecture := ""
+	if result, err := m.runCommand("uname -m"); err == nil {
+		architecture = imageArchitectures[strings.TrimSpace(result.Stdout)]
+	}
+	m.architecture = &architecture
+	return architecture
+}
+
+// imageArchitectures maps the machine names of uname to the container image architectures
+var imageArchitectures = map[string]string{
+	"x86_64":  "amd64",
+	"aarch64": "arm64",
+	"arm64":   "arm64",
+	"armv7l":  "arm",
+	"ppc64le": "ppc64le",
+	"s390x":   "s390x",
+	"riscv64": "riscv64",
+}
+
+// getRunningContainerLabels gets container labels from running container
+func (m *Manager) getRunningContainerLabels(serviceName string) (*container.ImageLabels, error) {
+	// Try jumpstarter labels first, then fall back to OCI standard labels
+	result, err := m.runCommand(fmt.Sprintf("podman inspect --format '{{index .Config.Labels \"jumpstarter.version\"}} {{index .Config.Labels \"jumpstarter.revision\"}} {{index .Config.Labels \"org.opencontainers.image.version\"}} {{index .Config.Labels \"org.opencontainers.image.revision\"}}' %s", serviceName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect container %s: %w", serviceName, err)
+	}
+
+	parts := strings.Fields(strings.TrimSpace(result.Stdout))
+	// Pad with empty strings if we got fewer parts
+	for len(parts) < 4 {
+		parts = append(parts, "")
+	}
+
+	jumpstarterVersion := parts[0]  // jumpstarter.version
+	jumpstarterRevision := parts[1] // jumpstarter.revision
+	ociVersion := parts[2]          // org.opencontainers.image.version
+	ociRevision := parts[3]         // org.opencontainers.image.revision
+
+	// Clean up "<no value>" to empty string
+	if jumpstarterVersion == noValuePlaceholder {
+		jumpstarterVersion = ""
+	}
+	if jumpstarterRevision == noValuePlaceholder {
+		jumpstarterRevision = ""
+	}
+	if ociVersion == noValuePlaceholder {
+		ociVersion = ""
+	}
+	if ociRevision == noValuePlaceholder {
+		ociRevision = ""
+	}
+
+	// Use jumpstarter labels if both exist, otherwise fall back to OCI labels
+	var version, revision string
+	if jumpstarterVersion != "" && jumpstarterRevision != "" {
+		version = jumpstarterVersion
+		revision = jumpstarterRevision
+	} else {
+		version = ociVersion
+		revision = ociRevision
+	}
+
+	// Return labels even if only partially available (version OR revision can be empty)
+	return &container.ImageLabels{
+		Version:  version,
+		Revision: revision,
+	}, nil
+}
+
+// RunHostCommand implements the HostManager interface by exposing runCommand
+func (m *Manager) RunHostCommand(command string) (*CommandResult, error) {
+	return m.runCommand(command)
+}
+
+// SanitizeDiff removes sensitive information from diff output
+func SanitizeDiff(diff string) string {
+	// Regex patterns to match sensitive fields
+	patterns := []struct {
+		pattern     string
+		replacement string
+	}{
+		// Match patterns like "token: value", "password: value", etc.
+		{`(?i)(token|password|key|secret|api_key|auth_token|bearer_token|access_token|refresh_token|client_secret|private_key|passphrase|credential)(\s*[:=]\s*)([^\s\n]+)`, `${1}${2}<TOKEN>`},
+		// Match patterns like "TOKEN=value", "PASSWORD=value", etc.
+		{`(?i)(TOKEN|PASSWORD|KEY|SECRET|API_KEY|AUTH_TOKEN|BEARER_TOKEN|ACCESS_TOKEN|REFRESH_TOKEN|CLIENT_SECRET|PRIVATE_KEY|PASSPHRASE|CREDENTIAL)(\s*=\s*)([^\s\n]+)`, `${1}${2}<TOKEN>`},
+		// Match patterns in double quotes like "token": "value"
+		{`(?i)(")(token|password|key|secret|api_key|auth_token|bearer_token|access_token|refresh_token|client_secret|private_key|passphrase|credential)("\s*[:=]\s*")([^"]+)(")`, `${1}${2}${3}<TOKEN>${5}`},
+		// Match patterns in single quotes like 'token': 'value'
+		{`(?i)(')(token|password|key|secret|api_key|auth_token|bearer_token|access_token|refresh_token|client_secret|private_key|passphrase|credential)('\s*[:=]\s*')([^']+)(')`, `${1}${2}${3}<TOKEN>${5}`},
+	}
+
+	result := diff
+	for _, p := range patterns {
+		re := regexp.MustCompile(p.pattern)
+		result = re.ReplaceAllString(result, p.replacement)
+	}
+
+	return result
+}
+
+func (m *Manager) reconcileFile(path string, content string, dryRun bool) (bool, error) {
+	// don't start touching files once cancelled, a write in progress is always completed
+	if err := m.context().Err(); err != nil {
+		return false, fmt.Errorf("not reconciling %s: %w", path, err)
+	}
+
+	// Check if file exists and read its content
+	existing, err := m.transport.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		// File doesn't exist
+		if content == "" {
+			// File doesn't exist and content is empty - nothing to do
+			return false, nil
+		}
+
+		// File doesn't exist and content is not empty - create it
+		if err := m.recordFile(path, plan.ActionCreate, "", content); err != nil {
+			return false, err
+		}
+		if dryRun {
+			_, _ = fmt.Fprintf(m.writer, "            📄 Would create file: %s\n", path)
+			return true, nil
+		}
+
+		m.keepPrevious(path, false, "")
+		if err := m.writeFile(path, content); err != nil {
+			return false, err
+		}
+
+		_, _ = fmt.Fprintf(m.writer, "            📄 Created file: %s\n", path)
+		return true, nil
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(m.writer, "Failed to read existing file %s: %v\n", path, err)
+		return false, fmt.Errorf("failed to read existing file %s: %w", path, err)
+	}
+	existingContent := []byte(existing)
+
+	// If content is empty, delete the file. An empty file is left alone, it may be the
+	// /dev/null link of a service masked by StopExporter.
+	if content == "" && len(existingContent) == 0 {
+		return false, nil
+	}
+	if content == "" {
+		if err := m.recordFile(path, plan.ActionDelete, string(existingContent), ""); err != nil {
+			return false, err
+		}
+		if dryRun {
+			_, _ = fmt.Fprintf(m.writer, "            🗑️ Would delete file: %s\n", path)
+			return true, nil
+		}
+		m.keepPrevious(path, true, string(existingContent))
+		err = m.transport.RemoveFile(path)
+		if err != nil {
+			return false, fmt.Errorf("failed to delete file %s: %w", path, err)
+		}
+		_, _ = fmt.Fprintf(m.writer, "            🗑️  Deleted file: %s\n", path)
+		return true, nil
+	}
+
+	// Check if content matches
+	if string(existingContent) == content {
+		// Content matches, the mode and ownership may still need a fix, without restarting the exporter
+		return false, m.reconcileAttrs(path, dryRun)
+	}
+
+	// Content doesn't match, show diff and update the file
+	diff := cmp.Diff(string(existingContent), content)
+	if diff != "" {
+		sanitizedDiff := SanitizeDiff(diff)
+		_, _ = fmt.Fprintf(m.writer, "            📄 Changes for file: %s\n", path)
+		_, _ = fmt.Fprintf(m.writer, "            Diff (-existing +new):\n%s\n", sanitizedDiff)
+	}
+
+	if err := m.recordFile(path, plan.ActionUpdate, string(existingContent), content); err != nil {
+		return false, err
+	}
+	if dryRun {
+		_, _ = fmt.Fprintf(m.writer, "            ✏️ Would update file: %s\n", path)
+		return true, nil
+	}
+
+	m.keepPrevious(path, true, string(existingContent))
+	if err := m.writeFile(path, content); err != nil {
+		_, _ = fmt.Fprintf(m.writer, "Failed to write updated content to %s: %v\n", path, err)
+		return false, err
+	}
+
+	_, _ = fmt.Fprintf(m.writer, "            ✏️ Updated file: %s\n", path)
+	return true, nil
+}
+
+// GetBootcStatus checks the bootc status and returns the appropriate BootcStatus enum
+func (m *Manager) GetBootcStatus() BootcStatus {
+	if !m.transport.RunsCommands() {
+		return BOOTC_NOT_MANAGED
+	}
+	// Check if a bootc upgrade or switch is already running
+	if m.BootcUpdating() {
+		return BOOTC_UPDATING
+	}
+
+	// Check booted image
+	bootcStdout, err := m.RunHostCommand("[ -f /run/ostree-booted ] && bootc upgrade --check")
+	if err == nil && bootcStdout != nil && bootcStdout.ExitCode == 0 && bootcStdout.Stdout != "" {
+		if strings.HasPrefix(bootcStdout.Stdout, "No changes") {
+			return BOOTC_UP_TO_DATE
+		} else {
+			return BOOTC_WILL_UPDATE
+		}
+	}
+
+	return BOOTC_NOT_MANAGED
+}
+
+// HandleBootcUpgrade switches the host to the bootc image of ContainerImage when set, or handles
+// bootc upgrade checking and execution
+func (m *Manager) HandleBootcUpgrade(dryRun bool) error {
+	if m.ExporterHost.Spec.ContainerImage != "" {
+		if switched, err := m.handleBootcSwitch(dryRun); err != nil || switched {
+			return err
+		}
+	}
+	status := m.GetBootcStatus()
+
+	switch status {
+	case BOOTC_UPDATING:
+		_, _ = fmt.Fprintf(m.writer, "    ⚠️  Bootc upgrade in progress\n")
+		if m.bootcScheduler != nil {
+			m.bootcScheduler.Updating()
+		}
+	case BOOTC_UP_TO_DATE:
+		_, _ = fmt.Fprintf(m.writer, "    ✅ Bootc image is up to date\n")
+	case BOOTC_WILL_UPDATE:
+		if pending := m.startBootcUpdate(); pending != "" {
+			_, _ = fmt.Fprintf(m.writer, "    ⏸️  Bootc upgrade pending: %s\n", pending)
+			return nil
+		}
+		if err := m.record(plan.Change{Kind: "bootc", Name: "image", Action: plan.ActionUpgrade}); err != nil {
+			return err
+		}
+		if dryRun {
+			_, _ = fmt.Fprintf(m.writer, "    📄 Would upgrade bootc image\n")
+		} else {
+			// Trigger bootc upgrade timer now. Assuming it uses manual activation (e.g. OnActiveSec=0, RandomizedDelaySec=1h, RemainAfterElapse=false)
+			_, err := m.RunHostCommand("systemctl restart bootc-fetch-apply-updates.timer")
+			if err != nil {
+				return fmt.Errorf("error triggering bootc upgrade service: %w", err)
+			}
+			_, _ = fmt.Fprintf(m.writer, "    ✅ Bootc upgrade started\n")
+			return nil
+		}
+	case BOOTC_NOT_MANAGED:
+		_, _ = fmt.Fprintf(m.writer, "    ℹ️ Not a bootc managed host\n")
+	}
+	return nil
+}
+
+// Close closes the transport of the host
+func (m *Manager) Close() error {
+	return m.transport.Close()
+}
+
+// SetRecorder records the file, service and bootc changes made on the host in r
+func (m *Manager) SetRecorder(r *plan.Recorder) {
+	m.recorder = r
+}
+
+// SetSecrets masks secrets in the hashes of the recorded file changes, they are the credentials
+// of the next exporter applied: a plan made before the credential of a new exporter was issued
+// still matches its files once they are rendered with the credential
+func (m *Manager) SetSecrets(secrets ...string) {
+	m.secrets = secrets
+}
+
+// SetBootcScheduler defers the bootc upgrades and switches until s starts them
+func (m *Manager) SetBootcScheduler(s BootcScheduler) {
+	m.bootcScheduler = s
+}
+
+// record records a change made on this host, the change must not be made on error
+func (m *Manager) record(change plan.Change) error {
+	return RecordHostChange(m.recorder, m.ExporterHost.Name, change)
+}
+
+// RecordHostChange records a change made on the exporter host hostName in r, the change
+// must not be made on error
+func RecordHostChange(r *plan.Recorder, hostName string, change plan.Change) error {
+	change.Target = plan.TargetHost
+	change.Scope = hostName
+	return r.Record(change)
+}
+
+// fileChange returns the change of a file, with the hashes of the existing and new contents
+// where the secrets are masked
+func fileChange(path string, action plan.Action, before, after string, secrets []string) plan.Change {
+	change := plan.Change{Kind: "file", Name: path, Action: action}
+	if before != "" {
+		change.Before = plan.HashMasked(before, secrets)
+	}
+	if after != "" {
+		change.After = plan.HashMasked(after, secrets)
+	}
+	return change
+}
+
+// recordFile records a file change, with the hashes of the existing and new contents
+func (m *Manager) recordFile(path string, action plan.Action, before, after string) error {
+	return m.record(fileChange(path, action, before, after, m.secrets))
+}
+
+// recordService records an action on a systemd service
+func (m *Manager) recordService(name string, action plan.Action, before, after string) error {
+	return m.record(plan.Change{Kind: "service", Name: name, Action: action, Before: before, After: after})
+}
+
+// SetWriter sets the output writer for this host manager.
+// By default, output goes to os.Stdout.
+func (m *Manager) SetWriter(w io.Writer) {
+	m.writer = w
+}
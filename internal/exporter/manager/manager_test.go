@@ -0,0 +1,262 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/api/v1alpha1"
+)
+
+// memTransport is a Transport with an in-memory file system, running the commands with exec,
+// it runs no command when exec is nil
+type memTransport struct {
+	exec func(command string) (string, uint32)
+
+	mu       sync.Mutex
+	files    map[string]memFile
+	commands []string
+}
+
+type memFile struct {
+	content string
+	attrs   FileAttrs
+}
+
+func newMemTransport(exec func(command string) (string, uint32)) *memTransport {
+	return &memTransport{exec: exec, files: make(map[string]memFile)}
+}
+
+func (t *memTransport) ReadFile(path string) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	file, ok := t.files[path]
+	if !ok {
+		return "", fmt.Errorf("failed to open %s: %w", path, fs.ErrNotExist)
+	}
+	return file.content, nil
+}
+
+func (t *memTransport) WriteFile(path, content string, attrs FileAttrs) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	// the files are created by root, the ownership to keep is the one of the file replaced
+	current := FileAttrs{}
+	if file, ok := t.files[path]; ok {
+		current = file.attrs
+	}
+	t.files[path] = memFile{content: content, attrs: attrs.Resolve(current)}
+	return nil
+}
+
+func (t *memTransport) RemoveFile(path string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.files[path]; !ok {
+		return fmt.Errorf("failed to delete file %s: %w", path, fs.ErrNotExist)
+	}
+	delete(t.files, path)
+	return nil
+}
+
+func (t *memTransport) FileAttrs(path string) (FileAttrs, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	file, ok := t.files[path]
+	if !ok {
+		return FileAttrs{}, fmt.Errorf("failed to stat %s: %w", path, fs.ErrNotExist)
+	}
+	return file.attrs, nil
+}
+
+func (t *memTransport) SetFileAttrs(path string, attrs FileAttrs) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	file, ok := t.files[path]
+	if !ok {
+		return fmt.Errorf("failed to set mode of %s: %w", path, fs.ErrNotExist)
+	}
+	file.attrs = attrs.Resolve(file.attrs)
+	t.files[path] = file
+	return nil
+}
+
+func (t *memTransport) RunsCommands() bool {
+	return t.exec != nil
+}
+
+func (t *memTransport) Run(command string) (*CommandResult, error) {
+	if t.exec == nil {
+		return nil, fmt.Errorf("no command is run on this host")
+	}
+	t.mu.Lock()
+	t.commands = append(t.commands, command)
+	t.mu.Unlock()
+	stdout, exitCode := t.exec(command)
+	result := &CommandResult{Stdout: stdout, ExitCode: int(exitCode)}
+	if exitCode != 0 {
+		return result, fmt.Errorf("failed to run command: exit code %d", exitCode)
+	}
+	return result, nil
+}
+
+func (t *memTransport) Close() error {
+	return nil
+}
+
+// ran reports whether a command starting with command was run
+func (t *memTransport) ran(command string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, c := range t.commands {
+		if strings.HasPrefix(c, command) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeRemoteFile writes a file on the host as it was left by hand, owned by root
+func writeRemoteFile(t *testing.T, transport *memTransport, path, content string) {
+	t.Helper()
+	require.NoError(t, transport.WriteFile(path, content, FileAttrs{Mode: 0o644}))
+}
+
+func createTestExporterHost(name string) *v1alpha1.ExporterHost {
+	return &v1alpha1.ExporterHost{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		Spec: v1alpha1.ExporterHostSpec{
+			Management: v1alpha1.Management{
+				SSH: v1alpha1.SSHCredentials{
+					Host: "test-host.example.com",
+					User: "testuser",
+					Port: 22,
+				},
+			},
+		},
+	}
+}
+
+func TestSanitizeDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "sanitize token with colon",
+			input:    "  token: abc123def456\n+ token: xyz789ghi012",
+			expected: "  token: <TOKEN>\n+ token: <TOKEN>",
+		},
+		{
+			name:     "sanitize password with equals",
+			input:    "- PASSWORD=oldpassword\n+ PASSWORD=newpassword",
+			expected: "- PASSWORD=<TOKEN>\n+ PASSWORD=<TOKEN>",
+		},
+		{
+			name:     "sanitize api_key with spaces",
+			input:    "  api_key : sk-1234567890abcdef\n+ api_key : sk-fedcba0987654321",
+			expected: "  api_key : <TOKEN>\n+ api_key : <TOKEN>",
+		},
+		{
+			name:     "sanitize quoted fields",
+			input:    `  "secret": "my-secret-value"\n+ "secret": "new-secret-value"`,
+			expected: `  "secret": "<TOKEN>"\n+ "secret": "<TOKEN>"`,
+		},
+		{
+			name:     "sanitize single quoted fields",
+			input:    `  'private_key': 'rsa-key-content'\n+ 'private_key': 'new-rsa-key-content'`,
+			expected: `  'private_key': '<TOKEN>'\n+ 'private_key': '<TOKEN>'`,
+		},
+		{
+			name:     "preserve non-sensitive fields",
+			input:    "  host: example.com\n+ host: new-example.com\n  port: 22\n+ port: 2222",
+			expected: "  host: example.com\n+ host: new-example.com\n  port: 22\n+ port: 2222",
+		},
+		{
+			name:     "mixed sensitive and non-sensitive",
+			input:    "  host: example.com\n  token: abc123\n+ host: new-example.com\n+ token: xyz789",
+			expected: "  host: example.com\n  token: <TOKEN>\n+ host: new-example.com\n+ token: <TOKEN>",
+		},
+		{
+			name:     "case insensitive matching",
+			input:    "  TOKEN: abc123\n  Token: def456\n+ token: xyz789",
+			expected: "  TOKEN: <TOKEN>\n  Token: <TOKEN>\n+ token: <TOKEN>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := SanitizeDiff(tt.input)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestManagerCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	transport := newMemTransport(nil)
+	manager := New(ctx, createTestExporterHost("cancelled"), transport)
+
+	changed, err := manager.reconcileFile("/etc/jumpstarter/exporters/test.yaml", "content", false)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.False(t, changed)
+	assert.Empty(t, transport.files, "no file is touched")
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	diff, err := UnifiedDiff("/etc/test.yaml", "a: 1\nb: 2\n", "a: 1\nb: 3\n")
+	require.NoError(t, err)
+	assert.Equal(t, "--- a/etc/test.yaml\n+++ b/etc/test.yaml\n@@ -1,2 +1,2 @@\n a: 1\n-b: 2\n+b: 3\n", diff)
+
+	diff, err = UnifiedDiff("/etc/test.yaml", "", "a: 1\n")
+	require.NoError(t, err)
+	assert.Equal(t, "--- /dev/null\n+++ b/etc/test.yaml\n@@ -0,0 +1 @@\n+a: 1\n", diff)
+
+	diff, err = UnifiedDiff("/etc/test.yaml", "a: 1\n", "")
+	require.NoError(t, err)
+	assert.Equal(t, "--- a/etc/test.yaml\n+++ /dev/null\n@@ -1 +0,0 @@\n-a: 1\n", diff)
+
+	diff, err = UnifiedDiff("/etc/test.yaml", "a: 1\n", "a: 1\n")
+	require.NoError(t, err)
+	assert.Empty(t, diff)
+}
+
+func TestManager_FileDiff(t *testing.T) {
+	transport := newMemTransport(nil)
+	manager := New(context.Background(), createTestExporterHost("diff"), transport)
+
+	exporterConfig := &v1alpha1.ExporterConfigTemplate{}
+	exporterConfig.Spec.ExporterMetadata.Name = "exporter"
+	exporterConfig.Spec.ConfigTemplate = "endpoint: grpc.example.com\ntoken: new-token\n"
+	exporterConfig.Spec.SystemdServiceTemplate = "[Service]\nExecStart=/usr/bin/jmp run\n"
+
+	writeRemoteFile(t, transport, "/etc/jumpstarter/exporters/exporter.yaml",
+		"endpoint: grpc.old.example.com\ntoken: old-token\n")
+
+	diff, err := manager.fileDiff(exporterConfig)
+	require.NoError(t, err)
+	assert.Equal(t, "--- /dev/null\n"+
+		"+++ b/etc/systemd/system/exporter.service\n"+
+		"@@ -0,0 +1,2 @@\n"+
+		"+[Service]\n"+
+		"+ExecStart=/usr/bin/jmp run\n"+
+		"--- a/etc/jumpstarter/exporters/exporter.yaml\n"+
+		"+++ b/etc/jumpstarter/exporters/exporter.yaml\n"+
+		"@@ -1,2 +1,2 @@\n"+
+		"-endpoint: grpc.old.example.com\n"+
+		"-token: <TOKEN>\n"+
+		"+endpoint: grpc.example.com\n"+
+		"+token: <TOKEN>\n", diff)
+	assert.NotContains(t, diff, "new-token")
+}
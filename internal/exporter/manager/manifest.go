@@ -0,0 +1,161 @@
+package manager
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/plan"
+)
+
+// managedExportersFile lists the exporters deployed on the host by this tool, so the ones removed
+// from the configuration can be found and decommissioned
+const managedExportersFile = "/etc/jumpstarter/managed-exporters.yaml"
+
+const managedExportersHeader = "# exporters deployed by jumpstarter-lab-config, do not edit\n"
+
+// managedExporters is the content of the managedExportersFile
+type managedExporters struct {
+	// Exporters maps the exporter service names to the name of their ExporterInstance
+	Exporters map[string]string `yaml:"exporters"`
+}
+
+// ManagedExporters returns the exporters deployed on the host, as a map of the exporter service
+// names to the name of their ExporterInstance
+func (m *Manager) ManagedExporters() (map[string]string, error) {
+	content, err := m.readFile(managedExportersFile)
+	if err != nil {
+		return nil, err
+	}
+	return parseManagedExporters(content)
+}
+
+// parseManagedExporters parses the content of the managedExportersFile
+func parseManagedExporters(content string) (map[string]string, error) {
+	var manifest managedExporters
+	if err := yaml.Unmarshal([]byte(content), &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", managedExportersFile, err)
+	}
+	if manifest.Exporters == nil {
+		manifest.Exporters = make(map[string]string)
+	}
+	return manifest.Exporters, nil
+}
+
+// marshalManagedExporters returns the content of the managedExportersFile
+func marshalManagedExporters(exporters map[string]string) (string, error) {
+	content, err := yaml.Marshal(managedExporters{Exporters: exporters})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal the managed exporters: %w", err)
+	}
+	return managedExportersHeader + string(content), nil
+}
+
+// MarkManaged records the exporter service svcName of the ExporterInstance instanceName in the
+// managed exporters of the host, on dry run the change is recorded without writing it
+func (m *Manager) MarkManaged(svcName, instanceName string, dryRun bool) error {
+	exporters, err := m.ManagedExporters()
+	if err != nil {
+		return err
+	}
+	previous, ok := exporters[svcName]
+	if ok && previous == instanceName {
+		return nil
+	}
+	if err := m.record(ManagedExporterChange(svcName, previous, instanceName)); err != nil {
+		return err
+	}
+	if dryRun {
+		return nil
+	}
+	exporters[svcName] = instanceName
+	return m.writeManagedExporters(exporters)
+}
+
+// ManagedExporterChange returns the change of the managed exporters of a host adding the exporter
+// service svcName of the ExporterInstance instanceName, previous is the instance it was listed with
+func ManagedExporterChange(svcName, previous, instanceName string) plan.Change {
+	change := plan.Change{Kind: "managed-exporter", Name: svcName, Action: plan.ActionCreate,
+		After: plan.HashContent(instanceName)}
+	if previous != "" {
+		change.Action = plan.ActionUpdate
+		change.Before = plan.HashContent(previous)
+	}
+	return change
+}
+
+// writeManagedExporters replaces the managed exporters of the host
+func (m *Manager) writeManagedExporters(exporters map[string]string) error {
+	content, err := marshalManagedExporters(exporters)
+	if err != nil {
+		return err
+	}
+	return m.writeFile(managedExportersFile, content)
+}
+
+// Decommission stops and disables the exporter service svcName, removes its container unit, service
+// unit and exporter config, and drops it from the managed exporters of the host
+func (m *Manager) Decommission(svcName string, dryRun bool) error {
+	if err := m.context().Err(); err != nil {
+		return fmt.Errorf("not decommissioning %s: %w", svcName, err)
+	}
+	containerSystemdFile, serviceSystemdFile, exporterConfigFile := ExporterFiles(svcName)
+
+	if err := m.recordService(svcName, plan.ActionDelete, "", ""); err != nil {
+		return err
+	}
+	runsCommands := m.transport.RunsCommands()
+	switch {
+	case !runsCommands:
+		// the services aren't run on this host, only their files are removed
+	case dryRun:
+		_, _ = fmt.Fprintf(m.writer, "        📄 Would stop and disable %s\n", svcName)
+	default:
+		if err := m.stopService(svcName); err != nil {
+			return err
+		}
+		// a dead exporter may be masked
+		if err := m.unmaskService(svcName); err != nil {
+			return err
+		}
+		serviceUnit, err := m.readFile(serviceSystemdFile)
+		if err != nil {
+			return err
+		}
+		// the services generated from quadlet container units can't be disabled, they go away with their unit
+		if serviceUnit != "" {
+			if _, err := m.runCommand(fmt.Sprintf("systemctl disable %q", svcName)); err != nil {
+				return fmt.Errorf("failed to disable %s: %w", svcName, err)
+			}
+		}
+	}
+
+	for _, path := range []string{containerSystemdFile, serviceSystemdFile, exporterConfigFile} {
+		if _, err := m.reconcileFile(path, "", dryRun); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+	}
+	if dryRun {
+		return nil
+	}
+
+	if runsCommands {
+		if _, err := m.runCommand("systemctl daemon-reload"); err != nil {
+			return fmt.Errorf("failed to reload systemd: %w", err)
+		}
+	}
+	exporters, err := m.ManagedExporters()
+	if err != nil {
+		return err
+	}
+	delete(exporters, svcName)
+	if err := m.writeManagedExporters(exporters); err != nil {
+		return err
+	}
+	if !runsCommands {
+		_, _ = fmt.Fprintf(m.writer, "        ✅ %s removed\n", svcName)
+		return nil
+	}
+	_, _ = fmt.Fprintf(m.writer, "        ✅ %s stopped, disabled and removed\n", svcName)
+	return nil
+}
@@ -0,0 +1,88 @@
+package manager
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/plan"
+)
+
+func TestManagedExporters(t *testing.T) {
+	transport := newMemTransport(nil)
+	manager := New(context.Background(), createTestExporterHost("manifest"), transport)
+
+	exporters, err := manager.ManagedExporters()
+	require.NoError(t, err)
+	assert.Empty(t, exporters, "a host without manifest has no managed exporters")
+
+	require.NoError(t, manager.MarkManaged("exporter-a", "instance-a", false))
+	require.NoError(t, manager.MarkManaged("exporter-b", "instance-b", false))
+	require.NoError(t, manager.MarkManaged("exporter-a", "instance-a", false))
+
+	exporters, err = manager.ManagedExporters()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"exporter-a": "instance-a", "exporter-b": "instance-b"}, exporters)
+
+	content, err := transport.ReadFile(managedExportersFile)
+	require.NoError(t, err)
+	assert.Contains(t, content, managedExportersHeader)
+}
+
+func TestMarkManaged_Recorded(t *testing.T) {
+	transport := newMemTransport(nil)
+	recorder := plan.NewRecorder()
+	manager := New(context.Background(), createTestExporterHost("manifest"), transport)
+	manager.SetRecorder(recorder)
+
+	require.NoError(t, manager.MarkManaged("exporter", "instance", true))
+	_, err := transport.ReadFile(managedExportersFile)
+	assert.Error(t, err, "nothing is written on dry run")
+
+	planned := recorder.Plan(plan.Options{})
+	require.Len(t, planned.Changes, 1)
+	assert.Equal(t, "managed-exporter", planned.Changes[0].Kind)
+	assert.Equal(t, plan.ActionCreate, planned.Changes[0].Action)
+
+	manager.SetRecorder(planned.Enforce())
+	require.NoError(t, manager.MarkManaged("exporter", "instance", false))
+	require.ErrorIs(t, manager.MarkManaged("other", "instance", false), plan.ErrUnplanned)
+	exporters, err := manager.ManagedExporters()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"exporter": "instance"}, exporters)
+}
+
+func TestDecommission_DryRun(t *testing.T) {
+	transport := newMemTransport(func(string) (string, uint32) { return "", 0 })
+	var out bytes.Buffer
+	recorder := plan.NewRecorder()
+	manager := New(context.Background(), createTestExporterHost("decommission"), transport)
+	manager.SetWriter(&out)
+
+	writeRemoteFile(t, transport, "/etc/containers/systemd/old.container", "[Container]\n")
+	writeRemoteFile(t, transport, "/etc/jumpstarter/exporters/old.yaml", "token: secret\n")
+	require.NoError(t, manager.MarkManaged("old", "old-instance", false))
+	manager.SetRecorder(recorder)
+
+	require.NoError(t, manager.Decommission("old", true))
+	assert.Contains(t, out.String(), "Would stop and disable old")
+	assert.Contains(t, out.String(), "Would delete file: /etc/containers/systemd/old.container")
+	assert.Contains(t, out.String(), "Would delete file: /etc/jumpstarter/exporters/old.yaml")
+	assert.NotContains(t, out.String(), "old.service")
+
+	// nothing was changed
+	_, err := transport.FileAttrs("/etc/containers/systemd/old.container")
+	require.NoError(t, err)
+	exporters, err := manager.ManagedExporters()
+	require.NoError(t, err)
+	assert.Contains(t, exporters, "old")
+
+	changes := recorder.Plan(plan.Options{}).Changes
+	require.Len(t, changes, 3)
+	for _, change := range changes {
+		assert.Equal(t, plan.ActionDelete, change.Action)
+	}
+}
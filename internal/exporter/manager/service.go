@@ -0,0 +1,113 @@
+package manager
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/plan"
+)
+
+const systemdStateMasked = "masked"
+
+// serviceState returns the active and the enablement state of a systemd service
+func (m *Manager) serviceState(svcName string) (active, enabled string) {
+	// both commands exit with a non-zero status for inactive or disabled services
+	if result, _ := m.runCommand(fmt.Sprintf("systemctl is-active %q", svcName)); result != nil {
+		active = strings.TrimSpace(result.Stdout)
+	}
+	if result, _ := m.runCommand(fmt.Sprintf("systemctl is-enabled %q", svcName)); result != nil {
+		enabled = strings.TrimSpace(result.Stdout)
+	}
+	return active, enabled
+}
+
+// stopService stops a systemd service, a service which isn't loaded is already stopped
+func (m *Manager) stopService(svcName string) error {
+	_, err := m.runCommand(fmt.Sprintf("systemctl stop %q", svcName))
+	if err == nil {
+		return nil
+	}
+	switch active, _ := m.serviceState(svcName); active {
+	case "inactive", "failed", "unknown":
+		return nil
+	}
+	return fmt.Errorf("failed to stop %s: %w", svcName, err)
+}
+
+// StopExporter stops the exporter service svcName and keeps it from starting again, leaving its
+// files in place. Service units are disabled, while the services generated from quadlet container
+// units, which can't be disabled, are masked. Apply starts it again. It returns false when the
+// exporter is not deployed or already stopped.
+func (m *Manager) StopExporter(svcName string, dryRun bool) (bool, error) {
+	if err := m.context().Err(); err != nil {
+		return false, fmt.Errorf("not stopping %s: %w", svcName, err)
+	}
+	if !m.transport.RunsCommands() {
+		return false, nil
+	}
+	containerSystemdFile, serviceSystemdFile, _ := ExporterFiles(svcName)
+	containerUnit, err := m.readFile(containerSystemdFile)
+	if err != nil {
+		return false, err
+	}
+	serviceUnit, err := m.readFile(serviceSystemdFile)
+	if err != nil {
+		return false, err
+	}
+	if containerUnit == "" && serviceUnit == "" {
+		return false, nil
+	}
+
+	active, enabled := m.serviceState(svcName)
+	stopped := active != systemdStateActive && active != "activating"
+	if serviceUnit != "" && stopped && enabled != "enabled" {
+		return false, nil
+	}
+	if serviceUnit == "" && stopped && enabled == systemdStateMasked {
+		return false, nil
+	}
+
+	if err := m.recordService(svcName, plan.ActionStop, "", ""); err != nil {
+		return false, err
+	}
+	if dryRun {
+		_, _ = fmt.Fprintf(m.writer, "        📄 Would stop and disable %s\n", svcName)
+		return true, nil
+	}
+
+	if err := m.stopService(svcName); err != nil {
+		return false, err
+	}
+	if serviceUnit != "" {
+		if _, err := m.runCommand(fmt.Sprintf("systemctl disable %q", svcName)); err != nil {
+			return false, fmt.Errorf("failed to disable %s: %w", svcName, err)
+		}
+	} else if _, err := m.runCommand(fmt.Sprintf("systemctl mask %q", svcName)); err != nil {
+		return false, fmt.Errorf("failed to mask %s: %w", svcName, err)
+	}
+	_, _ = fmt.Fprintf(m.writer, "        💤 %s stopped and disabled\n", svcName)
+	return true, nil
+}
+
+// reviveService undoes StopExporter before a service is started, enabling the service units
+// and unmasking the quadlet services
+func (m *Manager) reviveService(svcName string, serviceUnit bool) error {
+	if serviceUnit {
+		if _, err := m.runCommand(fmt.Sprintf("systemctl enable %q", svcName)); err != nil {
+			return fmt.Errorf("failed to enable exporter: %w", err)
+		}
+		return nil
+	}
+	return m.unmaskService(svcName)
+}
+
+// unmaskService unmasks a service masked by StopExporter
+func (m *Manager) unmaskService(svcName string) error {
+	if _, enabled := m.serviceState(svcName); enabled != systemdStateMasked {
+		return nil
+	}
+	if _, err := m.runCommand(fmt.Sprintf("systemctl unmask %q", svcName)); err != nil {
+		return fmt.Errorf("failed to unmask %s: %w", svcName, err)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package manager
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// CommandResult represents the result of running a command on an exporter host
+type CommandResult struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}
+
+// KeepOwner is the UID or GID of FileAttrs leaving the owner or group of a file as it is, it is
+// also reported for the ownership a Transport doesn't know
+const KeepOwner = -1
+
+// FileAttrs are the mode and ownership of a file
+type FileAttrs struct {
+	Mode     os.FileMode
+	UID, GID int
+}
+
+func (a FileAttrs) String() string {
+	if a.UID == KeepOwner && a.GID == KeepOwner {
+		return fmt.Sprintf("%04o", a.Mode.Perm())
+	}
+	return fmt.Sprintf("%04o %s:%s", a.Mode.Perm(), ownerString(a.UID), ownerString(a.GID))
+}
+
+// ownerString returns a UID or GID, * for KeepOwner
+func ownerString(id int) string {
+	if id == KeepOwner {
+		return "*"
+	}
+	return strconv.Itoa(id)
+}
+
+// Matches reports whether the attributes of a file match the declared attributes a, the owner and
+// group left as KeepOwner on either side always match
+func (a FileAttrs) Matches(actual FileAttrs) bool {
+	return a.Mode.Perm() == actual.Mode.Perm() && idMatches(a.UID, actual.UID) && idMatches(a.GID, actual.GID)
+}
+
+func idMatches(declared, actual int) bool {
+	return declared == KeepOwner || actual == KeepOwner || declared == actual
+}
+
+// Resolve returns the attributes a with the owner and group left as KeepOwner taken from current
+func (a FileAttrs) Resolve(current FileAttrs) FileAttrs {
+	if a.UID == KeepOwner {
+		a.UID = current.UID
+	}
+	if a.GID == KeepOwner {
+		a.GID = current.GID
+	}
+	return a
+}
+
+// Transport gives the Manager access to the file system and the commands of an exporter host
+type Transport interface {
+	// ReadFile reads a file, the error wraps fs.ErrNotExist when it is missing
+	ReadFile(path string) (string, error)
+	// WriteFile replaces a file with content through a temporary file renamed over it, so an
+	// interrupted write never leaves a truncated file, creating its parent directories. The owner
+	// and group left as KeepOwner in attrs are kept from the file replaced.
+	WriteFile(path, content string, attrs FileAttrs) error
+	// RemoveFile removes a file
+	RemoveFile(path string) error
+	// FileAttrs returns the mode and ownership of a file
+	FileAttrs(path string) (FileAttrs, error)
+	// SetFileAttrs sets the mode and ownership of a file, the owner and group left as KeepOwner in
+	// attrs are left unchanged
+	SetFileAttrs(path string, attrs FileAttrs) error
+	// RunsCommands reports whether Run can run commands, the exporter services are only
+	// deployed, neither started nor stopped, when it can't
+	RunsCommands() bool
+	// Run runs a command, an error is returned with the result when it exits with a non-zero status
+	Run(command string) (*CommandResult, error)
+	Close() error
+}
@@ -0,0 +1,191 @@
+package ssh
+
+import (
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/exporter/manager"
+)
+
+// memAttrs keeps the mode and ownership set on the files of the in-memory sftp server, which
+// ignores them, the files are owned by the user uid with the mode of the in-memory file system
+// by default. Like sshd, only root can change the ownership of a file.
+type memAttrs struct {
+	cmd    sftp.PosixRenameFileCmder
+	lister sftp.FileLister
+	uid    int
+
+	mu    sync.Mutex
+	attrs map[string]manager.FileAttrs
+}
+
+func memAttrsHandlers(uid int) sftp.Handlers {
+	handlers := sftp.InMemHandler()
+	fs := &memAttrs{cmd: handlers.FileCmd.(sftp.PosixRenameFileCmder), lister: handlers.FileList,
+		uid: uid, attrs: make(map[string]manager.FileAttrs)}
+	handlers.FileCmd = fs
+	handlers.FileList = fs
+	return handlers
+}
+
+func (fs *memAttrs) Filecmd(r *sftp.Request) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	attrs := fs.attrsOf(r.Filepath)
+	flags, stat := r.AttrFlags(), r.Attributes()
+	if r.Method == "Setstat" && flags.UidGid && fs.uid != 0 &&
+		(int(stat.UID) != attrs.UID || int(stat.GID) != attrs.GID) {
+		return sftp.ErrSSHFxPermissionDenied
+	}
+	if err := fs.cmd.Filecmd(r); err != nil {
+		return err
+	}
+	switch r.Method {
+	case "Setstat":
+		if flags.Permissions {
+			attrs.Mode = stat.FileMode().Perm()
+		}
+		if flags.UidGid {
+			attrs.UID, attrs.GID = int(stat.UID), int(stat.GID)
+		}
+		fs.attrs[r.Filepath] = attrs
+	case "Remove":
+		delete(fs.attrs, r.Filepath)
+	}
+	return nil
+}
+
+func (fs *memAttrs) PosixRename(r *sftp.Request) error {
+	if err := fs.cmd.PosixRename(r); err != nil {
+		return err
+	}
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.attrs[r.Target] = fs.attrsOf(r.Filepath)
+	delete(fs.attrs, r.Filepath)
+	return nil
+}
+
+// attrsOf returns the attributes of a file, fs.mu must be held
+func (fs *memAttrs) attrsOf(path string) manager.FileAttrs {
+	if attrs, ok := fs.attrs[path]; ok {
+		return attrs
+	}
+	return manager.FileAttrs{Mode: 0o644, UID: fs.uid, GID: fs.uid}
+}
+
+func (fs *memAttrs) Filelist(r *sftp.Request) (sftp.ListerAt, error) {
+	lister, err := fs.lister.Filelist(r)
+	if err != nil || r.Method != "Stat" {
+		return lister, err
+	}
+	infos := make([]os.FileInfo, 1)
+	if _, err := lister.ListAt(infos, 0); err != nil {
+		return nil, err
+	}
+	if infos[0].IsDir() {
+		return lister, nil
+	}
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return memAttrsLister{memAttrsInfo{FileInfo: infos[0], attrs: fs.attrsOf(r.Filepath)}}, nil
+}
+
+// memAttrsInfo is a os.FileInfo with the attributes kept by memAttrs
+type memAttrsInfo struct {
+	os.FileInfo
+	attrs manager.FileAttrs
+}
+
+func (i memAttrsInfo) Mode() os.FileMode { return i.attrs.Mode }
+func (i memAttrsInfo) Sys() any          { return nil }
+func (i memAttrsInfo) Uid() uint32       { return uint32(i.attrs.UID) }
+func (i memAttrsInfo) Gid() uint32       { return uint32(i.attrs.GID) }
+
+type memAttrsLister []os.FileInfo
+
+func (l memAttrsLister) ListAt(infos []os.FileInfo, offset int64) (int, error) {
+	if offset >= int64(len(l)) {
+		return 0, nil
+	}
+	return copy(infos, l[offset:]), nil
+}
+
+func TestTransport_WriteFile(t *testing.T) {
+	client := newInMemorySFTPClient(t)
+	transport := &Transport{exporterHost: createTestExporterHost("files"), sftpClient: client}
+	const path = "/etc/jumpstarter/exporters/exporter.yaml"
+
+	require.NoError(t, transport.WriteFile(path, "token: first\n", keepOwner(0o600)))
+	attrs, err := transport.FileAttrs(path)
+	require.NoError(t, err)
+	assert.Equal(t, manager.FileAttrs{Mode: 0o600}, attrs)
+
+	require.NoError(t, transport.WriteFile(path, "token: second\n", keepOwner(0o600)))
+	content, err := transport.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "token: second\n", content)
+
+	entries, err := client.ReadDir("/etc/jumpstarter/exporters")
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "no temporary file is left behind")
+	assert.Equal(t, "exporter.yaml", entries[0].Name())
+
+	_, err = transport.ReadFile("/etc/jumpstarter/exporters/missing.yaml")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	require.NoError(t, transport.RemoveFile(path))
+	_, err = transport.ReadFile(path)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestTransport_SetFileAttrs(t *testing.T) {
+	client := newInMemorySFTPClient(t)
+	transport := &Transport{exporterHost: createTestExporterHost("files"), sftpClient: client}
+	const path = "/etc/jumpstarter/exporters/exporter.yaml"
+
+	require.NoError(t, transport.WriteFile(path, "token: secret\n", manager.FileAttrs{Mode: 0o644, UID: 1000, GID: 1000}))
+	attrs, err := transport.FileAttrs(path)
+	require.NoError(t, err)
+	assert.Equal(t, manager.FileAttrs{Mode: 0o644, UID: 1000, GID: 1000}, attrs)
+
+	require.NoError(t, transport.SetFileAttrs(path, keepOwner(0o600)))
+	attrs, err = transport.FileAttrs(path)
+	require.NoError(t, err)
+	assert.Equal(t, manager.FileAttrs{Mode: 0o600, UID: 1000, GID: 1000}, attrs, "the owner isn't declared")
+
+	require.NoError(t, transport.SetFileAttrs(path, manager.FileAttrs{Mode: 0o600}))
+	attrs, err = transport.FileAttrs(path)
+	require.NoError(t, err)
+	assert.Equal(t, manager.FileAttrs{Mode: 0o600}, attrs)
+}
+
+func TestTransport_WriteFileAsUser(t *testing.T) {
+	client := newInMemorySFTPClientAs(t, 1000)
+	transport := &Transport{exporterHost: createTestExporterHost("files"), sftpClient: client}
+	const path = "/etc/jumpstarter/exporters/exporter.yaml"
+
+	require.NoError(t, transport.WriteFile(path, "token: first\n", keepOwner(0o600)))
+	attrs, err := transport.FileAttrs(path)
+	require.NoError(t, err)
+	assert.Equal(t, manager.FileAttrs{Mode: 0o600, UID: 1000, GID: 1000}, attrs, "no chown without a declared owner")
+
+	require.NoError(t, transport.WriteFile(path, "token: second\n", manager.FileAttrs{Mode: 0o600, UID: 1000, GID: 1000}))
+	require.NoError(t, transport.SetFileAttrs(path, keepOwner(0o640)))
+
+	err = transport.WriteFile(path, "token: third\n", manager.FileAttrs{Mode: 0o600})
+	assert.ErrorContains(t, err, "failed to set owner 0:0", "only root can chown")
+	content, err := transport.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "token: second\n", content)
+}
+
+// keepOwner returns the attributes with mode, keeping the ownership of the files
+func keepOwner(mode os.FileMode) manager.FileAttrs {
+	return manager.FileAttrs{Mode: mode, UID: manager.KeepOwner, GID: manager.KeepOwner}
+}
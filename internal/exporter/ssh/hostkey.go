@@ -0,0 +1,180 @@
+package ssh
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/api/v1alpha1"
+)
+
+// HostKeyError is returned when the host key of an exporter host can't be verified. Retrying
+// doesn't help, so it must be reported instead of being queued for retry.
+type HostKeyError struct {
+	Host        string
+	Fingerprint string
+	// Expected is the pinned key or fingerprint, empty when the host key is unknown
+	Expected string
+	// JumpHost is set when the key is the one of a jump host
+	JumpHost bool
+}
+
+func (e *HostKeyError) Error() string {
+	if e.Mismatch() {
+		return fmt.Sprintf("host key mismatch for %s: got %s, expected %s, "+
+			"the host may have been reinstalled or someone may be impersonating it", e.Host, e.Fingerprint, e.Expected)
+	}
+	if e.JumpHost {
+		return fmt.Sprintf("unknown host key %s for jump host %s, pin it in the hostKey of the jump host "+
+			"or add it to the known_hosts file", e.Fingerprint, e.Host)
+	}
+	return fmt.Sprintf("unknown host key %s for %s, pin it in spec.management.ssh.hostKey, "+
+		"add it to the known_hosts file or use --ssh-host-keys=tofu", e.Fingerprint, e.Host)
+}
+
+// Mismatch reports whether the host presented a different key than the expected one
+func (e *HostKeyError) Mismatch() bool {
+	return e.Expected != ""
+}
+
+// IsHostKeyError checks if err is caused by a host key verification failure
+func IsHostKeyError(err error) bool {
+	var hostKeyErr *HostKeyError
+	return errors.As(err, &hostKeyErr)
+}
+
+// HostKeyVerifier verifies the host keys of the exporter hosts. The key pinned in the ExporterHost
+// (spec.management.ssh.hostKey, or the SSHHostKeyAnnotation recorded on first use) takes priority,
+// then the known_hosts file. Unknown keys are rejected unless TrustOnFirstUse is set.
+type HostKeyVerifier struct {
+	// KnownHostsFile is the known_hosts file checked for hosts without a pinned key
+	KnownHostsFile string
+	// TrustOnFirstUse accepts the key of hosts without a pinned or known key
+	TrustOnFirstUse bool
+	// OnFirstUse is called with the fingerprint of the keys accepted on first use, i.e. to pin them,
+	// the connection fails if it returns an error
+	OnFirstUse func(host *v1alpha1.ExporterHost, fingerprint string) error
+}
+
+// pinnedHostKey returns the host key or fingerprint pinned for the host
+func pinnedHostKey(host *v1alpha1.ExporterHost) string {
+	if key := strings.TrimSpace(host.Spec.Management.SSH.HostKey); key != "" {
+		return key
+	}
+	return strings.TrimSpace(host.Annotations[v1alpha1.SSHHostKeyAnnotation])
+}
+
+// matchesPinnedKey checks a host key against a pinned public key or SHA256 fingerprint
+func matchesPinnedKey(pinned string, key ssh.PublicKey) (bool, error) {
+	if strings.HasPrefix(pinned, "SHA256:") {
+		return pinned == ssh.FingerprintSHA256(key), nil
+	}
+	pinnedKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(pinned))
+	if err != nil {
+		return false, fmt.Errorf("invalid pinned host key %q: %w", pinned, err)
+	}
+	return bytes.Equal(pinnedKey.Marshal(), key.Marshal()), nil
+}
+
+// callback returns the ssh.HostKeyCallback verifying the key of host
+func (v *HostKeyVerifier) callback(host *v1alpha1.ExporterHost) (ssh.HostKeyCallback, error) {
+	var trust func(fingerprint string) error
+	if v != nil && v.TrustOnFirstUse {
+		trust = func(fingerprint string) error {
+			if v.OnFirstUse == nil {
+				return nil
+			}
+			return v.OnFirstUse(host, fingerprint)
+		}
+	}
+	return v.verify(pinnedHostKey(host), trust, false)
+}
+
+// jumpHostCallback returns the ssh.HostKeyCallback verifying the key of a jump host, it is
+// never trusted on first use as there is no ExporterHost to record it in
+func (v *HostKeyVerifier) jumpHostCallback(jump v1alpha1.SSHJumpHost) (ssh.HostKeyCallback, error) {
+	return v.verify(strings.TrimSpace(jump.HostKey), nil, true)
+}
+
+// CheckKnownHosts checks that the known_hosts file can be loaded, so a missing or invalid file fails
+// the sync once before any connection instead of failing every host
+func (v *HostKeyVerifier) CheckKnownHosts() error {
+	if v == nil || v.KnownHostsFile == "" {
+		return nil
+	}
+	_, err := v.loadKnownHosts()
+	return err
+}
+
+// loadKnownHosts loads the known_hosts file
+func (v *HostKeyVerifier) loadKnownHosts() (ssh.HostKeyCallback, error) {
+	callback, err := knownhosts.New(v.KnownHostsFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("known_hosts file %s not found: create it, i.e. with ssh-keyscan, or fix known_hosts "+
+			"in the configuration file: %w", v.KnownHostsFile, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load known_hosts file %s: %w", v.KnownHostsFile, err)
+	}
+	return callback, nil
+}
+
+// verify returns a ssh.HostKeyCallback checking the pinned key, then the known_hosts file. Unknown
+// keys are passed to trust when not nil, and rejected otherwise.
+func (v *HostKeyVerifier) verify(pinned string, trust func(fingerprint string) error, jumpHost bool) (ssh.HostKeyCallback, error) {
+	var knownHostsCallback ssh.HostKeyCallback
+	if v != nil && v.KnownHostsFile != "" {
+		var err error
+		knownHostsCallback, err = v.loadKnownHosts()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		fingerprint := ssh.FingerprintSHA256(key)
+
+		if pinned != "" {
+			ok, err := matchesPinnedKey(pinned, key)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return &HostKeyError{Host: hostname, Fingerprint: fingerprint, Expected: pinned, JumpHost: jumpHost}
+			}
+			return nil
+		}
+
+		if knownHostsCallback != nil {
+			err := knownHostsCallback(hostname, remote, key)
+			var keyErr *knownhosts.KeyError
+			switch {
+			case err == nil:
+				return nil
+			case errors.As(err, &keyErr) && len(keyErr.Want) > 0:
+				expected := make([]string, 0, len(keyErr.Want))
+				for _, want := range keyErr.Want {
+					expected = append(expected, ssh.FingerprintSHA256(want.Key))
+				}
+				return &HostKeyError{Host: hostname, Fingerprint: fingerprint,
+					Expected: strings.Join(expected, " or "), JumpHost: jumpHost}
+			case !errors.As(err, &keyErr):
+				return err
+			}
+		}
+
+		if trust == nil {
+			return &HostKeyError{Host: hostname, Fingerprint: fingerprint, JumpHost: jumpHost}
+		}
+		if err := trust(fingerprint); err != nil {
+			return fmt.Errorf("failed to record host key %s for %s: %w", fingerprint, hostname, err)
+		}
+		return nil
+	}, nil
+}
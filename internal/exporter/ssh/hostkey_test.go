@@ -0,0 +1,155 @@
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func generateHostKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	key, err := ssh.NewPublicKey(pub)
+	require.NoError(t, err)
+	return key
+}
+
+// verifyHostKey runs the host key callback of v for host presenting key
+func verifyHostKey(t *testing.T, v *HostKeyVerifier, host *v1alpha1.ExporterHost, key ssh.PublicKey) error {
+	t.Helper()
+	callback, err := v.callback(host)
+	require.NoError(t, err)
+	return callback("test-host.example.com:22", &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 22}, key)
+}
+
+func TestHostKeyVerifier_Pinned(t *testing.T) {
+	key := generateHostKey(t)
+	other := generateHostKey(t)
+
+	for name, pinned := range map[string]string{
+		"authorized key": string(ssh.MarshalAuthorizedKey(key)),
+		"fingerprint":    ssh.FingerprintSHA256(key),
+	} {
+		t.Run(name, func(t *testing.T) {
+			host := createTestExporterHost("pinned")
+			host.Spec.Management.SSH.HostKey = pinned
+
+			require.NoError(t, verifyHostKey(t, nil, host, key))
+
+			err := verifyHostKey(t, &HostKeyVerifier{TrustOnFirstUse: true}, host, other)
+			require.True(t, IsHostKeyError(err))
+			var hostKeyErr *HostKeyError
+			require.ErrorAs(t, err, &hostKeyErr)
+			assert.True(t, hostKeyErr.Mismatch(), "trust on first use must not override a pinned key")
+			assert.Equal(t, ssh.FingerprintSHA256(other), hostKeyErr.Fingerprint)
+		})
+	}
+
+	t.Run("recorded annotation", func(t *testing.T) {
+		host := createTestExporterHost("recorded")
+		host.Annotations = map[string]string{v1alpha1.SSHHostKeyAnnotation: ssh.FingerprintSHA256(key)}
+		require.NoError(t, verifyHostKey(t, nil, host, key))
+		assert.True(t, IsHostKeyError(verifyHostKey(t, nil, host, other)))
+	})
+
+	t.Run("invalid pinned key", func(t *testing.T) {
+		host := createTestExporterHost("invalid")
+		host.Spec.Management.SSH.HostKey = "not a key"
+		err := verifyHostKey(t, nil, host, key)
+		require.ErrorContains(t, err, "invalid pinned host key")
+	})
+}
+
+func TestHostKeyVerifier_KnownHosts(t *testing.T) {
+	key := generateHostKey(t)
+	knownHostsFile := filepath.Join(t.TempDir(), "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize("test-host.example.com:22")}, key)
+	require.NoError(t, os.WriteFile(knownHostsFile, []byte(line+"\n"), 0644))
+	v := &HostKeyVerifier{KnownHostsFile: knownHostsFile}
+
+	t.Run("known key", func(t *testing.T) {
+		require.NoError(t, verifyHostKey(t, v, createTestExporterHost("known"), key))
+	})
+
+	t.Run("changed key", func(t *testing.T) {
+		tofu := &HostKeyVerifier{KnownHostsFile: knownHostsFile, TrustOnFirstUse: true}
+		err := verifyHostKey(t, tofu, createTestExporterHost("changed"), generateHostKey(t))
+		var hostKeyErr *HostKeyError
+		require.ErrorAs(t, err, &hostKeyErr)
+		assert.True(t, hostKeyErr.Mismatch())
+		assert.Equal(t, ssh.FingerprintSHA256(key), hostKeyErr.Expected)
+	})
+
+	t.Run("pinned key takes priority", func(t *testing.T) {
+		other := generateHostKey(t)
+		host := createTestExporterHost("pinned")
+		host.Spec.Management.SSH.HostKey = ssh.FingerprintSHA256(other)
+		require.NoError(t, verifyHostKey(t, v, host, other))
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		missing := &HostKeyVerifier{KnownHostsFile: filepath.Join(t.TempDir(), "missing")}
+		_, err := missing.callback(createTestExporterHost("x"))
+		require.ErrorContains(t, err, "known_hosts file "+missing.KnownHostsFile+" not found")
+		require.ErrorContains(t, missing.CheckKnownHosts(), "not found: create it")
+	})
+
+	t.Run("invalid file", func(t *testing.T) {
+		invalid := filepath.Join(t.TempDir(), "known_hosts")
+		require.NoError(t, os.WriteFile(invalid, []byte("host.example.com ssh-ed25519 not-a-key\n"), 0600))
+		err := (&HostKeyVerifier{KnownHostsFile: invalid}).CheckKnownHosts()
+		require.ErrorContains(t, err, "failed to load known_hosts file "+invalid)
+	})
+
+	t.Run("no file", func(t *testing.T) {
+		require.NoError(t, (&HostKeyVerifier{}).CheckKnownHosts())
+	})
+}
+
+func TestHostKeyVerifier_UnknownHost(t *testing.T) {
+	key := generateHostKey(t)
+
+	t.Run("strict", func(t *testing.T) {
+		err := verifyHostKey(t, &HostKeyVerifier{}, createTestExporterHost("unknown"), key)
+		var hostKeyErr *HostKeyError
+		require.ErrorAs(t, err, &hostKeyErr)
+		assert.False(t, hostKeyErr.Mismatch())
+		assert.True(t, strings.Contains(err.Error(), "--ssh-host-keys=tofu"))
+	})
+
+	t.Run("trust on first use", func(t *testing.T) {
+		var recorded string
+		v := &HostKeyVerifier{
+			TrustOnFirstUse: true,
+			OnFirstUse: func(host *v1alpha1.ExporterHost, fingerprint string) error {
+				recorded = host.Name + "=" + fingerprint
+				return nil
+			},
+		}
+		require.NoError(t, verifyHostKey(t, v, createTestExporterHost("new"), key))
+		assert.Equal(t, "new="+ssh.FingerprintSHA256(key), recorded)
+	})
+
+	t.Run("recording failure", func(t *testing.T) {
+		v := &HostKeyVerifier{
+			TrustOnFirstUse: true,
+			OnFirstUse: func(*v1alpha1.ExporterHost, string) error {
+				return assert.AnError
+			},
+		}
+		err := verifyHostKey(t, v, createTestExporterHost("new"), key)
+		require.ErrorIs(t, err, assert.AnError)
+		assert.False(t, IsHostKeyError(err))
+	})
+}
@@ -0,0 +1,345 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/api/v1alpha1"
+)
+
+// connectTimeout bounds the TCP connection and the SSH handshake with a host
+const connectTimeout = 15 * time.Second
+
+// endpoint is an SSH server to connect to, an exporter host or one of its jump hosts
+type endpoint struct {
+	host        string
+	port        int
+	user        string
+	keyFile     string
+	keyData     string
+	keyPassword string
+	password    string
+}
+
+func hostEndpoint(c v1alpha1.SSHCredentials) endpoint {
+	return endpoint{host: c.Host, port: c.Port, user: c.User, keyFile: c.KeyFile,
+		keyData: c.SSHKeyData, keyPassword: c.SSHKeyPassword, password: c.Password}
+}
+
+func jumpHostEndpoint(j v1alpha1.SSHJumpHost) endpoint {
+	return endpoint{host: j.Host, port: j.Port, user: j.User, keyFile: j.KeyFile,
+		keyData: j.SSHKeyData, keyPassword: j.SSHKeyPassword, password: j.Password}
+}
+
+func (e endpoint) addr() string {
+	port := 22
+	if e.port != 0 {
+		port = e.port
+	}
+	return net.JoinHostPort(e.host, fmt.Sprint(port))
+}
+
+// authMethods returns the SSH authentication methods of the endpoint, done must be called once
+// the handshake is over
+func (e endpoint) authMethods() (auth []ssh.AuthMethod, done func(), err error) {
+	done = func() {}
+	if e.keyFile != "" {
+		key, err := os.ReadFile(e.keyFile)
+		if err != nil {
+			return nil, done, fmt.Errorf("failed to read SSH key file: %w", err)
+		}
+		var signer ssh.Signer
+		if e.keyPassword != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(e.keyPassword))
+			if err != nil {
+				return nil, done, fmt.Errorf("failed to parse encrypted SSH private key from file: %w", err)
+			}
+		} else {
+			signer, err = ssh.ParsePrivateKey(key)
+			if err != nil {
+				return nil, done, fmt.Errorf("failed to parse SSH private key from file: %w", err)
+			}
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+
+	if e.keyData != "" {
+		var signer ssh.Signer
+		if e.keyPassword != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(e.keyData), []byte(e.keyPassword))
+			if err != nil {
+				return nil, done, fmt.Errorf("failed to parse encrypted SSH private key from sshKeyData: %w", err)
+			}
+		} else {
+			signer, err = ssh.ParsePrivateKey([]byte(e.keyData))
+			if err != nil {
+				return nil, done, fmt.Errorf("failed to parse SSH private key from sshKeyData: %w", err)
+			}
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+
+	if e.password != "" {
+		auth = append(auth, ssh.Password(e.password))
+	}
+
+	// Check if SSH agent is running and use it if available
+	agentSocket := os.Getenv("SSH_AUTH_SOCK")
+	if agentSocket != "" {
+		// Connect to the agent's socket.
+		conn, err := net.Dial("unix", agentSocket)
+		if err != nil {
+			log.Printf("Failed to connect to SSH agent: %v", err)
+		} else {
+			done = func() {
+				_ = conn.Close()
+			}
+
+			// Create a new agent client.
+			agentClient := agent.NewClient(conn)
+
+			auth = append(auth, ssh.PublicKeysCallback(agentClient.Signers))
+		}
+	}
+
+	return auth, done, nil
+}
+
+// dialFunc opens the network connection to an SSH server
+type dialFunc func(ctx context.Context, addr string) (net.Conn, error)
+
+func dialDirect(ctx context.Context, addr string) (net.Conn, error) {
+	dialer := net.Dialer{Timeout: connectTimeout}
+	return dialer.DialContext(ctx, "tcp", addr)
+}
+
+// UnreachableError is returned when the network connection to an exporter host dialed directly,
+// not through jump hosts, fails: the host or its network is down. The unknown host names, the
+// jump host, authentication and host key failures are not reported as such.
+type UnreachableError struct {
+	Addr string
+	Err  error
+}
+
+func (e *UnreachableError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *UnreachableError) Unwrap() error {
+	return e.Err
+}
+
+// IsUnreachable checks if err is caused by an exporter host which can't be reached over the network
+func IsUnreachable(err error) bool {
+	var unreachable *UnreachableError
+	return errors.As(err, &unreachable)
+}
+
+// dialUnreachable dials addr directly, the network failures are returned as an *UnreachableError
+func dialUnreachable(ctx context.Context, addr string) (net.Conn, error) {
+	conn, err := dialDirect(ctx, addr)
+	if err == nil || ctx.Err() != nil {
+		return conn, err
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		// a configuration error rather than a host down
+		return nil, err
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return nil, &UnreachableError{Addr: addr, Err: err}
+	}
+	return nil, err
+}
+
+// connect opens an SSH connection to e over a connection opened by dial, the handshake is
+// aborted when ctx is done
+func connect(ctx context.Context, e endpoint, hostKeyCallback ssh.HostKeyCallback, dial dialFunc) (*ssh.Client, error) {
+	auth, closeAuth, err := e.authMethods()
+	defer closeAuth()
+	if err != nil {
+		return nil, err
+	}
+
+	config := &ssh.ClientConfig{
+		User:            e.user,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         connectTimeout,
+	}
+
+	addr := e.addr()
+	conn, err := dial(ctx, addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to SSH host %s: %w", addr, err)
+	}
+
+	// abort the handshake if the context is done
+	_ = conn.SetDeadline(time.Now().Add(config.Timeout))
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.Close()
+	})
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if !stop() || err != nil {
+		_ = conn.Close()
+		if err == nil {
+			err = ctx.Err()
+		}
+		return nil, fmt.Errorf("failed to connect to SSH host %s: %w", addr, err)
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return ssh.NewClient(sshConn, chans, reqs), nil
+}
+
+// JumpHostPool keeps the connections to the jump hosts open, so every exporter host behind
+// a jump host reuses the same connection. It is safe for concurrent use.
+type JumpHostPool struct {
+	mu    sync.Mutex
+	conns map[string]*jumpHostConn
+	// order lists the connections in the order they were opened, they are closed in reverse order
+	order []*jumpHostConn
+}
+
+// jumpHostConn is a connection to a jump host, ready is closed once client or err is set
+type jumpHostConn struct {
+	ready  chan struct{}
+	client *ssh.Client
+	err    error
+}
+
+// NewJumpHostPool creates an empty JumpHostPool
+func NewJumpHostPool() *JumpHostPool {
+	return &JumpHostPool{conns: make(map[string]*jumpHostConn)}
+}
+
+// chainKey identifies the connection to the last jump host of a chain
+func chainKey(jumps []v1alpha1.SSHJumpHost) string {
+	hops := make([]string, 0, len(jumps))
+	for _, jump := range jumps {
+		e := jumpHostEndpoint(jump)
+		hops = append(hops, e.user+"@"+e.addr())
+	}
+	return strings.Join(hops, ",")
+}
+
+// dial opens a connection to addr through the chain of jump hosts
+func (p *JumpHostPool) dial(ctx context.Context, jumps []v1alpha1.SSHJumpHost, hostKeys *HostKeyVerifier, addr string) (net.Conn, error) {
+	client, err := p.client(ctx, jumps, hostKeys)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := client.DialContext(ctx, "tcp", addr)
+	if err == nil || ctx.Err() != nil {
+		return conn, err
+	}
+
+	// the jump host connection may have been lost since it was opened, reconnect once
+	if _, _, keepaliveErr := client.SendRequest("keepalive@openssh.com", true, nil); keepaliveErr == nil {
+		return nil, fmt.Errorf("failed to connect to %s through jump host %s: %w", addr, jumps[len(jumps)-1].Host, err)
+	}
+	p.forget(jumps, client)
+	if client, err = p.client(ctx, jumps, hostKeys); err != nil {
+		return nil, err
+	}
+	conn, err = client.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s through jump host %s: %w", addr, jumps[len(jumps)-1].Host, err)
+	}
+	return conn, nil
+}
+
+// client returns the connection to the last jump host of the chain, opening it when needed
+func (p *JumpHostPool) client(ctx context.Context, jumps []v1alpha1.SSHJumpHost, hostKeys *HostKeyVerifier) (*ssh.Client, error) {
+	key := chainKey(jumps)
+
+	p.mu.Lock()
+	conn, ok := p.conns[key]
+	if !ok {
+		conn = &jumpHostConn{ready: make(chan struct{})}
+		p.conns[key] = conn
+	}
+	p.mu.Unlock()
+
+	if !ok {
+		client, err := p.connect(ctx, jumps, hostKeys)
+		p.mu.Lock()
+		conn.client, conn.err = client, err
+		if err != nil {
+			// don't keep failures, the next host or retry connects again
+			delete(p.conns, key)
+		} else {
+			p.order = append(p.order, conn)
+		}
+		p.mu.Unlock()
+		close(conn.ready)
+	}
+
+	select {
+	case <-conn.ready:
+		return conn.client, conn.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// connect opens the connection to the last jump host of the chain, through the previous ones
+func (p *JumpHostPool) connect(ctx context.Context, jumps []v1alpha1.SSHJumpHost, hostKeys *HostKeyVerifier) (*ssh.Client, error) {
+	jump := jumps[len(jumps)-1]
+	dial := dialFunc(dialDirect)
+	if len(jumps) > 1 {
+		dial = func(ctx context.Context, addr string) (net.Conn, error) {
+			return p.dial(ctx, jumps[:len(jumps)-1], hostKeys, addr)
+		}
+	}
+
+	hostKeyCallback, err := hostKeys.jumpHostCallback(jump)
+	if err != nil {
+		return nil, err
+	}
+	client, err := connect(ctx, jumpHostEndpoint(jump), hostKeyCallback, dial)
+	if err != nil {
+		return nil, fmt.Errorf("jump host %s: %w", jump.Host, err)
+	}
+	return client, nil
+}
+
+// forget drops a lost connection to a jump host, unless it was already replaced
+func (p *JumpHostPool) forget(jumps []v1alpha1.SSHJumpHost, client *ssh.Client) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	key := chainKey(jumps)
+	if conn, ok := p.conns[key]; ok && conn.client == client {
+		delete(p.conns, key)
+	}
+	p.order = slices.DeleteFunc(p.order, func(conn *jumpHostConn) bool {
+		return conn.client == client
+	})
+	_ = client.Close()
+}
+
+// Close closes the connections to the jump hosts
+func (p *JumpHostPool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var firstErr error
+	for _, conn := range slices.Backward(p.order) {
+		if err := conn.client.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	p.conns = make(map[string]*jumpHostConn)
+	p.order = nil
+	return firstErr
+}
@@ -0,0 +1,283 @@
+package ssh
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+// testSSHServer is a minimal SSH server accepting testPassword, which forwards direct-tcpip
+// channels so it can be used as a jump host, and runs the commands of sessions with exec
+type testSSHServer struct {
+	addr       string
+	hostKey    ssh.PublicKey
+	handshakes atomic.Int32
+	// exec returns the output and exit status of a command, sessions are rejected when nil
+	exec func(command string) (string, uint32)
+}
+
+func startTestSSHServer(t *testing.T) *testSSHServer {
+	t.Helper()
+	return startTestCommandServer(t, nil)
+}
+
+// startTestCommandServer starts a testSSHServer running the commands with exec
+func startTestCommandServer(t *testing.T, exec func(command string) (string, uint32)) *testSSHServer {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(priv)
+	require.NoError(t, err)
+
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(_ ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if string(password) != testPassword {
+				return nil, fmt.Errorf("wrong password")
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+
+	server := &testSSHServer{addr: listener.Addr().String(), hostKey: signer.PublicKey(), exec: exec}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn, config)
+		}
+	}()
+	return server
+}
+
+func (s *testSSHServer) serve(conn net.Conn, config *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	s.handshakes.Add(1)
+	go ssh.DiscardRequests(reqs)
+	for newChannel := range chans {
+		if newChannel.ChannelType() == "session" && s.exec != nil {
+			channel, requests, err := newChannel.Accept()
+			if err != nil {
+				continue
+			}
+			go s.session(channel, requests)
+			continue
+		}
+		if newChannel.ChannelType() != "direct-tcpip" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "not supported")
+			continue
+		}
+		var target struct {
+			Host       string
+			Port       uint32
+			OriginHost string
+			OriginPort uint32
+		}
+		if err := ssh.Unmarshal(newChannel.ExtraData(), &target); err != nil {
+			_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		targetConn, err := net.Dial("tcp", net.JoinHostPort(target.Host, strconv.Itoa(int(target.Port))))
+		if err != nil {
+			_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			_ = targetConn.Close()
+			continue
+		}
+		go ssh.DiscardRequests(requests)
+		go func() {
+			_, _ = io.Copy(channel, targetConn)
+			_ = channel.Close()
+		}()
+		go func() {
+			_, _ = io.Copy(targetConn, channel)
+			_ = targetConn.Close()
+		}()
+	}
+}
+
+// session runs the command of an exec request with s.exec
+func (s *testSSHServer) session(channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer func() {
+		_ = channel.Close()
+	}()
+	for req := range requests {
+		if req.Type != "exec" {
+			_ = req.Reply(false, nil)
+			continue
+		}
+		var payload struct{ Command string }
+		if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+			_ = req.Reply(false, nil)
+			return
+		}
+		_ = req.Reply(true, nil)
+		stdout, status := s.exec(payload.Command)
+		_, _ = channel.Write([]byte(stdout))
+		_, _ = channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+		return
+	}
+}
+
+func (s *testSSHServer) jumpHost(t *testing.T) v1alpha1.SSHJumpHost {
+	host, port, err := net.SplitHostPort(s.addr)
+	require.NoError(t, err)
+	portNumber, err := strconv.Atoi(port)
+	require.NoError(t, err)
+	return v1alpha1.SSHJumpHost{Host: host, Port: portNumber, User: "jump", Password: testPassword,
+		HostKey: ssh.FingerprintSHA256(s.hostKey)}
+}
+
+func (s *testSSHServer) exporterHost(t *testing.T, jumps ...v1alpha1.SSHJumpHost) *v1alpha1.ExporterHost {
+	jump := s.jumpHost(t)
+	host := createTestExporterHost("behind-jump-host")
+	host.Spec.Management.SSH = v1alpha1.SSHCredentials{Host: jump.Host, Port: jump.Port, User: "exporter",
+		Password: testPassword, HostKey: jump.HostKey, JumpHosts: jumps}
+	return host
+}
+
+// connectThrough connects to host through pool, like createSshClient does
+func connectThrough(t *testing.T, pool *JumpHostPool, host *v1alpha1.ExporterHost) (*ssh.Client, error) {
+	callback, err := (*HostKeyVerifier)(nil).callback(host)
+	require.NoError(t, err)
+	creds := host.Spec.Management.SSH
+	return connect(context.Background(), hostEndpoint(creds), callback, func(ctx context.Context, addr string) (net.Conn, error) {
+		return pool.dial(ctx, creds.JumpHosts, nil, addr)
+	})
+}
+
+func TestJumpHostPool_ReusesConnection(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+	jump := startTestSSHServer(t)
+	target := startTestSSHServer(t)
+	pool := NewJumpHostPool()
+	defer func() {
+		_ = pool.Close()
+	}()
+
+	host := target.exporterHost(t, jump.jumpHost(t))
+	for range 3 {
+		client, err := connectThrough(t, pool, host)
+		require.NoError(t, err)
+		_ = client.Close()
+	}
+	assert.Equal(t, int32(1), jump.handshakes.Load(), "the jump host connection must be shared")
+	assert.Equal(t, int32(3), target.handshakes.Load())
+
+	t.Run("reconnects a lost jump host connection", func(t *testing.T) {
+		client, err := pool.client(context.Background(), host.Spec.Management.SSH.JumpHosts, nil)
+		require.NoError(t, err)
+		_ = client.Close()
+
+		client, err = connectThrough(t, pool, host)
+		require.NoError(t, err)
+		_ = client.Close()
+		assert.Equal(t, int32(2), jump.handshakes.Load())
+	})
+}
+
+func TestJumpHostPool_Chain(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+	first := startTestSSHServer(t)
+	second := startTestSSHServer(t)
+	target := startTestSSHServer(t)
+	pool := NewJumpHostPool()
+	defer func() {
+		_ = pool.Close()
+	}()
+
+	client, err := connectThrough(t, pool, target.exporterHost(t, first.jumpHost(t), second.jumpHost(t)))
+	require.NoError(t, err)
+	_ = client.Close()
+	assert.Equal(t, int32(1), first.handshakes.Load())
+	assert.Equal(t, int32(1), second.handshakes.Load())
+	assert.Equal(t, int32(1), target.handshakes.Load())
+}
+
+func TestJumpHostPool_HostKeyMismatch(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+	jump := startTestSSHServer(t)
+	target := startTestSSHServer(t)
+	pool := NewJumpHostPool()
+	defer func() {
+		_ = pool.Close()
+	}()
+
+	jumpHost := jump.jumpHost(t)
+	jumpHost.HostKey = ssh.FingerprintSHA256(target.hostKey)
+	_, err := connectThrough(t, pool, target.exporterHost(t, jumpHost))
+	var hostKeyErr *HostKeyError
+	require.ErrorAs(t, err, &hostKeyErr)
+	assert.True(t, hostKeyErr.JumpHost)
+	assert.True(t, hostKeyErr.Mismatch())
+	assert.Equal(t, int32(0), target.handshakes.Load())
+
+	jumpHost.HostKey = ""
+	_, err = connectThrough(t, pool, target.exporterHost(t, jumpHost))
+	require.ErrorAs(t, err, &hostKeyErr)
+	assert.False(t, hostKeyErr.Mismatch())
+	assert.Contains(t, err.Error(), "jump host")
+}
+
+func TestConnect_Unreachable(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	closedHost, closedPort, err := net.SplitHostPort(closed.Addr().String())
+	require.NoError(t, err)
+	port, err := strconv.Atoi(closedPort)
+	require.NoError(t, err)
+	require.NoError(t, closed.Close())
+
+	t.Run("direct", func(t *testing.T) {
+		host := createTestExporterHost("down")
+		host.Spec.Management.SSH = v1alpha1.SSHCredentials{Host: closedHost, Port: port, User: "exporter",
+			Password: testPassword}
+		_, err := Connect(context.Background(), host, nil, nil)
+		require.Error(t, err)
+		assert.True(t, IsUnreachable(err), "the host is down: %v", err)
+	})
+
+	t.Run("jump host down", func(t *testing.T) {
+		target := startTestSSHServer(t)
+		jump := target.jumpHost(t)
+		jump.Port = port
+		_, err := Connect(context.Background(), target.exporterHost(t, jump), nil, nil)
+		require.Error(t, err)
+		assert.False(t, IsUnreachable(err), "the exporter host itself isn't known to be down: %v", err)
+	})
+
+	t.Run("authentication failure", func(t *testing.T) {
+		target := startTestSSHServer(t)
+		host := target.exporterHost(t)
+		host.Spec.Management.SSH.Password = "not-the-password"
+		_, err := Connect(context.Background(), host, nil, nil)
+		require.Error(t, err)
+		assert.False(t, IsUnreachable(err), "the host is up: %v", err)
+	})
+}
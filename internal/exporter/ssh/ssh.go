@@ -1,127 +1,109 @@
 package ssh
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"io/fs"
 	"net"
 	"os"
 	"path/filepath"
-	"regexp"
-	"strings"
 	"sync"
-	"time"
 
-	"github.com/google/go-cmp/cmp"
 	"github.com/jumpstarter-dev/jumpstarter-lab-config/api/v1alpha1"
-	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/container"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/exporter/manager"
 	"github.com/pkg/sftp"
 	"golang.org/x/crypto/ssh"
-	"golang.org/x/crypto/ssh/agent"
 )
 
-// BootcStatus represents the status of bootc upgrade
-type BootcStatus int
-
-const (
-	BOOTC_UP_TO_DATE BootcStatus = iota
-	BOOTC_UPDATING
-	BOOTC_WILL_UPDATE
-	BOOTC_NOT_MANAGED
-)
-
-const (
-	noValuePlaceholder = "<no value>"
-	systemdStateActive = "active"
-)
-
-type HostManager interface {
-	Status() (string, error)
-	NeedsUpdate() (bool, error)
-	Diff() (string, error)
-	Apply(exporterConfig *v1alpha1.ExporterConfigTemplate, dryRun bool) error
-	RunHostCommand(command string) (*CommandResult, error)
-	GetBootcStatus() BootcStatus
-	HandleBootcUpgrade(dryRun bool) error
-	SetWriter(w io.Writer)
-	Close() error
-}
-
-// CommandResult represents the result of running a command via SSH
-type CommandResult struct {
-	Stdout   string
-	Stderr   string
-	ExitCode int
-}
-
-type SSHHostManager struct {
-	ExporterHost *v1alpha1.ExporterHost `json:"exporterHost,omitempty"`
+// Transport runs the commands of the manager on an exporter host through an SSH connection,
+// and reaches its files through SFTP
+type Transport struct {
+	exporterHost *v1alpha1.ExporterHost
 	sshClient    *ssh.Client
 	sftpClient   *sftp.Client
-	mutex        *sync.Mutex
-	writer       io.Writer
+	// ctx bounds the lifetime of the connection, running commands are interrupted when it is done
+	ctx context.Context
+	// hostKeys verifies the host key, only pinned keys are accepted when nil
+	hostKeys *HostKeyVerifier
+	// jumpHosts provides the connections to the jump hosts, ownsJumpHosts is set when
+	// the pool is private to this transport
+	jumpHosts     *JumpHostPool
+	ownsJumpHosts bool
 }
 
-func NewSSHHostManager(exporterHost *v1alpha1.ExporterHost) (HostManager, error) {
-
-	sshHm := &SSHHostManager{
-		ExporterHost: exporterHost,
-		mutex:        &sync.Mutex{},
-		sshClient:    nil,
-		sftpClient:   nil,
-		writer:       os.Stdout,
-	}
-
-	sshClient, err := sshHm.createSshClient()
+// Connect connects to the exporter host, the connection and the commands run through it
+// are interrupted when ctx is done, while file writes already in progress are allowed to finish.
+// The host key is checked with hostKeys, a *HostKeyError is returned when it can't be verified,
+// and an *UnreachableError when the host can't be reached.
+// The connection goes through the jump hosts of the exporter host, reusing the connections of jumpHosts
+// when not nil.
+func Connect(ctx context.Context, exporterHost *v1alpha1.ExporterHost, hostKeys *HostKeyVerifier, jumpHosts *JumpHostPool) (*Transport, error) {
+	t := &Transport{
+		exporterHost: exporterHost,
+		ctx:          ctx,
+		hostKeys:     hostKeys,
+		jumpHosts:    jumpHosts,
+	}
+
+	sshClient, err := t.createSshClient()
 	if err != nil {
+		_ = t.Close()
 		return nil, fmt.Errorf("failed to create SSH client for %q: %w", exporterHost.Name, err)
 	}
+	t.sshClient = sshClient
 
 	sftpClient, err := sftp.NewClient(sshClient)
 	if err != nil {
-		_ = sshClient.Close() // Close SSH client if SFTP client creation fails
+		_ = t.Close() // Close SSH client if SFTP client creation fails
 		return nil, fmt.Errorf("failed to create SFTP client for %q: %w", exporterHost.Name, err)
 	}
 
-	sshHm.sshClient = sshClient
-	sshHm.sftpClient = sftpClient
-	return sshHm, nil
+	t.sftpClient = sftpClient
+	return t, nil
 }
 
-func (m *SSHHostManager) Status() (string, error) {
-	result, err := m.runCommand("ls -la")
-	if err != nil {
-		return "", fmt.Errorf("failed to run status command for %q: %w", m.ExporterHost.Name, err)
-	}
-
-	// For now, return a simple status based on exit code
-	if result.ExitCode == 0 {
-		return "ok", nil
+// context returns the context of the connection
+func (t *Transport) context() context.Context {
+	if t.ctx == nil {
+		return context.Background()
 	}
-	return fmt.Sprintf("error (exit code: %d)", result.ExitCode), nil
+	return t.ctx
 }
 
-// runCommand executes a command on the remote host and returns the result
-func (m *SSHHostManager) runCommand(command string) (*CommandResult, error) {
-	if m.sshClient == nil {
+// Run executes a command on the remote host and returns the result
+func (t *Transport) Run(command string) (*manager.CommandResult, error) {
+	if t.sshClient == nil {
 		return nil, fmt.Errorf("sshClient is not initialized")
 	}
-	session, err := m.sshClient.NewSession()
+	ctx := t.context()
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("not running command on %q: %w", t.exporterHost.Name, err)
+	}
+	session, err := t.sshClient.NewSession()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create SSH session for %q: %w", m.ExporterHost.Name, err)
+		return nil, fmt.Errorf("failed to create SSH session for %q: %w", t.exporterHost.Name, err)
 	}
 	defer func() {
 		_ = session.Close() // nolint:errcheck
 	}()
 
+	// interrupt the remote command when the context is done
+	stop := context.AfterFunc(ctx, func() {
+		_ = session.Signal(ssh.SIGTERM) // nolint:errcheck
+		_ = session.Close()             // nolint:errcheck
+	})
+	defer stop()
+
 	stdout, err := session.StdoutPipe()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create stdout pipe for %q: %w", m.ExporterHost.Name, err)
+		return nil, fmt.Errorf("failed to create stdout pipe for %q: %w", t.exporterHost.Name, err)
 	}
 
 	stderr, err := session.StderrPipe()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create stderr pipe for %q: %w", m.ExporterHost.Name, err)
+		return nil, fmt.Errorf("failed to create stderr pipe for %q: %w", t.exporterHost.Name, err)
 	}
 
 	// Capture stdout and stderr
@@ -147,10 +129,14 @@ func (m *SSHHostManager) runCommand(command string) (*CommandResult, error) {
 
 	// Check for errors in reading stdout/stderr
 	if stdoutErr != nil {
-		return nil, fmt.Errorf("failed to read stdout for %q: %w", m.ExporterHost.Name, stdoutErr)
+		return nil, fmt.Errorf("failed to read stdout for %q: %w", t.exporterHost.Name, stdoutErr)
 	}
 	if stderrErr != nil {
-		return nil, fmt.Errorf("failed to read stderr for %q: %w", m.ExporterHost.Name, stderrErr)
+		return nil, fmt.Errorf("failed to read stderr for %q: %w", t.exporterHost.Name, stderrErr)
+	}
+
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, fmt.Errorf("command interrupted on %q: %w", t.exporterHost.Name, ctxErr)
 	}
 
 	// Get exit code
@@ -160,538 +146,197 @@ func (m *SSHHostManager) runCommand(command string) (*CommandResult, error) {
 			exitCode = exitErr.ExitStatus()
 		} else {
 			// If it's not an exit error, return the error
-			return nil, fmt.Errorf("failed to run command for %q: %w", m.ExporterHost.Name, err)
+			return nil, fmt.Errorf("failed to run command for %q: %w", t.exporterHost.Name, err)
 		}
 	}
 	err = nil
 
 	if exitCode != 0 {
-		err = fmt.Errorf("failed to run command for %q: %q", m.ExporterHost.Name, string(stderrBytes))
+		err = fmt.Errorf("failed to run command for %q: %q", t.exporterHost.Name, string(stderrBytes))
 	}
 
-	return &CommandResult{
+	return &manager.CommandResult{
 		Stdout:   string(stdoutBytes),
 		Stderr:   string(stderrBytes),
 		ExitCode: exitCode,
 	}, err
 }
 
-func (m *SSHHostManager) NeedsUpdate() (bool, error) {
-	return false, nil
-}
-
-func (m *SSHHostManager) Diff() (string, error) {
-	return "Not implemented yet", nil
-}
-
-func (m *SSHHostManager) Apply(exporterConfig *v1alpha1.ExporterConfigTemplate, dryRun bool) error {
-	// Validate mutual exclusivity: both templates cannot be specified simultaneously
-	if exporterConfig.Spec.SystemdContainerTemplate != "" && exporterConfig.Spec.SystemdServiceTemplate != "" {
-		return fmt.Errorf("both SystemdContainerTemplate and SystemdServiceTemplate specified - only one should be used")
-	}
-
-	// Helper function to restart service, gracefully wating on lease exit
-	restartGracefully := func(serviceName string, dryRun bool) {
-		if !dryRun {
-			_, enableErr := m.runCommand(fmt.Sprintf("command -v podman >/dev/null 2>&1 && podman kill -s SIGHUP %q || systemctl kill -s SIGHUP %q", serviceName, serviceName))
-			if enableErr != nil {
-				_, _ = fmt.Fprintf(m.writer, "        ❌ Failed to signal %s: %v\n", serviceName, enableErr)
-			} else {
-				_, _ = fmt.Fprintf(m.writer, "        ✅ %s signalled to restart when not leased\n", serviceName)
-			}
-		} else {
-			_, _ = fmt.Fprintf(m.writer, "        📄 Would trigger restart of %s\n", serviceName)
-		}
-	}
-
-	svcName := exporterConfig.Spec.ExporterMetadata.Name
-	containerSystemdFile := "/etc/containers/systemd/" + svcName + ".container"
-	serviceSystemdFile := "/etc/systemd/system/" + svcName + ".service"
-	exporterConfigFile := "/etc/jumpstarter/exporters/" + svcName + ".yaml"
+func (t *Transport) createSshClient() (*ssh.Client, error) {
+	creds := t.exporterHost.Spec.Management.SSH
 
-	changedContainer, err := m.reconcileFile(containerSystemdFile, exporterConfig.Spec.SystemdContainerTemplate, dryRun)
+	hostKeyCallback, err := t.hostKeys.callback(t.exporterHost)
 	if err != nil {
-		return fmt.Errorf("failed to reconcile container systemd file: %w", err)
+		return nil, err
 	}
 
-	changedService, err := m.reconcileFile(serviceSystemdFile, exporterConfig.Spec.SystemdServiceTemplate, dryRun)
-	if err != nil {
-		return fmt.Errorf("failed to reconcile service systemd file: %w", err)
-	}
-
-	changedExporterConfig, err := m.reconcileFile(exporterConfigFile, exporterConfig.Spec.ConfigTemplate, dryRun)
-	if err != nil {
-		return fmt.Errorf("failed to reconcile exporter config file: %w", err)
-	}
-
-	if m.GetBootcStatus() == BOOTC_UPDATING {
-		if dryRun {
-			_, _ = fmt.Fprintf(m.writer, "        📄 Bootc upgrade in progress, would skip exporter service restarts/container updates\n")
-		} else {
-			_, _ = fmt.Fprintf(m.writer, "        ⚠️ Bootc upgrade in progress, skipping exporter service restarts/container updates\n")
-			return nil
+	dial := dialUnreachable
+	if len(creds.JumpHosts) > 0 {
+		if t.jumpHosts == nil {
+			// not shared with other hosts, the jump host connections are closed with this manager
+			t.jumpHosts = NewJumpHostPool()
+			t.ownsJumpHosts = true
 		}
-	}
-
-	// Only if bootc is not updating, we restart/start services and pull containers
-	// otherwise it's too much pressure on the system
-
-	if changedExporterConfig || changedContainer || changedService {
-		if !dryRun {
-			_, err := m.runCommand("systemctl daemon-reload")
-			if err != nil {
-				return fmt.Errorf("failed to reload systemd: %w", err)
-			}
-			if changedService {
-				_, err = m.runCommand("systemctl enable " + fmt.Sprintf("%q", svcName))
-				if err != nil {
-					return fmt.Errorf("failed to enable exporter: %w", err)
-				}
-			}
-
-			statusResult, _ := m.runCommand("systemctl is-active " + fmt.Sprintf("%q", svcName))
-			serviceRunning := statusResult != nil && statusResult.ExitCode == 0 && strings.TrimSpace(statusResult.Stdout) == systemdStateActive
-
-			if serviceRunning {
-				restartGracefully(svcName, dryRun)
-			} else {
-				_, startErr := m.runCommand("systemctl start " + fmt.Sprintf("%q", svcName))
-				if startErr != nil {
-					_, _ = fmt.Fprintf(m.writer, "        ❌ Failed to start service %s: %v\n", svcName, startErr)
-				} else {
-					_, _ = fmt.Fprintf(m.writer, "        ✅ Service %s started\n", svcName)
-				}
-			}
-		} else {
-			_, _ = fmt.Fprintf(m.writer, "        📄 Would reload systemd and start/restart %s\n", svcName)
-		}
-	} else {
-		// Check if service is running and start if needed
-		statusResult, err := m.runCommand("systemctl is-active " + fmt.Sprintf("%q", svcName))
-		serviceRunning := err == nil && strings.TrimSpace(statusResult.Stdout) == systemdStateActive
-
-		if !serviceRunning {
-			_, _ = fmt.Fprintf(m.writer, "        ⚠️ Service %s is not running...\n", svcName)
-			if !dryRun {
-				_, enableErr := m.runCommand("systemctl restart " + fmt.Sprintf("%q", svcName))
-				if enableErr != nil {
-					_, _ = fmt.Fprintf(m.writer, "        ❌ Failed to restart service %s: %v\n", svcName, enableErr)
-				} else {
-					_, _ = fmt.Fprintf(m.writer, "        ✅ Service %s restarted\n", svcName)
-				}
-			} else {
-				_, _ = fmt.Fprintf(m.writer, "        📄 Would restart service %s\n", svcName)
-			}
-		} else {
-			// Only check container version if service is running
-			err = m.checkContainerVersion(exporterConfig, svcName, dryRun, restartGracefully)
-			if err != nil {
-				return fmt.Errorf("container version check failed: %w", err)
-			}
+		dial = func(ctx context.Context, addr string) (net.Conn, error) {
+			return t.jumpHosts.dial(ctx, creds.JumpHosts, t.hostKeys, addr)
 		}
 	}
 
-	return nil
+	return connect(t.context(), hostEndpoint(creds), hostKeyCallback, dial)
 }
 
-// checkContainerVersion checks if container needs updating using detailed version comparison
-func (m *SSHHostManager) checkContainerVersion(exporterConfig *v1alpha1.ExporterConfigTemplate, svcName string, dryRun bool, restartService func(string, bool)) error {
-	// Only check version for container-based exporters
-	if exporterConfig.Spec.SystemdContainerTemplate == "" {
-		return nil
+// Close closes the SFTP and SSH clients, and the jump host connections when they aren't shared
+func (t *Transport) Close() error {
+	var sftpCloseError error = nil
+	var sshCloseError error = nil
+	if t.sftpClient != nil {
+		sftpCloseError = t.sftpClient.Close()
 	}
-
-	// Check detailed version comparison (if we have container image info)
-	if exporterConfig.Spec.ContainerImage != "" {
-		return m.checkDetailedContainerVersion(exporterConfig.Spec.ContainerImage, svcName, dryRun, restartService)
+	if t.sshClient != nil {
+		sshCloseError = t.sshClient.Close()
 	}
-
-	// No container image specified, nothing to check
-	return nil
-}
-
-// checkDetailedContainerVersion performs detailed version comparison using skopeo and podman inspect
-func (m *SSHHostManager) checkDetailedContainerVersion(containerImage, svcName string, dryRun bool, restartService func(string, bool)) error {
-	// Get expected version from registry
-	expectedLabels, err := container.GetImageLabelsFromRegistry(containerImage)
-	if err != nil {
-		_, _ = fmt.Fprintf(m.writer, "        ⚠️ Could not check container version: %v\n", err)
-		return nil // Don't fail the entire operation, just skip version check
-	}
-
-	if expectedLabels.IsEmpty() {
-		_, _ = fmt.Fprintf(m.writer, "        ℹ️ No version info available for image %s\n", containerImage)
-		return nil // Don't fail, just skip version check
+	if t.ownsJumpHosts {
+		_ = t.jumpHosts.Close()
 	}
-
-	// Get running container version
-	runningLabels, err := m.getRunningContainerLabels(svcName)
-	if err != nil {
-		_, _ = fmt.Fprintf(m.writer, "        ⚠️ Could not check running container version: %v\n", err)
-		return nil // Container might not be running yet, which is fine
+	if sshCloseError != nil {
+		return sshCloseError
 	}
 
-	// Compare versions
-	if expectedLabels.Matches(runningLabels) {
-		if dryRun {
-			_, _ = fmt.Fprintf(m.writer, "        ✅ Exporter container image running latest version\n")
-		}
-		// In non-dry-run mode, print nothing for matching versions as requested
-	} else {
-		if dryRun {
-			_, _ = fmt.Fprintf(m.writer, "        🔄 Would restart service for container update (running: %s, latest: %s)\n",
-				runningLabels.String(), expectedLabels.String())
-		} else {
-			_, _ = fmt.Fprintf(m.writer, "        🔄 Restarting service for container update (running: %s, latest: %s)\n",
-				runningLabels.String(), expectedLabels.String())
-			restartService(svcName, dryRun)
-		}
+	if sftpCloseError != nil {
+		return sftpCloseError
 	}
 
 	return nil
 }
 
-// getRunningContainerLabels gets container labels from running container
-func (m *SSHHostManager) getRunningContainerLabels(serviceName string) (*container.ImageLabels, error) {
-	// Try jumpstarter labels first, then fall back to OCI standard labels
-	result, err := m.runCommand(fmt.Sprintf("podman inspect --format '{{index .Config.Labels \"jumpstarter.version\"}} {{index .Config.Labels \"jumpstarter.revision\"}} {{index .Config.Labels \"org.opencontainers.image.version\"}} {{index .Config.Labels \"org.opencontainers.image.revision\"}}' %s", serviceName))
-	if err != nil {
-		return nil, fmt.Errorf("failed to inspect container %s: %w", serviceName, err)
-	}
-
-	parts := strings.Fields(strings.TrimSpace(result.Stdout))
-	// Pad with empty strings if we got fewer parts
-	for len(parts) < 4 {
-		parts = append(parts, "")
-	}
-
-	jumpstarterVersion := parts[0]  // jumpstarter.version
-	jumpstarterRevision := parts[1] // jumpstarter.revision
-	ociVersion := parts[2]          // org.opencontainers.image.version
-	ociRevision := parts[3]         // org.opencontainers.image.revision
-
-	// Clean up "<no value>" to empty string
-	if jumpstarterVersion == noValuePlaceholder {
-		jumpstarterVersion = ""
-	}
-	if jumpstarterRevision == noValuePlaceholder {
-		jumpstarterRevision = ""
-	}
-	if ociVersion == noValuePlaceholder {
-		ociVersion = ""
-	}
-	if ociRevision == noValuePlaceholder {
-		ociRevision = ""
-	}
-
-	// Use jumpstarter labels if both exist, otherwise fall back to OCI labels
-	var version, revision string
-	if jumpstarterVersion != "" && jumpstarterRevision != "" {
-		version = jumpstarterVersion
-		revision = jumpstarterRevision
-	} else {
-		version = ociVersion
-		revision = ociRevision
-	}
-
-	// Return labels even if only partially available (version OR revision can be empty)
-	return &container.ImageLabels{
-		Version:  version,
-		Revision: revision,
-	}, nil
-}
-
-// RunHostCommand implements the HostManager interface by exposing runCommand
-func (m *SSHHostManager) RunHostCommand(command string) (*CommandResult, error) {
-	return m.runCommand(command)
+// RunsCommands reports that the commands are run through the SSH connection
+func (t *Transport) RunsCommands() bool {
+	return true
 }
 
-// sanitizeDiff removes sensitive information from diff output
-func sanitizeDiff(diff string) string {
-	// Regex patterns to match sensitive fields
-	patterns := []struct {
-		pattern     string
-		replacement string
-	}{
-		// Match patterns like "token: value", "password: value", etc.
-		{`(?i)(token|password|key|secret|api_key|auth_token|bearer_token|access_token|refresh_token|client_secret|private_key|passphrase|credential)(\s*[:=]\s*)([^\s\n]+)`, `${1}${2}<TOKEN>`},
-		// Match patterns like "TOKEN=value", "PASSWORD=value", etc.
-		{`(?i)(TOKEN|PASSWORD|KEY|SECRET|API_KEY|AUTH_TOKEN|BEARER_TOKEN|ACCESS_TOKEN|REFRESH_TOKEN|CLIENT_SECRET|PRIVATE_KEY|PASSPHRASE|CREDENTIAL)(\s*=\s*)([^\s\n]+)`, `${1}${2}<TOKEN>`},
-		// Match patterns in double quotes like "token": "value"
-		{`(?i)(")(token|password|key|secret|api_key|auth_token|bearer_token|access_token|refresh_token|client_secret|private_key|passphrase|credential)("\s*[:=]\s*")([^"]+)(")`, `${1}${2}${3}<TOKEN>${5}`},
-		// Match patterns in single quotes like 'token': 'value'
-		{`(?i)(')(token|password|key|secret|api_key|auth_token|bearer_token|access_token|refresh_token|client_secret|private_key|passphrase|credential)('\s*[:=]\s*')([^']+)(')`, `${1}${2}${3}<TOKEN>${5}`},
-	}
-
-	result := diff
-	for _, p := range patterns {
-		re := regexp.MustCompile(p.pattern)
-		result = re.ReplaceAllString(result, p.replacement)
-	}
-
-	return result
-}
-
-func (m *SSHHostManager) reconcileFile(path string, content string, dryRun bool) (bool, error) {
-	// Check if file exists and read its content
-	file, err := m.sftpClient.Open(path)
+// ReadFile reads a file on the host
+func (t *Transport) ReadFile(path string) (string, error) {
+	if t.sftpClient == nil {
+		return "", fmt.Errorf("sftpClient is not initialized")
+	}
+	file, err := t.sftpClient.Open(path)
 	if err != nil {
-		// File doesn't exist
-		if content == "" {
-			// File doesn't exist and content is empty - nothing to do
-			return false, nil
-		}
-
-		// File doesn't exist and content is not empty - create it
-		if dryRun {
-			_, _ = fmt.Fprintf(m.writer, "            📄 Would create file: %s\n", path)
-			return true, nil
-		}
-
-		// Create parent directories if needed
-		parentDir := filepath.Dir(path)
-		if parentDir != "/" && parentDir != "." {
-			err = m.sftpClient.MkdirAll(parentDir)
-			if err != nil {
-				return false, fmt.Errorf("failed to create parent directories for %s: %w", path, err)
-			}
-		}
-
-		// Create the file
-		newFile, err := m.sftpClient.Create(path)
-		if err != nil {
-			return false, fmt.Errorf("failed to create file %s: %w", path, err)
-		}
-		defer func() {
-			_ = newFile.Close() // nolint:errcheck
-		}()
-
-		_, err = newFile.Write([]byte(content))
-		if err != nil {
-			return false, fmt.Errorf("failed to write content to %s: %w", path, err)
-		}
-
-		_, _ = fmt.Fprintf(m.writer, "            📄 Created file: %s\n", path)
-		return true, nil
+		return "", fmt.Errorf("failed to open %s: %w", path, err)
 	}
-
-	// File exists, read its content
-	existingContent, err := io.ReadAll(file)
-	_ = file.Close() // nolint:errcheck
+	defer func() {
+		_ = file.Close() // nolint:errcheck
+	}()
+	content, err := io.ReadAll(file)
 	if err != nil {
-		_, _ = fmt.Fprintf(m.writer, "Failed to read existing file %s: %v\n", path, err)
-		return false, fmt.Errorf("failed to read existing file %s: %w", path, err)
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
 	}
+	return string(content), nil
+}
 
-	// If content is empty, delete the file
-	if content == "" {
-		if dryRun {
-			_, _ = fmt.Fprintf(m.writer, "            🗑️ Would delete file: %s\n", path)
-			return true, nil
-		}
-		err = m.sftpClient.Remove(path)
-		if err != nil {
-			return false, fmt.Errorf("failed to delete file %s: %w", path, err)
-		}
-		_, _ = fmt.Fprintf(m.writer, "            🗑️  Deleted file: %s\n", path)
-		return true, nil
+// WriteFile writes content to path through a temporary file renamed over it, so an interrupted
+// write never leaves a truncated file. The temporary file gets the mode and ownership attrs
+// before the content is written, the owner and group left as KeepOwner are the ones of the file
+// replaced.
+func (t *Transport) WriteFile(path, content string, attrs manager.FileAttrs) error {
+	if err := t.sftpClient.MkdirAll(filepath.Dir(path)); err != nil {
+		return fmt.Errorf("failed to create parent directories for %s: %w", path, err)
 	}
-
-	// Check if content matches
-	if string(existingContent) == content {
-		// Content matches, no change needed
-		return false, nil
+	if info, err := t.sftpClient.Stat(path); err == nil {
+		attrs = attrs.Resolve(statAttrs(info))
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to stat %s: %w", path, err)
 	}
+	tmpPath := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
 
-	// Content doesn't match, show diff and update the file
-	diff := cmp.Diff(string(existingContent), content)
-	if diff != "" {
-		sanitizedDiff := sanitizeDiff(diff)
-		_, _ = fmt.Fprintf(m.writer, "            📄 Changes for file: %s\n", path)
-		_, _ = fmt.Fprintf(m.writer, "            Diff (-existing +new):\n%s\n", sanitizedDiff)
+	file, err := t.sftpClient.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file for %s: %w", path, err)
 	}
-
-	if dryRun {
-		_, _ = fmt.Fprintf(m.writer, "            ✏️ Would update file: %s\n", path)
-		return true, nil
+	err = writeTempFile(file, content, attrs)
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to close: %w", closeErr)
 	}
-
-	updateFile, err := m.sftpClient.OpenFile(path, os.O_WRONLY|os.O_TRUNC)
-	if err != nil {
-		return false, fmt.Errorf("failed to open file %s for writing: %w", path, err)
+	if err == nil {
+		err = t.sftpClient.PosixRename(tmpPath, path)
 	}
-	defer func() {
-		_ = updateFile.Close() // nolint:errcheck
-	}()
-
-	_, err = updateFile.Write([]byte(content))
 	if err != nil {
-		_, _ = fmt.Fprintf(m.writer, "Failed to write updated content to %s: %v\n", path, err)
-		return false, fmt.Errorf("failed to write updated content to %s: %w", path, err)
+		_ = t.sftpClient.Remove(tmpPath) // nolint:errcheck
+		return fmt.Errorf("failed to write %s: %w", path, err)
 	}
-
-	_, _ = fmt.Fprintf(m.writer, "            ✏️ Updated file: %s\n", path)
-	return true, nil
+	return nil
 }
 
-// GetBootcStatus checks the bootc status and returns the appropriate BootcStatus enum
-func (m *SSHHostManager) GetBootcStatus() BootcStatus {
-	// Check if bootc upgrade service is already running
-	statusCmd, _ := m.RunHostCommand("systemctl is-active bootc-fetch-apply-updates.service bootc-fetch-apply-updates.timer")
-	if statusCmd != nil {
-		statuses := strings.Fields(statusCmd.Stdout)
-		if len(statuses) == 2 &&
-			(statuses[0] == systemdStateActive || statuses[0] == "activating" ||
-				statuses[1] == systemdStateActive || statuses[1] == "activating") {
-			return BOOTC_UPDATING
-		}
+// writeTempFile sets the attributes of a temporary file and writes its content
+func writeTempFile(file *sftp.File, content string, attrs manager.FileAttrs) error {
+	if err := file.Chmod(attrs.Mode); err != nil {
+		return fmt.Errorf("failed to set mode: %w", err)
 	}
-
-	// Check booted image
-	bootcStdout, err := m.RunHostCommand("[ -f /run/ostree-booted ] && bootc upgrade --check")
-	if err == nil && bootcStdout != nil && bootcStdout.ExitCode == 0 && bootcStdout.Stdout != "" {
-		if strings.HasPrefix(bootcStdout.Stdout, "No changes") {
-			return BOOTC_UP_TO_DATE
-		} else {
-			return BOOTC_WILL_UPDATE
-		}
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat: %w", err)
 	}
-
-	return BOOTC_NOT_MANAGED
-}
-
-// HandleBootcUpgrade handles bootc upgrade checking and execution
-func (m *SSHHostManager) HandleBootcUpgrade(dryRun bool) error {
-	status := m.GetBootcStatus()
-
-	switch status {
-	case BOOTC_UPDATING:
-		_, _ = fmt.Fprintf(m.writer, "    ⚠️  Bootc upgrade in progress\n")
-	case BOOTC_UP_TO_DATE:
-		_, _ = fmt.Fprintf(m.writer, "    ✅ Bootc image is up to date\n")
-	case BOOTC_WILL_UPDATE:
-		if dryRun {
-			_, _ = fmt.Fprintf(m.writer, "    📄 Would upgrade bootc image\n")
-		} else {
-			// Trigger bootc upgrade timer now. Assuming it uses manual activation (e.g. OnActiveSec=0, RandomizedDelaySec=1h, RemainAfterElapse=false)
-			_, err := m.RunHostCommand("systemctl restart bootc-fetch-apply-updates.timer")
-			if err != nil {
-				return fmt.Errorf("error triggering bootc upgrade service: %w", err)
-			}
-			_, _ = fmt.Fprintf(m.writer, "    ✅ Bootc upgrade started\n")
-			return nil
-		}
-	case BOOTC_NOT_MANAGED:
-		_, _ = fmt.Fprintf(m.writer, "    ℹ️ Not a bootc managed host\n")
+	if err := chown(file.Chown, statAttrs(info), attrs); err != nil {
+		return err
+	}
+	if _, err := file.Write([]byte(content)); err != nil {
+		return err
 	}
 	return nil
 }
 
-func (m *SSHHostManager) createSshClient() (*ssh.Client, error) {
-
-	port := 22
-
-	if m.ExporterHost.Spec.Management.SSH.Port != 0 {
-		port = m.ExporterHost.Spec.Management.SSH.Port
-	}
-
-	// Create SSH client authentication methods
-	auth := []ssh.AuthMethod{}
-	if m.ExporterHost.Spec.Management.SSH.KeyFile != "" {
-		key, err := os.ReadFile(m.ExporterHost.Spec.Management.SSH.KeyFile)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read SSH key file: %w", err)
-		}
-		var signer ssh.Signer
-		if m.ExporterHost.Spec.Management.SSH.SSHKeyPassword != "" {
-			signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(m.ExporterHost.Spec.Management.SSH.SSHKeyPassword))
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse encrypted SSH private key from file: %w", err)
-			}
-		} else {
-			signer, err = ssh.ParsePrivateKey(key)
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse SSH private key from file: %w", err)
-			}
-		}
-		auth = append(auth, ssh.PublicKeys(signer))
-	}
-
-	if m.ExporterHost.Spec.Management.SSH.SSHKeyData != "" {
-		var signer ssh.Signer
-		var err error
-		if m.ExporterHost.Spec.Management.SSH.SSHKeyPassword != "" {
-			signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(m.ExporterHost.Spec.Management.SSH.SSHKeyData), []byte(m.ExporterHost.Spec.Management.SSH.SSHKeyPassword))
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse encrypted SSH private key from sshKeyData: %w", err)
-			}
-		} else {
-			signer, err = ssh.ParsePrivateKey([]byte(m.ExporterHost.Spec.Management.SSH.SSHKeyData))
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse SSH private key from sshKeyData: %w", err)
-			}
-		}
-		auth = append(auth, ssh.PublicKeys(signer))
+// chown changes the ownership current of a file to the one declared in attrs, the owner and group
+// left as KeepOwner are kept. Nothing is changed when the ownership already matches, changing it
+// needs root.
+func chown(chownFunc func(uid, gid int) error, current, attrs manager.FileAttrs) error {
+	want := attrs.Resolve(current)
+	if want.UID == current.UID && want.GID == current.GID {
+		return nil
 	}
-
-	if m.ExporterHost.Spec.Management.SSH.Password != "" {
-		auth = append(auth, ssh.Password(m.ExporterHost.Spec.Management.SSH.Password))
+	if err := chownFunc(want.UID, want.GID); err != nil {
+		return fmt.Errorf("failed to set owner %d:%d: %w", want.UID, want.GID, err)
 	}
+	return nil
+}
 
-	// Check if SSH agent is running and use it if available
-	agentSocket := os.Getenv("SSH_AUTH_SOCK")
-	if agentSocket != "" {
-		// Connect to the agent's socket.
-		conn, err := net.Dial("unix", agentSocket)
-		if err != nil {
-			log.Printf("Failed to connect to SSH agent: %v", err)
-		} else {
-			defer conn.Close() // nolint:errcheck
-
-			// Create a new agent client.
-			agentClient := agent.NewClient(conn)
-
-			auth = append(auth, ssh.PublicKeysCallback(agentClient.Signers))
-		}
+// statAttrs returns the mode and ownership of a file, its ownership is KeepOwner when unknown
+func statAttrs(info os.FileInfo) manager.FileAttrs {
+	attrs := manager.FileAttrs{Mode: info.Mode().Perm(), UID: manager.KeepOwner, GID: manager.KeepOwner}
+	if stat, ok := info.Sys().(*sftp.FileStat); ok {
+		attrs.UID, attrs.GID = int(stat.UID), int(stat.GID)
 	}
+	return attrs
+}
 
-	config := &ssh.ClientConfig{
-		User:            m.ExporterHost.Spec.Management.SSH.User,
-		Auth:            auth,
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(), // Use a secure callback in production
-		Timeout:         15 * time.Second,
+// RemoveFile removes a file on the host
+func (t *Transport) RemoveFile(path string) error {
+	if err := t.sftpClient.Remove(path); err != nil {
+		return fmt.Errorf("failed to delete file %s: %w", path, err)
 	}
+	return nil
+}
 
-	client, err := ssh.Dial("tcp", fmt.Sprintf("%s:%d", m.ExporterHost.Spec.Management.SSH.Host, port), config)
+// FileAttrs returns the mode and ownership of a file on the host
+func (t *Transport) FileAttrs(path string) (manager.FileAttrs, error) {
+	info, err := t.sftpClient.Stat(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to SSH host %s:%d: %w", m.ExporterHost.Spec.Management.SSH.Host, port, err)
+		return manager.FileAttrs{}, fmt.Errorf("failed to stat %s: %w", path, err)
 	}
-	return client, nil
-
+	return statAttrs(info), nil
 }
 
-func (m *SSHHostManager) Close() error {
-	var sftpCloseError error = nil
-	var sshCloseError error = nil
-	if m.sftpClient != nil {
-		sftpCloseError = m.sftpClient.Close()
-	}
-	if m.sshClient != nil {
-		sshCloseError = m.sshClient.Close()
+// SetFileAttrs sets the mode and ownership of a file on the host, it is only chowned when attrs
+// declare another owner or group
+func (t *Transport) SetFileAttrs(path string, attrs manager.FileAttrs) error {
+	current, err := t.FileAttrs(path)
+	if err != nil {
+		return err
 	}
-	if sshCloseError != nil {
-		return sshCloseError
+	if err := t.sftpClient.Chmod(path, attrs.Mode); err != nil {
+		return fmt.Errorf("failed to set mode of %s: %w", path, err)
 	}
-
-	if sftpCloseError != nil {
-		return sftpCloseError
+	chownPath := func(uid, gid int) error { return t.sftpClient.Chown(path, uid, gid) }
+	if err := chown(chownPath, current, attrs); err != nil {
+		return fmt.Errorf("failed to set owner of %s: %w", path, err)
 	}
-
 	return nil
 }
-
-// SetWriter sets the output writer for this SSH host manager.
-// By default, output goes to os.Stdout.
-func (m *SSHHostManager) SetWriter(w io.Writer) {
-	m.writer = w
-}
@@ -1,18 +1,23 @@
 package ssh
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/jumpstarter-dev/jumpstarter-lab-config/api/v1alpha1"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/exporter/manager"
+	"github.com/pkg/sftp"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -65,7 +70,7 @@ func createTestExporterHost(name string) *v1alpha1.ExporterHost {
 	}
 }
 
-func TestSSHHostManagerCreation(t *testing.T) {
+func TestConnect(t *testing.T) {
 	tests := []struct {
 		name        string
 		setupHost   func() *v1alpha1.ExporterHost
@@ -97,7 +102,7 @@ func TestSSHHostManagerCreation(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			host := tt.setupHost()
-			_, err := NewSSHHostManager(host)
+			_, err := Connect(context.Background(), host, nil, nil)
 
 			if tt.expectError {
 				if err == nil {
@@ -236,11 +241,11 @@ func TestAuthMethodCreation(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			host := tt.setupHost(t)
 
-			sshManager := &SSHHostManager{
-				ExporterHost: host,
+			transport := &Transport{
+				exporterHost: host,
 			}
 
-			_, err := sshManager.createSshClient()
+			_, err := transport.createSshClient()
 
 			if tt.expectError {
 				if err == nil {
@@ -295,11 +300,11 @@ func TestSSHCredentialsValidation(t *testing.T) {
 			host.Spec.Management.SSH.Port = tt.port
 			host.Spec.Management.SSH.Password = testPassword // Add auth method
 
-			sshManager := &SSHHostManager{
-				ExporterHost: host,
+			transport := &Transport{
+				exporterHost: host,
 			}
 
-			_, err := sshManager.createSshClient()
+			_, err := transport.createSshClient()
 
 			// We expect connection to fail, but we can verify the error message contains the expected host:port
 			if err != nil {
@@ -312,78 +317,66 @@ func TestSSHCredentialsValidation(t *testing.T) {
 	}
 }
 
-func TestSSHHostManagerInterface(t *testing.T) {
-	host := createTestExporterHost("interface-test")
-	host.Spec.Management.SSH.Password = testPassword
+func TestConnectCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
 
-	// Test that SSHHostManager implements HostManager interface
-	var manager HostManager
-	sshManager := &SSHHostManager{
-		ExporterHost: host,
-	}
+	t.Run("no connection is attempted", func(t *testing.T) {
+		host := createTestExporterHost("cancelled")
+		host.Spec.Management.SSH.Password = testPassword
 
-	manager = sshManager
+		_, err := Connect(ctx, host, nil, nil)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
 
-	// Test interface methods are available
-	_, err := manager.Status()
-	if err != nil && !strings.Contains(err.Error(), "SSH host is not configured") && !strings.Contains(err.Error(), "sshClient is not initialized") {
-		// We expect either success or the specific SSH host error
-		t.Errorf("Unexpected error from Status method: %v", err)
-	}
+// newInMemorySFTPClient returns a sftp client backed by an in-memory file system, owned by root
+func newInMemorySFTPClient(t *testing.T) *sftp.Client {
+	t.Helper()
+	return newInMemorySFTPClientAs(t, 0)
 }
 
-func TestSanitizeDiff(t *testing.T) {
-	tests := []struct {
-		name     string
-		input    string
-		expected string
-	}{
-		{
-			name:     "sanitize token with colon",
-			input:    "  token: abc123def456\n+ token: xyz789ghi012",
-			expected: "  token: <TOKEN>\n+ token: <TOKEN>",
-		},
-		{
-			name:     "sanitize password with equals",
-			input:    "- PASSWORD=oldpassword\n+ PASSWORD=newpassword",
-			expected: "- PASSWORD=<TOKEN>\n+ PASSWORD=<TOKEN>",
-		},
-		{
-			name:     "sanitize api_key with spaces",
-			input:    "  api_key : sk-1234567890abcdef\n+ api_key : sk-fedcba0987654321",
-			expected: "  api_key : <TOKEN>\n+ api_key : <TOKEN>",
-		},
-		{
-			name:     "sanitize quoted fields",
-			input:    `  "secret": "my-secret-value"\n+ "secret": "new-secret-value"`,
-			expected: `  "secret": "<TOKEN>"\n+ "secret": "<TOKEN>"`,
-		},
-		{
-			name:     "sanitize single quoted fields",
-			input:    `  'private_key': 'rsa-key-content'\n+ 'private_key': 'new-rsa-key-content'`,
-			expected: `  'private_key': '<TOKEN>'\n+ 'private_key': '<TOKEN>'`,
-		},
-		{
-			name:     "preserve non-sensitive fields",
-			input:    "  host: example.com\n+ host: new-example.com\n  port: 22\n+ port: 2222",
-			expected: "  host: example.com\n+ host: new-example.com\n  port: 22\n+ port: 2222",
-		},
-		{
-			name:     "mixed sensitive and non-sensitive",
-			input:    "  host: example.com\n  token: abc123\n+ host: new-example.com\n+ token: xyz789",
-			expected: "  host: example.com\n  token: <TOKEN>\n+ host: new-example.com\n+ token: <TOKEN>",
-		},
-		{
-			name:     "case insensitive matching",
-			input:    "  TOKEN: abc123\n  Token: def456\n+ token: xyz789",
-			expected: "  TOKEN: <TOKEN>\n  Token: <TOKEN>\n+ token: <TOKEN>",
-		},
-	}
+// newInMemorySFTPClientAs creates a client of an in-memory sftp server logged in as the user uid
+func newInMemorySFTPClientAs(t *testing.T, uid int) *sftp.Client {
+	t.Helper()
+	serverConn, clientConn := net.Pipe()
+	server := sftp.NewRequestServer(serverConn, memAttrsHandlers(uid))
+	go func() {
+		_ = server.Serve()
+	}()
+	client, err := sftp.NewClientPipe(clientConn, clientConn)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = client.Close()
+		_ = server.Close()
+	})
+	return client
+}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := sanitizeDiff(tt.input)
-			assert.Equal(t, tt.expected, result)
-		})
-	}
+func TestTransport_Run(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+	server := startTestCommandServer(t, func(command string) (string, uint32) {
+		if command == "systemctl is-active exporter" {
+			return "inactive\n", 3
+		}
+		return "ok\n", 0
+	})
+	transport := &Transport{exporterHost: server.exporterHost(t), ctx: context.Background()}
+	client, err := transport.createSshClient()
+	require.NoError(t, err)
+	transport.sshClient = client
+	defer func() {
+		_ = transport.Close()
+	}()
+
+	assert.True(t, transport.RunsCommands())
+	result, err := transport.Run("true")
+	require.NoError(t, err)
+	assert.Equal(t, &manager.CommandResult{Stdout: "ok\n"}, result)
+
+	result, err = transport.Run("systemctl is-active exporter")
+	require.Error(t, err, "a non-zero exit status is an error")
+	require.NotNil(t, result)
+	assert.Equal(t, 3, result.ExitCode)
+	assert.Equal(t, "inactive\n", result.Stdout)
 }
@@ -2,15 +2,22 @@ package template
 
 import (
 	"fmt"
+	"strconv"
 
 	v1alpha1 "github.com/jumpstarter-dev/jumpstarter-lab-config/api/v1alpha1"
 	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/config"
 	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/templating"
 )
 
+// DryRunToken is the token rendered for the exporters which aren't created yet, on dry run
+const DryRunToken = "<dry-run-token>"
+
 type ServiceParameters struct {
+	// TlsCA is the base64 encoded PEM CA bundle of the controller
 	TlsCA string
-	Token string
+	// TlsInsecure disables the TLS verification of the controller
+	TlsInsecure bool
+	Token       string
 }
 type ExporterInstanceTemplater struct {
 	config                 *config.Config
@@ -38,6 +45,7 @@ func (e *ExporterInstanceTemplater) SetServiceParameters(serviceParameters Servi
 func (s *ServiceParameters) Parameters() *templating.Parameters {
 	parameters := templating.NewParameters("service")
 	parameters.Set("tls_ca", s.TlsCA)
+	parameters.Set("tls_insecure", strconv.FormatBool(s.TlsInsecure))
 	parameters.Set("token", s.Token)
 	return parameters
 }
@@ -0,0 +1,113 @@
+package instance
+
+import (
+	"context"
+	"fmt"
+	"maps"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/plan"
+)
+
+// fieldManager is the server-side apply field manager used for every object applied by this tool
+const fieldManager = "jumpstarter-lab-config"
+
+// SetForceConflicts makes server-side apply take ownership of fields managed by other field managers
+func (i *Instance) SetForceConflicts(force bool) {
+	i.forceConflicts = force
+}
+
+// desiredObjectMeta builds the metadata applied for an object from the configuration,
+// it only contains the fields owned by this tool so fields set by others are preserved
+func (i *Instance) desiredObjectMeta(src *metav1.ObjectMeta) metav1.ObjectMeta {
+	meta := metav1.ObjectMeta{
+		Name:      src.Name,
+		Namespace: src.Namespace,
+		Labels:    maps.Clone(src.Labels),
+	}
+	i.prepareMetadata(&meta, src.Annotations)
+	return meta
+}
+
+// applyOptions returns the patch options for a server-side apply
+func (i *Instance) applyOptions(extra ...client.PatchOption) []client.PatchOption {
+	opts := []client.PatchOption{client.FieldOwner(fieldManager)}
+	if i.forceConflicts {
+		opts = append(opts, client.ForceOwnership)
+	}
+	return append(opts, extra...)
+}
+
+// applyObject server-side applies desired over the live object. The diff is computed against the
+// result of a server-side dry-run of the same apply, so it reflects what the server would persist
+// (defaulting, fields owned by other managers) instead of a local guess.
+func (i *Instance) applyObject(ctx context.Context, live, desired client.Object, objType string) error {
+	if err := i.setGroupVersionKind(desired); err != nil {
+		return err
+	}
+
+	dryRunObj := desired.DeepCopyObject().(client.Object)
+	if err := i.client.Patch(ctx, dryRunObj, client.Apply, i.applyOptions(client.DryRunAll)...); err != nil {
+		return i.applyError(objType, desired.GetName(), err)
+	}
+
+	changed := i.checkAndPrintDiff(live, dryRunObj, objType, desired.GetName(), i.dryRun)
+	if !changed {
+		return nil
+	}
+	if err := i.recordChange(objType, plan.ActionUpdate, live, dryRunObj); err != nil {
+		return err
+	}
+	if i.dryRun {
+		return nil
+	}
+
+	i.printf("📝 [%s] Applying %s %s in namespace %s\n", i.config.Name, objType, desired.GetName(), desired.GetNamespace())
+	if err := i.client.Patch(ctx, desired, client.Apply, i.applyOptions()...); err != nil {
+		return i.applyError(objType, desired.GetName(), err)
+	}
+	return nil
+}
+
+// createObject creates a new object through server-side apply, so the created fields are owned by our field manager
+func (i *Instance) createObject(ctx context.Context, obj client.Object, objType string) error {
+	if err := i.recordChange(objType, plan.ActionCreate, nil, obj); err != nil {
+		return err
+	}
+	if i.dryRun {
+		i.printf("➕ [%s] dry run: Would create %s %s in namespace %s\n", i.config.Name, objType, obj.GetName(), obj.GetNamespace())
+		return nil
+	}
+	i.printf("➕ [%s] Creating %s %s in namespace %s\n", i.config.Name, objType, obj.GetName(), obj.GetNamespace())
+
+	if err := i.setGroupVersionKind(obj); err != nil {
+		return err
+	}
+	if err := i.client.Patch(ctx, obj, client.Apply, i.applyOptions()...); err != nil {
+		return i.applyError(objType, obj.GetName(), err)
+	}
+	return nil
+}
+
+// setGroupVersionKind sets the type information required in server-side apply requests
+func (i *Instance) setGroupVersionKind(obj client.Object) error {
+	gvk, err := apiutil.GVKForObject(obj, i.client.Scheme())
+	if err != nil {
+		return fmt.Errorf("failed to get GroupVersionKind for %s: %w", obj.GetName(), err)
+	}
+	obj.GetObjectKind().SetGroupVersionKind(gvk)
+	return nil
+}
+
+// applyError reports field manager conflicts explicitly, they are not retried
+func (i *Instance) applyError(objType, name string, err error) error {
+	if apierrors.IsConflict(err) {
+		return fmt.Errorf("%s %s has fields managed by another field manager, "+
+			"fix the conflicting manager or use --force-conflicts to take ownership: %w", objType, name, err)
+	}
+	return fmt.Errorf("failed to apply %s %s: %w", objType, name, err)
+}
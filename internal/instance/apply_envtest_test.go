@@ -0,0 +1,167 @@
+package instance
+
+import (
+	"context"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/jumpstarter-dev/jumpstarter-controller/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+
+	v1alphaConfig "github.com/jumpstarter-dev/jumpstarter-lab-config/api/v1alpha1"
+)
+
+// clientCRD is a minimal Client CRD, enough for the API server to track the field ownership of
+// the client spec
+var clientCRD = &apiextensionsv1.CustomResourceDefinition{
+	ObjectMeta: metav1.ObjectMeta{Name: "clients.jumpstarter.dev"},
+	Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+		Group: v1alpha1.GroupVersion.Group,
+		Names: apiextensionsv1.CustomResourceDefinitionNames{
+			Plural: "clients", Singular: "client", Kind: "Client", ListKind: "ClientList",
+		},
+		Scope: apiextensionsv1.NamespaceScoped,
+		Versions: []apiextensionsv1.CustomResourceDefinitionVersion{{
+			Name:    v1alpha1.GroupVersion.Version,
+			Served:  true,
+			Storage: true,
+			Subresources: &apiextensionsv1.CustomResourceSubresources{
+				Status: &apiextensionsv1.CustomResourceSubresourceStatus{},
+			},
+			Schema: &apiextensionsv1.CustomResourceValidation{
+				OpenAPIV3Schema: &apiextensionsv1.JSONSchemaProps{
+					Type: "object",
+					Properties: map[string]apiextensionsv1.JSONSchemaProps{
+						"spec": {Type: "object", Properties: map[string]apiextensionsv1.JSONSchemaProps{
+							"username": {Type: "string"},
+						}},
+						"status": {Type: "object", XPreserveUnknownFields: ptr.To(true)},
+					},
+				},
+			},
+		}},
+	},
+}
+
+// newEnvtestInstance creates an Instance backed by a real API server, the test is skipped unless
+// the envtest binaries are installed (KUBEBUILDER_ASSETS is set by make test)
+func newEnvtestInstance(t *testing.T) *Instance {
+	t.Helper()
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		t.Skip("KUBEBUILDER_ASSETS is not set, run make setup-envtest")
+	}
+
+	s := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(s))
+	require.NoError(t, v1alpha1.AddToScheme(s))
+
+	env := &envtest.Environment{
+		CRDs:                  []*apiextensionsv1.CustomResourceDefinition{clientCRD.DeepCopy()},
+		ErrorIfCRDPathMissing: true,
+	}
+	cfg, err := env.Start()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = env.Stop() })
+
+	c, err := client.New(cfg, client.Options{Scheme: s})
+	require.NoError(t, err)
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}}
+	require.NoError(t, c.Create(context.Background(), ns))
+
+	return &Instance{
+		client: c,
+		config: &v1alphaConfig.JumpstarterInstance{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-instance"},
+			Spec:       v1alphaConfig.JumpstarterInstanceSpec{Namespace: "test-ns"},
+		},
+	}
+}
+
+// managers returns the field managers of obj, with their operation
+func managers(obj client.Object) []string {
+	names := []string{}
+	for _, entry := range obj.GetManagedFields() {
+		names = append(names, entry.Manager+"/"+string(entry.Operation))
+	}
+	return names
+}
+
+func TestApplyObject_Envtest(t *testing.T) {
+	ctx := context.Background()
+	inst := newEnvtestInstance(t)
+	key := client.ObjectKey{Namespace: "test-ns", Name: "alice"}
+	get := func() *v1alpha1.Client {
+		live := &v1alpha1.Client{}
+		require.NoError(t, inst.client.Get(ctx, key, live))
+		return live
+	}
+
+	t.Run("created objects are owned by our field manager", func(t *testing.T) {
+		require.NoError(t, inst.createClient(ctx, testClient("bob", "", "bob")))
+
+		live := &v1alpha1.Client{}
+		require.NoError(t, inst.client.Get(ctx, client.ObjectKey{Namespace: "test-ns", Name: "bob"}, live))
+		assert.Equal(t, []string{fieldManager + "/Apply"}, managers(live))
+		assert.Equal(t, DefaultManagedBy, live.Labels[ManagedByLabel])
+	})
+
+	// objects written by earlier versions of this tool, with client.Update
+	legacy := testClient("alice", "test-ns", "alice")
+	inst.prepareMetadata(&legacy.ObjectMeta, nil)
+	require.NoError(t, inst.client.Create(ctx, legacy, client.FieldOwner("legacy-updater")))
+
+	t.Run("unchanged objects are left alone", func(t *testing.T) {
+		before := get()
+		require.NoError(t, inst.updateClient(ctx, before, testClient("alice", "", "alice")))
+		assert.Equal(t, before.ResourceVersion, get().ResourceVersion)
+	})
+
+	t.Run("fields owned by another manager conflict", func(t *testing.T) {
+		err := inst.updateClient(ctx, get(), testClient("alice", "", "alice2"))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "--force-conflicts")
+		assert.Equal(t, "alice", *get().Spec.Username)
+	})
+
+	t.Run("dry run reports the conflict too", func(t *testing.T) {
+		inst.dryRun = true
+		defer func() { inst.dryRun = false }()
+
+		err := inst.updateClient(ctx, get(), testClient("alice", "", "alice2"))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "--force-conflicts")
+	})
+
+	t.Run("force conflicts takes ownership", func(t *testing.T) {
+		inst.SetForceConflicts(true)
+		defer inst.SetForceConflicts(false)
+
+		require.NoError(t, inst.updateClient(ctx, get(), testClient("alice", "", "alice2")))
+		live := get()
+		assert.Equal(t, "alice2", *live.Spec.Username)
+		assert.True(t, slices.Contains(managers(live), fieldManager+"/Apply"))
+	})
+
+	t.Run("dry run does not persist", func(t *testing.T) {
+		inst.dryRun = true
+		defer func() { inst.dryRun = false }()
+
+		require.NoError(t, inst.updateClient(ctx, get(), testClient("alice", "", "alice3")))
+		assert.Equal(t, "alice2", *get().Spec.Username)
+	})
+
+	t.Run("owned fields apply without conflicts", func(t *testing.T) {
+		require.NoError(t, inst.updateClient(ctx, get(), testClient("alice", "", "alice3")))
+		assert.Equal(t, "alice3", *get().Spec.Username)
+	})
+}
@@ -0,0 +1,135 @@
+package instance
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/jumpstarter-dev/jumpstarter-controller/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/config"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/plan"
+)
+
+func testClient(name, namespace, username string) *v1alpha1.Client {
+	return &v1alpha1.Client{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec:       v1alpha1.ClientSpec{Username: &username},
+	}
+}
+
+func TestUpdateClient_ServerSideApply(t *testing.T) {
+	t.Run("unchanged objects are only dry-run applied", func(t *testing.T) {
+		existing := testClient("alice", "test-ns", "alice")
+		inst, recorder := newTestInstanceWithApply(t, false, false, existing)
+		inst.prepareMetadata(&existing.ObjectMeta, nil)
+		require.NoError(t, inst.client.Update(context.Background(), existing))
+
+		require.NoError(t, inst.updateClient(context.Background(), existing, testClient("alice", "", "alice")))
+		require.Len(t, recorder.calls, 1)
+		assert.True(t, recorder.calls[0].dryRun)
+		assert.Empty(t, recorder.appliedNames())
+	})
+
+	t.Run("changed objects are applied with our field manager", func(t *testing.T) {
+		existing := testClient("alice", "test-ns", "alice")
+		inst, recorder := newTestInstanceWithApply(t, false, false, existing)
+
+		require.NoError(t, inst.updateClient(context.Background(), existing, testClient("alice", "", "alice2")))
+		assert.Equal(t, []string{"alice"}, recorder.appliedNames())
+		for _, call := range recorder.calls {
+			assert.Equal(t, fieldManager, call.owner)
+			assert.False(t, call.force)
+		}
+
+		updated := &v1alpha1.Client{}
+		require.NoError(t, inst.client.Get(context.Background(), client.ObjectKey{Namespace: "test-ns", Name: "alice"}, updated))
+		assert.Equal(t, "alice2", *updated.Spec.Username)
+	})
+
+	t.Run("dry run does not apply", func(t *testing.T) {
+		existing := testClient("alice", "test-ns", "alice")
+		inst, recorder := newTestInstanceWithApply(t, true, false, existing)
+
+		require.NoError(t, inst.updateClient(context.Background(), existing, testClient("alice", "", "alice2")))
+		require.Len(t, recorder.calls, 1)
+		assert.True(t, recorder.calls[0].dryRun)
+	})
+
+	t.Run("force conflicts takes ownership", func(t *testing.T) {
+		existing := testClient("alice", "test-ns", "alice")
+		inst, recorder := newTestInstanceWithApply(t, false, false, existing)
+		inst.SetForceConflicts(true)
+
+		require.NoError(t, inst.updateClient(context.Background(), existing, testClient("alice", "", "alice2")))
+		for _, call := range recorder.calls {
+			assert.True(t, call.force)
+		}
+	})
+}
+
+func TestApplyObject_ConflictIsReported(t *testing.T) {
+	existing := testClient("alice", "test-ns", "alice")
+	inst, recorder := newTestInstanceWithApply(t, false, false, existing)
+	recorder.applyErr = apierrors.NewConflict(schema.GroupResource{Group: "jumpstarter.dev", Resource: "clients"},
+		"alice", assert.AnError)
+
+	err := inst.updateClient(context.Background(), existing, testClient("alice", "", "alice2"))
+	require.Error(t, err)
+	assert.True(t, apierrors.IsConflict(err))
+	assert.Contains(t, err.Error(), "--force-conflicts")
+	assert.Len(t, recorder.calls, 1, "conflicts must not be retried")
+}
+
+func TestCreateClient_ServerSideApply(t *testing.T) {
+	inst, recorder := newTestInstanceWithApply(t, false, false)
+	cfgClient := testClient("bob", "", "bob")
+
+	require.NoError(t, inst.createClient(context.Background(), cfgClient))
+	assert.Equal(t, []string{"bob"}, recorder.appliedNames())
+	assert.Empty(t, cfgClient.Namespace, "the configuration object must not be modified")
+
+	created := &v1alpha1.Client{}
+	require.NoError(t, inst.client.Get(context.Background(), client.ObjectKey{Namespace: "test-ns", Name: "bob"}, created))
+	assert.Equal(t, managedByAnnotation, created.Annotations["managed-by"])
+}
+
+func TestSyncClients_RecordsChanges(t *testing.T) {
+	existing := testClient("alice", "test-ns", "alice")
+	cfg := &config.Config{Loaded: &config.LoadedLabConfig{Clients: map[string]*v1alpha1.Client{
+		"alice": testClient("alice", "", "alice2"),
+		"bob":   testClient("bob", "", "bob"),
+	}}}
+
+	record := func() *plan.Plan {
+		inst := newTestInstance(t, true, true, existing.DeepCopy(), ownedClient("gone", DefaultManagedBy))
+		inst.SetOutput(&bytes.Buffer{})
+		changes := plan.NewRecorder()
+		inst.SetRecorder(changes)
+		require.NoError(t, inst.SyncClients(context.Background(), cfg, nil))
+		return changes.Plan(plan.Options{})
+	}
+
+	p := record()
+	actions := map[string]plan.Action{}
+	for _, change := range p.Changes {
+		assert.Equal(t, plan.TargetInstance, change.Target)
+		assert.Equal(t, "test-instance", change.Scope)
+		assert.Equal(t, "client", change.Kind)
+		actions[change.Name] = change.Action
+	}
+	assert.Equal(t, map[string]plan.Action{
+		"alice": plan.ActionUpdate,
+		"bob":   plan.ActionCreate,
+		"gone":  plan.ActionDelete,
+	}, actions)
+
+	// the same live state gives the same plan
+	assert.Empty(t, p.Drift(record()))
+}
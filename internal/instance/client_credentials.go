@@ -0,0 +1,175 @@
+package instance
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/jumpstarter-dev/jumpstarter-controller/api/v1alpha1"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/config"
+)
+
+// clientConfigFile is the jumpstarter client configuration file format,
+// as found in ~/.config/jumpstarter/clients/<alias>.yaml
+type clientConfigFile struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	Metadata   struct {
+		Namespace string `yaml:"namespace"`
+		Name      string `yaml:"name"`
+	} `yaml:"metadata"`
+	Endpoint string `yaml:"endpoint"`
+	TLS      struct {
+		CA       string `yaml:"ca"`
+		Insecure bool   `yaml:"insecure"`
+	} `yaml:"tls"`
+	Token   string `yaml:"token"`
+	Drivers struct {
+		Allow  []string `yaml:"allow"`
+		Unsafe bool     `yaml:"unsafe"`
+	} `yaml:"drivers"`
+}
+
+// ClientConfigFileName returns the name of the client config file written for a client of this instance
+func (i *Instance) ClientConfigFileName(clientName string) string {
+	return fmt.Sprintf("%s-%s.yaml", clientName, i.config.Name)
+}
+
+// GetClientConfig returns a ready to use jumpstarter client config file for the named client
+func (i *Instance) GetClientConfig(ctx context.Context, clientName string) ([]byte, error) {
+	if len(i.config.Spec.Endpoints) == 0 {
+		return nil, fmt.Errorf("[%s] jumpstarter instance has no endpoints for client %s", i.config.Name, clientName)
+	}
+
+	token, err := i.getClientToken(ctx, clientName)
+	if err != nil {
+		return nil, err
+	}
+
+	controllerTLS, err := i.GetControllerTLS(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	clientConfig := clientConfigFile{
+		APIVersion: "jumpstarter.dev/v1alpha1",
+		Kind:       "ClientConfig",
+		Endpoint:   i.config.Spec.Endpoints[0],
+		Token:      token,
+	}
+	clientConfig.Metadata.Namespace = i.config.Spec.Namespace
+	clientConfig.Metadata.Name = clientName
+	clientConfig.TLS.CA = controllerTLS.CA
+	clientConfig.TLS.Insecure = controllerTLS.Insecure
+	clientConfig.Drivers.Allow = []string{}
+
+	out, err := yaml.Marshal(&clientConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal client config for %s: %w", clientName, err)
+	}
+	return out, nil
+}
+
+// WriteClientConfigs writes the client config files of the clients synced to this instance into outputDir
+func (i *Instance) WriteClientConfigs(ctx context.Context, cfg *config.Config, filter *regexp.Regexp, outputDir string) error {
+	configClientMap, _ := applyClientFilter(filter, nil, cfg.Loaded.GetClientsForJumpstarterInstance(i.config))
+
+	for name := range configClientMap {
+		path := filepath.Join(outputDir, i.ClientConfigFileName(name))
+		if i.dryRun {
+			i.printf("🔑 [%s] dry run: Would write client config for %s to %s\n", i.config.Name, name, path)
+			continue
+		}
+
+		if err := i.waitClientCredentials(ctx, name); err != nil {
+			return fmt.Errorf("[%s] failed to wait for client credentials for %s: %w", i.config.Name, name, err)
+		}
+		clientConfig, err := i.GetClientConfig(ctx, name)
+		if err != nil {
+			return err
+		}
+		if err := WriteClientConfigFile(path, clientConfig); err != nil {
+			return err
+		}
+		i.printf("🔑 [%s] Wrote client config for %s to %s\n", i.config.Name, name, path)
+	}
+	return nil
+}
+
+// WriteClientConfigFile writes a client config file readable only by the current user, it contains a token
+func WriteClientConfigFile(path string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+	// os.WriteFile keeps the mode of an existing file, the token would stay readable by others
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to write client config %s: %w", path, err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	if err := file.Chmod(0600); err != nil {
+		return fmt.Errorf("failed to restrict the permissions of client config %s: %w", path, err)
+	}
+	if err := file.Truncate(0); err != nil {
+		return fmt.Errorf("failed to write client config %s: %w", path, err)
+	}
+	if _, err := file.Write(content); err != nil {
+		return fmt.Errorf("failed to write client config %s: %w", path, err)
+	}
+	return file.Close()
+}
+
+// waitClientCredentials waits for the controller to issue the credential of a client
+func (i *Instance) waitClientCredentials(ctx context.Context, clientName string) error {
+	maxRetries := 10
+	retryDelay := 1 * time.Second
+	var err error
+
+	for r := 0; r < maxRetries; r++ {
+		if _, err = i.getClientToken(ctx, clientName); err == nil {
+			return nil
+		}
+		i.printf("⌛ [%s] Waiting for client credentials for %s in namespace %s\n", i.config.Name, clientName, i.config.Spec.Namespace)
+		if err := sleepContext(ctx, retryDelay); err != nil {
+			return err
+		}
+		retryDelay *= 2
+		if retryDelay > 10*time.Second {
+			retryDelay = 10 * time.Second
+		}
+	}
+	return fmt.Errorf("failed to get client credentials after %d retries, last error: %w", maxRetries, err)
+}
+
+// getClientToken reads the token from the credential secret of a client
+func (i *Instance) getClientToken(ctx context.Context, clientName string) (string, error) {
+	clientObj := &v1alpha1.Client{}
+	namespace := i.config.Spec.Namespace
+	if err := i.client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: clientName}, clientObj); err != nil {
+		return "", fmt.Errorf("failed to get client %s: %w", clientName, err)
+	}
+
+	if clientObj.Status.Credential == nil {
+		return "", fmt.Errorf("client %s has no credential yet", clientName)
+	}
+
+	secret := &corev1.Secret{}
+	if err := i.client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: clientObj.Status.Credential.Name}, secret); err != nil {
+		return "", fmt.Errorf("failed to get secret %s: %w", clientObj.Status.Credential.Name, err)
+	}
+
+	token, ok := secret.Data["token"]
+	if !ok {
+		return "", fmt.Errorf("secret %s does not contain a token", clientObj.Status.Credential.Name)
+	}
+	return string(token), nil
+}
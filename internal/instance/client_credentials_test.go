@@ -0,0 +1,127 @@
+package instance
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jumpstarter-dev/jumpstarter-controller/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/config"
+)
+
+func clientWithCredential(name string) *v1alpha1.Client {
+	c := testClient(name, "test-ns", name)
+	c.Status.Credential = &corev1.LocalObjectReference{Name: name + "-client"}
+	return c
+}
+
+func credentialSecret(name, token string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test-ns"},
+		Data:       map[string][]byte{"token": []byte(token)},
+	}
+}
+
+func caConfigMap() *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: defaultCAConfigMapName, Namespace: "test-ns"},
+		Data:       map[string]string{defaultCAKey: testCA},
+	}
+}
+
+func TestGetClientConfig(t *testing.T) {
+	t.Run("renders a client config file", func(t *testing.T) {
+		inst := newTestInstance(t, false, false,
+			clientWithCredential("alice"), credentialSecret("alice-client", "secret-token"), caConfigMap())
+		inst.config.Spec.Endpoints = []string{"grpc.example.com:443", "grpc-alt.example.com:443"}
+
+		out, err := inst.GetClientConfig(context.Background(), "alice")
+		require.NoError(t, err)
+
+		var parsed clientConfigFile
+		require.NoError(t, yaml.Unmarshal(out, &parsed))
+		assert.Equal(t, "ClientConfig", parsed.Kind)
+		assert.Equal(t, "alice", parsed.Metadata.Name)
+		assert.Equal(t, "test-ns", parsed.Metadata.Namespace)
+		assert.Equal(t, "grpc.example.com:443", parsed.Endpoint)
+		assert.Equal(t, "secret-token", parsed.Token)
+		assert.Equal(t, base64.StdEncoding.EncodeToString([]byte(testCA)), parsed.TLS.CA)
+		assert.False(t, parsed.TLS.Insecure)
+		assert.NotNil(t, parsed.Drivers.Allow)
+	})
+
+	t.Run("credential not issued yet", func(t *testing.T) {
+		inst := newTestInstance(t, false, false, testClient("alice", "test-ns", "alice"), caConfigMap())
+		inst.config.Spec.Endpoints = []string{"grpc.example.com:443"}
+
+		_, err := inst.GetClientConfig(context.Background(), "alice")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no credential yet")
+	})
+
+	t.Run("instance without endpoints", func(t *testing.T) {
+		inst := newTestInstance(t, false, false,
+			clientWithCredential("alice"), credentialSecret("alice-client", "secret-token"), caConfigMap())
+
+		_, err := inst.GetClientConfig(context.Background(), "alice")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no endpoints")
+	})
+}
+
+func TestWriteClientConfigs(t *testing.T) {
+	cfg := &config.Config{Loaded: &config.LoadedLabConfig{
+		Clients: map[string]*v1alpha1.Client{
+			"alice": testClient("alice", "", "alice"),
+		},
+	}}
+
+	t.Run("writes one file per client", func(t *testing.T) {
+		inst := newTestInstance(t, false, false,
+			clientWithCredential("alice"), credentialSecret("alice-client", "secret-token"), caConfigMap())
+		inst.config.Spec.Endpoints = []string{"grpc.example.com:443"}
+		dir := filepath.Join(t.TempDir(), "clients")
+
+		require.NoError(t, inst.WriteClientConfigs(context.Background(), cfg, nil, dir))
+
+		path := filepath.Join(dir, "alice-test-instance.yaml")
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Contains(t, string(content), "token: secret-token")
+	})
+
+	t.Run("existing files are made private", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "alice.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("a longer previous content\n"), 0644))
+
+		require.NoError(t, WriteClientConfigFile(path, []byte("token: new\n")))
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "token: new\n", string(content))
+	})
+
+	t.Run("dry run writes nothing", func(t *testing.T) {
+		inst := newTestInstance(t, true, false)
+		dir := filepath.Join(t.TempDir(), "clients")
+
+		require.NoError(t, inst.WriteClientConfigs(context.Background(), cfg, nil, dir))
+
+		_, err := os.Stat(dir)
+		assert.True(t, os.IsNotExist(err))
+	})
+}
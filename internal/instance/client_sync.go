@@ -11,32 +11,16 @@ import (
 )
 
 func (i *Instance) SyncClients(ctx context.Context, cfg *config.Config, filter *regexp.Regexp) error {
-	fmt.Printf("\n🔄 [%s] Syncing clients ===========================\n\n", i.config.Name)
+	i.printf("\n🔄 [%s] Syncing clients ===========================\n\n", i.config.Name)
 	instanceClients, err := i.listClients(ctx)
 	if err != nil {
 		return fmt.Errorf("[%s] failed to list clients: %w", i.config.Name, err)
 	}
 
-	configClientMap := cfg.Loaded.Clients
+	configClientMap := cfg.Loaded.GetClientsForJumpstarterInstance(i.config)
 
 	// Apply filter if provided
-	if filter != nil {
-		filteredInstanceItems := []v1alpha1.Client{}
-		for _, item := range instanceClients.Items {
-			if filter.MatchString(item.Name) {
-				filteredInstanceItems = append(filteredInstanceItems, item)
-			}
-		}
-		instanceClients.Items = filteredInstanceItems
-
-		filteredConfigClientMap := make(map[string]*v1alpha1.Client)
-		for name, clientObj := range configClientMap {
-			if filter.MatchString(name) {
-				filteredConfigClientMap[name] = clientObj
-			}
-		}
-		configClientMap = filteredConfigClientMap
-	}
+	configClientMap, instanceClients.Items = applyClientFilter(filter, instanceClients.Items, configClientMap)
 
 	// create a clientMap from instanceClients
 	instanceClientMap := make(map[string]v1alpha1.Client)
@@ -47,7 +31,7 @@ func (i *Instance) SyncClients(ctx context.Context, cfg *config.Config, filter *
 	// delete clients that are not in config
 	for _, instanceClient := range instanceClients.Items {
 		if _, ok := configClientMap[instanceClient.Name]; !ok {
-			err := i.deleteClient(ctx, instanceClient.Name)
+			err := i.pruneObject(ctx, &instanceClient, "client")
 			if err != nil {
 				return fmt.Errorf("[%s] failed to delete client %s: %w", i.config.Name, instanceClient.Name, err)
 			}
@@ -77,6 +61,32 @@ func (i *Instance) SyncClients(ctx context.Context, cfg *config.Config, filter *
 	return nil
 }
 
+func applyClientFilter(
+	filter *regexp.Regexp,
+	instanceClientItems []v1alpha1.Client,
+	configClientMap map[string]*v1alpha1.Client,
+) (map[string]*v1alpha1.Client, []v1alpha1.Client) {
+	if filter == nil {
+		return configClientMap, instanceClientItems
+	}
+
+	filteredInstanceItems := []v1alpha1.Client{}
+	for _, item := range instanceClientItems {
+		if filter.MatchString(item.Name) {
+			filteredInstanceItems = append(filteredInstanceItems, item)
+		}
+	}
+
+	filteredConfigClientMap := make(map[string]*v1alpha1.Client)
+	for name, clientObj := range configClientMap {
+		if filter.MatchString(name) {
+			filteredConfigClientMap[name] = clientObj
+		}
+	}
+
+	return filteredConfigClientMap, filteredInstanceItems
+}
+
 // listClients lists all clients in the instance's namespace
 func (i *Instance) listClients(ctx context.Context) (*v1alpha1.ClientList, error) {
 	clients := &v1alpha1.ClientList{}
@@ -103,58 +113,20 @@ func (i *Instance) getClientByName(ctx context.Context, name string) (*v1alpha1.
 	return clientObj, err
 }
 
-// updateClient updates a client
+// updateClient server-side applies the client from the config over the existing one
 func (i *Instance) updateClient(ctx context.Context, oldClientObj, clientObj *v1alpha1.Client) error {
-	// Create a copy of the old object to preserve ResourceVersion and other metadata
-	updatedClient := oldClientObj.DeepCopy()
-
-	// Update the spec and other fields from the new config
-	updatedClient.Spec = clientObj.Spec
-	updatedClient.Labels = clientObj.Labels
-
-	// Prepare metadata (annotations, namespace, etc.)
-	// For updates, we want to preserve existing annotations and merge new ones
-	i.prepareMetadata(&updatedClient.ObjectMeta, clientObj.Annotations)
-	changed := i.checkAndPrintDiff(oldClientObj, updatedClient, "client", updatedClient.Name, i.dryRun)
-	if i.dryRun || !changed {
-		return nil
+	desired := &v1alpha1.Client{
+		ObjectMeta: i.desiredObjectMeta(&clientObj.ObjectMeta),
+		Spec:       clientObj.DeepCopy().Spec,
 	}
-
-	return i.client.Update(ctx, updatedClient)
+	return i.applyObject(ctx, oldClientObj, desired, "client")
 }
 
 // createClient creates a new client
 func (i *Instance) createClient(ctx context.Context, clientObj *v1alpha1.Client) error {
-	// Prepare metadata (annotations, namespace, etc.)
-	i.prepareMetadata(&clientObj.ObjectMeta, clientObj.Annotations)
-
-	if i.dryRun {
-		fmt.Printf("➕ [%s] dry run: Would create client %s in namespace %s\n", i.config.Name, clientObj.Name, clientObj.Namespace)
-		return nil
-	} else {
-		fmt.Printf("➕ [%s] Creating client %s in namespace %s\n", i.config.Name, clientObj.Name, clientObj.Namespace)
-	}
-
-	return i.client.Create(ctx, clientObj)
-}
-
-// deleteClient deletes a client by name
-func (i *Instance) deleteClient(ctx context.Context, name string) error {
-	clientObj := &v1alpha1.Client{}
-	namespace := i.config.Spec.Namespace
-	if namespace == "" {
-		return fmt.Errorf("namespace is required to delete client %s", name)
+	desired := &v1alpha1.Client{
+		ObjectMeta: i.desiredObjectMeta(&clientObj.ObjectMeta),
+		Spec:       clientObj.DeepCopy().Spec,
 	}
-
-	err := i.client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, clientObj)
-	if err != nil {
-		return fmt.Errorf("failed to get client %s: %w", name, err)
-	}
-
-	if i.dryRun {
-		fmt.Printf("🗑️ [%s] dry run: Would delete client %s in namespace %s\n", i.config.Name, name, namespace)
-		return nil
-	}
-
-	return i.client.Delete(ctx, clientObj)
+	return i.createObject(ctx, desired, "client")
 }
@@ -0,0 +1,160 @@
+package instance
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/config"
+)
+
+// DeletionLimits bounds how many objects a single apply may prune from an instance,
+// a zero value disables the corresponding check
+type DeletionLimits struct {
+	// MaxCount is the maximum number of objects that can be deleted
+	MaxCount int
+	// MaxPercent is the maximum percentage of the owned objects that can be deleted
+	MaxPercent int
+}
+
+// minPercentDeletions is the number of deletions from which the percentage check applies, a few
+// deletions in a small instance are a large percentage of it. Deleting every owned object of a
+// kind is refused whatever the count.
+const minPercentDeletions = 5
+
+// PlannedDeletion describes an object that the sync would delete
+type PlannedDeletion struct {
+	Kind      string
+	Name      string
+	Namespace string
+}
+
+// DeletionPlan holds the deletions planned for an instance before any change is made
+type DeletionPlan struct {
+	Instance  string
+	Deletions []PlannedDeletion
+	// Owned is the number of objects owned by this tool in the instance
+	Owned int
+	// OwnedKinds is the number of objects owned by this tool in the instance, by kind
+	OwnedKinds map[string]int
+}
+
+// PlanDeletions computes the objects that SyncClients, SyncPolicies and SyncExporters would delete
+// with the same filters, nothing is deleted when pruning is disabled
+func (i *Instance) PlanDeletions(
+	ctx context.Context,
+	cfg *config.Config,
+	clientFilter, policyFilter, exporterFilter *regexp.Regexp,
+	unmanagedExporters map[string]bool,
+) (*DeletionPlan, error) {
+	plan := &DeletionPlan{Instance: i.config.Name, OwnedKinds: map[string]int{}}
+
+	instanceClients, err := i.listClients(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("[%s] failed to list clients: %w", i.config.Name, err)
+	}
+	configClientMap, clientItems := applyClientFilter(clientFilter, instanceClients.Items,
+		cfg.Loaded.GetClientsForJumpstarterInstance(i.config))
+	for _, item := range clientItems {
+		_, inConfig := configClientMap[item.Name]
+		i.planDeletion(plan, &item, "client", inConfig)
+	}
+
+	instancePolicies, err := i.listPolicies(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("[%s] failed to list policies: %w", i.config.Name, err)
+	}
+	configPolicyMap, policyItems := applyPolicyFilter(policyFilter, instancePolicies.Items,
+		cfg.Loaded.GetPoliciesForJumpstarterInstance(i.config))
+	for _, item := range policyItems {
+		_, inConfig := configPolicyMap[item.Name]
+		i.planDeletion(plan, &item, "policy", inConfig)
+	}
+
+	instanceExporters, err := i.listExporters(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("[%s] failed to list exporters: %w", i.config.Name, err)
+	}
+	configExporterMap, err := buildConfigExporterMap(cfg, i.config.Name, unmanagedExporters)
+	if err != nil {
+		return nil, fmt.Errorf("[%s] failed to build exporter config map: %w", i.config.Name, err)
+	}
+	configExporterMap, exporterItems := applyExporterFilter(exporterFilter, instanceExporters.Items, configExporterMap)
+	for _, item := range exporterItems {
+		if isUnmanagedExporter(item.Name, unmanagedExporters) {
+			continue
+		}
+		_, inConfig := configExporterMap[item.Name]
+		i.planDeletion(plan, &item, "exporter", inConfig)
+	}
+
+	return plan, nil
+}
+
+// planDeletion counts an owned object and records it for deletion when it is no longer in the config
+func (i *Instance) planDeletion(plan *DeletionPlan, obj client.Object, kind string, inConfig bool) {
+	if !i.isOwned(obj) {
+		return
+	}
+	plan.Owned++
+	plan.OwnedKinds[kind]++
+	if !inConfig && i.prune {
+		plan.Deletions = append(plan.Deletions, PlannedDeletion{Kind: kind, Name: obj.GetName(), Namespace: obj.GetNamespace()})
+	}
+}
+
+// wipedKinds returns the kinds of which every owned object would be deleted, i.e. after a glob typo
+func (p *DeletionPlan) wipedKinds() []string {
+	deleted := map[string]int{}
+	for _, d := range p.Deletions {
+		deleted[d.Kind]++
+	}
+	var wiped []string
+	for kind, count := range deleted {
+		if count >= p.OwnedKinds[kind] {
+			wiped = append(wiped, kind)
+		}
+	}
+	slices.Sort(wiped)
+	return wiped
+}
+
+// Check returns an error when the plan exceeds the limits or deletes every owned object of a kind,
+// after listing the planned deletions to out
+func (p *DeletionPlan) Check(limits DeletionLimits, out io.Writer) error {
+	count := len(p.Deletions)
+	if count == 0 {
+		return nil
+	}
+
+	exceeded := limits.MaxCount > 0 && count > limits.MaxCount
+	if limits.MaxPercent > 0 && count >= minPercentDeletions && count*100 > limits.MaxPercent*p.Owned {
+		exceeded = true
+	}
+	wiped := p.wipedKinds()
+	if !exceeded && len(wiped) == 0 {
+		return nil
+	}
+
+	if exceeded {
+		_, _ = fmt.Fprintf(out, "\n🛑 [%s] Refusing to delete %d of %d managed objects (limits: %d objects, %d%%):\n",
+			p.Instance, count, p.Owned, limits.MaxCount, limits.MaxPercent)
+	} else {
+		_, _ = fmt.Fprintf(out, "\n🛑 [%s] Refusing to delete every managed %s:\n", p.Instance, strings.Join(wiped, ", "))
+	}
+	for _, d := range p.Deletions {
+		_, _ = fmt.Fprintf(out, "   🗑️ %s %s in namespace %s\n", d.Kind, d.Name, d.Namespace)
+	}
+	if len(wiped) > 0 {
+		_, _ = fmt.Fprintln(out, "   Check the source globs in your configuration, or use --allow-mass-delete to proceed.")
+	} else {
+		_, _ = fmt.Fprintln(out, "   Check the source globs in your configuration, or use --allow-mass-delete or --max-deletions to proceed.")
+	}
+
+	return fmt.Errorf("[%s] mass deletion guard: %d objects would be deleted", p.Instance, count)
+}
@@ -0,0 +1,103 @@
+package instance
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/jumpstarter-dev/jumpstarter-controller/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/config"
+)
+
+func deletions(n int) []PlannedDeletion {
+	result := make([]PlannedDeletion, n)
+	for i := range result {
+		result[i] = PlannedDeletion{Kind: "client", Name: "client", Namespace: "test-ns"}
+	}
+	return result
+}
+
+// clientPlan plans the deletion of n of the owned clients
+func clientPlan(n, owned int) DeletionPlan {
+	return DeletionPlan{Deletions: deletions(n), Owned: owned, OwnedKinds: map[string]int{"client": owned}}
+}
+
+func TestDeletionPlanCheck(t *testing.T) {
+	limits := DeletionLimits{MaxCount: 10, MaxPercent: 30}
+
+	tests := []struct {
+		name      string
+		plan      DeletionPlan
+		limits    DeletionLimits
+		expectErr bool
+	}{
+		{"no deletions", clientPlan(0, 100), limits, false},
+		{"within limits", clientPlan(5, 100), limits, false},
+		{"too many objects", clientPlan(11, 100), limits, true},
+		{"too large percentage", clientPlan(6, 10), limits, true},
+		{"a few deletions in a small instance are allowed by the percentage", clientPlan(4, 6), limits, false},
+		{"every object of a small instance", clientPlan(4, 4), limits, true},
+		{"the only object of a kind", clientPlan(1, 1), limits, true},
+		{"every object of a kind among others", DeletionPlan{Deletions: deletions(2), Owned: 20,
+			OwnedKinds: map[string]int{"client": 2, "exporter": 18}}, limits, true},
+		{"every object of a kind without limits", clientPlan(3, 3), DeletionLimits{}, true},
+		{"explicit count without percentage", clientPlan(20, 21), DeletionLimits{MaxCount: 20}, false},
+		{"disabled limits", clientPlan(50, 51), DeletionLimits{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.plan.Instance = "test-instance"
+			var out bytes.Buffer
+			err := tt.plan.Check(tt.limits, &out)
+			if tt.expectErr {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "mass deletion guard")
+				assert.Contains(t, out.String(), "🗑️ client client in namespace test-ns")
+			} else {
+				assert.NoError(t, err)
+				assert.Empty(t, out.String())
+			}
+		})
+	}
+}
+
+func TestPlanDeletions(t *testing.T) {
+	cfg := &config.Config{Loaded: &config.LoadedLabConfig{
+		Clients: map[string]*v1alpha1.Client{"kept": testClient("kept", "", "kept")},
+	}}
+	objs := []*v1alpha1.Client{
+		ownedClient("kept", DefaultManagedBy),
+		ownedClient("stale-1", DefaultManagedBy),
+		ownedClient("stale-2", DefaultManagedBy),
+		testClient("manual", "test-ns", "manual"),
+	}
+
+	t.Run("owned objects missing from the config are planned", func(t *testing.T) {
+		inst := newTestInstance(t, false, true, objs[0].DeepCopy(), objs[1].DeepCopy(), objs[2].DeepCopy(), objs[3].DeepCopy())
+		plan, err := inst.PlanDeletions(context.Background(), cfg, nil, nil, nil, nil)
+		require.NoError(t, err)
+		assert.Equal(t, 3, plan.Owned)
+		assert.Equal(t, map[string]int{"client": 3}, plan.OwnedKinds)
+		assert.ElementsMatch(t, []string{"stale-1", "stale-2"}, []string{plan.Deletions[0].Name, plan.Deletions[1].Name})
+	})
+
+	t.Run("filters are honored", func(t *testing.T) {
+		inst := newTestInstance(t, false, true, objs[0].DeepCopy(), objs[1].DeepCopy(), objs[2].DeepCopy(), objs[3].DeepCopy())
+		plan, err := inst.PlanDeletions(context.Background(), cfg, regexp.MustCompile("-1$"), nil, nil, nil)
+		require.NoError(t, err)
+		require.Len(t, plan.Deletions, 1)
+		assert.Equal(t, "stale-1", plan.Deletions[0].Name)
+	})
+
+	t.Run("nothing is planned without prune", func(t *testing.T) {
+		inst := newTestInstance(t, false, false, objs[1].DeepCopy())
+		plan, err := inst.PlanDeletions(context.Background(), cfg, nil, nil, nil, nil)
+		require.NoError(t, err)
+		assert.Empty(t, plan.Deletions)
+	})
+}
@@ -4,10 +4,13 @@ import (
 	"context"
 	"fmt"
 	"regexp"
+	"sync"
 	"time"
 
+	"golang.org/x/sync/errgroup"
+
 	corev1 "k8s.io/api/core/v1"
-	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 
 	"github.com/jumpstarter-dev/jumpstarter-controller/api/v1alpha1"
 	v1alpha1Config "github.com/jumpstarter-dev/jumpstarter-lab-config/api/v1alpha1"
@@ -42,92 +45,70 @@ func (i *Instance) getExporterByName(ctx context.Context, name string) (*v1alpha
 	return exporter, err
 }
 
-// updateExporter updates an exporter with retry logic to handle conflicts
-//
-//nolint:unused
-func (i *Instance) updateExporter(ctx context.Context, oldExporter, exporter *v1alpha1.Exporter) error {
-	maxRetries := 10
-	baseDelay := 100 * time.Millisecond
-
-	latestExporter := oldExporter
-
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		// Fetch the latest version of the exporter to get current ResourceVersion
-
-		// Create a copy of the latest object to preserve ResourceVersion and other metadata
-		updatedExporter := latestExporter.DeepCopy()
-
-		// Update the spec and other fields from the new config
-		updatedExporter.Spec = exporter.Spec
-		updatedExporter.Labels = exporter.Labels
-
-		// Prepare metadata (annotations, namespace, etc.)
-		// For updates, we want to preserve existing annotations and merge new ones
-		i.prepareMetadata(&updatedExporter.ObjectMeta, exporter.Annotations)
-
-		// Only print diff on first attempt to avoid spam
-		if attempt == 0 {
-			changed := i.checkAndPrintDiff(oldExporter, updatedExporter, "exporter", updatedExporter.Name, i.dryRun)
-			if !changed {
-				return nil
-			}
-		}
-
-		if i.dryRun {
-			return nil
-		}
+// ExporterOnline checks whether the controller reports the exporter name as online, i.e. connected
+func (i *Instance) ExporterOnline(ctx context.Context, name string) (bool, error) {
+	exporter, err := i.getExporterByName(ctx, name)
+	if err != nil {
+		return false, fmt.Errorf("failed to get exporter %s: %w", name, err)
+	}
+	return meta.IsStatusConditionTrue(exporter.Status.Conditions, string(v1alpha1.ExporterConditionTypeOnline)), nil
+}
 
-		err := i.client.Update(ctx, updatedExporter)
-		if err == nil {
-			// Success
-			return nil
+// leasesTTL is how long the listed leases are used, the lease checks right before a restart see
+// the leases acquired since the exporter sync started
+const leasesTTL = 5 * time.Second
+
+// ExporterLease returns the active lease of the exporter name, empty when it isn't leased. The
+// leases are listed again once they are older than leasesTTL, and indexed by exporter.
+func (i *Instance) ExporterLease(ctx context.Context, name string) (string, error) {
+	i.leasesMutex.Lock()
+	defer i.leasesMutex.Unlock()
+	if i.leases == nil || time.Since(i.leasesListed) > leasesTTL {
+		leases, err := i.listActiveLeases(ctx)
+		if err != nil {
+			return "", err
 		}
+		i.leases = leases
+		i.leasesListed = time.Now()
+	}
+	return i.leases[name], nil
+}
 
-		// Check if this is a conflict error
-		if i.isConflictError(err) {
-			if attempt < maxRetries-1 {
-				// Calculate delay with exponential backoff and jitter
-				delay := time.Duration(attempt+1) * baseDelay
-				if delay > 2*time.Second {
-					delay = 2 * time.Second
-				}
-				fmt.Printf("⚠️  [%s] Conflict updating exporter %s, retrying in %v (attempt %d/%d)\n",
-					i.config.Name, exporter.Name, delay, attempt+1, maxRetries)
-				time.Sleep(delay)
-				latestExporter = &v1alpha1.Exporter{}
-				err := i.client.Get(ctx, client.ObjectKey{
-					Namespace: i.config.Spec.Namespace,
-					Name:      exporter.Name,
-				}, latestExporter)
-				if err != nil {
-					return fmt.Errorf("failed to fetch latest exporter %s: %w", exporter.Name, err)
-				}
-				continue
-			}
+// listActiveLeases lists the leases which didn't end, by the name of their exporter
+func (i *Instance) listActiveLeases(ctx context.Context) (map[string]string, error) {
+	namespace := i.config.Spec.Namespace
+	if namespace == "" {
+		return nil, fmt.Errorf("namespace is required to list the leases of the exporters")
+	}
+	leases := &v1alpha1.LeaseList{}
+	if err := i.client.List(ctx, leases, client.InNamespace(namespace)); err != nil {
+		return nil, fmt.Errorf("failed to list leases: %w", err)
+	}
+	active := make(map[string]string)
+	for _, lease := range leases.Items {
+		if !lease.Status.Ended && lease.Status.ExporterRef != nil {
+			active[lease.Status.ExporterRef.Name] = lease.Name
 		}
-
-		// Return the error if it's not a conflict or we've exhausted retries
-		return fmt.Errorf("failed to update exporter %s after %d attempts: %w", exporter.Name, attempt+1, err)
 	}
+	return active, nil
+}
 
-	return fmt.Errorf("failed to update exporter %s after %d retries due to conflicts", exporter.Name, maxRetries)
+// updateExporter server-side applies the exporter from the config over the existing one
+func (i *Instance) updateExporter(ctx context.Context, oldExporter, exporter *v1alpha1.Exporter) error {
+	desired := &v1alpha1.Exporter{
+		ObjectMeta: i.desiredObjectMeta(&exporter.ObjectMeta),
+		Spec:       exporter.DeepCopy().Spec,
+	}
+	return i.applyObject(ctx, oldExporter, desired, "exporter")
 }
 
 // createExporter creates a new exporter
-//
-//nolint:unused
 func (i *Instance) createExporter(ctx context.Context, exporter *v1alpha1.Exporter) error {
-	// Prepare metadata (annotations, namespace, etc.)
-	i.prepareMetadata(&exporter.ObjectMeta, exporter.Annotations)
-
-	if i.dryRun {
-		fmt.Printf("➕ [%s] dry run: Would create exporter %s in namespace %s\n", i.config.Name, exporter.Name, exporter.Namespace)
-		return nil
-	} else {
-		fmt.Printf("➕ [%s] Creating exporter %s in namespace %s\n", i.config.Name, exporter.Name, exporter.Namespace)
+	desired := &v1alpha1.Exporter{
+		ObjectMeta: i.desiredObjectMeta(&exporter.ObjectMeta),
+		Spec:       exporter.DeepCopy().Spec,
 	}
-
-	return i.client.Create(ctx, exporter)
+	return i.createObject(ctx, desired, "exporter")
 }
 
 func (i *Instance) waitExporterCredentials(ctx context.Context, exporter *v1alpha1.Exporter) (*template.ServiceParameters, error) {
@@ -135,18 +116,25 @@ func (i *Instance) waitExporterCredentials(ctx context.Context, exporter *v1alph
 	retryDelay := 1 * time.Second
 	var err error
 
+	// a missing CA bundle is a configuration problem, don't wait for it
+	if _, err := i.GetControllerTLS(ctx); err != nil {
+		return nil, err
+	}
+
 	// the exporter object needs the namespace of the jumpstarter instance
 	exporter.Namespace = i.config.Spec.Namespace
 	for r := 0; r < maxRetries; r++ {
 		var serviceParameters *template.ServiceParameters
 		serviceParameters, err = i.getExporterCredentials(ctx, exporter)
 		if err != nil {
-			fmt.Printf("⌛ [%s] Waiting for exporter credentials for %s in namespace %s\n", i.config.Name, exporter.Name, exporter.Namespace)
+			i.printf("⌛ [%s] Waiting for exporter credentials for %s in namespace %s\n", i.config.Name, exporter.Name, exporter.Namespace)
 		}
 		if serviceParameters != nil {
 			return serviceParameters, nil
 		}
-		time.Sleep(retryDelay)
+		if err := sleepContext(ctx, retryDelay); err != nil {
+			return nil, err
+		}
 		retryDelay *= 2
 		if retryDelay > 10*time.Second {
 			retryDelay = 10 * time.Second
@@ -176,33 +164,21 @@ func (i *Instance) getExporterCredentials(ctx context.Context, exporter *v1alpha
 		return nil, fmt.Errorf("secret %s does not contain a token", exporterObj.Status.Credential.Name)
 	}
 
-	return &template.ServiceParameters{
-		Token: string(token),
-		TlsCA: "", // TODO: add tls ca when we have it
-	}, nil
+	return i.serviceParameters(ctx, string(token))
 }
 
-// deleteExporter deletes an exporter by name
-//
-//nolint:unused
-func (i *Instance) deleteExporter(ctx context.Context, name string) error {
-	exporter := &v1alpha1.Exporter{}
-	namespace := i.config.Spec.Namespace
-	if namespace == "" {
-		return fmt.Errorf("namespace is required to delete exporter %s", name)
-	}
-
-	err := i.client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, exporter)
+// serviceParameters builds the parameters rendered in the exporter configs for a token
+func (i *Instance) serviceParameters(ctx context.Context, token string) (*template.ServiceParameters, error) {
+	controllerTLS, err := i.GetControllerTLS(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to get exporter %s: %w", name, err)
-	}
-
-	if i.dryRun || i.prune {
-		fmt.Printf("🗑️ [%s] dry run / don't prune: Would delete exporter %s in namespace %s\n", i.config.Name, name, namespace)
-		return nil
+		return nil, err
 	}
 
-	return i.client.Delete(ctx, exporter)
+	return &template.ServiceParameters{
+		Token:       token,
+		TlsCA:       controllerTLS.CA,
+		TlsInsecure: controllerTLS.Insecure,
+	}, nil
 }
 
 // formatCredentialsYAML formats credentials in YAML format for easy copy&paste
@@ -216,11 +192,11 @@ func (i *Instance) formatCredentialsYAML(exporterName string, serviceParameters
 	// Build YAML output
 	yaml := fmt.Sprintf("endpoint: \"%s\"\n", endpoint)
 
-	// Always add TLS section
-	if serviceParameters.TlsCA != "" {
-		yaml += fmt.Sprintf("tls:\n  ca: \"%s\"\n", serviceParameters.TlsCA)
-	} else {
+	// Always add TLS section, insecure mode must be enabled explicitly in the jumpstarter instance
+	if serviceParameters.TlsInsecure {
 		yaml += "tls:\n  ca: \"\"\n  insecure: true\n"
+	} else {
+		yaml += fmt.Sprintf("tls:\n  ca: \"%s\"\n  insecure: false\n", serviceParameters.TlsCA)
 	}
 
 	yaml += fmt.Sprintf("token: \"%s\"", serviceParameters.Token)
@@ -257,7 +233,7 @@ func (i *Instance) getGrpcEndpoint(exporterName string, cfg *config.Config) (str
 
 func (i *Instance) SyncExporters(ctx context.Context, cfg *config.Config, filter *regexp.Regexp, unmanagedExporters map[string]bool) (map[string]template.ServiceParameters, error) {
 	serviceParametersMap := make(map[string]template.ServiceParameters)
-	fmt.Printf("\n🔄 [%s] Syncing exporters ===========================\n\n", i.config.Name)
+	i.printf("\n🔄 [%s] Syncing exporters ===========================\n\n", i.config.Name)
 	instanceExporters, err := i.listExporters(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("[%s] failed to list exporters: %w", i.config.Name, err)
@@ -282,7 +258,7 @@ func (i *Instance) SyncExporters(ctx context.Context, cfg *config.Config, filter
 		if loggedUnmanagedSkips[name] {
 			return
 		}
-		fmt.Printf("⏸️  [%s] Skipping unmanaged exporter %s for sync\n", i.config.Name, name)
+		i.printf("⏸️  [%s] Skipping unmanaged exporter %s for sync\n", i.config.Name, name)
 		loggedUnmanagedSkips[name] = true
 	}
 
@@ -293,13 +269,17 @@ func (i *Instance) SyncExporters(ctx context.Context, cfg *config.Config, filter
 			continue
 		}
 		if _, ok := configExporterMap[instanceExporter.Name]; !ok {
-			err := i.deleteExporter(ctx, instanceExporter.Name)
+			err := i.pruneObject(ctx, &instanceExporter, "exporter")
 			if err != nil {
 				return nil, fmt.Errorf("[%s] failed to delete exporter %s: %w", i.config.Name, instanceExporter.Name, err)
 			}
 		}
 	}
 
+	// the credentials of new exporters can take a while to be issued by the controller,
+	// they are awaited concurrently once all the exporters have been applied
+	var credentialWaits []credentialWait
+
 	// create exporters that are in config but not in instance
 	for _, cfgExporter := range configExporterMap {
 		if _, ok := instanceExporterMap[cfgExporter.Name]; !ok {
@@ -309,31 +289,19 @@ func (i *Instance) SyncExporters(ctx context.Context, cfg *config.Config, filter
 				return nil, fmt.Errorf("[%s] failed to create exporter %s: %w", i.config.Name, cfgExporter.Name, err)
 			}
 
-			var serviceParameters *template.ServiceParameters
 			if !i.dryRun {
-				serviceParameters, err = i.waitExporterCredentials(ctx, &cfgExporter)
-				if err != nil {
-					return nil, fmt.Errorf("[%s] failed to wait for exporter credentials for %s: %w", i.config.Name, cfgExporter.Name, err)
-				}
-			} else {
-				serviceParameters = &template.ServiceParameters{
-					Token: "dry-run",
-					TlsCA: "",
-				}
+				credentialWaits = append(credentialWaits, credentialWait{exporter: cfgExporter, created: true})
+				continue
 			}
 
-			// Print credentials when flag is set, or for new non-managed exporters (not in dry run)
-			if i.printCredentials || (!i.dryRun && cfg.Loaded.ExporterInstances[cfgExporter.Name] != nil &&
-				cfg.Loaded.ExporterInstances[cfgExporter.Name].Spec.ExporterHostRef.Name == "") {
-				yamlOutput, err := i.formatCredentialsYAML(cfgExporter.Name, serviceParameters, cfg)
-				if err != nil {
-					return nil, fmt.Errorf("[%s] failed to format credentials for exporter %s: %w", i.config.Name, cfgExporter.Name, err)
-				}
-				fmt.Printf("🔍 [%s] Exporter connection details snippet for exporter %s:\n%s\n", i.config.Name, cfgExporter.Name, yamlOutput)
+			serviceParameters, err := i.serviceParameters(ctx, template.DryRunToken)
+			if err != nil {
+				return nil, err
 			}
-
-			svcParamRef := i.config.Name + ":" + cfgExporter.Name
-			serviceParametersMap[svcParamRef] = *serviceParameters
+			if err := i.printCredentialsSnippet(cfgExporter.Name, serviceParameters, cfg, false); err != nil {
+				return nil, err
+			}
+			serviceParametersMap[i.config.Name+":"+cfgExporter.Name] = *serviceParameters
 		}
 	}
 
@@ -351,25 +319,97 @@ func (i *Instance) SyncExporters(ctx context.Context, cfg *config.Config, filter
 				return nil, fmt.Errorf("[%s] failed to update exporter %s: %w", i.config.Name, instanceExporter.Name, err)
 			}
 
-			serviceParameters, err := i.waitExporterCredentials(ctx, &exporterObj)
+			credentialWaits = append(credentialWaits, credentialWait{exporter: exporterObj})
+		}
+	}
+
+	var mu sync.Mutex
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(maxConcurrentCredentialWaits)
+	for _, w := range credentialWaits {
+		g.Go(func() error {
+			serviceParameters, err := i.waitExporterCredentials(gctx, &w.exporter)
 			if err != nil {
-				return nil, fmt.Errorf("[%s] failed to wait for exporter credentials for %s: %w", i.config.Name, exporterObj.Name, err)
+				return fmt.Errorf("[%s] failed to wait for exporter credentials for %s: %w", i.config.Name, w.exporter.Name, err)
 			}
-			if i.printCredentials {
-				yamlOutput, err := i.formatCredentialsYAML(exporterObj.Name, serviceParameters, cfg)
-				if err != nil {
-					return nil, fmt.Errorf("[%s] failed to format credentials for exporter %s: %w", i.config.Name, exporterObj.Name, err)
-				}
-				fmt.Printf("🔍 [%s] Exporter connection details snippet for exporter %s:\n%s\n", i.config.Name, exporterObj.Name, yamlOutput)
+
+			// print credentials for new non-managed exporters, they must be configured by hand
+			printAlways := w.created && cfg.Loaded.ExporterInstances[w.exporter.Name] != nil &&
+				cfg.Loaded.ExporterInstances[w.exporter.Name].Spec.ExporterHostRef.Name == ""
+			if err := i.printCredentialsSnippet(w.exporter.Name, serviceParameters, cfg, printAlways); err != nil {
+				return err
 			}
-			svcParamRef := i.config.Name + ":" + exporterObj.Name
-			serviceParametersMap[svcParamRef] = *serviceParameters
-		}
+
+			mu.Lock()
+			defer mu.Unlock()
+			serviceParametersMap[i.config.Name+":"+w.exporter.Name] = *serviceParameters
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	return serviceParametersMap, nil
+}
+
+// ExporterServiceParameters returns the service parameters of the configured exporters without
+// changing the instance. The exporters without credentials yet are left out, apply writes their
+// config only once the credentials are issued, so it can't be compared yet.
+func (i *Instance) ExporterServiceParameters(ctx context.Context, cfg *config.Config, filter *regexp.Regexp, unmanagedExporters map[string]bool) (map[string]template.ServiceParameters, error) {
+	instanceExporters, err := i.listExporters(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("[%s] failed to list exporters: %w", i.config.Name, err)
+	}
+
+	configExporterMap, err := buildConfigExporterMap(cfg, i.config.Name, unmanagedExporters)
+	if err != nil {
+		return nil, fmt.Errorf("[%s] failed to build exporter config map: %w", i.config.Name, err)
+	}
+	configExporterMap, instanceExporterItems := applyExporterFilter(filter, instanceExporters.Items, configExporterMap)
+
+	issued := make(map[string]bool)
+	for _, instanceExporter := range instanceExporterItems {
+		issued[instanceExporter.Name] = instanceExporter.Status.Credential != nil
 	}
 
+	serviceParametersMap := make(map[string]template.ServiceParameters)
+	for name, cfgExporter := range configExporterMap {
+		if !issued[name] {
+			continue
+		}
+		cfgExporter.Namespace = i.config.Spec.Namespace
+		serviceParameters, err := i.getExporterCredentials(ctx, &cfgExporter)
+		if err != nil {
+			return nil, fmt.Errorf("[%s] failed to get exporter credentials for %s: %w", i.config.Name, name, err)
+		}
+		if serviceParameters != nil {
+			serviceParametersMap[i.config.Name+":"+name] = *serviceParameters
+		}
+	}
 	return serviceParametersMap, nil
 }
 
+// credentialWait is an exporter whose credentials must be retrieved after the sync
+type credentialWait struct {
+	exporter v1alpha1.Exporter
+	created  bool
+}
+
+// printCredentialsSnippet prints the connection details of an exporter when --print-exporter-credentials
+// is used, or when always is set
+func (i *Instance) printCredentialsSnippet(exporterName string, serviceParameters *template.ServiceParameters, cfg *config.Config, always bool) error {
+	if !i.printCredentials && !always {
+		return nil
+	}
+	yamlOutput, err := i.formatCredentialsYAML(exporterName, serviceParameters, cfg)
+	if err != nil {
+		return fmt.Errorf("[%s] failed to format credentials for exporter %s: %w", i.config.Name, exporterName, err)
+	}
+	i.printf("🔍 [%s] Exporter connection details snippet for exporter %s:\n%s\n", i.config.Name, exporterName, yamlOutput)
+	return nil
+}
+
 func buildConfigExporterMap(cfg *config.Config, jumpstarterInstance string, unmanagedExporters map[string]bool) (map[string]v1alpha1.Exporter, error) {
 	configExporterMap := make(map[string]v1alpha1.Exporter)
 	for _, cfgExporterInstance := range cfg.Loaded.ExporterInstances {
@@ -451,8 +491,3 @@ func GetExporterObjectForInstance(cfg *config.Config, e *v1alpha1Config.Exporter
 	}
 	return nil, nil
 }
-
-// isConflictError checks if an error is a Kubernetes conflict error
-func (i *Instance) isConflictError(err error) bool {
-	return apierrors.IsConflict(err)
-}
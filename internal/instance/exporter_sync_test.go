@@ -0,0 +1,164 @@
+package instance
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jumpstarter-dev/jumpstarter-controller/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1alphaConfig "github.com/jumpstarter-dev/jumpstarter-lab-config/api/v1alpha1"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/config"
+)
+
+// exporterWithCredential returns a live exporter with its credential secret
+func exporterWithCredential(name string) (*v1alpha1.Exporter, *corev1.Secret) {
+	exporter := &v1alpha1.Exporter{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test-ns"},
+		Status:     v1alpha1.ExporterStatus{Credential: &corev1.LocalObjectReference{Name: name + "-exporter"}},
+	}
+	return exporter, credentialSecret(name+"-exporter", name+"-token")
+}
+
+func TestSyncExporters_CollectsCredentials(t *testing.T) {
+	objs := []client.Object{caConfigMap()}
+	exporterInstances := map[string]*v1alphaConfig.ExporterInstance{}
+	for n := range 5 {
+		name := fmt.Sprintf("exporter-%d", n)
+		exporter, secret := exporterWithCredential(name)
+		objs = append(objs, exporter, secret)
+		exporterInstances[name] = &v1alphaConfig.ExporterInstance{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: v1alphaConfig.ExporterInstanceSpec{
+				Username:               name,
+				ExporterHostRef:        v1alphaConfig.ExporterHostRef{Name: "host"},
+				JumpstarterInstanceRef: v1alphaConfig.JumsptarterInstanceRef{Name: "test-instance"},
+			},
+		}
+	}
+	cfg := &config.Config{Loaded: &config.LoadedLabConfig{ExporterInstances: exporterInstances}}
+
+	inst := newTestInstance(t, false, false, objs...)
+	var out bytes.Buffer
+	inst.SetOutput(&out)
+
+	params, err := inst.SyncExporters(context.Background(), cfg, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, params, 5)
+	for n := range 5 {
+		name := fmt.Sprintf("exporter-%d", n)
+		assert.Equal(t, name+"-token", params["test-instance:"+name].Token)
+	}
+	assert.Contains(t, out.String(), "Syncing exporters")
+}
+
+func TestWaitExporterCredentials_Cancelled(t *testing.T) {
+	exporter := &v1alpha1.Exporter{ObjectMeta: metav1.ObjectMeta{Name: "pending", Namespace: "test-ns"}}
+	inst := newTestInstance(t, false, false, caConfigMap(), exporter)
+	inst.SetOutput(&bytes.Buffer{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := inst.waitExporterCredentials(ctx, exporter.DeepCopy())
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestExporterServiceParameters(t *testing.T) {
+	exporter, secret := exporterWithCredential("issued")
+	pending := &v1alpha1.Exporter{ObjectMeta: metav1.ObjectMeta{Name: "pending", Namespace: "test-ns"}}
+	exporterInstances := map[string]*v1alphaConfig.ExporterInstance{}
+	for _, name := range []string{"issued", "pending", "new"} {
+		exporterInstances[name] = &v1alphaConfig.ExporterInstance{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: v1alphaConfig.ExporterInstanceSpec{
+				Username:               name,
+				ExporterHostRef:        v1alphaConfig.ExporterHostRef{Name: "host"},
+				JumpstarterInstanceRef: v1alphaConfig.JumsptarterInstanceRef{Name: "test-instance"},
+			},
+		}
+	}
+	cfg := &config.Config{Loaded: &config.LoadedLabConfig{ExporterInstances: exporterInstances}}
+
+	inst := newTestInstance(t, false, false, caConfigMap(), exporter, secret, pending)
+	var out bytes.Buffer
+	inst.SetOutput(&out)
+
+	params, err := inst.ExporterServiceParameters(context.Background(), cfg, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "issued-token", params["test-instance:issued"].Token)
+	assert.NotContains(t, params, "test-instance:pending", "the exporters without credentials are left out")
+	assert.NotContains(t, params, "test-instance:new")
+	assert.Empty(t, out.String())
+
+	// nothing was created
+	var exporters v1alpha1.ExporterList
+	require.NoError(t, inst.client.List(context.Background(), &exporters))
+	assert.Len(t, exporters.Items, 2)
+}
+
+func TestExporterOnline(t *testing.T) {
+	online := &v1alpha1.Exporter{
+		ObjectMeta: metav1.ObjectMeta{Name: "online", Namespace: "test-ns"},
+		Status: v1alpha1.ExporterStatus{Conditions: []metav1.Condition{
+			{Type: string(v1alpha1.ExporterConditionTypeOnline), Status: metav1.ConditionTrue},
+		}},
+	}
+	offline := &v1alpha1.Exporter{
+		ObjectMeta: metav1.ObjectMeta{Name: "offline", Namespace: "test-ns"},
+		Status: v1alpha1.ExporterStatus{Conditions: []metav1.Condition{
+			{Type: string(v1alpha1.ExporterConditionTypeOnline), Status: metav1.ConditionFalse},
+		}},
+	}
+	inst := newTestInstance(t, false, false, online, offline)
+
+	isOnline, err := inst.ExporterOnline(context.Background(), "online")
+	require.NoError(t, err)
+	assert.True(t, isOnline)
+
+	isOnline, err = inst.ExporterOnline(context.Background(), "offline")
+	require.NoError(t, err)
+	assert.False(t, isOnline)
+
+	_, err = inst.ExporterOnline(context.Background(), "missing")
+	assert.Error(t, err)
+}
+
+func TestExporterLease(t *testing.T) {
+	lease := func(name, exporter string, ended bool) *v1alpha1.Lease {
+		return &v1alpha1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test-ns"},
+			Status:     v1alpha1.LeaseStatus{ExporterRef: &corev1.LocalObjectReference{Name: exporter}, Ended: ended},
+		}
+	}
+	inst := newTestInstance(t, false, false, lease("ended", "leased", true), lease("active", "leased", false),
+		lease("other", "other", false))
+
+	name, err := inst.ExporterLease(context.Background(), "leased")
+	require.NoError(t, err)
+	assert.Equal(t, "active", name)
+
+	name, err = inst.ExporterLease(context.Background(), "free")
+	require.NoError(t, err)
+	assert.Empty(t, name)
+
+	// the leases are listed again once they are older than leasesTTL
+	require.NoError(t, inst.client.Create(context.Background(), lease("new", "free", false)))
+	name, err = inst.ExporterLease(context.Background(), "free")
+	require.NoError(t, err)
+	assert.Empty(t, name)
+
+	inst.leasesListed = time.Now().Add(-leasesTTL - time.Second)
+	name, err = inst.ExporterLease(context.Background(), "free")
+	require.NoError(t, err)
+	assert.Equal(t, "new", name, "the lease acquired since the first check is seen")
+}
@@ -0,0 +1,114 @@
+package instance
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jumpstarter-dev/jumpstarter-controller/api/v1alpha1"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	v1alphaConfig "github.com/jumpstarter-dev/jumpstarter-lab-config/api/v1alpha1"
+)
+
+// applyCall records a server-side apply request received by the fake client
+type applyCall struct {
+	name   string
+	dryRun bool
+	force  bool
+	owner  string
+}
+
+// fakeApplyClient wraps the controller-runtime fake client, which does not support
+// server-side apply, and emulates apply patches with Create/Update
+type fakeApplyClient struct {
+	calls    []applyCall
+	applyErr error
+}
+
+func (f *fakeApplyClient) patch(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if patch.Type() != types.ApplyPatchType {
+		return c.Patch(ctx, obj, patch, opts...)
+	}
+
+	patchOpts := &client.PatchOptions{}
+	patchOpts.ApplyOptions(opts)
+	dryRun := len(patchOpts.DryRun) > 0
+	f.calls = append(f.calls, applyCall{
+		name:   obj.GetName(),
+		dryRun: dryRun,
+		force:  patchOpts.Force != nil && *patchOpts.Force,
+		owner:  patchOpts.FieldManager,
+	})
+	if f.applyErr != nil {
+		return f.applyErr
+	}
+
+	live := obj.DeepCopyObject().(client.Object)
+	err := c.Get(ctx, client.ObjectKeyFromObject(obj), live)
+	if apierrors.IsNotFound(err) {
+		if dryRun {
+			return nil
+		}
+		return c.Create(ctx, obj)
+	}
+	if err != nil {
+		return err
+	}
+	obj.SetResourceVersion(live.GetResourceVersion())
+	if dryRun {
+		return nil
+	}
+	return c.Update(ctx, obj)
+}
+
+// appliedNames returns the names of the objects that were really applied (not dry-run)
+func (f *fakeApplyClient) appliedNames() []string {
+	names := []string{}
+	for _, call := range f.calls {
+		if !call.dryRun {
+			names = append(names, call.name)
+		}
+	}
+	return names
+}
+
+// newTestInstance creates an Instance backed by a fake client preloaded with objs
+func newTestInstance(t *testing.T, dryRun, prune bool, objs ...client.Object) *Instance {
+	inst, _ := newTestInstanceWithApply(t, dryRun, prune, objs...)
+	return inst
+}
+
+// newTestInstanceWithApply is like newTestInstance but also returns the apply recorder
+func newTestInstanceWithApply(t *testing.T, dryRun, prune bool, objs ...client.Object) (*Instance, *fakeApplyClient) {
+	t.Helper()
+	s := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(s))
+	require.NoError(t, v1alpha1.AddToScheme(s))
+
+	recorder := &fakeApplyClient{}
+	c := fake.NewClientBuilder().
+		WithScheme(s).
+		WithObjects(objs...).
+		// like the API server, updates must not reset the status issued by the controller
+		WithStatusSubresource(&v1alpha1.Exporter{}, &v1alpha1.Client{}, &v1alpha1.ExporterAccessPolicy{}).
+		WithInterceptorFuncs(interceptor.Funcs{Patch: recorder.patch}).
+		Build()
+
+	return &Instance{
+		client: c,
+		config: &v1alphaConfig.JumpstarterInstance{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-instance"},
+			Spec:       v1alphaConfig.JumpstarterInstanceSpec{Namespace: "test-ns"},
+		},
+		dryRun: dryRun,
+		prune:  prune,
+	}, recorder
+}
@@ -3,13 +3,17 @@ package instance
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/jumpstarter-dev/jumpstarter-controller/api/v1alpha1"
 	v1alphaConfig "github.com/jumpstarter-dev/jumpstarter-lab-config/api/v1alpha1"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/plan"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
@@ -18,6 +22,8 @@ import (
 
 const (
 	managedByAnnotation = "jumpstarter-lab-config"
+	// maxConcurrentCredentialWaits bounds the exporter credentials awaited at the same time per instance
+	maxConcurrentCredentialWaits = 10
 )
 
 // Instance wraps a Kubernetes client and provides methods for operating on Jumpstarter resources
@@ -27,6 +33,20 @@ type Instance struct {
 	dryRun           bool
 	prune            bool
 	printCredentials bool
+	forceConflicts   bool
+	managedBy        string
+	// out receives the progress output of the instance, os.Stdout when unset
+	out io.Writer
+	// recorder receives the changes of the sync, nil unless a plan is being made or checked
+	recorder *plan.Recorder
+
+	tlsMutex      sync.Mutex
+	controllerTLS *ControllerTLS
+
+	// leases maps the exporter names to their active lease, listed at leasesListed
+	leasesMutex  sync.Mutex
+	leases       map[string]string
+	leasesListed time.Time
 }
 
 // NewInstance creates a new Instance from a JumpstarterInstance and optional kubeconfig string
@@ -97,6 +117,21 @@ func NewInstance(instance *v1alphaConfig.JumpstarterInstance, kubeconfigStr stri
 	}, nil
 }
 
+// SetOutput redirects the progress output of the instance, i.e. to buffer it while
+// several instances are synced in parallel
+func (i *Instance) SetOutput(w io.Writer) {
+	i.out = w
+}
+
+// printf writes progress output for the instance
+func (i *Instance) printf(format string, args ...any) {
+	out := i.out
+	if out == nil {
+		out = os.Stdout
+	}
+	_, _ = fmt.Fprintf(out, format, args...)
+}
+
 // GetClient returns the underlying Kubernetes client
 func (i *Instance) GetClient() client.Client {
 	return i.client
@@ -142,12 +177,30 @@ func (i *Instance) prepareMetadata(metadata *metav1.ObjectMeta, newAnnotations m
 	// Ensure the managed-by annotation is set
 	metadata.Annotations["managed-by"] = managedByAnnotation
 
+	// Ensure the managed-by label is set, it is used to recognize the objects we own when pruning
+	if metadata.Labels == nil {
+		metadata.Labels = make(map[string]string)
+	}
+	metadata.Labels[ManagedByLabel] = i.managedByValue()
+
 	// Set namespace if not already set
 	if metadata.Namespace == "" {
 		metadata.Namespace = i.config.Spec.Namespace
 	}
 }
 
+// sleepContext waits for d, returning early with the context error if ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
 // validateInstance performs basic validation on a JumpstarterInstance
 func validateInstance(instance *v1alphaConfig.JumpstarterInstance) error {
 	if instance == nil {
@@ -164,17 +217,19 @@ func validateInstance(instance *v1alphaConfig.JumpstarterInstance) error {
 func (i *Instance) checkAndPrintDiff(oldObj, newObj interface{}, objType, objName string, dry bool) bool {
 	// Options to ignore Kubernetes metadata fields that change frequently
 	ignoreOpts := []cmp.Option{
+		cmpopts.IgnoreTypes(metav1.TypeMeta{}),
 		cmpopts.IgnoreFields(metav1.ObjectMeta{}, "Generation", "CreationTimestamp", "ResourceVersion", "UID", "ManagedFields"),
 		cmpopts.IgnoreFields(v1alpha1.Exporter{}, "Status"),
 		cmpopts.IgnoreFields(v1alpha1.Client{}, "Status"),
+		cmpopts.IgnoreFields(v1alpha1.ExporterAccessPolicy{}, "Status"),
 	}
 
 	diff := cmp.Diff(oldObj, newObj, ignoreOpts...)
 	if dry {
 		if diff != "" {
-			fmt.Printf("📝 [%s] dry run: Would update %s %s, diff: %s\n", i.config.Name, objType, objName, diff)
+			i.printf("📝 [%s] dry run: Would update %s %s, diff: %s\n", i.config.Name, objType, objName, diff)
 		} else {
-			fmt.Printf("✅ [%s] dry run: No changes needed for %s %s\n", i.config.Name, objType, objName)
+			i.printf("✅ [%s] dry run: No changes needed for %s %s\n", i.config.Name, objType, objName)
 		}
 	}
 
@@ -0,0 +1,67 @@
+package instance
+
+import (
+	"context"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/plan"
+)
+
+const (
+	// ManagedByLabel marks the controller objects owned by this tool, it can be used in label selectors
+	// (i.e. kubectl get clients -l app.kubernetes.io/managed-by=jumpstarter-lab-config)
+	ManagedByLabel = "app.kubernetes.io/managed-by"
+	// DefaultManagedBy is the default value of the ManagedByLabel
+	DefaultManagedBy = "jumpstarter-lab-config"
+)
+
+// SetManagedBy sets the ManagedByLabel value used to stamp and recognize the objects owned by this run,
+// which allows several configurations to share a namespace without pruning each other's objects
+func (i *Instance) SetManagedBy(managedBy string) {
+	i.managedBy = managedBy
+}
+
+// managedByValue returns the ManagedByLabel value for this instance
+func (i *Instance) managedByValue() string {
+	if i.managedBy == "" {
+		return DefaultManagedBy
+	}
+	return i.managedBy
+}
+
+// isOwned checks if an object in the cluster was created by this tool
+func (i *Instance) isOwned(obj client.Object) bool {
+	if value, ok := obj.GetLabels()[ManagedByLabel]; ok {
+		return value == i.managedByValue()
+	}
+	// objects created before the managed-by label existed only carry the legacy annotation
+	return i.managedByValue() == DefaultManagedBy && obj.GetAnnotations()["managed-by"] == managedByAnnotation
+}
+
+// pruneObject deletes an object that is no longer in the configuration. Only objects owned by this tool
+// are deleted and only when --prune is used, objects created by hand are reported as foreign.
+func (i *Instance) pruneObject(ctx context.Context, obj client.Object, objType string) error {
+	if !i.isOwned(obj) {
+		i.printf("👽 [%s] Foreign %s %s in namespace %s is not managed by %s, leaving it alone\n",
+			i.config.Name, objType, obj.GetName(), obj.GetNamespace(), i.managedByValue())
+		return nil
+	}
+
+	if !i.prune {
+		i.printf("⚠️  [%s] %s %s in namespace %s is not in the configuration, use --prune to delete it\n",
+			i.config.Name, objType, obj.GetName(), obj.GetNamespace())
+		return nil
+	}
+
+	if err := i.recordChange(objType, plan.ActionDelete, obj, nil); err != nil {
+		return err
+	}
+	if i.dryRun {
+		i.printf("🗑️ [%s] dry run: Would delete %s %s in namespace %s\n", i.config.Name, objType, obj.GetName(), obj.GetNamespace())
+		return nil
+	}
+	i.printf("🗑️ [%s] Deleting %s %s in namespace %s\n", i.config.Name, objType, obj.GetName(), obj.GetNamespace())
+
+	return client.IgnoreNotFound(i.client.Delete(ctx, obj))
+}
@@ -0,0 +1,130 @@
+package instance
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jumpstarter-dev/jumpstarter-controller/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1alphaConfig "github.com/jumpstarter-dev/jumpstarter-lab-config/api/v1alpha1"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/config"
+)
+
+func ownedClient(name, managedBy string) *v1alpha1.Client {
+	c := testClient(name, "test-ns", name)
+	c.Labels = map[string]string{ManagedByLabel: managedBy}
+	return c
+}
+
+func TestIsOwned(t *testing.T) {
+	inst := newTestInstance(t, false, false)
+
+	legacy := testClient("legacy", "test-ns", "legacy")
+	legacy.Annotations = map[string]string{"managed-by": managedByAnnotation}
+
+	assert.True(t, inst.isOwned(ownedClient("owned", DefaultManagedBy)))
+	assert.True(t, inst.isOwned(legacy), "objects with the legacy annotation are owned")
+	assert.False(t, inst.isOwned(testClient("manual", "test-ns", "manual")))
+	assert.False(t, inst.isOwned(ownedClient("other", "other-tool")))
+
+	inst.SetManagedBy("lab-b")
+	assert.True(t, inst.isOwned(ownedClient("owned", "lab-b")))
+	assert.False(t, inst.isOwned(ownedClient("owned", DefaultManagedBy)))
+	assert.False(t, inst.isOwned(legacy), "legacy objects belong to the default owner")
+}
+
+func TestPrepareMetadata_SetsManagedByLabel(t *testing.T) {
+	inst := newTestInstance(t, false, false)
+	inst.SetManagedBy("lab-b")
+
+	meta := metav1.ObjectMeta{Name: "alice"}
+	inst.prepareMetadata(&meta, nil)
+	assert.Equal(t, "lab-b", meta.Labels[ManagedByLabel])
+	assert.Equal(t, "test-ns", meta.Namespace)
+}
+
+func clientExists(t *testing.T, inst *Instance, name string) bool {
+	t.Helper()
+	err := inst.client.Get(context.Background(), client.ObjectKey{Namespace: "test-ns", Name: name}, &v1alpha1.Client{})
+	return err == nil
+}
+
+func TestSyncClients_Pruning(t *testing.T) {
+	emptyConfig := &config.Config{Loaded: &config.LoadedLabConfig{Clients: map[string]*v1alpha1.Client{}}}
+
+	t.Run("without prune nothing is deleted", func(t *testing.T) {
+		inst := newTestInstance(t, false, false, ownedClient("owned", DefaultManagedBy))
+		require.NoError(t, inst.SyncClients(context.Background(), emptyConfig, nil))
+		assert.True(t, clientExists(t, inst, "owned"))
+	})
+
+	t.Run("with prune only owned objects are deleted", func(t *testing.T) {
+		inst := newTestInstance(t, false, true,
+			ownedClient("owned", DefaultManagedBy),
+			ownedClient("other-owner", "other-tool"),
+			testClient("manual", "test-ns", "manual"),
+		)
+		require.NoError(t, inst.SyncClients(context.Background(), emptyConfig, nil))
+		assert.False(t, clientExists(t, inst, "owned"))
+		assert.True(t, clientExists(t, inst, "other-owner"))
+		assert.True(t, clientExists(t, inst, "manual"))
+	})
+
+	t.Run("dry run with prune does not delete", func(t *testing.T) {
+		inst := newTestInstance(t, true, true, ownedClient("owned", DefaultManagedBy))
+		require.NoError(t, inst.SyncClients(context.Background(), emptyConfig, nil))
+		assert.True(t, clientExists(t, inst, "owned"))
+	})
+}
+
+func TestSyncClients_ScopedToInstance(t *testing.T) {
+	// a client that moved to another instance is pruned from this one
+	inst := newTestInstance(t, false, true, ownedClient("moved", DefaultManagedBy))
+
+	everywhere := testClient("everywhere", "", "everywhere")
+	partner := testClient("partner", "", "partner")
+	partner.Annotations = map[string]string{v1alphaConfig.JumpstarterInstancesAnnotation: "partner-instance"}
+	moved := testClient("moved", "", "moved")
+	moved.Annotations = map[string]string{v1alphaConfig.JumpstarterInstancesAnnotation: "partner-instance"}
+	cfg := &config.Config{Loaded: &config.LoadedLabConfig{Clients: map[string]*v1alpha1.Client{
+		"everywhere": everywhere, "partner": partner, "moved": moved,
+	}}}
+
+	require.NoError(t, inst.SyncClients(context.Background(), cfg, nil))
+	assert.True(t, clientExists(t, inst, "everywhere"))
+	assert.False(t, clientExists(t, inst, "partner"))
+	assert.False(t, clientExists(t, inst, "moved"))
+}
+
+func TestSyncExporters_Pruning(t *testing.T) {
+	owned := &v1alpha1.Exporter{ObjectMeta: metav1.ObjectMeta{
+		Name: "owned", Namespace: "test-ns", Labels: map[string]string{ManagedByLabel: DefaultManagedBy},
+	}}
+	manual := &v1alpha1.Exporter{ObjectMeta: metav1.ObjectMeta{Name: "manual", Namespace: "test-ns"}}
+	emptyConfig := &config.Config{Loaded: &config.LoadedLabConfig{}}
+
+	exists := func(inst *Instance, name string) bool {
+		_, err := inst.getExporterByName(context.Background(), name)
+		return err == nil
+	}
+
+	t.Run("without prune nothing is deleted", func(t *testing.T) {
+		inst := newTestInstance(t, false, false, owned.DeepCopy(), manual.DeepCopy())
+		_, err := inst.SyncExporters(context.Background(), emptyConfig, nil, nil)
+		require.NoError(t, err)
+		assert.True(t, exists(inst, "owned"))
+		assert.True(t, exists(inst, "manual"))
+	})
+
+	t.Run("with prune only owned objects are deleted", func(t *testing.T) {
+		inst := newTestInstance(t, false, true, owned.DeepCopy(), manual.DeepCopy())
+		_, err := inst.SyncExporters(context.Background(), emptyConfig, nil, nil)
+		require.NoError(t, err)
+		assert.False(t, exists(inst, "owned"))
+		assert.True(t, exists(inst, "manual"))
+	})
+}
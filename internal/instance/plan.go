@@ -0,0 +1,44 @@
+package instance
+
+import (
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/plan"
+)
+
+// SetRecorder records every change of the sync in r, i.e. to build a plan from a dry-run
+func (i *Instance) SetRecorder(r *plan.Recorder) {
+	i.recorder = r
+}
+
+// recordChange records a change of an object, live and desired are nil when the object
+// doesn't exist before or after the change
+func (i *Instance) recordChange(objType string, action plan.Action, live, desired client.Object) error {
+	if i.recorder == nil {
+		return nil
+	}
+	change := plan.Change{
+		Target: plan.TargetInstance,
+		Scope:  i.config.Name,
+		Kind:   objType,
+		Action: action,
+	}
+	for _, obj := range []client.Object{live, desired} {
+		if obj != nil {
+			change.Namespace = obj.GetNamespace()
+			change.Name = obj.GetName()
+		}
+	}
+	var err error
+	if live != nil {
+		if change.Before, err = plan.HashObject(live); err != nil {
+			return err
+		}
+	}
+	if desired != nil {
+		if change.After, err = plan.HashObject(desired); err != nil {
+			return err
+		}
+	}
+	return i.recorder.Record(change)
+}
@@ -0,0 +1,122 @@
+package instance
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/jumpstarter-dev/jumpstarter-controller/api/v1alpha1"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/config"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// SyncPolicies creates, updates and (with --prune) deletes the ExporterAccessPolicy
+// objects targeting this instance so they match the configuration
+func (i *Instance) SyncPolicies(ctx context.Context, cfg *config.Config, filter *regexp.Regexp) error {
+	i.printf("\n🔄 [%s] Syncing policies ===========================\n\n", i.config.Name)
+	instancePolicies, err := i.listPolicies(ctx)
+	if err != nil {
+		return fmt.Errorf("[%s] failed to list policies: %w", i.config.Name, err)
+	}
+
+	configPolicyMap := cfg.Loaded.GetPoliciesForJumpstarterInstance(i.config)
+
+	// Apply filter if provided
+	configPolicyMap, instancePolicies.Items = applyPolicyFilter(filter, instancePolicies.Items, configPolicyMap)
+
+	// create a policyMap from instancePolicies
+	instancePolicyMap := make(map[string]v1alpha1.ExporterAccessPolicy)
+	for _, instPolicy := range instancePolicies.Items {
+		instancePolicyMap[instPolicy.Name] = instPolicy
+	}
+
+	// delete policies that are not in config
+	for _, instancePolicy := range instancePolicies.Items {
+		if _, ok := configPolicyMap[instancePolicy.Name]; !ok {
+			err := i.pruneObject(ctx, &instancePolicy, "policy")
+			if err != nil {
+				return fmt.Errorf("[%s] failed to delete policy %s: %w", i.config.Name, instancePolicy.Name, err)
+			}
+		}
+	}
+
+	// create policies that are in config but not in instance
+	for _, cfgPolicy := range configPolicyMap {
+		if _, ok := instancePolicyMap[cfgPolicy.Name]; !ok {
+			err := i.createPolicy(ctx, cfgPolicy)
+			if err != nil {
+				return fmt.Errorf("[%s] failed to create policy %s: %w", i.config.Name, cfgPolicy.Name, err)
+			}
+		}
+	}
+
+	// update policies that are in both config and instance
+	for _, instancePolicy := range instancePolicies.Items {
+		if cfgPolicy, ok := configPolicyMap[instancePolicy.Name]; ok {
+			err := i.updatePolicy(ctx, &instancePolicy, cfgPolicy)
+			if err != nil {
+				return fmt.Errorf("[%s] failed to update policy %s: %w", i.config.Name, instancePolicy.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func applyPolicyFilter(
+	filter *regexp.Regexp,
+	instancePolicyItems []v1alpha1.ExporterAccessPolicy,
+	configPolicyMap map[string]*v1alpha1.ExporterAccessPolicy,
+) (map[string]*v1alpha1.ExporterAccessPolicy, []v1alpha1.ExporterAccessPolicy) {
+	if filter == nil {
+		return configPolicyMap, instancePolicyItems
+	}
+
+	filteredInstanceItems := []v1alpha1.ExporterAccessPolicy{}
+	for _, item := range instancePolicyItems {
+		if filter.MatchString(item.Name) {
+			filteredInstanceItems = append(filteredInstanceItems, item)
+		}
+	}
+
+	filteredConfigPolicyMap := make(map[string]*v1alpha1.ExporterAccessPolicy)
+	for name, policy := range configPolicyMap {
+		if filter.MatchString(name) {
+			filteredConfigPolicyMap[name] = policy
+		}
+	}
+
+	return filteredConfigPolicyMap, filteredInstanceItems
+}
+
+// listPolicies lists all exporter access policies in the instance's namespace
+func (i *Instance) listPolicies(ctx context.Context) (*v1alpha1.ExporterAccessPolicyList, error) {
+	policies := &v1alpha1.ExporterAccessPolicyList{}
+	namespace := i.config.Spec.Namespace
+	if namespace == "" {
+		// If no namespace specified, list from all namespaces
+		err := i.client.List(ctx, policies)
+		return policies, err
+	}
+
+	err := i.client.List(ctx, policies, client.InNamespace(namespace))
+	return policies, err
+}
+
+// updatePolicy server-side applies the policy from the config over the existing one
+func (i *Instance) updatePolicy(ctx context.Context, oldPolicy, policy *v1alpha1.ExporterAccessPolicy) error {
+	desired := &v1alpha1.ExporterAccessPolicy{
+		ObjectMeta: i.desiredObjectMeta(&policy.ObjectMeta),
+		Spec:       policy.DeepCopy().Spec,
+	}
+	return i.applyObject(ctx, oldPolicy, desired, "policy")
+}
+
+// createPolicy creates a new exporter access policy
+func (i *Instance) createPolicy(ctx context.Context, policy *v1alpha1.ExporterAccessPolicy) error {
+	desired := &v1alpha1.ExporterAccessPolicy{
+		ObjectMeta: i.desiredObjectMeta(&policy.ObjectMeta),
+		Spec:       policy.DeepCopy().Spec,
+	}
+	return i.createObject(ctx, desired, "policy")
+}
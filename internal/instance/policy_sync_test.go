@@ -0,0 +1,117 @@
+package instance
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jumpstarter-dev/jumpstarter-controller/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1alphaConfig "github.com/jumpstarter-dev/jumpstarter-lab-config/api/v1alpha1"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/config"
+)
+
+func testPolicy(name, namespace string, priority int) *v1alpha1.ExporterAccessPolicy {
+	return &v1alpha1.ExporterAccessPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec: v1alpha1.ExporterAccessPolicySpec{
+			ExporterSelector: metav1.LabelSelector{MatchLabels: map[string]string{"dut-purpose": "ci"}},
+			Policies: []v1alpha1.Policy{
+				{Priority: priority},
+			},
+		},
+	}
+}
+
+func policyConfig(policies ...*v1alpha1.ExporterAccessPolicy) *config.Config {
+	loaded := &config.LoadedLabConfig{Policies: map[string]*v1alpha1.ExporterAccessPolicy{}}
+	for _, p := range policies {
+		loaded.Policies[p.Name] = p
+	}
+	return &config.Config{Loaded: loaded}
+}
+
+func getPolicy(t *testing.T, inst *Instance, name string) (*v1alpha1.ExporterAccessPolicy, error) {
+	t.Helper()
+	policy := &v1alpha1.ExporterAccessPolicy{}
+	err := inst.client.Get(context.Background(), client.ObjectKey{Namespace: "test-ns", Name: name}, policy)
+	return policy, err
+}
+
+func TestSyncPolicies_CreatesAndUpdates(t *testing.T) {
+	existing := testPolicy("existing", "test-ns", 1)
+	inst := newTestInstance(t, false, false, existing)
+
+	cfg := policyConfig(testPolicy("new", "", 10), testPolicy("existing", "", 20))
+	require.NoError(t, inst.SyncPolicies(context.Background(), cfg, nil))
+
+	created, err := getPolicy(t, inst, "new")
+	require.NoError(t, err)
+	assert.Equal(t, 10, created.Spec.Policies[0].Priority)
+	assert.Equal(t, managedByAnnotation, created.Annotations["managed-by"])
+
+	updated, err := getPolicy(t, inst, "existing")
+	require.NoError(t, err)
+	assert.Equal(t, 20, updated.Spec.Policies[0].Priority)
+
+	// the loaded configuration must not be modified by the sync
+	assert.Empty(t, cfg.Loaded.Policies["new"].Namespace)
+}
+
+func TestSyncPolicies_DryRun(t *testing.T) {
+	inst := newTestInstance(t, true, true, testPolicy("existing", "test-ns", 1), testPolicy("stale", "test-ns", 1))
+
+	cfg := policyConfig(testPolicy("new", "", 10), testPolicy("existing", "", 20))
+	require.NoError(t, inst.SyncPolicies(context.Background(), cfg, nil))
+
+	_, err := getPolicy(t, inst, "new")
+	assert.Error(t, err, "dry run must not create policies")
+
+	existing, err := getPolicy(t, inst, "existing")
+	require.NoError(t, err)
+	assert.Equal(t, 1, existing.Spec.Policies[0].Priority, "dry run must not update policies")
+
+	_, err = getPolicy(t, inst, "stale")
+	assert.NoError(t, err, "dry run must not delete policies")
+}
+
+func TestSyncPolicies_Prune(t *testing.T) {
+	t.Run("without prune stale policies are kept", func(t *testing.T) {
+		inst := newTestInstance(t, false, false, testPolicy("stale", "test-ns", 1))
+		require.NoError(t, inst.SyncPolicies(context.Background(), policyConfig(), nil))
+
+		_, err := getPolicy(t, inst, "stale")
+		assert.NoError(t, err)
+	})
+
+	t.Run("with prune stale policies are deleted", func(t *testing.T) {
+		stale := testPolicy("stale", "test-ns", 1)
+		stale.Labels = map[string]string{ManagedByLabel: DefaultManagedBy}
+		inst := newTestInstance(t, false, true, stale)
+		require.NoError(t, inst.SyncPolicies(context.Background(), policyConfig(), nil))
+
+		_, err := getPolicy(t, inst, "stale")
+		assert.Error(t, err)
+	})
+}
+
+func TestSyncPolicies_ScopedToInstance(t *testing.T) {
+	inst := newTestInstance(t, false, false)
+
+	otherNamespace := testPolicy("other-namespace", "other-ns", 1)
+	otherInstance := testPolicy("other-instance", "", 1)
+	otherInstance.Annotations = map[string]string{v1alphaConfig.JumpstarterInstancesAnnotation: "other, another"}
+	thisInstance := testPolicy("this-instance", "", 1)
+	thisInstance.Annotations = map[string]string{v1alphaConfig.JumpstarterInstancesAnnotation: "other,test-instance"}
+
+	cfg := policyConfig(otherNamespace, otherInstance, thisInstance)
+	require.NoError(t, inst.SyncPolicies(context.Background(), cfg, nil))
+
+	policies, err := inst.listPolicies(context.Background())
+	require.NoError(t, err)
+	require.Len(t, policies.Items, 1)
+	assert.Equal(t, "this-instance", policies.Items[0].Name)
+}
@@ -0,0 +1,114 @@
+package instance
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1alphaConfig "github.com/jumpstarter-dev/jumpstarter-lab-config/api/v1alpha1"
+)
+
+const (
+	// defaultCAConfigMapName is the ConfigMap published by the Jumpstarter controller with its CA bundle
+	defaultCAConfigMapName = "jumpstarter-service-ca-cert"
+	// defaultCAKey is the key holding the CA bundle when the reference doesn't set one
+	defaultCAKey = "ca.crt"
+)
+
+// ControllerTLS holds the TLS settings distributed to the exporters and clients of an instance
+type ControllerTLS struct {
+	// CA is the base64 encoded PEM CA bundle, as expected by the jumpstarter tls.ca setting
+	CA string
+	// Insecure disables the TLS verification
+	Insecure bool
+}
+
+// GetControllerTLS returns the TLS settings of the instance controller, the CA bundle is retrieved
+// from the cluster once and cached for the rest of the run. A missing CA bundle is only a warning
+// in dry run.
+func (i *Instance) GetControllerTLS(ctx context.Context) (*ControllerTLS, error) {
+	i.tlsMutex.Lock()
+	defer i.tlsMutex.Unlock()
+
+	if i.controllerTLS != nil {
+		return i.controllerTLS, nil
+	}
+
+	tlsConfig := i.config.Spec.TLS
+	if tlsConfig.Insecure {
+		i.controllerTLS = &ControllerTLS{Insecure: true}
+		return i.controllerTLS, nil
+	}
+
+	pem, err := i.readCABundle(ctx, tlsConfig)
+	if err != nil && i.dryRun {
+		// the CA is only needed to write the exporter configs, a dry run can report everything else
+		i.printf("⚠️  [%s] Controller CA bundle not found, applying will fail until it's published "+
+			"(or spec.tls.insecure is set in the JumpstarterInstance): %v\n", i.config.Name, err)
+		i.controllerTLS = &ControllerTLS{}
+		return i.controllerTLS, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("[%s] failed to retrieve the controller CA bundle "+
+			"(set spec.tls.insecure in the JumpstarterInstance to allow insecure connections): %w", i.config.Name, err)
+	}
+
+	i.controllerTLS = &ControllerTLS{CA: base64.StdEncoding.EncodeToString(pem)}
+	return i.controllerTLS, nil
+}
+
+// readCABundle reads the PEM CA bundle from the referenced Secret or ConfigMap
+func (i *Instance) readCABundle(ctx context.Context, tlsConfig v1alphaConfig.JumpstarterInstanceTLS) ([]byte, error) {
+	if ref := tlsConfig.CASecretRef; ref != nil {
+		key := i.caObjectKey(ref)
+		secret := &corev1.Secret{}
+		if err := i.client.Get(ctx, key, secret); err != nil {
+			return nil, fmt.Errorf("failed to get secret %s: %w", key, err)
+		}
+		return caBundleFromData(secret.Data, caKey(ref), "secret", key)
+	}
+
+	ref := tlsConfig.CAConfigMapRef
+	if ref == nil {
+		ref = &v1alphaConfig.CAReference{Name: defaultCAConfigMapName}
+	}
+	key := i.caObjectKey(ref)
+	configMap := &corev1.ConfigMap{}
+	if err := i.client.Get(ctx, key, configMap); err != nil {
+		return nil, fmt.Errorf("failed to get configmap %s: %w", key, err)
+	}
+	data := make(map[string][]byte, len(configMap.Data)+len(configMap.BinaryData))
+	for k, v := range configMap.BinaryData {
+		data[k] = v
+	}
+	for k, v := range configMap.Data {
+		data[k] = []byte(v)
+	}
+	return caBundleFromData(data, caKey(ref), "configmap", key)
+}
+
+func (i *Instance) caObjectKey(ref *v1alphaConfig.CAReference) client.ObjectKey {
+	namespace := ref.Namespace
+	if namespace == "" {
+		namespace = i.config.Spec.Namespace
+	}
+	return client.ObjectKey{Namespace: namespace, Name: ref.Name}
+}
+
+func caKey(ref *v1alphaConfig.CAReference) string {
+	if ref.Key == "" {
+		return defaultCAKey
+	}
+	return ref.Key
+}
+
+func caBundleFromData(data map[string][]byte, key, kind string, objKey client.ObjectKey) ([]byte, error) {
+	pem, ok := data[key]
+	if !ok || len(pem) == 0 {
+		return nil, fmt.Errorf("%s %s does not contain a CA bundle in key %s", kind, objKey, key)
+	}
+	return pem, nil
+}
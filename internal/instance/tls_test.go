@@ -0,0 +1,112 @@
+package instance
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v1alphaConfig "github.com/jumpstarter-dev/jumpstarter-lab-config/api/v1alpha1"
+	"github.com/jumpstarter-dev/jumpstarter-lab-config/internal/exporter/template"
+)
+
+const testCA = "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n"
+
+func TestGetControllerTLS(t *testing.T) {
+	encodedCA := base64.StdEncoding.EncodeToString([]byte(testCA))
+
+	t.Run("default configmap", func(t *testing.T) {
+		inst := newTestInstance(t, false, false, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: defaultCAConfigMapName, Namespace: "test-ns"},
+			Data:       map[string]string{defaultCAKey: testCA},
+		})
+
+		controllerTLS, err := inst.GetControllerTLS(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, encodedCA, controllerTLS.CA)
+		assert.False(t, controllerTLS.Insecure)
+	})
+
+	t.Run("secret reference takes precedence", func(t *testing.T) {
+		inst := newTestInstance(t, false, false,
+			&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: defaultCAConfigMapName, Namespace: "test-ns"},
+				Data:       map[string]string{defaultCAKey: "wrong"},
+			},
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "router-ca", Namespace: "openshift-ingress"},
+				Data:       map[string][]byte{"tls.crt": []byte(testCA)},
+			},
+		)
+		inst.config.Spec.TLS = v1alphaConfig.JumpstarterInstanceTLS{
+			CASecretRef: &v1alphaConfig.CAReference{Name: "router-ca", Namespace: "openshift-ingress", Key: "tls.crt"},
+		}
+
+		controllerTLS, err := inst.GetControllerTLS(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, encodedCA, controllerTLS.CA)
+	})
+
+	t.Run("missing CA bundle is an error", func(t *testing.T) {
+		inst := newTestInstance(t, false, false)
+		_, err := inst.GetControllerTLS(context.Background())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "spec.tls.insecure")
+	})
+
+	t.Run("missing CA bundle is a warning in dry run", func(t *testing.T) {
+		inst := newTestInstance(t, true, false)
+		var out bytes.Buffer
+		inst.out = &out
+		controllerTLS, err := inst.GetControllerTLS(context.Background())
+		require.NoError(t, err)
+		assert.Empty(t, controllerTLS.CA)
+		assert.False(t, controllerTLS.Insecure)
+		assert.Contains(t, out.String(), "Controller CA bundle not found")
+	})
+
+	t.Run("missing key is an error", func(t *testing.T) {
+		inst := newTestInstance(t, false, false, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "custom", Namespace: "test-ns"},
+			Data:       map[string]string{"other": testCA},
+		})
+		inst.config.Spec.TLS.CAConfigMapRef = &v1alphaConfig.CAReference{Name: "custom"}
+		_, err := inst.GetControllerTLS(context.Background())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "does not contain a CA bundle")
+	})
+
+	t.Run("insecure is opt-in and skips the lookup", func(t *testing.T) {
+		inst := newTestInstance(t, false, false)
+		inst.config.Spec.TLS.Insecure = true
+
+		controllerTLS, err := inst.GetControllerTLS(context.Background())
+		require.NoError(t, err)
+		assert.True(t, controllerTLS.Insecure)
+		assert.Empty(t, controllerTLS.CA)
+	})
+}
+
+func TestServiceParametersUseControllerCA(t *testing.T) {
+	inst := newTestInstance(t, false, false, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: defaultCAConfigMapName, Namespace: "test-ns"},
+		Data:       map[string]string{defaultCAKey: testCA},
+	})
+
+	params, err := inst.serviceParameters(context.Background(), "token")
+	require.NoError(t, err)
+	assert.Equal(t, template.ServiceParameters{
+		TlsCA: base64.StdEncoding.EncodeToString([]byte(testCA)),
+		Token: "token",
+	}, *params)
+
+	rendered := params.Parameters()
+	value, ok := rendered.Get("tls_insecure")
+	require.True(t, ok)
+	assert.Equal(t, "false", value)
+}
@@ -0,0 +1,48 @@
+package output
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// Buffer collects output from concurrent writers so it can be flushed at once,
+// keeping the logs of parallel operations readable.
+type Buffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+// NewBuffer creates an empty Buffer.
+func NewBuffer() *Buffer {
+	return &Buffer{}
+}
+
+// Write appends p to the buffer, it is safe for concurrent use.
+func (b *Buffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// String returns the buffered output.
+func (b *Buffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// Len returns the number of buffered bytes.
+func (b *Buffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Len()
+}
+
+// Flush writes the buffered output to w and empties the buffer.
+func (b *Buffer) Flush(w io.Writer) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_, err := b.buf.WriteTo(w)
+	return err
+}
@@ -0,0 +1,31 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer(t *testing.T) {
+	b := NewBuffer()
+
+	var wg sync.WaitGroup
+	for n := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = fmt.Fprintf(b, "line %d\n", n)
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, b.String(), 70)
+
+	var out bytes.Buffer
+	require.NoError(t, b.Flush(&out))
+	assert.Len(t, out.String(), 70)
+	assert.Empty(t, b.String())
+}
@@ -0,0 +1,330 @@
+/*
+Copyright 2025. The Jumpstarter Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package plan records the changes a sync would make, so they can be reviewed
+// and applied later only if the live state did not drift in the meantime.
+package plan
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Version is the version of the plan file format
+const Version = 3
+
+// Action is the kind of change made to a target
+type Action string
+
+const (
+	ActionCreate  Action = "create"
+	ActionUpdate  Action = "update"
+	ActionDelete  Action = "delete"
+	ActionRestart Action = "restart"
+	ActionUpgrade Action = "upgrade"
+	ActionStop    Action = "stop"
+	ActionSwitch  Action = "switch"
+)
+
+const (
+	// TargetInstance marks changes to the objects of a jumpstarter instance
+	TargetInstance = "instance"
+	// TargetHost marks changes to an exporter host
+	TargetHost = "host"
+)
+
+// Change is a single create/update/delete of a k8s object or host file, or a host service action.
+// Before and After are the hashes of the live and desired state, empty when the object doesn't exist.
+type Change struct {
+	Target    string `json:"target"`
+	Scope     string `json:"scope"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	Action    Action `json:"action"`
+	Before    string `json:"before,omitempty"`
+	After     string `json:"after,omitempty"`
+}
+
+// key identifies the target of a change
+func (c Change) key() string {
+	return strings.Join([]string{c.Target, c.Scope, c.Kind, c.Namespace, c.Name, string(c.Action)}, "/")
+}
+
+func (c Change) String() string {
+	name := c.Name
+	if c.Namespace != "" {
+		name = c.Namespace + "/" + c.Name
+	}
+	return fmt.Sprintf("%s %s %s on %s %s", c.Action, c.Kind, name, c.Target, c.Scope)
+}
+
+// Options are the apply options the plan was made with, they are reused when the plan is applied
+type Options struct {
+	Prune              bool   `json:"prune"`
+	ManagedBy          string `json:"managedBy"`
+	ForceConflicts     bool   `json:"forceConflicts,omitempty"`
+	FilterClients      string `json:"filterClients,omitempty"`
+	FilterExporters    string `json:"filterExporters,omitempty"`
+	FilterPolicies     string `json:"filterPolicies,omitempty"`
+	AllowMassDelete    bool   `json:"allowMassDelete,omitempty"`
+	MaxDeletions       int    `json:"maxDeletions"`
+	MaxDeletionPercent int    `json:"maxDeletionPercent"`
+
+	// exporter host options
+	MaxConcurrentUpgrades int      `json:"maxConcurrentUpgrades,omitempty"`
+	HealthTimeout         Duration `json:"healthTimeout"`
+	ForceRestarts         bool     `json:"forceRestarts,omitempty"`
+	CanaryHosts           []string `json:"canaryHosts,omitempty"`
+	CanarySelector        string   `json:"canarySelector,omitempty"`
+	MaxUnavailable        int      `json:"maxUnavailable,omitempty"`
+	MaxFailurePercent     int      `json:"maxFailurePercent,omitempty"`
+	PowerCycleAfter       int      `json:"powerCycleAfter,omitempty"`
+	MaxPowerCycles        int      `json:"maxPowerCycles"`
+	PowerCycleCooldown    Duration `json:"powerCycleCooldown"`
+	BootTimeout           Duration `json:"bootTimeout"`
+}
+
+// hash returns the hash of the options, stored in the plan to detect edited options
+func (o Options) hash() string {
+	// plain fields and durations, marshaling can't fail
+	data, _ := json.Marshal(o)
+	return HashContent(string(data))
+}
+
+// Duration is a time.Duration stored as a string like 2m0s
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid duration %s: %w", data, err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q: %w", s, err)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Plan is the machine-readable list of changes of a dry-run
+type Plan struct {
+	Version     int       `json:"version"`
+	CreatedAt   time.Time `json:"createdAt"`
+	Options     Options   `json:"options"`
+	OptionsHash string    `json:"optionsHash"`
+	Changes     []Change  `json:"changes"`
+}
+
+// ErrUnplanned is returned when recording a change which isn't in the plan being applied
+var ErrUnplanned = errors.New("change not in the plan")
+
+// Recorder collects the changes of a sync, it is safe for concurrent use and a nil
+// Recorder discards everything. A Recorder made by Enforce also refuses the changes
+// which aren't in its plan.
+type Recorder struct {
+	mu      sync.Mutex
+	changes []Change
+	planned map[string]Change
+}
+
+// NewRecorder creates an empty Recorder
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// Enforce returns a Recorder refusing the changes which aren't in the plan, with the
+// planned live and desired state
+func (p *Plan) Enforce() *Recorder {
+	planned := make(map[string]Change, len(p.Changes))
+	for _, c := range p.Changes {
+		planned[c.key()] = c
+	}
+	return &Recorder{planned: planned}
+}
+
+// Record adds a change. It returns an error wrapping ErrUnplanned when the Recorder enforces
+// a plan without this change, the change must then not be made.
+func (r *Recorder) Record(c Change) error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.planned != nil {
+		old, ok := r.planned[c.key()]
+		if !ok || old.Before != c.Before || old.After != c.After {
+			return fmt.Errorf("refusing to %s: %w", c, ErrUnplanned)
+		}
+	}
+	r.changes = append(r.changes, c)
+	return nil
+}
+
+// Plan returns the recorded changes in a stable order
+func (r *Recorder) Plan(options Options) *Plan {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	changes := slices.Clone(r.changes)
+	slices.SortFunc(changes, func(a, b Change) int {
+		return strings.Compare(a.key(), b.key())
+	})
+	if changes == nil {
+		changes = []Change{}
+	}
+	return &Plan{
+		Version:     Version,
+		CreatedAt:   time.Now().UTC(),
+		Options:     options,
+		OptionsHash: options.hash(),
+		Changes:     changes,
+	}
+}
+
+// Save writes the plan as JSON
+func (p *Plan) Save(path string) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal plan: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write plan %s: %w", path, err)
+	}
+	return nil
+}
+
+// Load reads a plan written by Save
+func Load(path string) (*Plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan %s: %w", path, err)
+	}
+	p := &Plan{}
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("failed to parse plan %s: %w", path, err)
+	}
+	if p.Version != Version {
+		return nil, fmt.Errorf("unsupported plan version %d in %s, expected %d", p.Version, path, Version)
+	}
+	if p.Options.hash() != p.OptionsHash {
+		return nil, fmt.Errorf("the options of plan %s were changed after it was made, make a new plan", path)
+	}
+	return p, nil
+}
+
+// Drift returns the differences between the planned changes and the changes needed now,
+// an empty result means the plan can be applied as is
+func (p *Plan) Drift(current *Plan) []string {
+	planned := make(map[string]Change, len(p.Changes))
+	for _, c := range p.Changes {
+		planned[c.key()] = c
+	}
+
+	var drift []string
+	for _, c := range current.Changes {
+		old, ok := planned[c.key()]
+		delete(planned, c.key())
+		switch {
+		case !ok:
+			drift = append(drift, "unplanned change: "+c.String())
+		case old.Before != c.Before:
+			drift = append(drift, fmt.Sprintf("live state changed: %s (planned from %s, now %s)",
+				c, shortHash(old.Before), shortHash(c.Before)))
+		case old.After != c.After:
+			drift = append(drift, fmt.Sprintf("desired state changed: %s (planned %s, now %s)",
+				c, shortHash(old.After), shortHash(c.After)))
+		}
+	}
+	for _, c := range p.Changes {
+		if _, ok := planned[c.key()]; ok {
+			drift = append(drift, "planned change no longer needed: "+c.String())
+		}
+	}
+	slices.Sort(drift)
+	return drift
+}
+
+func shortHash(h string) string {
+	if h == "" {
+		return "<none>"
+	}
+	if len(h) > 12 {
+		return h[:12]
+	}
+	return h
+}
+
+// HashContent returns the hash of a file content
+func HashContent(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// maskedSecret replaces the secrets in the hashed contents
+const maskedSecret = "<masked>"
+
+// HashMasked returns the hash of a file content with the secrets masked, so a credential issued
+// after the plan was made, i.e. the token of a new exporter, doesn't change it
+func HashMasked(content string, secrets []string) string {
+	for _, secret := range secrets {
+		if secret != "" {
+			content = strings.ReplaceAll(content, secret, maskedSecret)
+		}
+	}
+	return HashContent(content)
+}
+
+// volatileMetadata lists the metadata fields set by the API server, they don't describe the desired state
+var volatileMetadata = []string{"resourceVersion", "uid", "generation", "creationTimestamp", "managedFields"}
+
+// HashObject returns the hash of a k8s object, ignoring the type, status and server populated metadata
+func HashObject(obj any) (string, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal object: %w", err)
+	}
+	fields := map[string]any{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return "", fmt.Errorf("failed to unmarshal object: %w", err)
+	}
+	delete(fields, "apiVersion")
+	delete(fields, "kind")
+	delete(fields, "status")
+	if metadata, ok := fields["metadata"].(map[string]any); ok {
+		for _, field := range volatileMetadata {
+			delete(metadata, field)
+		}
+	}
+	// maps are marshaled with sorted keys, the result is stable
+	normalized, err := json.Marshal(fields)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal object: %w", err)
+	}
+	return HashContent(string(normalized)), nil
+}
@@ -0,0 +1,138 @@
+package plan
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestHashObject_IgnoresServerFields(t *testing.T) {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "ns", Labels: map[string]string{"a": "b"}},
+		Data:       map[string]string{"key": "value"},
+	}
+	before, err := HashObject(cm)
+	require.NoError(t, err)
+
+	live := cm.DeepCopy()
+	live.TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"}
+	live.ResourceVersion = "42"
+	live.UID = "1234"
+	live.Generation = 3
+	live.CreationTimestamp = metav1.Now()
+	after, err := HashObject(live)
+	require.NoError(t, err)
+	assert.Equal(t, before, after)
+
+	live.Data["key"] = "other"
+	changed, err := HashObject(live)
+	require.NoError(t, err)
+	assert.NotEqual(t, before, changed)
+}
+
+func TestRecorder_NilIsNoop(t *testing.T) {
+	var r *Recorder
+	assert.NotPanics(t, func() {
+		assert.NoError(t, r.Record(Change{Name: "x"}))
+	})
+}
+
+func TestPlan_Enforce(t *testing.T) {
+	update := Change{Target: TargetInstance, Scope: "prod", Kind: "client", Namespace: "ns", Name: "c1", Action: ActionUpdate, Before: "b1", After: "a1"}
+	restart := Change{Target: TargetHost, Scope: "host-1", Kind: "service", Name: "exporter-e1", Action: ActionRestart}
+	r := (&Plan{Changes: []Change{update, restart}}).Enforce()
+
+	require.NoError(t, r.Record(restart))
+
+	liveChanged := update
+	liveChanged.Before = "b2"
+	assert.ErrorIs(t, r.Record(liveChanged), ErrUnplanned)
+
+	unplanned := Change{Target: TargetHost, Scope: "host-2", Kind: "file", Name: "/etc/a", Action: ActionDelete, Before: "x"}
+	err := r.Record(unplanned)
+	assert.ErrorIs(t, err, ErrUnplanned)
+	assert.ErrorContains(t, err, "refusing to delete file /etc/a on host host-2")
+
+	assert.Equal(t, []Change{restart}, r.Plan(Options{}).Changes, "only the planned changes are recorded")
+}
+
+func TestPlan_SaveLoad(t *testing.T) {
+	r := NewRecorder()
+	require.NoError(t, r.Record(Change{Target: TargetHost, Scope: "host-1", Kind: "file", Name: "/etc/a", Action: ActionCreate, After: HashContent("a")}))
+	require.NoError(t, r.Record(Change{Target: TargetInstance, Scope: "prod", Kind: "client", Namespace: "ns", Name: "c", Action: ActionDelete, Before: "x"}))
+	p := r.Plan(Options{Prune: true, ManagedBy: "lab", HealthTimeout: Duration(2 * time.Minute), CanaryHosts: []string{"host-1"}})
+
+	path := filepath.Join(t.TempDir(), "plan.json")
+	require.NoError(t, p.Save(path))
+
+	loaded, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, p.Options, loaded.Options)
+	assert.Equal(t, p.Changes, loaded.Changes)
+	// changes are sorted by target
+	assert.Equal(t, TargetHost, loaded.Changes[0].Target)
+}
+
+func TestPlan_Drift(t *testing.T) {
+	create := Change{Target: TargetInstance, Scope: "prod", Kind: "exporter", Namespace: "ns", Name: "e1", Action: ActionCreate, After: "a1"}
+	update := Change{Target: TargetInstance, Scope: "prod", Kind: "client", Namespace: "ns", Name: "c1", Action: ActionUpdate, Before: "b1", After: "a1"}
+	restart := Change{Target: TargetHost, Scope: "host-1", Kind: "service", Name: "exporter-e1", Action: ActionRestart}
+	planned := &Plan{Changes: []Change{create, update, restart}}
+
+	t.Run("no drift", func(t *testing.T) {
+		assert.Empty(t, planned.Drift(&Plan{Changes: []Change{restart, update, create}}))
+	})
+
+	t.Run("drift", func(t *testing.T) {
+		liveChanged := update
+		liveChanged.Before = "b2"
+		unplanned := Change{Target: TargetInstance, Scope: "prod", Kind: "policy", Namespace: "ns", Name: "p1", Action: ActionDelete, Before: "p"}
+		drift := planned.Drift(&Plan{Changes: []Change{create, liveChanged, unplanned}})
+		require.Len(t, drift, 3)
+		assert.Contains(t, drift, "live state changed: update client ns/c1 on instance prod (planned from b1, now b2)")
+		assert.Contains(t, drift, "unplanned change: delete policy ns/p1 on instance prod")
+		assert.Contains(t, drift, "planned change no longer needed: restart service exporter-e1 on host host-1")
+	})
+
+	t.Run("desired state changed", func(t *testing.T) {
+		desired := create
+		desired.After = "a2"
+		drift := planned.Drift(&Plan{Changes: []Change{desired, update, restart}})
+		assert.Equal(t, []string{"desired state changed: create exporter ns/e1 on instance prod (planned a1, now a2)"}, drift)
+	})
+}
+
+func TestLoad_RejectsUnknownVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plan.json")
+	require.NoError(t, (&Plan{Version: 99}).Save(path))
+	_, err := Load(path)
+	require.ErrorContains(t, err, "unsupported plan version 99")
+}
+
+func TestLoad_RejectsChangedOptions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plan.json")
+	require.NoError(t, NewRecorder().Plan(Options{MaxDeletions: 10, MaxPowerCycles: 3}).Save(path))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"maxPowerCycles": 3`)
+	edited := strings.Replace(string(data), `"maxPowerCycles": 3`, `"maxPowerCycles": 30`, 1)
+	require.NoError(t, os.WriteFile(path, []byte(edited), 0644))
+
+	_, err = Load(path)
+	require.ErrorContains(t, err, "options of plan "+path+" were changed")
+}
+
+func TestHashMasked(t *testing.T) {
+	planned := HashMasked("token: \"<dry-run-token>\"\n", []string{"<dry-run-token>"})
+	assert.Equal(t, planned, HashMasked("token: \"issued\"\n", []string{"issued"}))
+	assert.NotEqual(t, planned, HashMasked("token: \"issued\"\nendpoint: other\n", []string{"issued"}))
+	assert.Equal(t, HashContent("content"), HashMasked("content", []string{""}))
+}
@@ -0,0 +1,228 @@
+package power
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gosnmp/gosnmp"
+
+	api "github.com/jumpstarter-dev/jumpstarter-lab-config/api/v1alpha1"
+)
+
+// State is the state of an outlet, the value of its switching OID
+type State int64
+
+const (
+	Off State = 0
+	On  State = 1
+)
+
+func (s State) String() string {
+	switch s {
+	case Off:
+		return "off"
+	case On:
+		return "on"
+	}
+	return "unknown (" + strconv.FormatInt(int64(s), 10) + ")"
+}
+
+// SNMPOutlet switches the outlet of a PDU over SNMPv3, with the user-based security model at the
+// security level of its configuration: noAuthNoPriv without password, authNoPriv with a password,
+// and authPriv with a privacy protocol too. The outlet is switched by setting its OID, the
+// switching OID of the PDU followed by the plug number, to 1 (on) or 0 (off).
+type SNMPOutlet struct {
+	// Addr is the address of the SNMP agent of the PDU, port 161 by default
+	Addr string
+	User string
+	// OID is the OID of the outlet
+	OID string
+	// Timeout bounds every attempt of a request
+	Timeout time.Duration
+	// Retries is the number of times a request is sent again without response
+	Retries int
+
+	// flags is the security level of the requests
+	flags gosnmp.SnmpV3MsgFlags
+	// security holds the protocols and the passwords of the user
+	security *gosnmp.UsmSecurityParameters
+}
+
+// NewSNMPOutlet returns the outlet of an exporter host power configuration
+func NewSNMPOutlet(snmp api.SNMPPower) (*SNMPOutlet, error) {
+	if snmp.Host == "" {
+		return nil, errors.New("no SNMP host in the power configuration")
+	}
+	if snmp.User == "" {
+		return nil, errors.New("no SNMP user in the power configuration")
+	}
+	if snmp.OID == "" {
+		return nil, errors.New("no SNMP OID in the power configuration")
+	}
+	if snmp.Plug <= 0 {
+		return nil, errors.New("no SNMP plug in the power configuration")
+	}
+	for _, arc := range strings.Split(snmp.OID, ".") {
+		if _, err := strconv.ParseUint(arc, 10, 32); err != nil {
+			return nil, fmt.Errorf("invalid SNMP OID %q", snmp.OID)
+		}
+	}
+	flags, security, err := userSecurity(snmp)
+	if err != nil {
+		return nil, err
+	}
+	outlet := &SNMPOutlet{Addr: snmp.Host, User: snmp.User, OID: fmt.Sprintf("%s.%d", snmp.OID, snmp.Plug),
+		Timeout: 5 * time.Second, Retries: 2, flags: flags, security: security}
+	if _, _, err := net.SplitHostPort(snmp.Host); err != nil {
+		outlet.Addr = net.JoinHostPort(snmp.Host, "161")
+	}
+	return outlet, nil
+}
+
+// authProtocols are the authentication protocols of RFC 3414 and RFC 7860, by name
+var authProtocols = map[string]gosnmp.SnmpV3AuthProtocol{
+	"MD5":    gosnmp.MD5,
+	"SHA":    gosnmp.SHA,
+	"SHA224": gosnmp.SHA224,
+	"SHA256": gosnmp.SHA256,
+	"SHA384": gosnmp.SHA384,
+	"SHA512": gosnmp.SHA512,
+}
+
+// privProtocols are the privacy protocols of RFC 3414 (DES-CBC) and RFC 3826 (AES-128-CFB), by name
+var privProtocols = map[string]gosnmp.SnmpV3PrivProtocol{
+	"DES": gosnmp.DES,
+	"AES": gosnmp.AES,
+}
+
+// userSecurity returns the security level and the security parameters of the SNMP user of a power
+// configuration. The protocols which aren't supported are refused, rather than falling back to a
+// weaker level.
+func userSecurity(snmp api.SNMPPower) (gosnmp.SnmpV3MsgFlags, *gosnmp.UsmSecurityParameters, error) {
+	security := &gosnmp.UsmSecurityParameters{UserName: snmp.User}
+	if snmp.Password == "" {
+		if snmp.AuthProtocol != "" || snmp.PrivProtocol != "" || snmp.PrivPassword != "" {
+			return 0, nil, errors.New("the SNMP auth and privacy protocols need a password")
+		}
+		return gosnmp.NoAuthNoPriv, security, nil
+	}
+	authName := snmp.AuthProtocol
+	if authName == "" {
+		authName = "SHA"
+	}
+	auth, ok := authProtocols[authName]
+	if !ok {
+		return 0, nil, fmt.Errorf("unsupported SNMP auth protocol %q, expected MD5, SHA, SHA224, SHA256, SHA384 or SHA512",
+			snmp.AuthProtocol)
+	}
+	// RFC 3414 requires passwords of at least 8 characters, the agents refuse shorter ones
+	if len(snmp.Password) < 8 {
+		return 0, nil, errors.New("the SNMP password must have at least 8 characters")
+	}
+	security.AuthenticationProtocol, security.AuthenticationPassphrase = auth, snmp.Password
+
+	if snmp.PrivProtocol == "" {
+		if snmp.PrivPassword != "" {
+			return 0, nil, errors.New("the SNMP privacy password needs a privacy protocol")
+		}
+		return gosnmp.AuthNoPriv, security, nil
+	}
+	priv, ok := privProtocols[snmp.PrivProtocol]
+	if !ok {
+		return 0, nil, fmt.Errorf("unsupported SNMP privacy protocol %q, expected DES or AES", snmp.PrivProtocol)
+	}
+	privPassword := snmp.PrivPassword
+	if privPassword == "" {
+		privPassword = snmp.Password
+	}
+	if len(privPassword) < 8 {
+		return 0, nil, errors.New("the SNMP privacy password must have at least 8 characters")
+	}
+	security.PrivacyProtocol, security.PrivacyPassphrase = priv, privPassword
+	return gosnmp.AuthPriv, security, nil
+}
+
+// Status returns the state of the outlet
+func (o *SNMPOutlet) Status(ctx context.Context) (State, error) {
+	v, err := o.request(ctx, "get", func(client *gosnmp.GoSNMP) (*gosnmp.SnmpPacket, error) {
+		return client.Get([]string{o.OID})
+	})
+	if err != nil {
+		return 0, err
+	}
+	if v.Type != gosnmp.Integer {
+		return 0, fmt.Errorf("unexpected %s value of %s on the PDU %s", v.Type, o.OID, o.Addr)
+	}
+	return State(gosnmp.ToBigInt(v.Value).Int64()), nil
+}
+
+// Set switches the outlet on or off
+func (o *SNMPOutlet) Set(ctx context.Context, state State) error {
+	_, err := o.request(ctx, "set", func(client *gosnmp.GoSNMP) (*gosnmp.SnmpPacket, error) {
+		return client.Set([]gosnmp.SnmpPDU{{Name: o.OID, Type: gosnmp.Integer, Value: int(state)}})
+	})
+	return err
+}
+
+// Cycle switches the outlet off, then on again after delay
+func (o *SNMPOutlet) Cycle(ctx context.Context, delay time.Duration) error {
+	if err := o.Set(ctx, Off); err != nil {
+		return err
+	}
+	select {
+	case <-time.After(delay):
+	case <-ctx.Done():
+		return fmt.Errorf("interrupted with the outlet off: %w", ctx.Err())
+	}
+	return o.Set(ctx, On)
+}
+
+// request sends a request to the agent of the PDU with a new client, discovering its SNMP engine
+// first, and returns the single varbind of its response. verb names the operation in the errors.
+func (o *SNMPOutlet) request(ctx context.Context, verb string,
+	send func(*gosnmp.GoSNMP) (*gosnmp.SnmpPacket, error)) (gosnmp.SnmpPDU, error) {
+	host, port, err := net.SplitHostPort(o.Addr)
+	if err != nil {
+		return gosnmp.SnmpPDU{}, fmt.Errorf("invalid PDU address %q: %w", o.Addr, err)
+	}
+	portNumber, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return gosnmp.SnmpPDU{}, fmt.Errorf("invalid PDU port %q: %w", port, err)
+	}
+	client := &gosnmp.GoSNMP{
+		Target:             host,
+		Port:               uint16(portNumber),
+		Transport:          "udp",
+		Context:            ctx,
+		Timeout:            o.Timeout,
+		Retries:            o.Retries,
+		MaxOids:            gosnmp.MaxOids,
+		Version:            gosnmp.Version3,
+		SecurityModel:      gosnmp.UserSecurityModel,
+		MsgFlags:           o.flags,
+		SecurityParameters: o.security.Copy(),
+	}
+	if err := client.Connect(); err != nil {
+		return gosnmp.SnmpPDU{}, fmt.Errorf("failed to connect to the PDU %s: %w", o.Addr, err)
+	}
+	defer func() {
+		_ = client.Conn.Close()
+	}()
+
+	response, err := send(client)
+	if err != nil {
+		return gosnmp.SnmpPDU{}, fmt.Errorf("failed to %s %s on the PDU %s: %w", verb, o.OID, o.Addr, err)
+	}
+	if response.Error != gosnmp.NoError {
+		return gosnmp.SnmpPDU{}, fmt.Errorf("the PDU %s failed to %s %s: %s", o.Addr, verb, o.OID, response.Error)
+	}
+	if len(response.Variables) != 1 {
+		return gosnmp.SnmpPDU{}, fmt.Errorf("unexpected %d varbinds in the response of %s", len(response.Variables), o.Addr)
+	}
+	return response.Variables[0], nil
+}
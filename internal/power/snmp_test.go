@@ -0,0 +1,285 @@
+package power
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gosnmp/gosnmp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	api "github.com/jumpstarter-dev/jumpstarter-lab-config/api/v1alpha1"
+)
+
+const testOID = "1.3.6.1.4.1.13742.6.4.1.2.1.2.1"
+
+// the USM statistics reported on the security errors, RFC 3414
+const (
+	usmStatsUnsupportedSecLevels = ".1.3.6.1.6.3.15.1.1.1.0"
+	usmStatsUnknownUserNames     = ".1.3.6.1.6.3.15.1.1.3.0"
+	usmStatsUnknownEngineIDs     = ".1.3.6.1.6.3.15.1.1.4.0"
+	usmStatsWrongDigests         = ".1.3.6.1.6.3.15.1.1.5.0"
+	usmStatsDecryptionErrors     = ".1.3.6.1.6.3.15.1.1.6.0"
+)
+
+// testAgent is an in-process SNMPv3 agent with a single user, holding integer objects
+type testAgent struct {
+	addr string
+	// flags is the security level of the user
+	flags gosnmp.SnmpV3MsgFlags
+	// security holds the user and its keys, localized to the engine of the agent
+	security *gosnmp.UsmSecurityParameters
+
+	mu      sync.Mutex
+	objects map[string]int64
+	sets    []State
+	// silent drops the requests instead of answering them
+	silent bool
+}
+
+func startTestAgent(t *testing.T, snmp api.SNMPPower, objects map[string]int64) *testAgent {
+	t.Helper()
+	flags, security, err := userSecurity(snmp)
+	require.NoError(t, err)
+	security.AuthoritativeEngineID = "\x80\x00\x1f\x88\x04test-pdu"
+	security.AuthoritativeEngineBoots, security.AuthoritativeEngineTime = 1, 1000
+	require.NoError(t, security.InitSecurityKeys())
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	agent := &testAgent{addr: conn.LocalAddr().String(), flags: flags, security: security, objects: objects}
+	go agent.serve(conn)
+	return agent
+}
+
+func (a *testAgent) serve(conn net.PacketConn) {
+	buf := make([]byte, 65535)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if response := a.handle(buf[:n]); response != nil {
+			_, _ = conn.WriteTo(response, addr)
+		}
+	}
+}
+
+// handle returns the response to a request, nil to drop it
+func (a *testAgent) handle(packet []byte) []byte {
+	decoder := &gosnmp.GoSNMP{Version: gosnmp.Version3, SecurityModel: gosnmp.UserSecurityModel, MsgFlags: a.flags,
+		SecurityParameters: a.security.Copy()}
+	// the header is decoded even when the scoped PDU can't be decrypted
+	request, decodeErr := decoder.SnmpDecodePacket(bytes.Clone(packet))
+	usm, ok := request.SecurityParameters.(*gosnmp.UsmSecurityParameters)
+	if request.Version != gosnmp.Version3 || !ok {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.silent {
+		return nil
+	}
+
+	response := &gosnmp.SnmpPacket{Version: gosnmp.Version3, SecurityModel: gosnmp.UserSecurityModel,
+		MsgID: request.MsgID, RequestID: request.RequestID, ContextEngineID: a.security.AuthoritativeEngineID}
+	security := a.security.Copy().(*gosnmp.UsmSecurityParameters)
+	security.UserName = usm.UserName
+	response.SecurityParameters = security
+	report := func(oid string) []byte {
+		response.PDUType = gosnmp.Report
+		response.Variables = []gosnmp.SnmpPDU{{Name: oid, Type: gosnmp.Counter32, Value: uint32(1)}}
+		encoded, _ := response.MarshalMsg()
+		return encoded
+	}
+	switch {
+	case usm.AuthoritativeEngineID == "":
+		return report(usmStatsUnknownEngineIDs)
+	case usm.UserName != a.security.UserName:
+		return report(usmStatsUnknownUserNames)
+	case request.MsgFlags&gosnmp.AuthPriv != a.flags:
+		return report(usmStatsUnsupportedSecLevels)
+	case a.flags&gosnmp.AuthNoPriv != 0 && !a.authentic(packet, usm.AuthenticationParameters):
+		return report(usmStatsWrongDigests)
+	case decodeErr != nil:
+		return report(usmStatsDecryptionErrors)
+	}
+
+	response.PDUType, response.MsgFlags = gosnmp.GetResponse, a.flags
+	for i, v := range request.Variables {
+		oid := strings.TrimPrefix(v.Name, ".")
+		value, ok := a.objects[oid]
+		if !ok {
+			response.Error, response.ErrorIndex = gosnmp.NoSuchName, uint8(i+1)
+			break
+		}
+		if request.PDUType == gosnmp.SetRequest {
+			value = gosnmp.ToBigInt(v.Value).Int64()
+			if v.Type != gosnmp.Integer || (State(value) != On && State(value) != Off) {
+				response.Error, response.ErrorIndex = gosnmp.WrongValue, uint8(i+1)
+				break
+			}
+			a.objects[oid] = value
+			a.sets = append(a.sets, State(value))
+		}
+		response.Variables = append(response.Variables, gosnmp.SnmpPDU{Name: oid, Type: gosnmp.Integer, Value: int(value)})
+	}
+	if response.Error != gosnmp.NoError {
+		response.Variables = request.Variables
+	}
+	if err := a.security.InitPacket(response); err != nil {
+		return nil
+	}
+	encoded, _ := response.MarshalMsg()
+	return encoded
+}
+
+// authentic checks the digest of an authenticated request, the HMAC of the request with the digest
+// zeroed truncated to the length of the digest
+func (a *testAgent) authentic(packet []byte, digest string) bool {
+	i := bytes.Index(packet, []byte(digest))
+	if digest == "" || i < 0 {
+		return false
+	}
+	zeroed := bytes.Clone(packet)
+	clear(zeroed[i : i+len(digest)])
+	mac := hmac.New(a.security.AuthenticationProtocol.HashType().New, a.security.SecretKey)
+	_, _ = mac.Write(zeroed)
+	sum := mac.Sum(nil)
+	return len(digest) <= len(sum) && hmac.Equal(sum[:len(digest)], []byte(digest))
+}
+
+// outlet returns the outlet of plug on the agent, with the security configuration of snmp
+func (a *testAgent) outlet(t *testing.T, snmp api.SNMPPower, plug int) *SNMPOutlet {
+	t.Helper()
+	snmp.Host, snmp.OID, snmp.Plug = a.addr, testOID, plug
+	outlet, err := NewSNMPOutlet(snmp)
+	require.NoError(t, err)
+	outlet.Timeout = 100 * time.Millisecond
+	return outlet
+}
+
+func TestSNMPOutlet(t *testing.T) {
+	ctx := context.Background()
+	for name, snmp := range map[string]api.SNMPPower{
+		"noAuthNoPriv":        {User: "admin"},
+		"authNoPriv":          {User: "admin", Password: "pdu-password"},
+		"authNoPriv MD5":      {User: "admin", Password: "pdu-password", AuthProtocol: "MD5"},
+		"authNoPriv SHA512":   {User: "admin", Password: "pdu-password", AuthProtocol: "SHA512"},
+		"authPriv DES":        {User: "admin", Password: "pdu-password", PrivProtocol: "DES"},
+		"authPriv SHA256 AES": {User: "admin", Password: "pdu-password", AuthProtocol: "SHA256", PrivProtocol: "AES"},
+		"authPriv AES with a privacy password": {User: "admin", Password: "pdu-password", PrivProtocol: "AES",
+			PrivPassword: "privacy-password"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			agent := startTestAgent(t, snmp, map[string]int64{testOID + ".32": 1})
+			outlet := agent.outlet(t, snmp, 32)
+
+			state, err := outlet.Status(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, On, state)
+
+			require.NoError(t, outlet.Set(ctx, Off))
+			state, err = outlet.Status(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, Off, state)
+
+			require.NoError(t, outlet.Cycle(ctx, time.Millisecond))
+			agent.mu.Lock()
+			defer agent.mu.Unlock()
+			assert.Equal(t, []State{Off, Off, On}, agent.sets)
+		})
+	}
+
+	authNoPriv := api.SNMPPower{User: "admin", Password: "pdu-password"}
+	t.Run("wrong password", func(t *testing.T) {
+		agent := startTestAgent(t, authNoPriv, map[string]int64{testOID + ".32": 1})
+		_, err := agent.outlet(t, api.SNMPPower{User: "admin", Password: "not-the-password"}, 32).Status(ctx)
+		require.ErrorContains(t, err, "wrong digest")
+	})
+
+	t.Run("wrong auth protocol", func(t *testing.T) {
+		agent := startTestAgent(t, authNoPriv, map[string]int64{testOID + ".32": 1})
+		snmp := authNoPriv
+		snmp.AuthProtocol = "SHA256"
+		_, err := agent.outlet(t, snmp, 32).Status(ctx)
+		require.ErrorContains(t, err, "wrong digest")
+	})
+
+	t.Run("wrong privacy password", func(t *testing.T) {
+		snmp := api.SNMPPower{User: "admin", Password: "pdu-password", PrivProtocol: "AES", PrivPassword: "privacy-password"}
+		agent := startTestAgent(t, snmp, map[string]int64{testOID + ".32": 1})
+		snmp.PrivPassword = "not-the-password"
+		_, err := agent.outlet(t, snmp, 32).Status(ctx)
+		require.ErrorContains(t, err, "decryption error")
+	})
+
+	t.Run("unauthenticated request", func(t *testing.T) {
+		agent := startTestAgent(t, authNoPriv, map[string]int64{testOID + ".32": 1})
+		_, err := agent.outlet(t, api.SNMPPower{User: "admin"}, 32).Status(ctx)
+		require.ErrorContains(t, err, "unknown security level")
+	})
+
+	t.Run("unencrypted request", func(t *testing.T) {
+		agent := startTestAgent(t, api.SNMPPower{User: "admin", Password: "pdu-password", PrivProtocol: "DES"},
+			map[string]int64{testOID + ".32": 1})
+		_, err := agent.outlet(t, authNoPriv, 32).Status(ctx)
+		require.ErrorContains(t, err, "unknown security level")
+	})
+
+	t.Run("unknown plug", func(t *testing.T) {
+		agent := startTestAgent(t, authNoPriv, map[string]int64{testOID + ".32": 1})
+		err := agent.outlet(t, authNoPriv, 33).Set(ctx, On)
+		require.ErrorContains(t, err, "failed to set "+testOID+".33: NoSuchName")
+	})
+
+	t.Run("no response", func(t *testing.T) {
+		agent := startTestAgent(t, authNoPriv, map[string]int64{testOID + ".32": 1})
+		agent.mu.Lock()
+		agent.silent = true
+		agent.mu.Unlock()
+		outlet := agent.outlet(t, authNoPriv, 32)
+		outlet.Timeout = 10 * time.Millisecond
+		_, err := outlet.Status(ctx)
+		require.ErrorContains(t, err, "request timeout")
+	})
+}
+
+func TestNewSNMPOutlet(t *testing.T) {
+	outlet, err := NewSNMPOutlet(api.SNMPPower{Host: "pdu.example.com", User: "admin", Password: "pdu-password",
+		OID: testOID, Plug: 32})
+	require.NoError(t, err)
+	assert.Equal(t, "pdu.example.com:161", outlet.Addr)
+	assert.Equal(t, testOID+".32", outlet.OID)
+
+	for name, snmp := range map[string]api.SNMPPower{
+		"no host":        {User: "admin", OID: testOID, Plug: 1},
+		"no plug":        {Host: "pdu", User: "admin", OID: testOID},
+		"invalid OID":    {Host: "pdu", User: "admin", OID: "1.3.six", Plug: 1},
+		"short password": {Host: "pdu", User: "admin", Password: "short", OID: testOID, Plug: 1},
+	} {
+		_, err := NewSNMPOutlet(snmp)
+		assert.Error(t, err, name)
+	}
+
+	// the protocols which can't be honored are refused rather than falling back to a weaker level
+	for snmp, wantErr := range map[api.SNMPPower]string{
+		{Password: "pdu-password", AuthProtocol: "SHA1"}:                       `unsupported SNMP auth protocol "SHA1"`,
+		{Password: "pdu-password", PrivProtocol: "AES256"}:                     `unsupported SNMP privacy protocol "AES256"`,
+		{PrivProtocol: "AES"}:                                                  "the SNMP auth and privacy protocols need a password",
+		{Password: "pdu-password", PrivPassword: "privacy-1"}:                  "the SNMP privacy password needs a privacy protocol",
+		{Password: "pdu-password", PrivProtocol: "DES", PrivPassword: "short"}: "privacy password must have at least 8",
+	} {
+		snmp.Host, snmp.User, snmp.OID, snmp.Plug = "pdu", "admin", testOID, 1
+		_, err := NewSNMPOutlet(snmp)
+		assert.ErrorContains(t, err, wantErr)
+	}
+}